@@ -0,0 +1,211 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Access is a level of access to resources.
+type Access int
+
+const (
+	// AccessNone hides resources: requests get 404 Not Found and PROPFIND
+	// responses omit them.
+	AccessNone Access = iota
+	// AccessRead allows reading resources, but not modifying them.
+	AccessRead
+	// AccessWrite allows reading and modifying resources.
+	AccessWrite
+)
+
+// AccessRule grants a level of access to the resources matching a pattern.
+//
+// Rules are evaluated in order and the first rule matching both the resource
+// and the request user applies. Resources not matched by any rule are
+// writable. Removing, moving or copying a collection requires the access
+// needed for the operation on all of its members.
+type AccessRule struct {
+	// Pattern is matched against resource paths with path.Match, segment by
+	// segment. A "**" segment matches any number of segments, e.g.
+	// "/public/**" matches "/public" and everything below it.
+	Pattern string
+	// Users restricts the rule to some authenticated users (see
+	// UserFromContext). If empty, the rule applies to all users.
+	Users []string
+	// Anonymous restricts the rule to unauthenticated requests.
+	Anonymous bool
+	// Access is the level of access granted.
+	Access Access
+}
+
+func (rule *AccessRule) matches(name, user string, authenticated bool) bool {
+	if rule.Anonymous && authenticated {
+		return false
+	}
+	if len(rule.Users) > 0 && (!authenticated || !slices.Contains(rule.Users, user)) {
+		return false
+	}
+	return matchPattern(rule.Pattern, name)
+}
+
+// matchPattern reports whether name matches a pattern with "**" segments.
+func matchPattern(pattern, name string) bool {
+	return matchSegments(splitPath(pattern), splitPath(path.Clean("/"+name)))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// access returns the level of access of the request user to a resource.
+func (b *backend) access(ctx context.Context, name string) Access {
+	if len(b.AccessRules) == 0 {
+		return AccessWrite
+	}
+	user, authenticated := UserFromContext(ctx)
+	for i := range b.AccessRules {
+		if rule := &b.AccessRules[i]; rule.matches(name, user, authenticated) {
+			return rule.Access
+		}
+	}
+	return AccessWrite
+}
+
+// requiredAccess returns the level of access required on the request
+// resource to use a method.
+func requiredAccess(method string) Access {
+	if mutatingMethods[method] && method != "COPY" {
+		return AccessWrite
+	}
+	return AccessRead
+}
+
+// checkAccess returns an error if the access rules or the Permissions forbid
+// a request. COPY and MOVE additionally require write access to the
+// destination.
+func (b *backend) checkAccess(r *http.Request) error {
+	if len(b.AccessRules) == 0 && b.Permissions == nil {
+		return nil
+	}
+	if err := b.requireAccess(r.Context(), r.URL.Path, requiredAccess(r.Method)); err != nil {
+		return err
+	}
+	if err := b.requirePermission(r.Context(), r.Method, r.URL.Path); err != nil {
+		return err
+	}
+
+	var destPath string
+	switch r.Method {
+	case http.MethodDelete:
+		return b.checkMembersAccess(r.Context(), r.Method, r.URL.Path, "")
+	case "COPY", "MOVE":
+		dest, err := internal.ParseDestination(r.Header.Get("Destination"))
+		if err != nil {
+			// Let the handler reject the request
+			return nil
+		}
+		destPath, err = b.destinationPath((*internal.Href)(dest))
+		if err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if err := b.requireAccess(r.Context(), destPath, AccessWrite); err != nil {
+		return err
+	}
+	if !b.permitted(r.Context(), http.MethodPut, destPath) {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: writing %v not permitted", destPath)
+	}
+	members := true
+	if r.Method == "COPY" {
+		// With a depth of 0, only the collection itself is copied
+		depth, err := internal.ParseDepth(r.Header.Get("Depth"))
+		members = err != nil || depth != internal.DepthZero
+	}
+	if members {
+		if err := b.checkMembersAccess(r.Context(), r.Method, r.URL.Path, destPath); err != nil {
+			return err
+		}
+	}
+	// Members of an existing destination are removed when it's overwritten
+	if overwrite, err := internal.ParseOverwrite(r.Header.Get("Overwrite")); err != nil || overwrite {
+		return b.checkMembersAccess(r.Context(), http.MethodDelete, destPath, "")
+	}
+	return nil
+}
+
+// checkMembersAccess returns an error if the access rules or the Permissions
+// forbid a method on one of the members of the collection name, so that
+// resources can't be removed, moved or copied along with one of their
+// ancestors. For COPY and MOVE, the members must also be writable once at
+// their destination below dest.
+func (b *backend) checkMembersAccess(ctx context.Context, method, name, dest string) error {
+	fi, err := b.FileSystem.Stat(ctx, name)
+	if err != nil || !fi.IsDir {
+		// Missing resources are reported by the handler
+		return nil
+	}
+
+	required := requiredAccess(method)
+	root := path.Clean(name)
+	errProtected := internal.HTTPErrorf(http.StatusForbidden, "webdav: %v contains protected resources", name)
+	return walk(ctx, b.FileSystem, name, true, func(member *FileInfo) error {
+		p := path.Clean(member.Path)
+		if p == root {
+			return nil
+		}
+		if b.access(ctx, p) < required || !b.permitted(ctx, method, p) {
+			return errProtected
+		}
+		if dest != "" {
+			memberDest := path.Join(dest, strings.TrimPrefix(p, root))
+			if b.access(ctx, memberDest) < AccessWrite || !b.permitted(ctx, http.MethodPut, memberDest) {
+				return errProtected
+			}
+		}
+		return nil
+	})
+}
+
+func (b *backend) requireAccess(ctx context.Context, name string, required Access) error {
+	switch access := b.access(ctx, name); {
+	case access >= required:
+		return nil
+	case access == AccessNone:
+		return &internal.HTTPError{Code: http.StatusNotFound}
+	default:
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: %v is read-only", name)
+	}
+}
@@ -0,0 +1,95 @@
+package webdav_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func newAccessHandler(t *testing.T) (*webdav.Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"public/a.txt":       "a",
+		"docs/notes.txt":     "notes",
+		"docs/private/s.txt": "secret",
+		"docs/readme.txt":    "readme",
+		"inbox/":             "",
+	})
+	return &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		AccessRules: []webdav.AccessRule{
+			{Pattern: "/public/**", Access: webdav.AccessRead},
+			{Pattern: "/docs/private/**", Users: []string{"admin"}, Access: webdav.AccessWrite},
+			{Pattern: "/docs/private/**", Access: webdav.AccessNone},
+			{Pattern: "/docs/readme.txt", Access: webdav.AccessRead},
+		},
+	}, dir
+}
+
+func TestAccessRules(t *testing.T) {
+	h, dir := newAccessHandler(t)
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodGet, target: "/public/a.txt"}, http.StatusOK},
+		{testRequest{method: http.MethodPut, target: "/public/a.txt", body: "b"}, http.StatusForbidden},
+		{testRequest{method: http.MethodPut, target: "/public/new.txt", body: "b"}, http.StatusForbidden},
+		{testRequest{method: http.MethodGet, target: "/docs/private/s.txt"}, http.StatusNotFound},
+		{testRequest{method: http.MethodGet, target: "/docs/private/s.txt", user: "admin"}, http.StatusOK},
+		{testRequest{method: http.MethodPut, target: "/docs/private/s.txt", body: "x"}, http.StatusNotFound},
+		{testRequest{method: http.MethodDelete, target: "/docs/readme.txt"}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/docs/notes.txt", header: map[string]string{"Destination": "/public/notes.txt"}}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/public/a.txt", header: map[string]string{"Destination": "/inbox/a.txt"}}, http.StatusCreated},
+		{testRequest{method: "MOVE", target: "/docs/notes.txt", header: map[string]string{"Destination": "/docs/private/notes.txt"}}, http.StatusNotFound},
+		{testRequest{method: http.MethodPut, target: "/inbox/b.txt", body: "b"}, http.StatusCreated},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, tc.want)
+	}
+	checkFile(t, dir, "public/a.txt", "a")
+	checkFile(t, dir, "docs/private/s.txt", "secret")
+	checkFile(t, dir, "inbox/a.txt", "a")
+	checkMissing(t, dir, "public/notes.txt")
+
+	// Hidden resources are left out of listings
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/docs/", header: map[string]string{"Depth": "infinity"}}, http.StatusMultiStatus)
+	if body := w.Body.String(); strings.Contains(body, "private") || !strings.Contains(body, "notes.txt") {
+		t.Errorf("PROPFIND: unexpected members in\n%s", body)
+	}
+}
+
+// TestAccessRulesAncestors checks that protected resources can't be removed,
+// moved or copied along with one of their ancestors.
+func TestAccessRulesAncestors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  testRequest
+		want int
+	}{
+		{"delete root", testRequest{method: http.MethodDelete, target: "/"}, http.StatusForbidden},
+		{"delete read-only member", testRequest{method: http.MethodDelete, target: "/docs/"}, http.StatusForbidden},
+		{"move hidden member", testRequest{method: "MOVE", target: "/docs/", header: map[string]string{"Destination": "/inbox/docs/"}}, http.StatusForbidden},
+		{"copy hidden member", testRequest{method: "COPY", target: "/docs/", header: map[string]string{"Destination": "/inbox/docs/"}}, http.StatusForbidden},
+		{"copy collection only", testRequest{method: "COPY", target: "/docs/", header: map[string]string{"Destination": "/inbox/docs/", "Depth": "0"}}, http.StatusCreated},
+		{"copy to read-only destination member", testRequest{method: "COPY", target: "/inbox/", header: map[string]string{"Destination": "/docs/"}}, http.StatusForbidden},
+		{"copy allowed member", testRequest{method: "COPY", target: "/docs/", header: map[string]string{"Destination": "/inbox/docs/"}, user: "admin"}, http.StatusCreated},
+		{"delete writable collection", testRequest{method: http.MethodDelete, target: "/inbox/"}, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, dir := newAccessHandler(t)
+			checkStatus(t, h, tc.req, tc.want)
+			if tc.want == http.StatusForbidden {
+				checkFile(t, dir, "public/a.txt", "a")
+				checkFile(t, dir, "docs/private/s.txt", "secret")
+				checkFile(t, dir, "docs/readme.txt", "readme")
+				checkMissing(t, dir, "inbox/docs")
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// appPasswordEncoding encodes the random bytes of app passwords with
+// unambiguous lowercase characters.
+var appPasswordEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// appPasswordUseInterval is how often the last use of app passwords is
+// recorded.
+const appPasswordUseInterval = time.Minute
+
+// AppPassword is an application password, a random password generated for a
+// single device or client of a user, e.g. a sync client, which can be revoked
+// without changing the main password of the user.
+type AppPassword struct {
+	// ID identifies the password among those of its user.
+	ID   string
+	User string
+	// Name describes the device or client, e.g. "Laptop".
+	Name string
+	// Hash is the SHA-256 digest of the password. App passwords are random
+	// with 100 bits of entropy, a slow password hash isn't needed.
+	Hash    []byte
+	Created time.Time
+	// LastUsed is the time of the last authentication with the password,
+	// recorded at most once a minute.
+	LastUsed time.Time
+}
+
+// AppPasswordStore stores app passwords.
+type AppPasswordStore interface {
+	// CreateAppPassword stores a new app password.
+	CreateAppPassword(ctx context.Context, p *AppPassword) error
+	// AppPasswordByHash returns the app password with a hash, or
+	// ErrNotFound.
+	AppPasswordByHash(ctx context.Context, hash []byte) (*AppPassword, error)
+	// AppPasswords returns the app passwords of a user.
+	AppPasswords(ctx context.Context, user string) ([]AppPassword, error)
+	// DeleteAppPassword removes an app password of a user. It fails with
+	// ErrNotFound if it doesn't exist.
+	DeleteAppPassword(ctx context.Context, user, id string) error
+	// TouchAppPassword records the use of an app password at t.
+	TouchAppPassword(ctx context.Context, user, id string, t time.Time) error
+}
+
+// AppPasswords generates, checks and revokes app passwords. Clients
+// authenticate with an app password instead of the main password of the
+// user, e.g. with Basic authentication:
+//
+//	apps := &webdav.AppPasswords{Store: webdav.NewMemAppPasswordStore()}
+//	password, _, err := apps.Generate(ctx, "alice", "Phone")
+//	app.Use(basicauth.New(basicauth.Config{
+//		Authorizer: apps.Authorizer(checkMainPassword),
+//	}))
+type AppPasswords struct {
+	Store AppPasswordStore
+}
+
+// appPasswordHash returns the hash of an app password.
+func appPasswordHash(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return sum[:]
+}
+
+// Generate creates an app password for a user's device or client. The
+// password is only returned here, only its hash is stored.
+func (a *AppPasswords) Generate(ctx context.Context, user, name string) (string, *AppPassword, error) {
+	var b [13]byte // 4 groups of 5 characters, 100 bits
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", nil, err
+	}
+	s := appPasswordEncoding.EncodeToString(b[:])
+	password := strings.Join([]string{s[0:5], s[5:10], s[10:15], s[15:20]}, "-")
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", nil, err
+	}
+	p := &AppPassword{
+		ID:      hex.EncodeToString(id[:]),
+		User:    user,
+		Name:    name,
+		Hash:    appPasswordHash(password),
+		Created: time.Now(),
+	}
+	if err := a.Store.CreateAppPassword(ctx, p); err != nil {
+		return "", nil, err
+	}
+	return password, p, nil
+}
+
+// List returns the app passwords of a user.
+func (a *AppPasswords) List(ctx context.Context, user string) ([]AppPassword, error) {
+	return a.Store.AppPasswords(ctx, user)
+}
+
+// Revoke removes an app password of a user.
+func (a *AppPasswords) Revoke(ctx context.Context, user, id string) error {
+	return a.Store.DeleteAppPassword(ctx, user, id)
+}
+
+// Authenticate reports whether password is an app password of user.
+func (a *AppPasswords) Authenticate(ctx context.Context, user, password string) (bool, error) {
+	p, err := a.Store.AppPasswordByHash(ctx, appPasswordHash(password))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(p.User), []byte(user)) != 1 {
+		return false, nil
+	}
+
+	if now := time.Now(); now.Sub(p.LastUsed) >= appPasswordUseInterval {
+		if err := a.Store.TouchAppPassword(ctx, p.User, p.ID, now); err != nil {
+			log.Errorf("webdav: failed to record app password use: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// Authorizer returns a function checking Basic authentication credentials,
+// e.g. for the Authorizer of the basicauth middleware, accepting app
+// passwords and the passwords accepted by next, if not nil.
+func (a *AppPasswords) Authorizer(next func(user, password string) bool) func(user, password string) bool {
+	return func(user, password string) bool {
+		ok, err := a.Authenticate(context.Background(), user, password)
+		if err != nil {
+			log.Errorf("webdav: failed to check app password: %v", err)
+		}
+		if ok {
+			return true
+		}
+		return next != nil && next(user, password)
+	}
+}
+
+// MemAppPasswordStore is an in-memory AppPasswordStore.
+type MemAppPasswordStore struct {
+	mu        sync.Mutex
+	passwords map[string]*AppPassword // by hex-encoded hash
+}
+
+var _ AppPasswordStore = (*MemAppPasswordStore)(nil)
+
+// NewMemAppPasswordStore creates an empty in-memory app password store.
+func NewMemAppPasswordStore() *MemAppPasswordStore {
+	return &MemAppPasswordStore{passwords: make(map[string]*AppPassword)}
+}
+
+// find returns the hash key of an app password of a user.
+func (s *MemAppPasswordStore) find(user, id string) (string, bool) {
+	for k, p := range s.passwords {
+		if p.User == user && p.ID == id {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// CreateAppPassword implements AppPasswordStore.
+func (s *MemAppPasswordStore) CreateAppPassword(ctx context.Context, p *AppPassword) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := hex.EncodeToString(p.Hash)
+	if _, ok := s.passwords[k]; ok {
+		return ErrConflict
+	}
+	if _, ok := s.find(p.User, p.ID); ok {
+		return ErrConflict
+	}
+	stored := *p
+	s.passwords[k] = &stored
+	return nil
+}
+
+// AppPasswordByHash implements AppPasswordStore.
+func (s *MemAppPasswordStore) AppPasswordByHash(ctx context.Context, hash []byte) (*AppPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.passwords[hex.EncodeToString(hash)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *p
+	return &found, nil
+}
+
+// AppPasswords implements AppPasswordStore.
+func (s *MemAppPasswordStore) AppPasswords(ctx context.Context, user string) ([]AppPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var l []AppPassword
+	for _, p := range s.passwords {
+		if p.User == user {
+			l = append(l, *p)
+		}
+	}
+	slices.SortFunc(l, func(a, b AppPassword) int {
+		return a.Created.Compare(b.Created)
+	})
+	return l, nil
+}
+
+// DeleteAppPassword implements AppPasswordStore.
+func (s *MemAppPasswordStore) DeleteAppPassword(ctx context.Context, user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.find(user, id)
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.passwords, k)
+	return nil
+}
+
+// TouchAppPassword implements AppPasswordStore.
+func (s *MemAppPasswordStore) TouchAppPassword(ctx context.Context, user, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.find(user, id)
+	if !ok {
+		return ErrNotFound
+	}
+	s.passwords[k].LastUsed = t
+	return nil
+}
@@ -0,0 +1,69 @@
+package webdav_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestAppPasswords(t *testing.T) {
+	ctx := t.Context()
+	apps := &webdav.AppPasswords{Store: webdav.NewMemAppPasswordStore()}
+	phone, p, err := apps.Generate(ctx, "alice", "Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[a-z2-9]{5}(-[a-z2-9]{5}){3}$`).MatchString(phone) {
+		t.Errorf("Generate() = %q, want 4 groups of 5 characters", phone)
+	}
+	laptop, _, err := apps.Generate(ctx, "alice", "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := apps.Authorizer(func(user, password string) bool {
+		return user == "alice" && password == "main"
+	})
+	tests := []struct {
+		user, password string
+		want           bool
+	}{
+		{"alice", phone, true},
+		{"alice", laptop, true},
+		{"alice", "main", true},
+		{"bob", phone, false},
+		{"alice", "wrong", false},
+	}
+	for _, tc := range tests {
+		if got := auth(tc.user, tc.password); got != tc.want {
+			t.Errorf("Authorizer(%q, %q) = %v, want %v", tc.user, tc.password, got, tc.want)
+		}
+	}
+
+	list, err := apps.List(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "Phone" || list[1].Name != "Laptop" {
+		t.Fatalf("List() = %+v, want Phone and Laptop", list)
+	}
+	if list[0].LastUsed.IsZero() {
+		t.Errorf("LastUsed of a used password is zero")
+	}
+
+	// Revoking a password leaves the others working
+	if err := apps.Revoke(ctx, "bob", p.ID); !errors.Is(err, webdav.ErrNotFound) {
+		t.Errorf("Revoke() of another user = %v, want %v", err, webdav.ErrNotFound)
+	}
+	if err := apps.Revoke(ctx, "alice", p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if auth("alice", phone) {
+		t.Errorf("revoked password accepted")
+	}
+	if !auth("alice", laptop) {
+		t.Errorf("password rejected after revoking another one")
+	}
+}
@@ -0,0 +1,106 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEvent describes a request which modified or attempted to modify
+// resources, e.g. PUT, DELETE, MOVE or LOCK.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Method     string    `json:"method"`
+	// Path is the request path, including the mount prefix.
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"`
+	Status      int    `json:"status"`
+	BytesIn     int64  `json:"bytes_in"`
+	BytesOut    int64  `json:"bytes_out"`
+	// Duration is encoded in nanoseconds.
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// AuditSink records the audit trail of the modifications made through a
+// handler. Audit is called once each mutating request has been served,
+// whether it succeeded or not.
+type AuditSink interface {
+	Audit(ctx context.Context, event *AuditEvent) error
+}
+
+// FileAuditSink appends audit events to a file as JSON lines.
+type FileAuditSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+var (
+	_ AuditSink = (*FileAuditSink)(nil)
+	_ Flusher   = (*FileAuditSink)(nil)
+)
+
+// NewFileAuditSink opens the file name for appending audit events, creating it
+// if necessary.
+func NewFileAuditSink(name string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Audit implements AuditSink.
+func (s *FileAuditSink) Audit(ctx context.Context, event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(event)
+}
+
+// Flush implements Flusher by syncing the file to stable storage.
+func (s *FileAuditSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Sync()
+}
+
+// Close closes the file.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// newAuditEvent describes a served request. Strings are copied, since they
+// may share memory with buffers reused for other requests.
+func newAuditEvent(r *http.Request, sw *statusWriter, body *countingReader, start time.Time) *AuditEvent {
+	event := &AuditEvent{
+		Time:       start,
+		RemoteAddr: strings.Clone(r.RemoteAddr),
+		Method:     methodLabel(r.Method),
+		Path:       strings.Clone(r.URL.Path),
+		Status:     sw.Status(),
+		BytesIn:    body.bytes,
+		BytesOut:   sw.bytes,
+		Duration:   time.Since(start),
+	}
+	event.RequestID, _ = RequestIDFromContext(r.Context())
+	if r.Method == "COPY" || r.Method == "MOVE" {
+		event.Destination = strings.Clone(r.Header.Get("Destination"))
+	}
+	if user, ok := UserFromContext(r.Context()); ok {
+		event.User = strings.Clone(user)
+	}
+	if sw.err != nil {
+		event.Error = sw.err.Error()
+	}
+	return event
+}
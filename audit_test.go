@@ -0,0 +1,82 @@
+package webdav_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// failingAuditSink fails to record events.
+type failingAuditSink struct{}
+
+func (failingAuditSink) Audit(ctx context.Context, event *webdav.AuditEvent) error {
+	return errors.New("audit failed")
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := webdav.NewFileAuditSink(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), AuditSink: sink}
+
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "hello", user: "alice"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/b.txt"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/"}, http.StatusMultiStatus)
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/b.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusCreated)
+	// Failed modifications are audited too
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/missing"}, http.StatusNotFound)
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []webdav.AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event webdav.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decoding %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %v audit events, want PUT, MOVE and DELETE: %+v", len(events), events)
+	}
+	if e := events[0]; e.Method != "PUT" || e.Path != "/b.txt" || e.User != "alice" || e.Status != http.StatusCreated || e.BytesIn != 5 || e.Time.IsZero() {
+		t.Errorf("PUT event = %+v", e)
+	}
+	if e := events[1]; e.Method != "MOVE" || e.Destination != "/c.txt" || e.Status != http.StatusCreated {
+		t.Errorf("MOVE event = %+v", e)
+	}
+	if e := events[2]; e.Method != "DELETE" || e.Status != http.StatusNotFound || e.Error == "" {
+		t.Errorf("DELETE event = %+v, want the error", e)
+	}
+}
+
+func TestAuditSinkErrors(t *testing.T) {
+	if _, err := webdav.NewFileAuditSink(filepath.Join(t.TempDir(), "missing", "audit.jsonl")); err == nil {
+		t.Errorf("NewFileAuditSink() in a missing directory = nil, want an error")
+	}
+
+	// Requests succeed even if they can't be audited
+	dir := t.TempDir()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), AuditSink: failingAuditSink{}}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusCreated)
+	checkFile(t, dir, "a.txt", "a")
+}
@@ -0,0 +1,60 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TokenValidator validates bearer tokens sent by clients in the Authorization
+// header field.
+type TokenValidator interface {
+	// ValidateToken checks a token and returns the user it identifies.
+	ValidateToken(ctx context.Context, token string) (user string, err error)
+}
+
+type userContextKey struct{}
+
+// ContextWithUser returns a copy of ctx carrying the authenticated user.
+func ContextWithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user of a request context, e.g. the
+// principal of a validated bearer token.
+func UserFromContext(ctx context.Context) (user string, ok bool) {
+	user, ok = ctx.Value(userContextKey{}).(string)
+	return user, ok
+}
+
+// parseBearerToken extracts the token of an Authorization header field using
+// the Bearer scheme.
+func parseBearerToken(authorization string) (token string, ok bool) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// authenticateRequest validates the bearer token of a net/http request. It
+// returns the request with the identified user in its context, or replies
+// with 401 Unauthorized and returns false.
+func authenticateRequest(v TokenValidator, w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	token, ok := parseBearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "webdav: bearer token required", http.StatusUnauthorized)
+		return r, false
+	}
+
+	user, err := v.ValidateToken(r.Context(), token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return r, false
+	}
+
+	return r.WithContext(ContextWithUser(r.Context(), user)), true
+}
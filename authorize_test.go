@@ -0,0 +1,72 @@
+package webdav_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestAuthorize(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "private/": ""})
+	var calls []string
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(webdav.New(webdav.Config{
+		Prefix: "/dav",
+		Root:   webdav.LocalFileSystem(dir),
+		Authorize: func(c *fiber.Ctx, method, path string) error {
+			calls = append(calls, method+" "+path)
+			switch {
+			case path == "/secret.txt":
+				return webdav.NewHTTPError(http.StatusUnauthorized, errors.New("log in"))
+			case strings.HasPrefix(path, "/private/") && method != http.MethodGet:
+				return errors.New("read-only")
+			}
+			return nil
+		},
+	}))
+
+	tests := []struct {
+		method, target, dest string
+		want                 int
+		calls                []string
+	}{
+		{http.MethodGet, "/dav/a.txt", "", http.StatusOK, []string{"GET /a.txt"}},
+		{http.MethodGet, "/dav/secret.txt", "", http.StatusUnauthorized, []string{"GET /secret.txt"}},
+		{http.MethodPut, "/dav/private/b.txt", "", http.StatusForbidden, []string{"PUT /private/b.txt"}},
+		// Destinations are authorized too, relative to the prefix
+		{"COPY", "/dav/a.txt", "/dav/private/b.txt", http.StatusForbidden, []string{"COPY /a.txt", "COPY /private/b.txt"}},
+		{"MOVE", "/dav/a.txt", "http://example.com/dav/private/b.txt", http.StatusForbidden, []string{"MOVE /a.txt", "MOVE /private/b.txt"}},
+		{"MOVE", "/dav/a.txt", "/dav/secret.txt", http.StatusUnauthorized, []string{"MOVE /a.txt", "MOVE /secret.txt"}},
+		{"COPY", "/dav/a.txt", "/dav/c%20d.txt", http.StatusCreated, []string{"COPY /a.txt", "COPY /c d.txt"}},
+	}
+	for _, tc := range tests {
+		calls = nil
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		if tc.dest != "" {
+			req.Header.Set("Destination", tc.dest)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%v %v = %v, want %v", tc.method, tc.target, resp.StatusCode, tc.want)
+		}
+		if !slices.Equal(calls, tc.calls) {
+			t.Errorf("%v %v: Authorize calls = %q, want %q", tc.method, tc.target, calls, tc.calls)
+		}
+	}
+	checkFile(t, dir, "a.txt", "a")
+	checkFile(t, dir, "c d.txt", "a")
+	checkMissing(t, dir, "private/b.txt")
+	checkMissing(t, dir, "secret.txt")
+}
@@ -0,0 +1,320 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// AuthThrottle slows down brute-force and credential stuffing attacks by
+// tracking failed authentications per client IP address and per user name.
+// Once a client or user exceeds Threshold consecutive failures, further
+// attempts are rejected with "429 Too Many Requests" for a delay doubling
+// with each failure, until MaxFailures locks it out for Lockout. A successful
+// authentication clears the failures of the client and of the user.
+//
+// Failures are detected from responses: any "401 Unauthorized" response to
+// a request carrying credentials counts as one, and any "2xx" response, e.g.
+// "207 Multi-Status", as a success. Other responses, e.g. errors returned
+// before the credentials are checked, don't change the failures. Install the middleware in
+// front of the authentication middlewares and WebDAV handlers it protects:
+//
+//	throttle := &webdav.AuthThrottle{}
+//	app.Use(throttle.Middleware(), basicauth.New(...), webdav.New(config))
+type AuthThrottle struct {
+	// Threshold is the number of consecutive failures tolerated without
+	// delay, defaults to 3.
+	Threshold int
+	// Delay is the delay imposed after the first failure beyond Threshold,
+	// doubled with each subsequent failure. Defaults to one second.
+	Delay time.Duration
+	// MaxFailures is the number of consecutive failures locking a client or
+	// a user out, defaults to 10.
+	MaxFailures int
+	// Lockout is the duration of lockouts, defaults to 15 minutes. Failures
+	// are forgotten once it has elapsed since the last one.
+	Lockout time.Duration
+	// Store keeps track of failures, e.g. shared by several servers. If nil,
+	// failures are kept in memory.
+	Store AuthFailureStore
+
+	storeOnce sync.Once
+	store     AuthFailureStore
+}
+
+// AuthFailureStore stores the failed authentications of clients and users,
+// identified by keys.
+type AuthFailureStore interface {
+	// RecordFailure records a failure for key and returns the number of
+	// consecutive failures, including it. The count is reset if the previous
+	// failures have expired. Failures expire at expires.
+	RecordFailure(ctx context.Context, key string, now, expires time.Time) (count int, err error)
+	// Failures returns the number of unexpired consecutive failures of key
+	// and the time of the last one.
+	Failures(ctx context.Context, key string, now time.Time) (count int, last time.Time, err error)
+	// Reset forgets the failures of key.
+	Reset(ctx context.Context, key string) error
+}
+
+func (t *AuthThrottle) threshold() int {
+	if t.Threshold > 0 {
+		return t.Threshold
+	}
+	return 3
+}
+
+func (t *AuthThrottle) delay() time.Duration {
+	if t.Delay > 0 {
+		return t.Delay
+	}
+	return time.Second
+}
+
+func (t *AuthThrottle) maxFailures() int {
+	if t.MaxFailures > 0 {
+		return t.MaxFailures
+	}
+	return 10
+}
+
+func (t *AuthThrottle) lockout() time.Duration {
+	if t.Lockout > 0 {
+		return t.Lockout
+	}
+	return 15 * time.Minute
+}
+
+func (t *AuthThrottle) failureStore() AuthFailureStore {
+	t.storeOnce.Do(func() {
+		t.store = t.Store
+		if t.store == nil {
+			t.store = NewMemAuthFailureStore()
+		}
+	})
+	return t.store
+}
+
+// backoff returns how long a key with count consecutive failures must wait
+// after the last one.
+func (t *AuthThrottle) backoff(count int) time.Duration {
+	lockout := t.lockout()
+	if count >= t.maxFailures() {
+		return lockout
+	}
+	n := count - t.threshold()
+	if n <= 0 {
+		return 0
+	}
+	d := t.delay()
+	for i := 1; i < n && d < lockout; i++ {
+		d *= 2
+	}
+	return min(d, lockout)
+}
+
+// throttleKeys returns the keys tracking the failures of a client IP address
+// and of a user name, if any.
+func throttleKeys(ip, user string) []string {
+	keys := []string{"ip:" + ip}
+	if user != "" {
+		keys = append(keys, "user:"+user)
+	}
+	return keys
+}
+
+// Check returns how long a client must wait before trying to authenticate
+// as user again, or zero if it may try now. user is empty if unknown.
+func (t *AuthThrottle) Check(ctx context.Context, ip, user string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range throttleKeys(ip, user) {
+		count, last, err := t.failureStore().Failures(ctx, key, now)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, last.Add(t.backoff(count)).Sub(now))
+	}
+	return wait, nil
+}
+
+// Failure records a failed authentication of a client as user.
+func (t *AuthThrottle) Failure(ctx context.Context, ip, user string) error {
+	now := time.Now()
+	expires := now.Add(t.lockout())
+	for _, key := range throttleKeys(ip, user) {
+		if _, err := t.failureStore().RecordFailure(ctx, key, now, expires); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Success records a successful authentication of a client as user, clearing
+// the failures of both.
+func (t *AuthThrottle) Success(ctx context.Context, ip, user string) error {
+	for _, key := range throttleKeys(ip, user) {
+		if err := t.failureStore().Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Middleware returns a Fiber middleware throttling the authentications
+// performed by the next handlers.
+func (t *AuthThrottle) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authorization := c.Get(fiber.HeaderAuthorization)
+		if authorization == "" {
+			return c.Next()
+		}
+		ctx := c.UserContext()
+		ip, user := c.IP(), basicAuthUser(authorization)
+		if wait, err := t.Check(ctx, ip, user); err != nil {
+			log.Errorf("webdav: failed to check authentication failures: %v", err)
+		} else if wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(wait))
+			return c.Status(fiber.StatusTooManyRequests).SendString("webdav: too many failed authentications")
+		}
+
+		err := c.Next()
+		code := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			code = fiberErr.Code
+		}
+		t.observe(ctx, ip, user, code)
+		return err
+	}
+}
+
+// HTTPMiddleware wraps a net/http handler to throttle the authentications it
+// performs. Client IP addresses are taken from the remote address of
+// requests.
+func (t *AuthThrottle) HTTPMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		user := basicAuthUser(authorization)
+		if wait, err := t.Check(r.Context(), ip, user); err != nil {
+			log.Errorf("webdav: failed to check authentication failures: %v", err)
+		} else if wait > 0 {
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			http.Error(w, "webdav: too many failed authentications", http.StatusTooManyRequests)
+			return
+		}
+
+		sw := newStatusWriter(w)
+		h.ServeHTTP(sw, r)
+		t.observe(r.Context(), ip, user, sw.Status())
+	})
+}
+
+// observe records the outcome of a request carrying credentials.
+func (t *AuthThrottle) observe(ctx context.Context, ip, user string, code int) {
+	var err error
+	switch {
+	case code == http.StatusUnauthorized:
+		err = t.Failure(ctx, ip, user)
+	case code >= 200 && code < 300:
+		// Only responses served to authenticated requests confirm the
+		// credentials
+		err = t.Success(ctx, ip, user)
+	}
+	if err != nil {
+		log.Errorf("webdav: failed to record authentication: %v", err)
+	}
+}
+
+// basicAuthUser returns the user name of an Authorization header field using
+// the Basic scheme, or an empty string.
+func basicAuthUser(authorization string) string {
+	r := http.Request{Header: http.Header{"Authorization": {authorization}}}
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(user)
+}
+
+// MemAuthFailureStore is an in-memory AuthFailureStore.
+type MemAuthFailureStore struct {
+	mu        sync.Mutex
+	failures  map[string]*authFailures
+	lastSweep time.Time
+}
+
+type authFailures struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+var _ AuthFailureStore = (*MemAuthFailureStore)(nil)
+
+// NewMemAuthFailureStore creates an empty in-memory failure store.
+func NewMemAuthFailureStore() *MemAuthFailureStore {
+	return &MemAuthFailureStore{
+		failures:  make(map[string]*authFailures),
+		lastSweep: time.Now(),
+	}
+}
+
+// RecordFailure implements AuthFailureStore.
+func (s *MemAuthFailureStore) RecordFailure(ctx context.Context, key string, now, expires time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= limiterSweepInterval {
+		for k, f := range s.failures {
+			if !now.Before(f.expires) {
+				delete(s.failures, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	f, ok := s.failures[key]
+	if !ok || !now.Before(f.expires) {
+		f = &authFailures{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	f.expires = expires
+	return f.count, nil
+}
+
+// Failures implements AuthFailureStore.
+func (s *MemAuthFailureStore) Failures(ctx context.Context, key string, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok || !now.Before(f.expires) {
+		return 0, time.Time{}, nil
+	}
+	return f.count, f.last, nil
+}
+
+// Reset implements AuthFailureStore.
+func (s *MemAuthFailureStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
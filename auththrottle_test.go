@@ -0,0 +1,119 @@
+package webdav_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func basicAuth(user, password string) map[string]string {
+	return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))}
+}
+
+// newThrottledHandler returns a handler accepting the password "secret" for
+// all users, throttled by t. /forbidden is rejected before authentication.
+func newThrottledHandler(t *webdav.AuthThrottle) http.Handler {
+	return t.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if _, password, _ := r.BasicAuth(); password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+	}))
+}
+
+func TestAuthThrottle(t *testing.T) {
+	throttle := &webdav.AuthThrottle{Threshold: 2, Delay: time.Hour, Lockout: 2 * time.Hour}
+	h := newThrottledHandler(throttle)
+
+	for range 3 {
+		checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "wrong")}, http.StatusUnauthorized)
+	}
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "secret")}, http.StatusTooManyRequests)
+	if got := w.Header().Get("Retry-After"); got != "3600" {
+		t.Errorf("Retry-After = %q, want 3600", got)
+	}
+	// The client is throttled whatever the user
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("bob", "secret")}, http.StatusTooManyRequests)
+	// Requests without credentials aren't throttled
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/"}, http.StatusUnauthorized)
+}
+
+func TestAuthThrottleBackoff(t *testing.T) {
+	throttle := &webdav.AuthThrottle{Threshold: 2, Delay: time.Minute, MaxFailures: 5, Lockout: time.Hour}
+	ctx := t.Context()
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, time.Hour},
+		{6, time.Hour},
+	}
+	for _, tc := range tests {
+		if err := throttle.Failure(ctx, "192.0.2.1", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		wait, err := throttle.Check(ctx, "192.0.2.1", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait > tc.want || wait < tc.want-time.Second {
+			t.Errorf("Check() after %v failures = %v, want %v", tc.failures, wait, tc.want)
+		}
+	}
+
+	// The user is locked out from other clients too
+	if wait, err := throttle.Check(ctx, "192.0.2.2", "alice"); err != nil {
+		t.Fatal(err)
+	} else if wait < time.Hour-time.Second {
+		t.Errorf("Check() from another client = %v, want %v", wait, time.Hour)
+	}
+	if wait, err := throttle.Check(ctx, "192.0.2.2", "bob"); err != nil {
+		t.Fatal(err)
+	} else if wait != 0 {
+		t.Errorf("Check() for another user = %v, want 0", wait)
+	}
+}
+
+func TestAuthThrottleReset(t *testing.T) {
+	throttle := &webdav.AuthThrottle{Threshold: 2, Delay: time.Hour}
+	h := newThrottledHandler(throttle)
+	ctx := t.Context()
+
+	for range 2 {
+		checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "wrong")}, http.StatusUnauthorized)
+	}
+
+	// Responses which don't confirm the credentials keep the failures
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/forbidden", header: basicAuth("alice", "wrong")}, http.StatusForbidden)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "wrong")}, http.StatusUnauthorized)
+	if wait, err := throttle.Check(ctx, "192.0.2.1", "alice"); err != nil {
+		t.Fatal(err)
+	} else if wait == 0 {
+		t.Errorf("Check() after 3 failures = 0, want a delay")
+	}
+
+	// A successful authentication clears the failures of the client and
+	// of the user
+	throttle = &webdav.AuthThrottle{Threshold: 2, Delay: time.Hour}
+	h = newThrottledHandler(throttle)
+	for range 2 {
+		checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "wrong")}, http.StatusUnauthorized)
+	}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "secret")}, http.StatusMultiStatus)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "wrong")}, http.StatusUnauthorized)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("bob", "wrong")}, http.StatusUnauthorized)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: basicAuth("alice", "secret")}, http.StatusMultiStatus)
+}
@@ -0,0 +1,111 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCopyBufferSize is the default size of the buffers used to copy file
+// contents.
+const DefaultCopyBufferSize = 32 * 1024
+
+var (
+	copyBufferSize atomic.Int64
+	copyBuffers    sync.Pool
+)
+
+func init() {
+	copyBufferSize.Store(DefaultCopyBufferSize)
+}
+
+// SetCopyBufferSize sets the size of the buffers used to copy file contents
+// in uploads, downloads of non-seekable files, COPY and cross-device MOVE.
+// Buffers are pooled and shared by all handlers. Larger buffers reduce the
+// number of system calls for large files. A size of zero or less restores
+// DefaultCopyBufferSize.
+func SetCopyBufferSize(size int) {
+	if size <= 0 {
+		size = DefaultCopyBufferSize
+	}
+	copyBufferSize.Store(int64(size))
+}
+
+func getCopyBuffer() *[]byte {
+	size := int(copyBufferSize.Load())
+	if buf, ok := copyBuffers.Get().(*[]byte); ok && len(*buf) == size {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+func putCopyBuffer(buf *[]byte) {
+	// Drop buffers allocated before the size changed
+	if len(*buf) == int(copyBufferSize.Load()) {
+		copyBuffers.Put(buf)
+	}
+}
+
+// copyBuffer copies src to dst with a pooled buffer.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	// Let the kernel copy between files
+	if _, ok := src.(*os.File); ok {
+		if _, ok := dst.(*os.File); ok {
+			return io.Copy(dst, src)
+		}
+	}
+
+	buf := getCopyBuffer()
+	defer putCopyBuffer(buf)
+	// Hide io.ReaderFrom and io.WriterTo, whose fallbacks allocate their own
+	// buffers
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
+
+// kernelCopyChunk is the number of bytes copied by the kernel between checks
+// of the context in copyBufferContext.
+const kernelCopyChunk = 16 << 20
+
+// copyBufferContext is like copyBuffer, but stops with the error of ctx once
+// it's done, so that copying large files can be interrupted.
+func copyBufferContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	if ctx.Done() == nil {
+		return copyBuffer(dst, src)
+	}
+	if _, ok := src.(*os.File); ok {
+		if _, ok := dst.(*os.File); ok {
+			// The kernel still copies limited readers between files
+			var written int64
+			for {
+				if err := ctx.Err(); err != nil {
+					return written, err
+				}
+				n, err := io.CopyN(dst, src, kernelCopyChunk)
+				written += n
+				if err == io.EOF {
+					return written, nil
+				} else if err != nil {
+					return written, err
+				}
+			}
+		}
+	}
+	return copyBuffer(dst, contextReader{ctx, src})
+}
+
+// contextReader is a reader failing with the error of its context once it's
+// done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(b)
+}
@@ -0,0 +1,56 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// streamFileSystem serves files as plain readers, without io.Seeker.
+type streamFileSystem struct {
+	webdav.LocalFileSystem
+}
+
+func (fs streamFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := fs.LocalFileSystem.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{f, f}, nil
+}
+
+func TestCopyBufferSize(t *testing.T) {
+	t.Cleanup(func() { webdav.SetCopyBufferSize(0) })
+	content := strings.Repeat("0123456789", 10000)
+	for _, size := range []int{7, 0, 1 << 20} {
+		webdav.SetCopyBufferSize(size)
+		dir := t.TempDir()
+		h := &webdav.Handler{FileSystem: streamFileSystem{webdav.LocalFileSystem(dir)}}
+
+		checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: content}, http.StatusCreated)
+		checkFile(t, dir, "a.txt", content)
+		checkStatus(t, h, testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "http://example.com/b.txt"}}, http.StatusCreated)
+		checkFile(t, dir, "b.txt", content)
+		w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/b.txt"}, http.StatusOK)
+		if w.Body.String() != content {
+			t.Errorf("size %v: GET returned %v bytes, want %v", size, w.Body.Len(), len(content))
+		}
+
+		// Failed copies don't leave partial files behind
+		errRead := errors.New("read failed")
+		body := io.NopCloser(io.MultiReader(strings.NewReader(content), iotest.ErrReader(errRead)))
+		if _, _, err := h.FileSystem.Create(context.Background(), "/c.txt", body, &webdav.CreateOptions{}); !errors.Is(err, errRead) {
+			t.Errorf("size %v: Create() = %v, want %v", size, err, errRead)
+		}
+		checkMissing(t, dir, "c.txt")
+	}
+}
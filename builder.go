@@ -0,0 +1,116 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Server is a WebDAV server built once from a Config. It can be mounted in
+// Fiber apps as well as net/http servers, both sharing the same locks,
+// properties and limits:
+//
+//	s := webdav.NewServer(config)
+//	app.Use(s.FiberHandler())
+//	http.Handle("/dav/", s.HTTPHandler())
+type Server struct {
+	config  Config
+	handler serverHandler
+}
+
+// serverHandler is implemented by Handler and homeHandler.
+type serverHandler interface {
+	http.Handler
+	Shutdown(ctx context.Context) error
+	// checkContinue evaluates the preconditions of an upload before its body
+	// is read, see NewContinue.
+	checkContinue(r *http.Request) error
+}
+
+// NewServer creates a WebDAV server from a configuration.
+func NewServer(config Config) *Server {
+	var h serverHandler
+	if config.HomeDirs {
+		h = newHomeHandler(config)
+	} else {
+		h = newHandler(config)
+	}
+	return &Server{config: config, handler: h}
+}
+
+// HTTPHandler returns a net/http handler serving the WebDAV server. Requests
+// outside of Config.Prefix get 404 Not Found.
+//
+// Config.Authorize, which depends on the Fiber context, isn't called: wrap
+// the handler with a net/http middleware instead.
+func (s *Server) HTTPHandler() http.Handler {
+	v, m, signer := s.config.TokenValidator, s.config.ClientCertificates, s.config.URLSigner
+	if v == nil && m == nil && signer == nil {
+		return s.handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signer != nil {
+			ok, err := signer.verify(r.Method, r.URL.Path, r.URL.Query())
+			if err != nil {
+				internal.ServeError(w, err)
+				return
+			} else if ok {
+				s.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		if m != nil {
+			user, ok, err := authenticateCertificate(r.Context(), m, r.TLS)
+			if err != nil {
+				internal.ServeError(w, err)
+				return
+			} else if ok {
+				s.handler.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user)))
+				return
+			} else if v == nil {
+				internal.ServeError(w, errClientCertificateRequired)
+				return
+			}
+		}
+		if v != nil {
+			var ok bool
+			if r, ok = authenticateRequest(v, w, r); !ok {
+				return
+			}
+		}
+		s.handler.ServeHTTP(w, r)
+	})
+}
+
+// FiberHandler returns a Fiber handler serving the WebDAV server. Requests
+// outside of Config.Prefix are passed to the next handler.
+func (s *Server) FiberHandler() fiber.Handler {
+	return newMountsHandler([]*mount{s.mount()})
+}
+
+// Shutdown gracefully shuts down the server: new requests are rejected,
+// in-flight requests are waited for until ctx is done and buffered locks and
+// properties are flushed. See Handler.Shutdown.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.handler.Shutdown(ctx)
+}
+
+// Mounts creates a Fiber handler serving several servers with different
+// prefixes, like New.
+func Mounts(servers ...*Server) fiber.Handler {
+	mounts := make([]*mount, len(servers))
+	for i, s := range servers {
+		mounts[i] = s.mount()
+	}
+	return newMountsHandler(mounts)
+}
+
+func (s *Server) mount() *mount {
+	return &mount{
+		prefix:  cleanPrefix(s.config.Prefix),
+		handler: newFiberHandler(s.config, s.handler),
+	}
+}
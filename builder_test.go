@@ -0,0 +1,70 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	s := webdav.NewServer(webdav.Config{
+		Prefix:         "/dav",
+		Root:           webdav.LocalFileSystem(dir),
+		Lock:           true,
+		TokenValidator: testTokens{"alice-token": "alice"},
+		Authorize: func(c *fiber.Ctx, method, path string) error {
+			return fiber.ErrForbidden
+		},
+	})
+	h := s.HTTPHandler()
+	alice := map[string]string{"Authorization": "Bearer alice-token"}
+
+	// The net/http handler authenticates bearer tokens
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/a.txt"}, http.StatusUnauthorized)
+	if got := w.Header().Get("WWW-Authenticate"); got != "Bearer" {
+		t.Errorf("WWW-Authenticate = %q without token, want Bearer", got)
+	}
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/a.txt", header: map[string]string{"Authorization": "Bearer bob-token"}}, http.StatusUnauthorized)
+	if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, "invalid_token") {
+		t.Errorf("WWW-Authenticate = %q with an invalid token, want invalid_token", got)
+	}
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", header: alice}, http.StatusNotFound)
+
+	// Authorize is only called by the Fiber handler
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/a.txt", header: alice}, http.StatusOK)
+
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(s.FiberHandler())
+	req := httptest.NewRequest(http.MethodGet, "/dav/a.txt", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Fiber GET = %v, want 403 from Authorize", resp.StatusCode)
+	}
+
+	// Both handlers share the locks
+	s = webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(dir), Lock: true})
+	checkStatus(t, s.HTTPHandler(), testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+	app = fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(s.FiberHandler())
+	resp, err = app.Test(httptest.NewRequest(http.MethodPut, "/a.txt", strings.NewReader("b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Errorf("Fiber PUT to a resource locked with the net/http handler = %v, want 423", resp.StatusCode)
+	}
+	checkFile(t, dir, "a.txt", "a")
+}
@@ -0,0 +1,91 @@
+package webdav
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// CertificateMapper maps the verified certificates of TLS clients to users,
+// for mutual TLS authentication, e.g. of backup agents which shouldn't store
+// passwords.
+//
+// The TLS server must request and verify client certificates, e.g. with
+// tls.RequireAndVerifyClientCert and the pool of trusted CAs in ClientCAs.
+// Certificates which weren't verified are ignored.
+type CertificateMapper interface {
+	// MapCertificate returns the user identified by a client certificate.
+	// Requests with a certificate it returns an error for are rejected, with
+	// the status of the error or "403 Forbidden".
+	MapCertificate(ctx context.Context, cert *x509.Certificate) (user string, err error)
+}
+
+// CertificateSubjects is a CertificateMapper identifying users by the
+// subjects of their certificates.
+type CertificateSubjects struct {
+	// Users maps the subject common names and subject alternative names
+	// (DNS names, email addresses and URIs) of certificates to users. The
+	// common name is checked first, then alternative names in this order.
+	// If nil, the common name is the user.
+	Users map[string]string
+}
+
+var _ CertificateMapper = (*CertificateSubjects)(nil)
+
+// MapCertificate implements CertificateMapper.
+func (m *CertificateSubjects) MapCertificate(ctx context.Context, cert *x509.Certificate) (string, error) {
+	if m.Users == nil {
+		if cert.Subject.CommonName == "" {
+			return "", errors.New("webdav: client certificate has no common name")
+		}
+		return cert.Subject.CommonName, nil
+	}
+
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	for _, name := range names {
+		if user, ok := m.Users[name]; ok && name != "" {
+			return user, nil
+		}
+	}
+	return "", errors.New("webdav: client certificate doesn't identify a known user")
+}
+
+// clientCertificate returns the verified certificate of the client of a TLS
+// connection, if any.
+func clientCertificate(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// authenticateCertificate maps the verified client certificate of a TLS
+// connection to a user. It returns false if there is none.
+func authenticateCertificate(ctx context.Context, m CertificateMapper, state *tls.ConnectionState) (user string, ok bool, err error) {
+	cert := clientCertificate(state)
+	if cert == nil {
+		return "", false, nil
+	}
+	user, err = m.MapCertificate(ctx, cert)
+	if err != nil {
+		var httpErr *internal.HTTPError
+		if !errors.As(err, &httpErr) {
+			err = &internal.HTTPError{Code: http.StatusForbidden, Err: err}
+		}
+		return "", false, err
+	}
+	return user, true, nil
+}
+
+// errClientCertificateRequired rejects requests without a verified client
+// certificate when no other authentication method is configured.
+var errClientCertificateRequired = internal.HTTPErrorf(http.StatusUnauthorized, "webdav: client certificate required")
@@ -0,0 +1,97 @@
+package webdav_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// verifiedState returns the state of a TLS connection with a verified client
+// certificate.
+func verifiedState(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestCertificateSubjects(t *testing.T) {
+	backupURL, _ := url.Parse("spiffe://example.com/backup")
+	m := &webdav.CertificateSubjects{Users: map[string]string{
+		"ops.example.com":             "ops",
+		"spiffe://example.com/backup": "backup",
+	}}
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want string
+	}{
+		{"DNS name", &x509.Certificate{DNSNames: []string{"other.example.com", "ops.example.com"}}, "ops"},
+		{"URI", &x509.Certificate{URIs: []*url.URL{backupURL}}, "backup"},
+		{"unknown", &x509.Certificate{Subject: pkix.Name{CommonName: "eve"}}, ""},
+	}
+	for _, tc := range tests {
+		user, err := m.MapCertificate(t.Context(), tc.cert)
+		if tc.want == "" && err == nil {
+			t.Errorf("%v: MapCertificate() = %q, want error", tc.name, user)
+		} else if tc.want != "" && (err != nil || user != tc.want) {
+			t.Errorf("%v: MapCertificate() = %q, %v, want %q", tc.name, user, err, tc.want)
+		}
+	}
+
+	// Without Users, the common name is the user
+	if user, err := (&webdav.CertificateSubjects{}).MapCertificate(t.Context(), &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}); err != nil || user != "alice" {
+		t.Errorf("MapCertificate() = %q, %v, want alice", user, err)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	newHandler := func(v webdav.TokenValidator) http.Handler {
+		return webdav.NewServer(webdav.Config{
+			Root:               webdav.LocalFileSystem(dir),
+			ClientCertificates: &webdav.CertificateSubjects{Users: map[string]string{"backup-agent": "backup", "alice-laptop": "alice"}},
+			TokenValidator:     v,
+			Permissions:        testPermissions{read: map[string]string{"backup": "/", "alice": "/"}, write: map[string]string{"backup": "/"}},
+		}).HTTPHandler()
+	}
+
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		token string
+		want  int
+	}{
+		{"certificate", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "backup-agent"}}), "", http.StatusCreated},
+		{"certificate of another user", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "alice-laptop"}}), "", http.StatusForbidden},
+		{"unknown certificate", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "eve"}}), "backup-token", http.StatusForbidden},
+		{"unverified certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "backup-agent"}}}}, "", http.StatusUnauthorized},
+		{"token without certificate", nil, "backup-token", http.StatusNoContent},
+		{"no credentials", nil, "", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		h := newHandler(testTokens{"backup-token": "backup"})
+		r := httptest.NewRequest(http.MethodPut, "/backup.tar", strings.NewReader("data"))
+		r.TLS = tc.state
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%v: PUT = %v, want %v\n%s", tc.name, w.Code, tc.want, w.Body)
+		}
+	}
+
+	// Without TokenValidator, a certificate is required
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	newHandler(nil).ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET without certificate = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+}
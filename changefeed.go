@@ -0,0 +1,176 @@
+package webdav
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// changeFeedBuffer is the number of events buffered for each client of a
+// change feed. Clients falling further behind are disconnected, and expected
+// to reconnect and reload what they display.
+const changeFeedBuffer = 256
+
+// changeFeedPing is the interval of the comments keeping idle change feeds
+// open through proxies and detecting disconnected clients.
+const changeFeedPing = 30 * time.Second
+
+// changeFeed broadcasts the changes made through a handler to the clients
+// streaming them with Server-Sent Events.
+type changeFeed struct {
+	mu     sync.Mutex
+	subs   map[*changeSubscriber]struct{}
+	closed bool
+}
+
+// changeSubscriber receives the changes of the subtree root.
+type changeSubscriber struct {
+	root string
+	ch   chan *ChangeEvent
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subs: make(map[*changeSubscriber]struct{})}
+}
+
+// subscribe returns a subscriber to the changes below root, or nil once the
+// feed is closed.
+func (f *changeFeed) subscribe(root string) *changeSubscriber {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	sub := &changeSubscriber{root: root, ch: make(chan *ChangeEvent, changeFeedBuffer)}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes a subscriber, closing its channel if not done yet.
+func (f *changeFeed) unsubscribe(sub *changeSubscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish sends an event to the subscribers of the subtrees of its path or
+// destination. Subscribers whose buffer is full are dropped.
+func (f *changeFeed) publish(event *ChangeEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		if !isDescendant(event.Path, sub.root) && (event.Destination == "" || !isDescendant(event.Destination, sub.root)) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(f.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// close ends the streams of all subscribers, and refuses new ones.
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subs {
+		delete(f.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// acceptsEventStream reports whether a request asks for Server-Sent Events,
+// like EventSource does.
+func acceptsEventStream(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// serveChangeFeed streams the changes below the request resource as
+// Server-Sent Events, until the client goes away or the handler shuts down.
+// Changes of resources the user can't read are left out.
+func (b *backend) serveChangeFeed(w http.ResponseWriter, r *http.Request) error {
+	sub := b.ChangeFeed.subscribe(path.Clean(r.URL.Path))
+	if sub == nil {
+		return internal.HTTPErrorf(http.StatusServiceUnavailable, "webdav: server is shutting down")
+	}
+	// With Fiber, events are streamed once the request context is canceled,
+	// keep its values to check permissions
+	ctx := context.WithoutCancel(r.Context())
+	visible := func(event *ChangeEvent) bool {
+		return b.readable(ctx, event.Path) || (event.Destination != "" && b.readable(ctx, event.Destination))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disable buffering by nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if fctx, ok := fasthttpCtx(r.Context()); ok {
+		// The adaptor buffers responses, stream with fasthttp once the
+		// handler returns
+		fctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+			defer b.ChangeFeed.unsubscribe(sub)
+			streamChanges(context.Background(), bw, bw.Flush, sub, visible)
+		})
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	defer b.ChangeFeed.unsubscribe(sub)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusNotImplemented, "webdav: response writer can't stream")
+	}
+	w.WriteHeader(http.StatusOK)
+	streamChanges(r.Context(), w, func() error {
+		flusher.Flush()
+		return nil
+	}, sub, visible)
+	return nil
+}
+
+// streamChanges writes the events received by a subscriber as Server-Sent
+// Events, until its channel is closed, ctx is done or writing fails.
+func streamChanges(ctx context.Context, w io.Writer, flush func() error, sub *changeSubscriber, visible func(*ChangeEvent) bool) {
+	ticker := time.NewTicker(changeFeedPing)
+	defer ticker.Stop()
+
+	// Send the header right away, so that clients know they're connected
+	_, err := io.WriteString(w, ": connected\n\n")
+	for err == nil {
+		if err = flush(); err != nil {
+			return
+		}
+		select {
+		case event, ok := <-sub.ch:
+			if !ok {
+				return
+			} else if !visible(event) {
+				continue
+			}
+			var data []byte
+			if data, err = json.Marshal(event); err == nil {
+				_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			}
+		case <-ticker.C:
+			_, err = io.WriteString(w, ": ping\n\n")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package webdav_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// readChangeEvent reads the next Server-Sent Event of a change feed,
+// skipping comments.
+func readChangeEvent(t *testing.T, r *bufio.Reader) *webdav.ChangeEvent {
+	t.Helper()
+	var event webdav.ChangeEvent
+	var typ string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && typ != "":
+			if string(event.Type) != typ {
+				t.Errorf("event field %q, want %q", typ, event.Type)
+			}
+			return &event
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/": "", "docs/secret/": ""})
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		ChangeFeed: true,
+		Permissions: testPermissions{
+			read:   map[string]string{"alice": "/", "bob": "/"},
+			write:  map[string]string{"alice": "/"},
+			remove: map[string]string{"alice": "/"},
+			hidden: map[string]string{"bob": "/docs/secret"},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(webdav.ContextWithUser(r.Context(), "bob")))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/docs/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("GET = %v %v, want 200 text/event-stream", resp.Status, ct)
+	}
+	r := bufio.NewReader(resp.Body)
+	if line, err := r.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	// Only the visible changes below the subscribed collection are sent
+	for _, req := range []testRequest{
+		{method: http.MethodPut, target: "/other.txt", body: "other", user: "alice"},
+		{method: http.MethodPut, target: "/docs/secret/a.txt", body: "a", user: "alice"},
+		{method: http.MethodPut, target: "/docs/a.txt", body: "a", user: "alice"},
+		{method: "MOVE", target: "/other.txt", header: map[string]string{"Destination": "/docs/other.txt"}, user: "alice"},
+	} {
+		w := serve(t, h, req)
+		if w.Code/100 != 2 {
+			t.Fatalf("%v %v = %v", req.method, req.target, w.Code)
+		}
+	}
+	if e := readChangeEvent(t, r); e.Type != webdav.ChangeCreate || e.Path != "/docs/a.txt" || e.User != "alice" {
+		t.Errorf("first event = %+v, want creation of /docs/a.txt", e)
+	}
+	if e := readChangeEvent(t, r); e.Type != webdav.ChangeMove || e.Path != "/other.txt" || e.Destination != "/docs/other.txt" {
+		t.Errorf("second event = %+v, want move to /docs/other.txt", e)
+	}
+
+	// Shutting down ends the stream
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(io.Discard, r)
+		done <- err
+	}()
+	if err := h.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("reading the stream: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("stream not closed by Shutdown")
+	}
+}
@@ -0,0 +1,119 @@
+package webdav
+
+import (
+	"context"
+	"time"
+)
+
+// ChangeType is the kind of change described by a ChangeEvent.
+type ChangeType string
+
+const (
+	// ChangeCreate reports a created file or collection, including the
+	// destination of a COPY which didn't exist.
+	ChangeCreate ChangeType = "create"
+	// ChangeUpdate reports an overwritten file or COPY destination.
+	ChangeUpdate ChangeType = "update"
+	// ChangeDelete reports a removed resource.
+	ChangeDelete ChangeType = "delete"
+	// ChangeMove reports a moved resource, from Path to Destination.
+	ChangeMove ChangeType = "move"
+)
+
+// ChangeEvent describes a change made through a handler, as sent by
+// Webhooks and the change feed (see Handler.ChangeFeed).
+type ChangeEvent struct {
+	// ID identifies the event. Retried deliveries have the same ID.
+	ID   string     `json:"id"`
+	Type ChangeType `json:"type"`
+	Time time.Time  `json:"time"`
+	// Path is the path of the resource in the FileSystem, without the
+	// mount prefix.
+	Path string `json:"path"`
+	// Destination is the target of moves.
+	Destination string `json:"destination,omitempty"`
+	IsDir       bool   `json:"is_dir,omitempty"`
+	// Size is the size of uploaded files.
+	Size *int64 `json:"size,omitempty"`
+	// User is the authenticated user who performed the operation.
+	User      string `json:"user,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// newChangeEvent describes the change reported by a hook call.
+func newChangeEvent(ctx context.Context, typ ChangeType, event *Event) *ChangeEvent {
+	ce := &ChangeEvent{
+		ID:          newRequestID(),
+		Type:        typ,
+		Time:        time.Now(),
+		Path:        event.Path,
+		Destination: event.Destination,
+		User:        event.User,
+	}
+	if fi := event.FileInfo; fi != nil {
+		ce.IsDir = fi.IsDir
+		if !fi.IsDir {
+			size := fi.Size
+			ce.Size = &size
+		}
+	}
+	ce.RequestID, _ = RequestIDFromContext(ctx)
+	return ce
+}
+
+// changeHooks returns Hooks passing the changes made through the handler to
+// send.
+func changeHooks(send func(event *ChangeEvent)) Hooks {
+	created := func(event *Event) ChangeType {
+		if event.Created {
+			return ChangeCreate
+		}
+		return ChangeUpdate
+	}
+	return Hooks{
+		OnPut: func(ctx context.Context, event *Event) {
+			send(newChangeEvent(ctx, created(event), event))
+		},
+		OnDelete: func(ctx context.Context, event *Event) {
+			send(newChangeEvent(ctx, ChangeDelete, event))
+		},
+		OnMove: func(ctx context.Context, event *Event) {
+			send(newChangeEvent(ctx, ChangeMove, event))
+		},
+		OnCopy: func(ctx context.Context, event *Event) {
+			// Report the destination, the source is unchanged
+			copied := *event
+			copied.Path, copied.Destination = event.Destination, ""
+			send(newChangeEvent(ctx, created(event), &copied))
+		},
+		OnMkcol: func(ctx context.Context, event *Event) {
+			dir := *event
+			dir.FileInfo = &FileInfo{Path: event.Path, IsDir: true}
+			send(newChangeEvent(ctx, ChangeCreate, &dir))
+		},
+	}
+}
+
+// joinHooks returns Hooks calling the hooks of a, then those of b.
+func joinHooks(a, b Hooks) Hooks {
+	join := func(f, g func(context.Context, *Event)) func(context.Context, *Event) {
+		if f == nil {
+			return g
+		} else if g == nil {
+			return f
+		}
+		return func(ctx context.Context, event *Event) {
+			f(ctx, event)
+			g(ctx, event)
+		}
+	}
+	return Hooks{
+		OnGet:          join(a.OnGet, b.OnGet),
+		OnPut:          join(a.OnPut, b.OnPut),
+		OnDelete:       join(a.OnDelete, b.OnDelete),
+		OnMove:         join(a.OnMove, b.OnMove),
+		OnCopy:         join(a.OnCopy, b.OnCopy),
+		OnMkcol:        join(a.OnMkcol, b.OnMkcol),
+		OnMoveProgress: join(a.OnMoveProgress, b.OnMoveProgress),
+	}
+}
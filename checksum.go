@@ -0,0 +1,123 @@
+package webdav
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumAlgorithm is a hash algorithm used to compute file checksums.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "MD5"
+	ChecksumSHA1   ChecksumAlgorithm = "SHA1"
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+)
+
+func (alg ChecksumAlgorithm) new() (hash.Hash, error) {
+	switch alg {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("webdav: unsupported checksum algorithm %q", string(alg))
+}
+
+// checksum is a checksum in the "<algorithm>:<hex digest>" format used by
+// ownCloud and Nextcloud clients.
+type checksum struct {
+	Algorithm ChecksumAlgorithm
+	Digest    string
+}
+
+func parseChecksum(s string) (*checksum, error) {
+	alg, digest, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || digest == "" {
+		return nil, fmt.Errorf("webdav: malformed checksum %q", s)
+	}
+	c := &checksum{
+		Algorithm: ChecksumAlgorithm(strings.ToUpper(alg)),
+		Digest:    strings.ToLower(digest),
+	}
+	if _, err := c.Algorithm.new(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checksum) String() string {
+	return fmt.Sprintf("%v:%v", c.Algorithm, c.Digest)
+}
+
+// checksumReader computes checksums of the data read from an io.ReadCloser.
+type checksumReader struct {
+	io.ReadCloser
+	hashes map[ChecksumAlgorithm]hash.Hash
+	w      io.Writer
+}
+
+func newChecksumReader(rc io.ReadCloser, algs ...ChecksumAlgorithm) (*checksumReader, error) {
+	cr := &checksumReader{ReadCloser: rc, hashes: make(map[ChecksumAlgorithm]hash.Hash)}
+	var writers []io.Writer
+	for _, alg := range algs {
+		if _, ok := cr.hashes[alg]; ok {
+			continue
+		}
+		h, err := alg.new()
+		if err != nil {
+			return nil, err
+		}
+		cr.hashes[alg] = h
+		writers = append(writers, h)
+	}
+	cr.w = io.MultiWriter(writers...)
+	return cr, nil
+}
+
+func (cr *checksumReader) Read(b []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(b)
+	cr.w.Write(b[:n])
+	return n, err
+}
+
+func (cr *checksumReader) Sum(alg ChecksumAlgorithm) *checksum {
+	return &checksum{Algorithm: alg, Digest: hex.EncodeToString(cr.hashes[alg].Sum(nil))}
+}
+
+// computeChecksum reads a whole file to compute its checksum.
+func computeChecksum(ctx context.Context, fs FileSystem, name string, alg ChecksumAlgorithm) (*checksum, error) {
+	f, err := fs.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr, err := newChecksumReader(f, alg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, cr); err != nil {
+		return nil, err
+	}
+	return cr.Sum(alg), nil
+}
+
+// verifyChecksum checks that the uploaded data matches the checksum sent by
+// the client in the OC-Checksum header field.
+func verifyChecksum(cr *checksumReader, expected *checksum) error {
+	if got := cr.Sum(expected.Algorithm); got.Digest != expected.Digest {
+		return NewHTTPError(http.StatusBadRequest, fmt.Errorf("webdav: checksum mismatch: expected %v, got %v", expected, got))
+	}
+	return nil
+}
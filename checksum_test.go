@@ -0,0 +1,56 @@
+package webdav_test
+
+import (
+	"net/http"
+	"testing"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testFileSystems returns the FileSystems of the package serving dir.
+func testFileSystems(t *testing.T, dir string) map[string]webdav.FileSystem {
+	t.Helper()
+	root, err := webdav.NewRootFileSystem(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+	return map[string]webdav.FileSystem{
+		"local":  webdav.LocalFileSystem(dir),
+		"root":   root,
+		"legacy": webdav.AdaptLegacyFS(xwebdav.Dir(dir)),
+	}
+}
+
+func TestPutChecksum(t *testing.T) {
+	const (
+		sha1Hello = "SHA1:aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+		sha1Other = "SHA1:0000000000000000000000000000000000000000"
+	)
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "old"})
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "hello", header: map[string]string{"OC-Checksum": sha1Other}}, http.StatusBadRequest)
+			checkFile(t, dir, "a.txt", "old")
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "hello", header: map[string]string{"OC-Checksum": sha1Other}}, http.StatusBadRequest)
+			checkMissing(t, dir, "b.txt")
+
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "hello", header: map[string]string{"OC-Checksum": sha1Hello}}, http.StatusNoContent)
+			checkFile(t, dir, "a.txt", "hello")
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "hello", header: map[string]string{"OC-Checksum": sha1Hello}}, http.StatusCreated)
+			checkFile(t, dir, "b.txt", "hello")
+
+			// No staging file is left behind
+			if entries, err := readDirNames(dir); err != nil {
+				t.Fatal(err)
+			} else if len(entries) != 2 {
+				t.Errorf("directory entries = %v, want [a.txt b.txt]", entries)
+			}
+		})
+	}
+}
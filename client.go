@@ -116,7 +116,7 @@ func fileInfoFromResponse(resp *internal.Response) (*FileInfo, error) {
 
 		fi.Size = getLen.Length
 		fi.MIMEType = getType.Type
-		fi.ETag = string(getETag.ETag)
+		fi.ETag, fi.WeakETag = getETag.ETag.Tag, getETag.ETag.Weak
 	}
 
 	var getMod internal.GetLastModified
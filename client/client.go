@@ -0,0 +1,471 @@
+// Package client implements a WebDAV client, to access the resources of
+// remote WebDAV servers from Go programs.
+//
+// Errors returned for unsuccessful responses carry their HTTP status code,
+// and match the sentinel errors of the webdav package with errors.Is:
+//
+//	c, err := client.New(nil, "https://example.org/dav/")
+//	_, err = c.Stat(ctx, "notes.txt")
+//	if errors.Is(err, webdav.ErrNotFound) { ... }
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// HTTPClient performs HTTP requests. It's implemented by *http.Client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type basicAuthHTTPClient struct {
+	c                  HTTPClient
+	username, password string
+}
+
+func (c *basicAuthHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	req.SetBasicAuth(c.username, c.password)
+	return c.c.Do(req)
+}
+
+// HTTPClientWithBasicAuth returns an HTTP client that adds basic
+// authentication to all outgoing requests. If c is nil, the default client of
+// New is used.
+func HTTPClientWithBasicAuth(c HTTPClient, username, password string) HTTPClient {
+	if c == nil {
+		c = defaultHTTPClient
+	}
+	return &basicAuthHTTPClient{c, username, password}
+}
+
+// NewHTTPClient returns an HTTP client keeping up to conns idle connections
+// per host, instead of the two of http.DefaultTransport, so that concurrent
+// transfers reuse their connections rather than opening new ones.
+func NewHTTPClient(conns int) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = conns
+	t.MaxIdleConns = max(t.MaxIdleConns, conns)
+	return &http.Client{Transport: t}
+}
+
+// defaultHTTPClient is used by New when no client is provided, with enough
+// idle connections for a few concurrent syncs.
+var defaultHTTPClient = NewHTTPClient(4 * defaultSyncConcurrency)
+
+// Depth indicates whether a request applies to the members of a collection.
+type Depth int
+
+const (
+	// DepthZero applies a request to the resource only.
+	DepthZero Depth = Depth(internal.DepthZero)
+	// DepthOne applies a request to the resource and its direct members.
+	DepthOne Depth = Depth(internal.DepthOne)
+	// DepthInfinity applies a request to the resource and all its
+	// descendants.
+	DepthInfinity Depth = Depth(internal.DepthInfinity)
+)
+
+// Resource describes a remote file or collection.
+type Resource struct {
+	// Path is the URL path of the resource on the server.
+	Path     string
+	IsDir    bool
+	Size     int64
+	ModTime  time.Time
+	MIMEType string
+	// ETag is the entity tag of the resource as sent by the server, e.g.
+	// "xyzzy" or W/"xyzzy" with the quotes, if any.
+	ETag string
+	// Props holds the text content of the additional properties requested
+	// with PropFind, if the server returned them.
+	Props map[xml.Name]string
+}
+
+// Client is a WebDAV client. Resource names are resolved against the
+// endpoint URL, unless they're absolute paths.
+//
+// The client keeps track of the locks it takes, and submits their tokens in
+// the If header field of the requests modifying locked resources.
+type Client struct {
+	// Retry, if set, retries the requests failing with transient errors,
+	// and resumes interrupted downloads. It must be set before the client
+	// is used.
+	Retry *Retry
+
+	ic *internal.Client
+
+	mu             sync.Mutex
+	locks          map[string]heldLock // by token
+	partialUpdates *bool               // nil until known
+}
+
+// New creates a WebDAV client for the server at endpoint. If c is nil, a
+// client created with NewHTTPClient is used.
+//
+// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used.
+func New(c HTTPClient, endpoint string) (*Client, error) {
+	if c == nil {
+		c = defaultHTTPClient
+	}
+	client := new(Client)
+	ic, err := internal.NewClient(&retryHTTPClient{c: client, http: c}, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client.ic = ic
+	return client, nil
+}
+
+// do sends a request and discards the response body.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	// Read the rest of short bodies, so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return resp, nil
+}
+
+var resourcePropNames = []xml.Name{
+	internal.ResourceTypeName,
+	internal.GetContentLengthName,
+	internal.GetLastModifiedName,
+	internal.GetContentTypeName,
+	internal.GetETagName,
+}
+
+// PropFind returns the resource name and, depending on depth, its members or
+// descendants. props are additional properties to fetch into
+// Resource.Props.
+func (c *Client) PropFind(ctx context.Context, name string, depth Depth, props ...xml.Name) ([]Resource, error) {
+	names := append(resourcePropNames[:len(resourcePropNames):len(resourcePropNames)], props...)
+	ms, err := c.ic.PropFind(ctx, name, internal.Depth(depth), internal.NewPropNamePropFind(names...))
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Resource, 0, len(ms.Responses))
+	for i := range ms.Responses {
+		res, err := resourceFromResponse(&ms.Responses[i], props)
+		if err != nil {
+			return l, err
+		}
+		l = append(l, *res)
+	}
+	return l, nil
+}
+
+// Stat returns the resource name.
+func (c *Client) Stat(ctx context.Context, name string) (*Resource, error) {
+	l, err := c.PropFind(ctx, name, DepthZero)
+	if err != nil {
+		return nil, err
+	}
+	if len(l) != 1 {
+		return nil, fmt.Errorf("webdav: PROPFIND with Depth: 0 returned %d responses", len(l))
+	}
+	return &l[0], nil
+}
+
+func resourceFromResponse(resp *internal.Response, props []xml.Name) (*Resource, error) {
+	p, err := resp.Path()
+	if err != nil {
+		return nil, err
+	}
+	res := &Resource{Path: p}
+
+	var resType internal.ResourceType
+	if err := resp.DecodeProp(&resType); err != nil && !internal.IsNotFound(err) {
+		return nil, err
+	}
+	res.IsDir = resType.Is(internal.CollectionName)
+
+	var (
+		getLen  internal.GetContentLength
+		getType internal.GetContentType
+		getETag internal.GetETag
+		getMod  internal.GetLastModified
+	)
+	for _, v := range []interface{}{&getLen, &getType, &getETag, &getMod} {
+		if err := resp.DecodeProp(v); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	res.Size = getLen.Length
+	res.MIMEType = getType.Type
+	if getETag.ETag.Tag != "" {
+		res.ETag = getETag.ETag.String()
+	}
+	res.ModTime = time.Time(getMod.LastModified)
+
+	for _, name := range props {
+		for _, propstat := range resp.PropStats {
+			if propstat.Status.Code/100 != 2 {
+				continue
+			}
+			if raw := propstat.Prop.Get(name); raw != nil {
+				if res.Props == nil {
+					res.Props = make(map[xml.Name]string)
+				}
+				res.Props[name] = raw.GetTextContent()
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// resourceFromHeader describes the resource of a GET or PUT request from the
+// response header.
+func resourceFromHeader(req *http.Request, resp *http.Response) *Resource {
+	res := &Resource{
+		Path:     req.URL.Path,
+		MIMEType: resp.Header.Get("Content-Type"),
+		ETag:     resp.Header.Get("ETag"),
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		res.ModTime = t
+	}
+	return res
+}
+
+// GetOptions are options for Client.Get.
+type GetOptions struct {
+	// Offset is the position to start reading the file at, e.g. the size of
+	// a partial copy of the file to complete.
+	Offset int64
+	// Progress, if set, is called as the body is read with the position
+	// reached in the file and its size, -1 if unknown.
+	Progress func(transferred, total int64)
+}
+
+// Get fetches the content of a file. The returned Resource is described by
+// the response header, its Props are empty and its Size is the size of the
+// whole file. The caller must close the body.
+//
+// If the client has a Retry policy and the server supports range requests,
+// reading the body transparently resumes the download where it was
+// interrupted, as long as the file doesn't change.
+func (c *Client) Get(ctx context.Context, name string, opts *GetOptions) (io.ReadCloser, *Resource, error) {
+	if opts == nil {
+		opts = new(GetOptions)
+	}
+	req, resp, err := c.getRange(ctx, name, opts.Offset, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	res := resourceFromHeader(req, resp)
+	res.Size = resp.ContentLength
+	if opts.Offset > 0 && res.Size >= 0 {
+		res.Size += opts.Offset
+	}
+	body := &resumingBody{
+		ctx:         ctx,
+		c:           c,
+		name:        name,
+		body:        resp.Body,
+		offset:      opts.Offset,
+		size:        res.Size,
+		etag:        res.ETag,
+		acceptRange: resp.Header.Get("Accept-Ranges") == "bytes" || resp.StatusCode == http.StatusPartialContent,
+		progress:    opts.Progress,
+	}
+	return body, res, nil
+}
+
+// PutOptions are options for Client.Put.
+type PutOptions struct {
+	// ContentType is the media type of the file.
+	ContentType string
+	// ContentLength is the size of the file, if known in advance and body
+	// isn't a *bytes.Buffer, *bytes.Reader or *strings.Reader. Otherwise, the
+	// content is sent with chunked transfer coding.
+	ContentLength int64
+	// IfMatch, if set, only replaces the file if its entity tag matches, as
+	// returned in Resource.ETag, or if it exists with "*". It's used to
+	// avoid overwriting concurrent changes: the request fails with "412
+	// Precondition Failed" if the file changed since it was fetched.
+	IfMatch string
+	// IfNoneMatch set to "*" only creates the file if it doesn't exist.
+	IfNoneMatch string
+	// ModTime, if not zero, is the modification time to set on the file,
+	// with the X-OC-Mtime header field. Servers may ignore it.
+	ModTime time.Time
+	// ChunkSize, if positive, uploads files larger than ChunkSize in
+	// chunks of that size with partial updates, if the server advertises
+	// them with the "sabredav-partialupdate" DAV compliance class. Failed
+	// chunks are sent again alone. ContentLength must be set.
+	ChunkSize int64
+	// Progress, if set, is called as the body is sent with the number of
+	// bytes sent and ContentLength. The number of bytes sent goes back when
+	// a request is retried.
+	Progress func(transferred, total int64)
+}
+
+// Put creates or replaces a file with the content of body. The returned
+// Resource has the entity tag of the new content, if the server sent it.
+func (c *Client) Put(ctx context.Context, name string, body io.Reader, opts *PutOptions) (*Resource, error) {
+	if opts == nil {
+		opts = new(PutOptions)
+	}
+	if opts.ChunkSize > 0 && opts.ContentLength > opts.ChunkSize && c.supportsPartialUpdates(ctx) {
+		return c.putChunks(ctx, name, body, opts)
+	}
+	req, err := c.newPutRequest(http.MethodPut, name, body, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ContentLength > 0 {
+		req.ContentLength = opts.ContentLength
+	}
+	setRewindable(req, body)
+	setProgress(req, opts.Progress, 0, req.ContentLength)
+	setConditional(req, opts.IfMatch, opts.IfNoneMatch)
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res := resourceFromHeader(req, resp)
+	res.MIMEType = opts.ContentType
+	if resp.Header.Get("X-OC-Mtime") == "accepted" {
+		res.ModTime = opts.ModTime
+	}
+	if req.ContentLength > 0 {
+		res.Size = req.ContentLength
+	}
+	return res, nil
+}
+
+// newPutRequest creates a PUT or PATCH request with the header fields common
+// to whole and partial uploads.
+func (c *Client) newPutRequest(method, name string, body io.Reader, opts *PutOptions) (*http.Request, error) {
+	req, err := c.ic.NewRequest(method, name, body)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ContentType != "" && method == http.MethodPut {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+	if !opts.ModTime.IsZero() {
+		req.Header.Set("X-OC-Mtime", strconv.FormatInt(opts.ModTime.Unix(), 10))
+	}
+	c.submitLocks(req, name)
+	return req, nil
+}
+
+// Mkcol creates a collection.
+func (c *Client) Mkcol(ctx context.Context, name string) error {
+	req, err := c.ic.NewRequest("MKCOL", name, nil)
+	if err != nil {
+		return err
+	}
+	c.submitLocks(req, name)
+	_, err = c.do(ctx, req)
+	return err
+}
+
+// DeleteOptions are options for Client.Delete.
+type DeleteOptions struct {
+	// IfMatch, if set, only removes the resource if its entity tag matches.
+	// See PutOptions.IfMatch.
+	IfMatch string
+}
+
+// Delete removes a resource. Collections are removed with all their
+// descendants.
+func (c *Client) Delete(ctx context.Context, name string, opts *DeleteOptions) error {
+	if opts == nil {
+		opts = new(DeleteOptions)
+	}
+	req, err := c.ic.NewRequest(http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	setConditional(req, opts.IfMatch, "")
+	c.submitLocks(req, name)
+	_, err = c.do(ctx, req)
+	return err
+}
+
+// CopyOptions are options for Client.Copy.
+type CopyOptions struct {
+	// NoRecursive copies collections without their members.
+	NoRecursive bool
+	// NoOverwrite fails with "412 Precondition Failed" if the destination
+	// exists.
+	NoOverwrite bool
+}
+
+// Copy copies a resource to dest. It reports whether dest was created rather
+// than overwritten.
+func (c *Client) Copy(ctx context.Context, name, dest string, opts *CopyOptions) (created bool, err error) {
+	if opts == nil {
+		opts = new(CopyOptions)
+	}
+	req, err := c.ic.NewRequest("COPY", name, nil)
+	if err != nil {
+		return false, err
+	}
+	depth := internal.DepthInfinity
+	if opts.NoRecursive {
+		depth = internal.DepthZero
+	}
+	req.Header.Set("Destination", c.ic.ResolveHref(dest).String())
+	req.Header.Set("Overwrite", internal.FormatOverwrite(!opts.NoOverwrite))
+	req.Header.Set("Depth", depth.String())
+	c.submitLocks(req, dest)
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusCreated, nil
+}
+
+// MoveOptions are options for Client.Move.
+type MoveOptions struct {
+	// NoOverwrite fails with "412 Precondition Failed" if the destination
+	// exists.
+	NoOverwrite bool
+}
+
+// Move moves a resource to dest. It reports whether dest was created rather
+// than overwritten.
+func (c *Client) Move(ctx context.Context, name, dest string, opts *MoveOptions) (created bool, err error) {
+	if opts == nil {
+		opts = new(MoveOptions)
+	}
+	req, err := c.ic.NewRequest("MOVE", name, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Destination", c.ic.ResolveHref(dest).String())
+	req.Header.Set("Overwrite", internal.FormatOverwrite(!opts.NoOverwrite))
+	c.submitLocks(req, name, dest)
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusCreated, nil
+}
+
+// setConditional sets the If-Match and If-None-Match header fields of a
+// request.
+func setConditional(req *http.Request, ifMatch, ifNoneMatch string) {
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+}
@@ -0,0 +1,176 @@
+package client_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+	"github.com/Tryanks/fiber-webdav/client"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(&webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		LockSystem: webdav.NewLockSystem(),
+	})
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestClient(t *testing.T, ts *httptest.Server) *client.Client {
+	t.Helper()
+	c, err := client.New(ts.Client(), ts.URL+"/dav/")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t))
+
+	if err := c.Mkcol(ctx, "dir"); err != nil {
+		t.Fatalf("Mkcol() = %v", err)
+	}
+	put, err := c.Put(ctx, "dir/a.txt", strings.NewReader("hello"), &client.PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Put() = %v", err)
+	} else if put.ETag == "" {
+		t.Errorf("Put(): no entity tag")
+	}
+
+	body, res, err := c.Get(ctx, "dir/a.txt", nil)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	b, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("Get(): failed to read body: %v", err)
+	} else if string(b) != "hello" {
+		t.Errorf("Get() = %q, want %q", b, "hello")
+	}
+	if res.ETag != put.ETag || res.Size != 5 {
+		t.Errorf("Get(): resource = %+v, want entity tag %v and size 5", res, put.ETag)
+	}
+
+	executable := xml.Name{Space: "http://apache.org/dav/props/", Local: "executable"}
+	l, err := c.PropFind(ctx, "dir", client.DepthOne, executable)
+	if err != nil {
+		t.Fatalf("PropFind() = %v", err)
+	} else if len(l) != 2 {
+		t.Fatalf("PropFind(): got %v resources, want 2", len(l))
+	}
+	if !l[0].IsDir || l[0].Path != "/dav/dir/" {
+		t.Errorf("PropFind(): collection = %+v", l[0])
+	}
+	if f := l[1]; f.IsDir || f.Path != "/dav/dir/a.txt" || f.Size != 5 || f.ETag != put.ETag || !strings.HasPrefix(f.MIMEType, "text/plain") {
+		t.Errorf("PropFind(): file = %+v", f)
+	} else if f.Props[executable] != "F" {
+		t.Errorf("PropFind(): executable = %q, want %q", f.Props[executable], "F")
+	}
+
+	if created, err := c.Copy(ctx, "dir/a.txt", "dir/b.txt", nil); err != nil || !created {
+		t.Errorf("Copy() = %v, %v, want true, nil", created, err)
+	}
+	if _, err := c.Copy(ctx, "dir/a.txt", "dir/b.txt", &client.CopyOptions{NoOverwrite: true}); !errors.Is(err, webdav.ErrPreconditionFailed) {
+		t.Errorf("Copy() = %v, want precondition failed", err)
+	}
+	if created, err := c.Move(ctx, "dir/b.txt", "/dav/c.txt", nil); err != nil || !created {
+		t.Errorf("Move() = %v, %v, want true, nil", created, err)
+	}
+	if err := c.Delete(ctx, "dir", nil); err != nil {
+		t.Errorf("Delete() = %v", err)
+	}
+	if _, err := c.Stat(ctx, "dir/a.txt"); !errors.Is(err, webdav.ErrNotFound) {
+		t.Errorf("Stat() = %v, want not found", err)
+	}
+	if res, err := c.Stat(ctx, "c.txt"); err != nil || res.Size != 5 {
+		t.Errorf("Stat() = %+v, %v, want a 5 bytes file", res, err)
+	}
+}
+
+func TestClient_Lock(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	owner, other := newTestClient(t, ts), newTestClient(t, ts)
+
+	if err := owner.Mkcol(ctx, "dir"); err != nil {
+		t.Fatalf("Mkcol() = %v", err)
+	}
+	lock, err := owner.Lock(ctx, "dir", &client.LockOptions{Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	if lock.Token == "" || lock.Root != "/dav/dir" || lock.Timeout != time.Minute {
+		t.Errorf("Lock() = %+v", lock)
+	}
+	if l := owner.Locks(); len(l) != 1 || l[0].Token != lock.Token {
+		t.Errorf("Locks() = %+v, want the lock", l)
+	}
+
+	// The owner submits the lock token, others don't have it
+	if _, err := other.Put(ctx, "dir/a.txt", strings.NewReader("x"), nil); !errors.Is(err, webdav.ErrLocked) {
+		t.Errorf("Put() = %v, want locked", err)
+	}
+	if _, err := other.Lock(ctx, "dir", nil); !errors.Is(err, webdav.ErrLocked) {
+		t.Errorf("Lock() = %v, want locked", err)
+	}
+	if _, err := owner.Put(ctx, "dir/a.txt", strings.NewReader("x"), nil); err != nil {
+		t.Errorf("Put() = %v", err)
+	}
+	if _, err := owner.Move(ctx, "dir/a.txt", "b.txt", nil); err != nil {
+		t.Errorf("Move() = %v", err)
+	}
+	if err := owner.Refresh(ctx, lock); err != nil {
+		t.Errorf("Refresh() = %v", err)
+	}
+
+	if err := owner.Unlock(ctx, "dir", lock.Token); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if l := owner.Locks(); len(l) != 0 {
+		t.Errorf("Locks() = %+v, want none", l)
+	}
+	if _, err := other.Put(ctx, "dir/a.txt", strings.NewReader("x"), nil); err != nil {
+		t.Errorf("Put() = %v", err)
+	}
+}
+
+func TestClient_conditional(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t))
+
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("v1"), &client.PutOptions{IfNoneMatch: "*"}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("v1"), &client.PutOptions{IfNoneMatch: "*"}); !errors.Is(err, webdav.ErrPreconditionFailed) {
+		t.Errorf("Put() = %v, want precondition failed", err)
+	}
+
+	res, err := c.Stat(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	// Make sure the modification time, and thus the entity tag, changes
+	time.Sleep(10 * time.Millisecond)
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("v2"), &client.PutOptions{IfMatch: res.ETag}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	// A concurrent writer fetched the first version
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("v3"), &client.PutOptions{IfMatch: res.ETag}); !errors.Is(err, webdav.ErrPreconditionFailed) {
+		t.Errorf("Put() = %v, want precondition failed", err)
+	}
+	if err := c.Delete(ctx, "a.txt", &client.DeleteOptions{IfMatch: res.ETag}); !errors.Is(err, webdav.ErrPreconditionFailed) {
+		t.Errorf("Delete() = %v, want precondition failed", err)
+	}
+}
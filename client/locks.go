@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Lock is an exclusive write lock held on a resource.
+type Lock struct {
+	// Token is the lock token, e.g. "opaquelocktoken:...".
+	Token string
+	// Root is the URL path of the locked resource.
+	Root  string
+	Depth Depth
+	// Timeout is the time the lock expires after unless refreshed, zero if
+	// it doesn't.
+	Timeout time.Duration
+}
+
+// heldLock is a lock taken by the client, with its expiration time if any.
+type heldLock struct {
+	Lock
+	expires time.Time
+}
+
+// LockOptions are options for Client.Lock.
+type LockOptions struct {
+	// Timeout is the requested lock timeout. The server may grant another
+	// one. If zero, an infinite timeout is requested.
+	Timeout time.Duration
+	// NoRecursive locks a collection without its members.
+	NoRecursive bool
+}
+
+// Lock takes an exclusive write lock on a resource. The client remembers the
+// lock until it's released with Unlock or expires, and submits its token
+// with the requests modifying the locked resources.
+func (c *Client) Lock(ctx context.Context, name string, opts *LockOptions) (*Lock, error) {
+	if opts == nil {
+		opts = new(LockOptions)
+	}
+	lockInfo := &internal.LockInfo{
+		LockScope: internal.LockScope{Exclusive: &struct{}{}},
+		LockType:  internal.LockType{Write: &struct{}{}},
+	}
+	req, err := c.ic.NewXMLRequest("LOCK", name, lockInfo)
+	if err != nil {
+		return nil, err
+	}
+	depth := internal.DepthInfinity
+	if opts.NoRecursive {
+		depth = internal.DepthZero
+	}
+	req.Header.Set("Depth", depth.String())
+	req.Header.Set("Timeout", internal.Timeout{Duration: opts.Timeout}.String())
+
+	lock, err := c.doLock(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Token == "" {
+		return nil, fmt.Errorf("webdav: LOCK response has no lock token")
+	}
+	if lock.Root == "" {
+		lock.Root = req.URL.Path
+	}
+	c.holdLock(lock)
+	return lock, nil
+}
+
+// Refresh resets the timeout of a lock held by the client, e.g. periodically
+// while editing a file. The timeout of the lock is updated with the one
+// granted by the server.
+func (c *Client) Refresh(ctx context.Context, lock *Lock) error {
+	req, err := c.ic.NewRequest("LOCK", lock.Root, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("If", "("+internal.FormatLockToken(lock.Token)+")")
+	req.Header.Set("Timeout", internal.Timeout{Duration: lock.Timeout}.String())
+
+	refreshed, err := c.doLock(ctx, req)
+	if err != nil {
+		return err
+	}
+	lock.Timeout = refreshed.Timeout
+	c.holdLock(lock)
+	return nil
+}
+
+// Unlock releases a lock on a resource, and forgets it.
+func (c *Client) Unlock(ctx context.Context, name, token string) error {
+	req, err := c.ic.NewRequest("UNLOCK", name, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Lock-Token", internal.FormatLockToken(token))
+	_, err = c.do(ctx, req)
+	if err == nil || internal.HTTPErrorFromError(err).Code == http.StatusConflict {
+		// The lock doesn't exist anymore either way
+		c.mu.Lock()
+		delete(c.locks, token)
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// Locks returns the unexpired locks held by the client.
+func (c *Client) Locks() []Lock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var l []Lock
+	for token, held := range c.locks {
+		if !held.expires.IsZero() && now.After(held.expires) {
+			delete(c.locks, token)
+			continue
+		}
+		l = append(l, held.Lock)
+	}
+	slices.SortFunc(l, func(a, b Lock) int {
+		return strings.Compare(a.Root, b.Root)
+	})
+	return l
+}
+
+// holdLock remembers a lock taken or refreshed by the client.
+func (c *Client) holdLock(lock *Lock) {
+	held := heldLock{Lock: *lock}
+	if lock.Timeout > 0 {
+		held.expires = time.Now().Add(lock.Timeout)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locks == nil {
+		c.locks = make(map[string]heldLock)
+	}
+	c.locks[lock.Token] = held
+}
+
+// doLock sends a LOCK request and parses the lock it returns.
+func (c *Client) doLock(ctx context.Context, req *http.Request) (*Lock, error) {
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var prop internal.Prop
+	if err := xml.NewDecoder(resp.Body).Decode(&prop); err != nil {
+		return nil, fmt.Errorf("webdav: failed to decode LOCK response: %w", err)
+	}
+	var discovery internal.LockDiscovery
+	if err := prop.Decode(&discovery); err != nil {
+		return nil, err
+	} else if len(discovery.ActiveLock) == 0 {
+		return nil, fmt.Errorf("webdav: LOCK response has no active lock")
+	}
+
+	active := &discovery.ActiveLock[0]
+	lock := &Lock{
+		Root:  active.LockRoot.Href,
+		Depth: Depth(active.Depth),
+	}
+	if u, err := url.Parse(lock.Root); err == nil {
+		lock.Root = u.Path
+	}
+	if active.LockToken != nil {
+		lock.Token = active.LockToken.Href
+	}
+	if token, err := internal.ParseLockToken(resp.Header.Get("Lock-Token")); err == nil {
+		lock.Token = token
+	}
+	if active.Timeout != nil {
+		lock.Timeout = active.Timeout.Duration
+	}
+	return lock, nil
+}
+
+// submitLocks sets the If header field of a request modifying the resources
+// names, submitting the tokens of the held locks on them, their ancestors
+// and their descendants. Each token is tagged with the root of its lock.
+func (c *Client) submitLocks(req *http.Request, names ...string) {
+	var lists []string
+	for _, lock := range c.Locks() {
+		for _, name := range names {
+			p := c.ic.ResolveHref(name).Path
+			if lockCovers(lock.Root, p) || lockCovers(p, lock.Root) {
+				tag := c.ic.ResolveHref(lock.Root).String()
+				lists = append(lists, "<"+tag+"> ("+internal.FormatLockToken(lock.Token)+")")
+				break
+			}
+		}
+	}
+	if len(lists) > 0 {
+		req.Header.Set("If", strings.Join(lists, " "))
+	}
+}
+
+// lockCovers reports whether the resource p is root or one of its
+// descendants.
+func lockCovers(root, p string) bool {
+	root, p = path.Clean(root), path.Clean(p)
+	return root == p || root == "/" || strings.HasPrefix(p, root+"/")
+}
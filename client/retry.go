@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry retries requests failing with network errors or with the "429 Too
+// Many Requests", "502 Bad Gateway", "503 Service Unavailable" and "504
+// Gateway Timeout" statuses, waiting longer after each attempt. Only
+// idempotent requests are retried: GET, HEAD, OPTIONS, PROPFIND, REPORT, PUT,
+// DELETE and partial updates, and only if their body can be read again, e.g.
+// a *bytes.Reader or an *os.File.
+type Retry struct {
+	// MaxAttempts is the maximum number of attempts of a request, including
+	// the first one, defaults to 4.
+	MaxAttempts int
+	// MinDelay is the delay before the first retry, defaults to half a
+	// second. Delays double with each retry, with some jitter, and honor the
+	// Retry-After header field of responses.
+	MinDelay time.Duration
+	// MaxDelay caps delays, defaults to 30 seconds.
+	MaxDelay time.Duration
+}
+
+func (r *Retry) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return 4
+}
+
+func (r *Retry) minDelay() time.Duration {
+	if r.MinDelay > 0 {
+		return r.MinDelay
+	}
+	return 500 * time.Millisecond
+}
+
+func (r *Retry) maxDelay() time.Duration {
+	if r.MaxDelay > 0 {
+		return r.MaxDelay
+	}
+	return 30 * time.Second
+}
+
+// delay returns how long to wait before the attempt following attempt.
+func (r *Retry) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec >= 0 {
+			return min(time.Duration(sec)*time.Second, r.maxDelay())
+		}
+	}
+	d := r.minDelay()
+	for i := 1; i < attempt && d < r.maxDelay(); i++ {
+		d *= 2
+	}
+	// Spread the retries of concurrent requests over [d/2, d)
+	d = d/2 + rand.N(d/2+1)
+	return min(d, r.maxDelay())
+}
+
+// retryableMethods are the methods of the requests which can be sent again.
+var retryableMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+	"REPORT":           true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	// Partial updates with an explicit range
+	http.MethodPatch: true,
+}
+
+// retryable reports whether a request failed with a transient error.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryHTTPClient retries the requests sent with an HTTPClient according to
+// the Retry policy of a Client.
+type retryHTTPClient struct {
+	c    *Client
+	http HTTPClient
+}
+
+func (rc *retryHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	policy := rc.c.Retry
+	if policy == nil || !retryableMethods[req.Method] {
+		return rc.http.Do(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body can't be sent again
+		return rc.http.Do(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := rc.http.Do(req)
+		if attempt >= policy.maxAttempts() || !retryable(resp, err) {
+			return resp, err
+		}
+		delay := policy.delay(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,627 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// SyncOp is an operation performed by Sync.
+type SyncOp int
+
+const (
+	// SyncMkdir creates a directory or collection.
+	SyncMkdir SyncOp = iota
+	// SyncCopy copies a file from the source to the destination.
+	SyncCopy
+	// SyncRemove removes a file or directory missing from the source.
+	SyncRemove
+)
+
+// String returns the name of the operation.
+func (op SyncOp) String() string {
+	switch op {
+	case SyncMkdir:
+		return "mkdir"
+	case SyncCopy:
+		return "copy"
+	case SyncRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// SyncAction is an operation performed by Sync on a resource.
+type SyncAction struct {
+	Op SyncOp
+	// Path is the slash-separated path of the resource relative to the
+	// synced directories, "." for the directories themselves.
+	Path string
+}
+
+// SyncOptions are options for Client.Sync.
+type SyncOptions struct {
+	// Download mirrors the remote collection to the local directory, instead
+	// of the local directory to the remote collection.
+	Download bool
+	// Delete removes the destination files and directories missing from the
+	// source.
+	Delete bool
+	// DryRun only reports the actions a sync would perform.
+	DryRun bool
+	// State, if set, holds what the previous sync saw and is updated with
+	// this one. See SyncState.
+	State *SyncState
+	// Concurrency is the maximum number of files copied concurrently,
+	// defaults to 4. The HTTP client should keep at least as many idle
+	// connections per host, see NewHTTPClient.
+	Concurrency int
+}
+
+// defaultSyncConcurrency is the default of SyncOptions.Concurrency.
+const defaultSyncConcurrency = 4
+
+// SyncState records the files seen by a sync, so that the next one detects
+// changes with entity tags and, when downloading from servers supporting the
+// sync-collection report (RFC 6578), only lists the remote changes. It can
+// be persisted between syncs, e.g. encoded as JSON. A state must only be
+// reused with the same local directory, remote collection and direction.
+type SyncState struct {
+	// SyncToken is the sync-collection token of the remote collection.
+	SyncToken string
+	// Files maps the paths of synced files, relative to the synced
+	// directories, to their state.
+	Files map[string]SyncFile
+}
+
+// SyncFile is the state of a synced file.
+type SyncFile struct {
+	// ETag is the entity tag of the remote file.
+	ETag string
+	Size int64
+	// ModTime is the modification time of the local file.
+	ModTime time.Time
+}
+
+// syncEntry describes a file or directory on either side of a sync.
+type syncEntry struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+type syncer struct {
+	c       *Client
+	local   string
+	root    string // URL path of the remote collection
+	opts    *SyncOptions
+	actions []SyncAction
+	copies  []string // queued by queueCopy
+
+	mu    sync.Mutex
+	files map[string]SyncFile
+}
+
+// Sync mirrors the local directory to the remote collection, or the remote
+// collection to the local directory with SyncOptions.Download. Files are
+// copied if they're missing from the destination or if their size or
+// modification time differ, or, with a SyncState, if either side changed
+// since the previous sync. Modification times are preserved, with the
+// X-OC-Mtime header field on upload.
+//
+// Directories are created first, then files are copied concurrently, see
+// SyncOptions.Concurrency, and finally removals are performed.
+//
+// When downloading with a SyncState from a server supporting the
+// sync-collection report, only the remote changes since the previous sync
+// are applied, local changes are left alone.
+//
+// Sync returns the actions performed, or that would be performed with
+// SyncOptions.DryRun. On error, they include the copies that were queued but
+// canceled.
+func (c *Client) Sync(ctx context.Context, local, remote string, opts *SyncOptions) ([]SyncAction, error) {
+	if opts == nil {
+		opts = new(SyncOptions)
+	}
+	s := &syncer{
+		c:     c,
+		local: local,
+		root:  path.Clean(c.ic.ResolveHref(remote).Path),
+		opts:  opts,
+	}
+	if opts.State != nil {
+		if opts.State.Files == nil && !opts.DryRun {
+			opts.State.Files = make(map[string]SyncFile)
+		}
+		s.files = opts.State.Files
+	}
+
+	var err error
+	if opts.Download {
+		err = s.download(ctx)
+	} else {
+		err = s.upload(ctx)
+	}
+	return s.actions, err
+}
+
+// do records an action, and performs it unless it's a dry run.
+func (s *syncer) do(op SyncOp, rel string, f func() error) error {
+	s.actions = append(s.actions, SyncAction{Op: op, Path: rel})
+	if s.opts.DryRun {
+		return nil
+	}
+	return f()
+}
+
+// queueCopy records the copy of a file, performed later by transfer unless
+// it's a dry run.
+func (s *syncer) queueCopy(rel string) {
+	s.actions = append(s.actions, SyncAction{Op: SyncCopy, Path: rel})
+	if !s.opts.DryRun {
+		s.copies = append(s.copies, rel)
+	}
+}
+
+// transfer performs the queued copies with a pool of
+// SyncOptions.Concurrency workers. The first error cancels the other copies.
+func (s *syncer) transfer(ctx context.Context, copyFile func(ctx context.Context, rel string) error) error {
+	n := s.opts.Concurrency
+	if n <= 0 {
+		n = defaultSyncConcurrency
+	}
+	n = min(n, len(s.copies))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				if err := copyFile(ctx, rel); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+queue:
+	for _, rel := range s.copies {
+		select {
+		case jobs <- rel:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	s.copies = nil
+	return context.Cause(ctx)
+}
+
+// changed reports whether a file must be copied, given its local and
+// remote versions.
+func (s *syncer) changed(rel string, local, remote *syncEntry) bool {
+	if f, ok := s.files[rel]; ok && f.ETag != "" && remote.etag != "" {
+		return f.ETag != remote.etag || f.Size != local.size || !f.ModTime.Equal(local.modTime)
+	}
+	// Remote modification times have a one second precision
+	return local.size != remote.size || !local.modTime.Truncate(time.Second).Equal(remote.modTime.Truncate(time.Second))
+}
+
+// record updates the state of a synced file.
+func (s *syncer) record(rel string, f SyncFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files != nil {
+		s.files[rel] = f
+	}
+}
+
+// forget removes the state of a removed file or directory.
+func (s *syncer) forget(rel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.files {
+		if k == rel || strings.HasPrefix(k, rel+"/") {
+			delete(s.files, k)
+		}
+	}
+}
+
+func (s *syncer) localPath(rel string) string {
+	return filepath.Join(s.local, filepath.FromSlash(rel))
+}
+
+func (s *syncer) remotePath(rel string) string {
+	return path.Join(s.root, rel)
+}
+
+// localTree lists the directories and regular files below the local
+// directory. It returns nil if the directory doesn't exist.
+func (s *syncer) localTree() (map[string]*syncEntry, error) {
+	if _, err := os.Stat(s.local); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	tree := make(map[string]*syncEntry)
+	err := filepath.WalkDir(s.local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.local, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		tree[filepath.ToSlash(rel)] = &syncEntry{isDir: d.IsDir(), size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	return tree, err
+}
+
+// relPath returns the path of the remote resource p relative to the synced
+// collection.
+func (s *syncer) relPath(p string) (string, bool) {
+	p = path.Clean(p)
+	if s.root == "/" {
+		return strings.TrimPrefix(p, "/"), p != "/"
+	}
+	rel, ok := strings.CutPrefix(p, s.root+"/")
+	return rel, ok && rel != ""
+}
+
+func (s *syncer) addRemote(tree map[string]*syncEntry, res *Resource) {
+	if rel, ok := s.relPath(res.Path); ok {
+		tree[rel] = &syncEntry{isDir: res.IsDir, size: res.Size, modTime: res.ModTime, etag: res.ETag}
+	}
+}
+
+// remoteTree lists the descendants of the remote collection. Servers
+// refusing infinite depth PROPFIND requests are walked one level at a time.
+// It returns nil if the collection doesn't exist.
+func (s *syncer) remoteTree(ctx context.Context) (map[string]*syncEntry, error) {
+	tree := make(map[string]*syncEntry)
+	l, err := s.c.PropFind(ctx, s.root, DepthInfinity)
+	if internal.IsNotFound(err) {
+		return nil, nil
+	} else if err == nil {
+		for i := range l {
+			s.addRemote(tree, &l[i])
+		}
+		return tree, nil
+	} else if internal.HTTPErrorFromError(err).Code != http.StatusForbidden {
+		return nil, err
+	}
+
+	queue := []string{s.root}
+	for len(queue) > 0 {
+		l, err := s.c.PropFind(ctx, queue[0], DepthOne)
+		if internal.IsNotFound(err) && queue[0] == s.root {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		dir := path.Clean(queue[0])
+		queue = queue[1:]
+		for i := range l {
+			if path.Clean(l[i].Path) == dir {
+				continue
+			}
+			s.addRemote(tree, &l[i])
+			if l[i].IsDir {
+				queue = append(queue, l[i].Path)
+			}
+		}
+	}
+	return tree, nil
+}
+
+// remoteChanges lists the changes of the remote collection since the
+// previous sync with a sync-collection report, or all its members with a
+// token-less report. It returns false if the server doesn't support the
+// report.
+func (s *syncer) remoteChanges(ctx context.Context) (changed map[string]*syncEntry, removed []string, token string, ok bool, err error) {
+	prop := internal.NewPropNamePropFind(resourcePropNames...).Prop
+	ms, err := s.c.ic.SyncCollection(ctx, s.root, s.opts.State.SyncToken, internal.DepthInfinity, nil, prop)
+	if err != nil {
+		var httpErr *internal.HTTPError
+		if errors.As(err, &httpErr) {
+			// Unsupported report, or invalid token: fall back to PROPFIND
+			return nil, nil, "", false, nil
+		}
+		return nil, nil, "", false, err
+	}
+
+	changed = make(map[string]*syncEntry)
+	for i := range ms.Responses {
+		resp := &ms.Responses[i]
+		if resp.Status != nil && resp.Status.Code == http.StatusNotFound && len(resp.Hrefs) == 1 {
+			if rel, ok := s.relPath(resp.Hrefs[0].Path); ok {
+				removed = append(removed, rel)
+			}
+			continue
+		}
+		res, err := resourceFromResponse(resp, nil)
+		if err != nil {
+			return nil, nil, "", false, err
+		}
+		s.addRemote(changed, res)
+	}
+	return changed, removed, ms.SyncToken, true, nil
+}
+
+// upload mirrors the local directory to the remote collection.
+func (s *syncer) upload(ctx context.Context) error {
+	src, err := s.localTree()
+	if err != nil {
+		return err
+	} else if src == nil {
+		return &fs.PathError{Op: "sync", Path: s.local, Err: fs.ErrNotExist}
+	}
+	dst, err := s.remoteTree(ctx)
+	if err != nil {
+		return err
+	}
+	if dst == nil {
+		dst = make(map[string]*syncEntry)
+		if err := s.do(SyncMkdir, ".", func() error {
+			return s.c.Mkcol(ctx, s.root)
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range slices.Sorted(maps.Keys(src)) {
+		e, d := src[rel], dst[rel]
+		if d != nil && (e.isDir != d.isDir) {
+			// A file replaced a directory or the opposite
+			if err := s.do(SyncRemove, rel, func() error {
+				s.forget(rel)
+				return s.c.Delete(ctx, s.remotePath(rel), nil)
+			}); err != nil {
+				return err
+			}
+			d = nil
+		}
+		if e.isDir {
+			if d == nil {
+				if err := s.do(SyncMkdir, rel, func() error {
+					return s.c.Mkcol(ctx, s.remotePath(rel))
+				}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if d != nil && !s.changed(rel, e, d) {
+			continue
+		}
+		s.queueCopy(rel)
+	}
+	if err := s.transfer(ctx, func(ctx context.Context, rel string) error {
+		return s.uploadFile(ctx, rel, src[rel])
+	}); err != nil {
+		return err
+	}
+
+	if s.opts.Delete {
+		return s.removeMissing(dst, src, func(rel string) error {
+			return s.c.Delete(ctx, s.remotePath(rel), nil)
+		})
+	}
+	return nil
+}
+
+func (s *syncer) uploadFile(ctx context.Context, rel string, e *syncEntry) error {
+	f, err := os.Open(s.localPath(rel))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res, err := s.c.Put(ctx, s.remotePath(rel), f, &PutOptions{
+		ContentLength: e.size,
+		ModTime:       e.modTime,
+	})
+	if err != nil {
+		return err
+	}
+	s.record(rel, SyncFile{ETag: res.ETag, Size: e.size, ModTime: e.modTime})
+	return nil
+}
+
+// download mirrors the remote collection to the local directory.
+func (s *syncer) download(ctx context.Context) error {
+	var (
+		src     map[string]*syncEntry
+		removed []string
+		token   string
+		delta   bool
+	)
+	if s.opts.State != nil {
+		var ok bool
+		var err error
+		src, removed, token, ok, err = s.remoteChanges(ctx)
+		if err != nil {
+			return err
+		}
+		delta = ok && s.opts.State.SyncToken != ""
+		if !ok {
+			src = nil
+		}
+	}
+	if src == nil {
+		var err error
+		if src, err = s.remoteTree(ctx); err != nil {
+			return err
+		} else if src == nil {
+			return internal.HTTPErrorf(http.StatusNotFound, "webdav: collection %v not found", s.root)
+		}
+	}
+
+	dst, err := s.localTree()
+	if err != nil {
+		return err
+	}
+	if dst == nil {
+		dst = make(map[string]*syncEntry)
+		if err := s.do(SyncMkdir, ".", func() error {
+			return os.MkdirAll(s.local, 0o755)
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range slices.Sorted(maps.Keys(src)) {
+		e, d := src[rel], dst[rel]
+		if d != nil && (e.isDir != d.isDir) {
+			if err := s.do(SyncRemove, rel, func() error {
+				s.forget(rel)
+				return os.RemoveAll(s.localPath(rel))
+			}); err != nil {
+				return err
+			}
+			d = nil
+		}
+		if e.isDir {
+			if d == nil {
+				if err := s.do(SyncMkdir, rel, func() error {
+					return os.MkdirAll(s.localPath(rel), 0o755)
+				}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if d != nil && !s.changed(rel, d, e) {
+			continue
+		}
+		s.queueCopy(rel)
+	}
+	if err := s.transfer(ctx, func(ctx context.Context, rel string) error {
+		return s.downloadFile(ctx, rel, src[rel])
+	}); err != nil {
+		return err
+	}
+
+	if s.opts.Delete {
+		remove := func(rel string) error {
+			return os.RemoveAll(s.localPath(rel))
+		}
+		if delta {
+			slices.Sort(removed)
+			for _, rel := range removed {
+				if dst[rel] == nil {
+					continue
+				}
+				if err := s.do(SyncRemove, rel, func() error {
+					s.forget(rel)
+					return remove(rel)
+				}); err != nil {
+					return err
+				}
+			}
+		} else if err := s.removeMissing(dst, src, remove); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.State != nil && !s.opts.DryRun {
+		s.opts.State.SyncToken = token
+	}
+	return nil
+}
+
+func (s *syncer) downloadFile(ctx context.Context, rel string, e *syncEntry) error {
+	body, res, err := s.c.Get(ctx, s.remotePath(rel), nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	name := s.localPath(rel)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file, so that interrupted downloads don't leave
+	// truncated files behind
+	f, err := os.CreateTemp(filepath.Dir(name), ".sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	modTime := res.ModTime
+	if modTime.IsZero() {
+		modTime = e.modTime
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(f.Name(), time.Time{}, modTime); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	etag := res.ETag
+	if etag == "" {
+		etag = e.etag
+	}
+	s.record(rel, SyncFile{ETag: etag, Size: fi.Size(), ModTime: fi.ModTime()})
+	return nil
+}
+
+// removeMissing removes the destination entries missing from the source.
+// The descendants of removed directories are skipped.
+func (s *syncer) removeMissing(dst, src map[string]*syncEntry, remove func(rel string) error) error {
+	removed := make(map[string]bool)
+	for _, rel := range slices.Sorted(maps.Keys(dst)) {
+		if src[rel] != nil || removed[path.Dir(rel)] {
+			if removed[path.Dir(rel)] {
+				removed[rel] = true
+			}
+			continue
+		}
+		removed[rel] = true
+		if err := s.do(SyncRemove, rel, func() error {
+			s.forget(rel)
+			return remove(rel)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
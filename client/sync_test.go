@@ -0,0 +1,234 @@
+package client_test
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+	"github.com/Tryanks/fiber-webdav/client"
+)
+
+func writeFile(t *testing.T, name, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, time.Time{}, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkActions(t *testing.T, name string, got []client.SyncAction, err error, want ...client.SyncAction) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%v: Sync() = %v", name, err)
+	}
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%v: Sync() = %v, want %v", name, got, want)
+	}
+}
+
+func TestClient_Sync(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	c := newTestClient(t, ts)
+	local := t.TempDir()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	writeFile(t, filepath.Join(local, "a.txt"), "a", mtime)
+	writeFile(t, filepath.Join(local, "dir", "b.txt"), "b", mtime)
+
+	state := new(client.SyncState)
+	actions, err := c.Sync(ctx, local, "backup", &client.SyncOptions{State: state, DryRun: true})
+	dryRun := []client.SyncAction{
+		{Op: client.SyncMkdir, Path: "."},
+		{Op: client.SyncCopy, Path: "a.txt"},
+		{Op: client.SyncMkdir, Path: "dir"},
+		{Op: client.SyncCopy, Path: "dir/b.txt"},
+	}
+	checkActions(t, "dry run", actions, err, dryRun...)
+	if _, err := c.Stat(ctx, "backup"); err == nil {
+		t.Errorf("dry run: collection created")
+	}
+
+	actions, err = c.Sync(ctx, local, "backup", &client.SyncOptions{State: state})
+	checkActions(t, "upload", actions, err, dryRun...)
+	if res, err := c.Stat(ctx, "backup/dir/b.txt"); err != nil || !res.ModTime.Equal(mtime) {
+		t.Errorf("upload: Stat() = %+v, %v, want modification time %v", res, err, mtime)
+	}
+	if len(state.Files) != 2 || state.Files["dir/b.txt"].ETag == "" {
+		t.Errorf("upload: state = %+v", state)
+	}
+
+	actions, err = c.Sync(ctx, local, "backup", &client.SyncOptions{State: state})
+	checkActions(t, "unchanged", actions, err)
+
+	writeFile(t, filepath.Join(local, "a.txt"), "A", mtime.Add(time.Hour))
+	if err := os.RemoveAll(filepath.Join(local, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	actions, err = c.Sync(ctx, local, "backup", &client.SyncOptions{State: state})
+	checkActions(t, "changed", actions, err, client.SyncAction{Op: client.SyncCopy, Path: "a.txt"})
+	actions, err = c.Sync(ctx, local, "backup", &client.SyncOptions{State: state, Delete: true})
+	checkActions(t, "delete", actions, err, client.SyncAction{Op: client.SyncRemove, Path: "dir"})
+	if len(state.Files) != 1 {
+		t.Errorf("delete: state = %+v", state)
+	}
+
+	// Download without state, comparing sizes and modification times
+	mirror := filepath.Join(t.TempDir(), "mirror")
+	actions, err = c.Sync(ctx, mirror, "backup", &client.SyncOptions{Download: true})
+	checkActions(t, "download", actions, err,
+		client.SyncAction{Op: client.SyncMkdir, Path: "."},
+		client.SyncAction{Op: client.SyncCopy, Path: "a.txt"},
+	)
+	if b, err := os.ReadFile(filepath.Join(mirror, "a.txt")); err != nil || string(b) != "A" {
+		t.Errorf("download: a.txt = %q, %v", b, err)
+	}
+	actions, err = c.Sync(ctx, mirror, "backup", &client.SyncOptions{Download: true})
+	checkActions(t, "download unchanged", actions, err)
+}
+
+func TestClient_Sync_syncCollection(t *testing.T) {
+	ctx := context.Background()
+	h := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+	}
+	// A fake sync-collection report: the initial sync gets a.txt, the next
+	// one the removal of a.txt and the creation of b.txt
+	h.HandleReport(xml.Name{Space: "DAV:", Local: "sync-collection"}, func(w http.ResponseWriter, r *http.Request) error {
+		var query struct {
+			SyncToken string `xml:"sync-token"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&query); err != nil {
+			return err
+		}
+		file := func(name string) string {
+			return `<response><href>/dav/` + name + `</href><propstat><prop>` +
+				`<resourcetype/><getcontentlength>1</getcontentlength><getetag>"` + name + `"</getetag>` +
+				`</prop><status>HTTP/1.1 200 OK</status></propstat></response>`
+		}
+		var body string
+		switch query.SyncToken {
+		case "":
+			body = file("a.txt") + `<sync-token>t1</sync-token>`
+		case "t1":
+			body = `<response><href>/dav/a.txt</href><status>HTTP/1.1 404 Not Found</status></response>` +
+				file("b.txt") + `<sync-token>t2</sync-token>`
+		default:
+			return webdav.NewHTTPError(http.StatusForbidden, nil)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		_, err := io.WriteString(w, `<multistatus xmlns="DAV:">`+body+`</multistatus>`)
+		return err
+	})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	c := newTestClient(t, ts)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if _, err := c.Put(ctx, name, strings.NewReader(name[:1]), nil); err != nil {
+			t.Fatalf("Put() = %v", err)
+		}
+	}
+
+	local := t.TempDir()
+	state := new(client.SyncState)
+	opts := &client.SyncOptions{Download: true, Delete: true, State: state}
+	actions, err := c.Sync(ctx, local, "/dav/", opts)
+	checkActions(t, "initial", actions, err, client.SyncAction{Op: client.SyncCopy, Path: "a.txt"})
+	if state.SyncToken != "t1" {
+		t.Errorf("initial: sync token = %q, want t1", state.SyncToken)
+	}
+
+	actions, err = c.Sync(ctx, local, "/dav/", opts)
+	checkActions(t, "changes", actions, err,
+		client.SyncAction{Op: client.SyncCopy, Path: "b.txt"},
+		client.SyncAction{Op: client.SyncRemove, Path: "a.txt"},
+	)
+	if state.SyncToken != "t2" {
+		t.Errorf("changes: sync token = %q, want t2", state.SyncToken)
+	}
+	if _, err := os.Stat(filepath.Join(local, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("changes: a.txt not removed: %v", err)
+	}
+
+	// Unknown tokens fall back to PROPFIND
+	state.SyncToken = "expired"
+	actions, err = c.Sync(ctx, local, "/dav/", opts)
+	checkActions(t, "fallback", actions, err, client.SyncAction{Op: client.SyncCopy, Path: "a.txt"})
+}
+
+func TestClient_Sync_concurrency(t *testing.T) {
+	ctx := context.Background()
+	h := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+	}
+	var (
+		mu               sync.Mutex
+		inFlight, maxPut int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mu.Lock()
+			inFlight++
+			maxPut = max(maxPut, inFlight)
+			mu.Unlock()
+			// Simulate the latency of a remote server
+			time.Sleep(20 * time.Millisecond)
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := newTestClient(t, ts)
+
+	local := t.TempDir()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range 20 {
+		writeFile(t, filepath.Join(local, fmt.Sprintf("dir%d", i%3), fmt.Sprintf("%02d.txt", i)), "x", mtime)
+	}
+	state := new(client.SyncState)
+	actions, err := c.Sync(ctx, local, "/dav/backup", &client.SyncOptions{State: state, Concurrency: 8})
+	if err != nil {
+		t.Fatalf("Sync() = %v", err)
+	} else if len(actions) != 24 {
+		t.Errorf("Sync(): %v actions, want 24", len(actions))
+	}
+	if maxPut < 2 || maxPut > 8 {
+		t.Errorf("Sync(): %v concurrent uploads, want between 2 and 8", maxPut)
+	}
+	if len(state.Files) != 20 {
+		t.Errorf("Sync(): state has %v files, want 20", len(state.Files))
+	}
+
+	mirror := t.TempDir()
+	if _, err := c.Sync(ctx, mirror, "/dav/backup", &client.SyncOptions{Download: true, Concurrency: 8}); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(mirror, "dir1", "19.txt")); err != nil || string(b) != "x" {
+		t.Errorf("download: 19.txt = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// getRange sends a GET request for the content of a file from offset. If
+// ifRange is set, the request fails unless the file still has this entity
+// tag.
+func (c *Client) getRange(ctx context.Context, name string, offset int64, ifRange string) (*http.Request, *http.Response, error) {
+	req, err := c.ic.NewRequest(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		}
+	}
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset == 0 {
+		return req, resp, nil
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			resp.Body.Close()
+			return nil, nil, fmt.Errorf("webdav: invalid Content-Range %q for range starting at %v", resp.Header.Get("Content-Range"), offset)
+		}
+		return req, resp, nil
+	}
+	if ifRange != "" {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("webdav: %v changed while being downloaded", req.URL.Path)
+	}
+	// The server ignored the range, skip the beginning of the file
+	if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	if resp.ContentLength >= 0 {
+		resp.ContentLength -= offset
+	}
+	return req, resp, nil
+}
+
+// resumingBody is the body of a GET response, which requests the rest of the
+// file with a range request when reading fails.
+type resumingBody struct {
+	ctx         context.Context
+	c           *Client
+	name        string
+	body        io.ReadCloser
+	offset      int64 // in the file
+	size        int64 // -1 if unknown
+	etag        string
+	acceptRange bool
+	resumes     int
+	progress    func(transferred, total int64)
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if n > 0 && b.progress != nil {
+			b.progress(b.offset, b.size)
+		}
+		if err == nil || err == io.EOF || !b.resume() {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume replaces the body with the response to a range request for the
+// rest of the file, and reports whether it succeeded.
+func (b *resumingBody) resume() bool {
+	policy := b.c.Retry
+	if policy == nil || !b.acceptRange || b.resumes+1 >= policy.maxAttempts() {
+		return false
+	}
+	// Only strong entity tags guarantee that the parts fit together
+	if b.etag == "" || strings.HasPrefix(b.etag, "W/") {
+		return false
+	}
+	b.resumes++
+
+	t := time.NewTimer(policy.delay(b.resumes, nil))
+	select {
+	case <-b.ctx.Done():
+		t.Stop()
+		return false
+	case <-t.C:
+	}
+
+	_, resp, err := b.c.getRange(b.ctx, b.name, b.offset, b.etag)
+	if err != nil {
+		return false
+	}
+	b.body.Close()
+	b.body = resp.Body
+	return true
+}
+
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
+
+// setRewindable allows a request to be sent again if its body is an
+// io.Seeker, by seeking back to its current position.
+func setRewindable(req *http.Request, body io.Reader) {
+	s, ok := body.(io.Seeker)
+	if req.GetBody != nil || !ok {
+		return
+	}
+	off, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	// The transport closes request bodies, keep files open for retries
+	req.Body = io.NopCloser(body)
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := s.Seek(off, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(body), nil
+	}
+}
+
+// setProgress reports the bytes of the body of a request as they're sent,
+// starting at base.
+func setProgress(req *http.Request, progress func(transferred, total int64), base, total int64) {
+	if progress == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &progressReader{ReadCloser: req.Body, n: base, total: total, progress: progress}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressReader{ReadCloser: body, n: base, total: total, progress: progress}, nil
+		}
+	}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	n, total int64
+	progress func(transferred, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.progress(r.n, r.total)
+	}
+	return n, err
+}
+
+// supportsPartialUpdates reports whether the server advertises SabreDAV
+// partial updates. The answer is cached once known.
+func (c *Client) supportsPartialUpdates(ctx context.Context) bool {
+	c.mu.Lock()
+	known := c.partialUpdates
+	c.mu.Unlock()
+	if known != nil {
+		return *known
+	}
+
+	classes, _, err := c.ic.Options(ctx, "")
+	if err != nil {
+		return false
+	}
+	ok := classes["sabredav-partialupdate"]
+	c.mu.Lock()
+	c.partialUpdates = &ok
+	c.mu.Unlock()
+	return ok
+}
+
+// putChunks uploads a file with a PUT request for its first chunk, followed
+// by PATCH requests appending the other chunks, as specified by SabreDAV
+// partial updates.
+func (c *Client) putChunks(ctx context.Context, name string, body io.Reader, opts *PutOptions) (*Resource, error) {
+	buf := make([]byte, opts.ChunkSize)
+	var (
+		req  *http.Request
+		resp *http.Response
+	)
+	for off := int64(0); off < opts.ContentLength; {
+		n, err := io.ReadFull(body, buf[:min(opts.ChunkSize, opts.ContentLength-off)])
+		if err != nil {
+			return nil, err
+		}
+		chunk := bytes.NewReader(buf[:n])
+		if off == 0 {
+			req, err = c.newPutRequest(http.MethodPut, name, chunk, opts)
+			if err != nil {
+				return nil, err
+			}
+			setConditional(req, opts.IfMatch, opts.IfNoneMatch)
+		} else {
+			req, err = c.newPutRequest(http.MethodPatch, name, chunk, opts)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-sabredav-partialupdate")
+			req.Header.Set("X-Update-Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(n)-1))
+		}
+		setProgress(req, opts.Progress, off, opts.ContentLength)
+		if resp, err = c.do(ctx, req); err != nil {
+			return nil, err
+		}
+		off += int64(n)
+	}
+
+	res := resourceFromHeader(req, resp)
+	res.MIMEType = opts.ContentType
+	if resp.Header.Get("X-OC-Mtime") == "accepted" {
+		res.ModTime = opts.ModTime
+	}
+	res.Size = opts.ContentLength
+	return res, nil
+}
@@ -0,0 +1,204 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+	"github.com/Tryanks/fiber-webdav/client"
+)
+
+type httpClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// unavailable fails requests with "503 Service Unavailable".
+func unavailable(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	failures := 0
+	c, err := client.New(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		if failures > 0 {
+			failures--
+			return unavailable(req), nil
+		}
+		return ts.Client().Do(req)
+	}), ts.URL+"/dav/")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Retry = &client.Retry{MaxAttempts: 3, MinDelay: time.Millisecond}
+
+	failures = 2
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("hello"), nil); err != nil {
+		t.Errorf("Put() = %v", err)
+	}
+	if res, err := c.Stat(ctx, "a.txt"); err != nil || res.Size != 5 {
+		t.Errorf("Stat() = %+v, %v, want a 5 bytes file", res, err)
+	}
+
+	failures = 3
+	if _, err := c.Stat(ctx, "a.txt"); !errors.Is(err, webdav.NewHTTPError(http.StatusServiceUnavailable, nil)) {
+		t.Errorf("Stat() = %v, want service unavailable", err)
+	}
+	// MKCOL isn't idempotent
+	failures = 1
+	if err := c.Mkcol(ctx, "dir"); err == nil {
+		t.Errorf("Mkcol() succeeded, want service unavailable")
+	}
+}
+
+func TestClient_Get_resume(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	var ranges []string
+	c, err := client.New(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := ts.Client().Do(req)
+		if err != nil || req.Method != http.MethodGet {
+			return resp, err
+		}
+		ranges = append(ranges, req.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// The connection breaks after a few bytes
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(io.LimitReader(resp.Body, 3), iotest.ErrReader(io.ErrUnexpectedEOF)), resp.Body}
+		}
+		return resp, nil
+	}), ts.URL+"/dav/")
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	c.Retry = &client.Retry{MinDelay: time.Millisecond}
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("hello world"), nil); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+
+	var transferred, total int64
+	body, res, err := c.Get(ctx, "a.txt", &client.GetOptions{
+		Progress: func(n, size int64) { transferred, total = n, size },
+	})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	b, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(b) != "hello world" {
+		t.Errorf("Get() = %q, %v, want %q", b, err, "hello world")
+	}
+	if want := []string{"", "bytes=3-"}; fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("Get(): ranges = %q, want %q", ranges, want)
+	}
+	if res.Size != 11 || transferred != 11 || total != 11 {
+		t.Errorf("Get(): size = %v, progress = %v/%v, want 11", res.Size, transferred, total)
+	}
+
+	body, res, err = c.Get(ctx, "a.txt", &client.GetOptions{Offset: 6})
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	b, err = io.ReadAll(body)
+	body.Close()
+	if err != nil || string(b) != "world" || res.Size != 11 {
+		t.Errorf("Get() = %q, %v, size %v, want %q and size 11", b, err, res.Size, "world")
+	}
+}
+
+// partialUpdateServer is a minimal server supporting SabreDAV partial
+// updates of a single file.
+type partialUpdateServer struct {
+	mu       sync.Mutex
+	content  []byte
+	requests []string
+	failures int
+}
+
+func (s *partialUpdateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 && r.Method == http.MethodPatch {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 2, sabredav-partialupdate")
+	case http.MethodPut:
+		s.requests = append(s.requests, "PUT "+string(b))
+		s.content = b
+	case http.MethodPatch:
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("X-Update-Range"), "bytes=%d-%d", &start, &end); err != nil || start != len(s.content) || end-start+1 != len(b) {
+			http.Error(w, "invalid range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		s.requests = append(s.requests, "PATCH "+string(b))
+		s.content = append(s.content, b...)
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(s.content)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestClient_Put_chunks(t *testing.T) {
+	ctx := context.Background()
+	s := &partialUpdateServer{failures: 1}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := newTestClient(t, ts)
+	c.Retry = &client.Retry{MinDelay: time.Millisecond}
+
+	var progress []int64
+	res, err := c.Put(ctx, "a.txt", iotest.OneByteReader(strings.NewReader("hello world")), &client.PutOptions{
+		ContentLength: 11,
+		ChunkSize:     4,
+		Progress:      func(n, total int64) { progress = append(progress, n) },
+	})
+	if err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if want := []string{"PUT hell", "PATCH o wo", "PATCH rld"}; fmt.Sprint(s.requests) != fmt.Sprint(want) {
+		t.Errorf("Put(): requests = %q, want %q", s.requests, want)
+	}
+	if string(s.content) != "hello world" || res.Size != 11 || res.ETag != `"11"` {
+		t.Errorf("Put() = %+v, content %q", res, s.content)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 11 {
+		t.Errorf("Put(): progress = %v, want to end at 11", progress)
+	}
+
+	// Small files are uploaded at once
+	s.requests = nil
+	if _, err := c.Put(ctx, "a.txt", strings.NewReader("hi"), &client.PutOptions{ContentLength: 2, ChunkSize: 4}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if want := []string{"PUT hi"}; fmt.Sprint(s.requests) != fmt.Sprint(want) {
+		t.Errorf("Put(): requests = %q, want %q", s.requests, want)
+	}
+}
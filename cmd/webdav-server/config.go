@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// config is the configuration of the server, loaded from a YAML or TOML file.
+type config struct {
+	// Listen is the address to listen on.
+	Listen string `yaml:"listen" toml:"listen"`
+	// TLS enables HTTPS.
+	TLS *tlsConfig `yaml:"tls" toml:"tls"`
+	// Users enables HTTP basic authentication with the given credentials.
+	Users []userConfig `yaml:"users" toml:"users"`
+	// UsersFile is a users file, see usersFile. It enables HTTP basic
+	// authentication.
+	UsersFile string `yaml:"users_file" toml:"users_file"`
+	// Mounts are the directories served.
+	Mounts []mountConfig `yaml:"mounts" toml:"mounts"`
+	// ShutdownTimeout is the time given to in-flight requests to complete
+	// when the server is stopped.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// tlsConfig configures HTTPS, either with a certificate and key files or
+// with certificates obtained automatically.
+type tlsConfig struct {
+	Cert     string          `yaml:"cert" toml:"cert"`
+	Key      string          `yaml:"key" toml:"key"`
+	Autocert *autocertConfig `yaml:"autocert" toml:"autocert"`
+}
+
+type userConfig struct {
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type mountConfig struct {
+	Prefix   string `yaml:"prefix" toml:"prefix"`
+	Root     string `yaml:"root" toml:"root"`
+	ReadOnly bool   `yaml:"readonly" toml:"readonly"`
+	// Lock enables WebDAV locking, defaults to true.
+	Lock *bool `yaml:"lock" toml:"lock"`
+}
+
+// lock reports whether locking is enabled on the mount.
+func (m *mountConfig) lock() bool {
+	return m.Lock == nil || *m.Lock
+}
+
+// webdavConfig returns the configuration of the mount serving fs.
+func (m *mountConfig) webdavConfig(fs webdav.FileSystem) webdav.Config {
+	return webdav.Config{
+		Prefix:   m.Prefix,
+		Root:     fs,
+		Lock:     m.lock(),
+		ReadOnly: m.ReadOnly,
+	}
+}
+
+// loadConfig reads a configuration file. Its format is guessed from its
+// extension: ".toml" for TOML, YAML otherwise. Defaults aren't set.
+func loadConfig(name string) (*config, error) {
+	var cfg config
+	if err := decodeFile(name, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// decodeFile decodes a YAML or TOML file into v, depending on its extension.
+// Unknown fields are rejected.
+func decodeFile(name string, v interface{}) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml":
+		md, err := toml.Decode(string(data), v)
+		if err != nil {
+			return fmt.Errorf("failed to parse %v: %w", name, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("failed to parse %v: unknown field %q", name, undecoded[0].String())
+		}
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(v); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to parse %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setDefaults fills in the unset fields. By default, the current directory is
+// served on port 8080.
+func (cfg *config) setDefaults() {
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+		if cfg.TLS != nil && cfg.TLS.Autocert != nil {
+			cfg.Listen = ":443"
+		}
+	}
+	if cfg.TLS != nil && cfg.TLS.Autocert != nil && cfg.TLS.Autocert.CacheDir == "" {
+		cfg.TLS.Autocert.CacheDir = defaultAutocertCacheDir()
+	}
+	if len(cfg.Mounts) == 0 {
+		cfg.Mounts = []mountConfig{{}}
+	}
+	for i := range cfg.Mounts {
+		m := &cfg.Mounts[i]
+		if m.Prefix == "" {
+			m.Prefix = "/"
+		}
+		if m.Root == "" {
+			m.Root = "."
+		}
+	}
+}
+
+func (cfg *config) validate() error {
+	if tls := cfg.TLS; tls != nil {
+		if tls.Autocert != nil {
+			if tls.Cert != "" || tls.Key != "" {
+				return fmt.Errorf("tls autocert can't be used with cert and key")
+			}
+			if len(tls.Autocert.Domains) == 0 {
+				return fmt.Errorf("tls autocert requires domains")
+			}
+		} else if tls.Cert == "" || tls.Key == "" {
+			return fmt.Errorf("tls requires both cert and key")
+		}
+	}
+
+	usernames := make(map[string]bool)
+	for _, u := range cfg.Users {
+		if u.Username == "" {
+			return fmt.Errorf("user without username")
+		}
+		if usernames[u.Username] {
+			return fmt.Errorf("duplicate user %q", u.Username)
+		}
+		usernames[u.Username] = true
+	}
+
+	prefixes := make(map[string]bool)
+	for _, m := range cfg.Mounts {
+		prefix := filepath.ToSlash(filepath.Clean("/" + m.Prefix))
+		if prefixes[prefix] {
+			return fmt.Errorf("duplicate mount prefix %q", m.Prefix)
+		}
+		prefixes[prefix] = true
+
+		fi, err := os.Stat(m.Root)
+		if err != nil {
+			return fmt.Errorf("mount %q: %w", m.Prefix, err)
+		} else if !fi.IsDir() {
+			return fmt.Errorf("mount %q: root %q is not a directory", m.Prefix, m.Root)
+		}
+	}
+	return nil
+}
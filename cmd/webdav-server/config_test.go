@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	name = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadConfig(t *testing.T) {
+	const yamlConfig = `
+listen: ":9090"
+users:
+  - username: alice
+    password: secret
+mounts:
+  - prefix: /dav
+    root: /srv/dav
+    readonly: true
+    lock: false
+  - root: /srv/other
+`
+	const tomlConfig = `
+listen = ":9090"
+
+[[users]]
+username = "alice"
+password = "secret"
+
+[[mounts]]
+prefix = "/dav"
+root = "/srv/dav"
+readonly = true
+lock = false
+
+[[mounts]]
+root = "/srv/other"
+`
+	for name, content := range map[string]string{"webdav.yaml": yamlConfig, "webdav.TOML": tomlConfig} {
+		cfg, err := loadConfig(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("%v: loadConfig() = %v", name, err)
+		}
+		if cfg.Listen != ":9090" {
+			t.Errorf("%v: Listen = %q, want :9090", name, cfg.Listen)
+		}
+		if len(cfg.Users) != 1 || cfg.Users[0] != (userConfig{Username: "alice", Password: "secret"}) {
+			t.Errorf("%v: Users = %+v", name, cfg.Users)
+		}
+		if len(cfg.Mounts) != 2 {
+			t.Fatalf("%v: Mounts = %+v, want 2 mounts", name, cfg.Mounts)
+		}
+		if m := cfg.Mounts[0]; m.Prefix != "/dav" || m.Root != "/srv/dav" || !m.ReadOnly || m.lock() {
+			t.Errorf("%v: first mount = %+v", name, m)
+		}
+		if m := cfg.Mounts[1]; m.Prefix != "" || m.ReadOnly || !m.lock() {
+			t.Errorf("%v: second mount = %+v, want locking enabled by default", name, m)
+		}
+	}
+
+	// An empty file is valid
+	if _, err := loadConfig(writeConfig(t, "empty.yml", "")); err != nil {
+		t.Errorf("loadConfig() of an empty file = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, content string
+	}{
+		{"unknown.yaml", "listen: :80\nport: 80\n"},
+		{"unknown.toml", "port = 80\n"},
+		{"invalid.yaml", "mounts: [\n"},
+		{"invalid.toml", "listen = \n"},
+		{"type.yaml", "mounts: yes\n"},
+	} {
+		if _, err := loadConfig(writeConfig(t, tc.name, tc.content)); err == nil {
+			t.Errorf("loadConfig(%q) = nil, want an error", tc.name)
+		} else if !strings.Contains(err.Error(), tc.name) {
+			t.Errorf("loadConfig(%q) = %v, want the file name in the error", tc.name, err)
+		}
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("loadConfig() of a missing file = %v, want a not exist error", err)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	var cfg config
+	cfg.setDefaults()
+	if cfg.Listen != ":8080" {
+		t.Errorf("Listen = %q, want :8080", cfg.Listen)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want 30s", cfg.ShutdownTimeout)
+	}
+	if len(cfg.Mounts) != 1 || cfg.Mounts[0].Prefix != "/" || cfg.Mounts[0].Root != "." || !cfg.Mounts[0].lock() {
+		t.Errorf("Mounts = %+v, want the current directory on /", cfg.Mounts)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() of the defaults = %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{"valid", config{Users: []userConfig{{Username: "alice"}}, Mounts: []mountConfig{{Prefix: "/a", Root: dir}, {Prefix: "/b", Root: dir}}}, ""},
+		{"no username", config{Users: []userConfig{{Password: "secret"}}}, "without username"},
+		{"duplicate user", config{Users: []userConfig{{Username: "alice"}, {Username: "alice"}}}, "duplicate user"},
+		{"duplicate prefix", config{Mounts: []mountConfig{{Prefix: "/a", Root: dir}, {Prefix: "a/", Root: dir}}}, "duplicate mount prefix"},
+		{"missing root", config{Mounts: []mountConfig{{Prefix: "/a", Root: filepath.Join(dir, "missing")}}}, "no such file"},
+		{"file root", config{Mounts: []mountConfig{{Prefix: "/a", Root: file}}}, "not a directory"},
+	}
+	for _, tc := range tests {
+		err := tc.cfg.validate()
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%v: validate() = %v", tc.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: validate() = %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options are the command-line flags. Each flag defaults to an environment
+// variable, and overrides the configuration file.
+type options struct {
+	config   string
+	addr     string
+	root     string
+	prefix   string
+	readOnly bool
+	auth     string
+	users    string
+
+	shutdownTimeout time.Duration
+
+	tlsCert         string
+	tlsKey          string
+	autocertDomains string
+	autocertCache   string
+}
+
+func parseOptions() (*options, error) {
+	readOnly := false
+	if v := os.Getenv("WEBDAV_READONLY"); v != "" {
+		var err error
+		if readOnly, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid WEBDAV_READONLY: %w", err)
+		}
+	}
+
+	var shutdownTimeout time.Duration
+	if v := os.Getenv("WEBDAV_SHUTDOWN_TIMEOUT"); v != "" {
+		var err error
+		if shutdownTimeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid WEBDAV_SHUTDOWN_TIMEOUT: %w", err)
+		}
+	}
+
+	var opts options
+	flag.StringVar(&opts.config, "config", os.Getenv("WEBDAV_CONFIG"), "path to a YAML or TOML configuration file (env WEBDAV_CONFIG)")
+	flag.StringVar(&opts.addr, "addr", os.Getenv("WEBDAV_ADDR"), `address to listen on, defaults to ":8080" (env WEBDAV_ADDR)`)
+	flag.StringVar(&opts.root, "root", os.Getenv("WEBDAV_ROOT"), `directory to serve, defaults to "." (env WEBDAV_ROOT)`)
+	flag.StringVar(&opts.prefix, "prefix", os.Getenv("WEBDAV_PREFIX"), `URL path prefix to serve the directory on, defaults to "/" (env WEBDAV_PREFIX)`)
+	flag.BoolVar(&opts.readOnly, "readonly", readOnly, "reject requests modifying files (env WEBDAV_READONLY)")
+	flag.StringVar(&opts.auth, "auth", os.Getenv("WEBDAV_AUTH"), "require HTTP basic authentication with the credentials user:pass (env WEBDAV_AUTH)")
+	flag.StringVar(&opts.users, "users-file", os.Getenv("WEBDAV_USERS_FILE"), "users file with bcrypt password hashes and per-user scopes, reloaded on SIGHUP (env WEBDAV_USERS_FILE)")
+	flag.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", shutdownTimeout, "time given to in-flight requests to complete on SIGINT or SIGTERM, defaults to 30s (env WEBDAV_SHUTDOWN_TIMEOUT)")
+	flag.StringVar(&opts.tlsCert, "tls-cert", os.Getenv("WEBDAV_TLS_CERT"), "TLS certificate file, enables HTTPS (env WEBDAV_TLS_CERT)")
+	flag.StringVar(&opts.tlsKey, "tls-key", os.Getenv("WEBDAV_TLS_KEY"), "TLS private key file (env WEBDAV_TLS_KEY)")
+	flag.StringVar(&opts.autocertDomains, "autocert-domains", os.Getenv("WEBDAV_AUTOCERT_DOMAINS"), "comma-separated domains to obtain Let's Encrypt certificates for, enables HTTPS (env WEBDAV_AUTOCERT_DOMAINS)")
+	flag.StringVar(&opts.autocertCache, "autocert-cache", os.Getenv("WEBDAV_AUTOCERT_CACHE"), "directory storing Let's Encrypt certificates (env WEBDAV_AUTOCERT_CACHE)")
+	flag.Parse()
+	return &opts, nil
+}
+
+// apply overrides the configuration with the options.
+func (opts *options) apply(cfg *config) error {
+	if opts.addr != "" {
+		cfg.Listen = opts.addr
+	}
+
+	if opts.root != "" || opts.prefix != "" {
+		switch len(cfg.Mounts) {
+		case 0:
+			cfg.Mounts = []mountConfig{{}}
+		case 1:
+		default:
+			return fmt.Errorf("-root and -prefix can't be used with several mounts")
+		}
+		if opts.root != "" {
+			cfg.Mounts[0].Root = opts.root
+		}
+		if opts.prefix != "" {
+			cfg.Mounts[0].Prefix = opts.prefix
+		}
+	}
+
+	if opts.readOnly {
+		if len(cfg.Mounts) == 0 {
+			cfg.Mounts = []mountConfig{{}}
+		}
+		for i := range cfg.Mounts {
+			cfg.Mounts[i].ReadOnly = true
+		}
+	}
+
+	if opts.tlsCert != "" || opts.tlsKey != "" {
+		if opts.autocertDomains != "" {
+			return fmt.Errorf("-tls-cert and -tls-key can't be used with -autocert-domains")
+		}
+		cfg.TLS = &tlsConfig{Cert: opts.tlsCert, Key: opts.tlsKey}
+	} else if opts.autocertDomains != "" {
+		cfg.TLS = &tlsConfig{Autocert: &autocertConfig{
+			Domains:  strings.Split(opts.autocertDomains, ","),
+			CacheDir: opts.autocertCache,
+		}}
+	} else if opts.autocertCache != "" && cfg.TLS != nil && cfg.TLS.Autocert != nil {
+		cfg.TLS.Autocert.CacheDir = opts.autocertCache
+	}
+
+	if opts.shutdownTimeout != 0 {
+		cfg.ShutdownTimeout = opts.shutdownTimeout
+	}
+
+	if opts.users != "" {
+		cfg.UsersFile = opts.users
+	}
+
+	if opts.auth != "" {
+		username, password, ok := strings.Cut(opts.auth, ":")
+		if !ok || username == "" {
+			return fmt.Errorf("-auth must be formatted as user:pass")
+		}
+		cfg.Users = append(cfg.Users, userConfig{Username: username, Password: password})
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsApply(t *testing.T) {
+	cfg := &config{
+		Listen: ":9090",
+		Users:  []userConfig{{Username: "alice", Password: "secret"}},
+		Mounts: []mountConfig{{Prefix: "/dav", Root: "/srv/dav"}},
+	}
+	opts := &options{
+		addr:            ":8443",
+		root:            "/srv/files",
+		readOnly:        true,
+		auth:            "bob:pass:word",
+		users:           "users.yaml",
+		shutdownTimeout: time.Minute,
+	}
+	if err := opts.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := &config{
+		Listen:          ":8443",
+		Users:           []userConfig{{Username: "alice", Password: "secret"}, {Username: "bob", Password: "pass:word"}},
+		UsersFile:       "users.yaml",
+		Mounts:          []mountConfig{{Prefix: "/dav", Root: "/srv/files", ReadOnly: true}},
+		ShutdownTimeout: time.Minute,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	// Flags create the mount of the configuration without file
+	cfg = &config{}
+	if err := (&options{prefix: "/dav"}).apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Mounts) != 1 || cfg.Mounts[0].Prefix != "/dav" {
+		t.Errorf("Mounts = %+v, want a mount on /dav", cfg.Mounts)
+	}
+
+	// Options left empty don't override the configuration
+	cfg = &config{Listen: ":9090", Mounts: []mountConfig{{Root: "/a"}, {Prefix: "/b", Root: "/b"}}}
+	if err := (&options{}).apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":9090" || len(cfg.Mounts) != 2 || cfg.Mounts[0].ReadOnly {
+		t.Errorf("config = %+v, want it unchanged", cfg)
+	}
+}
+
+func TestOptionsApplyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts options
+		want string
+	}{
+		{"root with several mounts", options{root: "/srv"}, "several mounts"},
+		{"prefix with several mounts", options{prefix: "/dav"}, "several mounts"},
+		{"auth without password", options{auth: "alice"}, "user:pass"},
+		{"auth without username", options{auth: ":secret"}, "user:pass"},
+	}
+	for _, tc := range tests {
+		cfg := &config{Mounts: []mountConfig{{Prefix: "/a"}, {Prefix: "/b"}}}
+		if err := tc.opts.apply(cfg); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: apply() = %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestParseOptionsEnv(t *testing.T) {
+	for _, env := range []string{"WEBDAV_READONLY", "WEBDAV_SHUTDOWN_TIMEOUT"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, "invalid")
+			if _, err := parseOptions(); err == nil || !strings.Contains(err.Error(), env) {
+				t.Errorf("parseOptions() = %v, want an error about %v", err, env)
+			}
+		})
+	}
+}
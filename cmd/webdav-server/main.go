@@ -1,26 +1,154 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/Tryanks/fiber-webdav"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/basicauth"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
 func main() {
+	opts, err := parseOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := new(config)
+	if opts.config != "" {
+		cfg, err = loadConfig(opts.config)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := opts.apply(cfg); err != nil {
+		log.Fatal(err)
+	}
+	cfg.setDefaults()
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
 	app := fiber.New(fiber.Config{
 		RequestMethods: webdav.ExtendedMethods,
 	})
 	app.Use(logger.New())
 
-	app.Use("/", webdav.New(webdav.Config{
-		Prefix: "/",
-		Root:   webdav.LocalFileSystem("."),
-		Lock:   true,
-	}))
+	var users *userStore
+	passwords := plainPasswords(cfg.Users)
+	// Slow down password guessing
+	throttle := &webdav.AuthThrottle{}
+	if cfg.UsersFile != "" {
+		users, err = loadUsersFile(cfg.UsersFile, cfg.Mounts, cfg.Users)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reloadOnSIGHUP(users)
+
+		app.Use(throttle.Middleware(), basicAuth(func(username, password string) bool {
+			return users.authorize(username, password) || passwords(username, password)
+		}), setUser, users.serve)
+	} else if len(cfg.Users) > 0 {
+		app.Use(throttle.Middleware(), basicAuth(passwords), setUser)
+	}
+
+	servers := make([]*webdav.Server, len(cfg.Mounts))
+	for i, m := range cfg.Mounts {
+		fs, err := webdav.NewRootFileSystem(m.Root, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		servers[i] = webdav.NewServer(m.webdavConfig(fs))
+	}
+	app.Use(webdav.Mounts(servers...))
+
+	stopped := shutdownOnSignal(app, cfg.ShutdownTimeout, func() []*webdav.Server {
+		if users == nil {
+			return servers
+		}
+		return append(users.servers(), servers...)
+	})
 
-	err := app.Listen(":8080")
+	switch {
+	case cfg.TLS == nil:
+		err = app.Listen(cfg.Listen)
+	case cfg.TLS.Autocert != nil:
+		ln, lnErr := autocertListener(cfg.Listen, cfg.TLS.Autocert)
+		if lnErr != nil {
+			log.Fatal(lnErr)
+		}
+		err = app.Listener(ln)
+	default:
+		err = app.ListenTLS(cfg.Listen, cfg.TLS.Cert, cfg.TLS.Key)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	<-stopped
+}
+
+// shutdownOnSignal gracefully shuts down the app on SIGINT or SIGTERM: the
+// listeners are closed, in-flight requests get up to timeout to complete and
+// the WebDAV servers are shut down. The returned channel is closed once done.
+func shutdownOnSignal(app *fiber.App, timeout time.Duration, servers func() []*webdav.Server) <-chan struct{} {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sig := <-ch
+		signal.Stop(ch)
+		log.Infof("received %v, shutting down", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := app.ShutdownWithContext(ctx); err != nil {
+			log.Errorf("failed to shut down: %v", err)
+		}
+		for _, s := range servers() {
+			if err := s.Shutdown(ctx); err != nil {
+				log.Errorf("failed to shut down WebDAV server: %v", err)
+			}
+		}
+	}()
+	return stopped
+}
+
+// basicAuth requires HTTP basic authentication, with credentials checked by
+// authorize.
+func basicAuth(authorize func(username, password string) bool) fiber.Handler {
+	return basicauth.New(basicauth.Config{
+		Realm:      "WebDAV",
+		Authorizer: authorize,
+	})
+}
+
+// plainPasswords checks credentials against the users of the configuration
+// file.
+func plainPasswords(users []userConfig) func(username, password string) bool {
+	passwords := make(map[string]string, len(users))
+	for _, u := range users {
+		passwords[u.Username] = u.Password
+	}
+	return func(username, password string) bool {
+		want, ok := passwords[username]
+		return ok && subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1
+	}
+}
+
+// setUser makes the user authenticated by basicAuth available to the WebDAV
+// handler.
+func setUser(c *fiber.Ctx) error {
+	if username, ok := c.Locals("username").(string); ok {
+		webdav.SetUser(c, username)
+	}
+	return c.Next()
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	s := webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(t.TempDir()), Lock: true})
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods, DisableStartupMessage: true})
+	app.Use(s.FiberHandler())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listening := make(chan error, 1)
+	go func() {
+		listening <- app.Listener(ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	stopped := shutdownOnSignal(app, time.Second, func() []*webdav.Server {
+		return []*webdav.Server{s}
+	})
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Skip(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shut down after SIGTERM")
+	}
+	if err := <-listening; err != nil {
+		t.Errorf("Listener() = %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+		t.Errorf("GET after shutdown succeeded, want the listener closed")
+	}
+
+	// The WebDAV servers are shut down too
+	w := httptest.NewRecorder()
+	s.HTTPHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET on the WebDAV server after shutdown = %v, want 503", w.Code)
+	}
+}
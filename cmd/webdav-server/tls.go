@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// autocertConfig obtains certificates automatically from Let's Encrypt.
+type autocertConfig struct {
+	// Domains are the host names certificates are requested for.
+	Domains []string `yaml:"domains" toml:"domains"`
+	// CacheDir stores the account key and certificates.
+	CacheDir string `yaml:"cache_dir" toml:"cache_dir"`
+	// Email is the contact address of the ACME account, optional.
+	Email string `yaml:"email" toml:"email"`
+}
+
+// defaultAutocertCacheDir returns the default directory for autocert data,
+// in the user cache directory if available.
+func defaultAutocertCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "autocert"
+	}
+	return filepath.Join(dir, "webdav-server", "autocert")
+}
+
+// autocertListener listens for TLS connections on addr with certificates
+// obtained for the configured domains. Challenges are answered with the
+// TLS-ALPN-01 method, so addr must be reachable on port 443.
+func autocertListener(addr string, cfg *autocertConfig) (net.Listener, error) {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		Email:      cfg.Email,
+	}
+	tlsConfig := m.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tls.Listen("tcp", addr, tlsConfig)
+}
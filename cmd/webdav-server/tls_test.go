@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	mounts := []mountConfig{{Prefix: "/", Root: dir}}
+	tests := []struct {
+		name string
+		tls  tlsConfig
+		want string
+	}{
+		{"cert and key", tlsConfig{Cert: "cert.pem", Key: "key.pem"}, ""},
+		{"autocert", tlsConfig{Autocert: &autocertConfig{Domains: []string{"example.com"}}}, ""},
+		{"cert without key", tlsConfig{Cert: "cert.pem"}, "both cert and key"},
+		{"key without cert", tlsConfig{Key: "key.pem"}, "both cert and key"},
+		{"autocert with cert", tlsConfig{Cert: "cert.pem", Key: "key.pem", Autocert: &autocertConfig{Domains: []string{"example.com"}}}, "can't be used with cert"},
+		{"autocert without domains", tlsConfig{Autocert: &autocertConfig{}}, "requires domains"},
+	}
+	for _, tc := range tests {
+		cfg := config{TLS: &tc.tls, Mounts: mounts}
+		err := cfg.validate()
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%v: validate() = %v", tc.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: validate() = %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Autocert listens on the HTTPS port by default
+	cfg := config{TLS: &tlsConfig{Autocert: &autocertConfig{Domains: []string{"example.com"}}}}
+	cfg.setDefaults()
+	if cfg.Listen != ":443" {
+		t.Errorf("Listen = %q with autocert, want :443", cfg.Listen)
+	}
+	if cfg.TLS.Autocert.CacheDir == "" {
+		t.Errorf("autocert CacheDir isn't set by default")
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	cfg := &config{}
+	if err := (&options{autocertDomains: "example.com,www.example.com", autocertCache: "/var/cache/autocert"}).apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if a := cfg.TLS.Autocert; len(a.Domains) != 2 || a.Domains[1] != "www.example.com" || a.CacheDir != "/var/cache/autocert" {
+		t.Errorf("autocert = %+v", a)
+	}
+
+	// The cache directory overrides the one of the configuration file
+	cfg = &config{TLS: &tlsConfig{Autocert: &autocertConfig{Domains: []string{"example.com"}, CacheDir: "cache"}}}
+	if err := (&options{autocertCache: "/var/cache/autocert"}).apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLS.Autocert.CacheDir != "/var/cache/autocert" {
+		t.Errorf("autocert CacheDir = %q, want the option", cfg.TLS.Autocert.CacheDir)
+	}
+
+	cfg = &config{}
+	if err := (&options{tlsCert: "cert.pem", tlsKey: "key.pem"}).apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLS == nil || cfg.TLS.Cert != "cert.pem" || cfg.TLS.Key != "key.pem" || cfg.TLS.Autocert != nil {
+		t.Errorf("TLS = %+v, want the certificate and key", cfg.TLS)
+	}
+
+	err := (&options{tlsCert: "cert.pem", tlsKey: "key.pem", autocertDomains: "example.com"}).apply(&config{})
+	if err == nil {
+		t.Errorf("apply() with a certificate and autocert = nil, want an error")
+	}
+}
+
+func TestAutocertListener(t *testing.T) {
+	ln, err := autocertListener("127.0.0.1:0", &autocertConfig{
+		Domains:  []string{"example.com"},
+		CacheDir: filepath.Join(t.TempDir(), "autocert"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	// Certificates aren't requested for other domains
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: "other.example.org"})
+	if err == nil {
+		conn.Close()
+		t.Fatal("TLS handshake for another domain succeeded, want an error")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// usersFile lists users with their own scope on the mounts:
+//
+//	users:
+//	  - username: alice
+//	    password_hash: $2a$10$...
+//	    root: alice
+//	    quota: 1073741824
+type usersFile struct {
+	Users []userEntry `yaml:"users" toml:"users"`
+}
+
+// userEntry is a user of a users file.
+type userEntry struct {
+	Username string `yaml:"username" toml:"username"`
+	// PasswordHash is the bcrypt hash of the password.
+	PasswordHash string `yaml:"password_hash" toml:"password_hash"`
+	// Root is the directory served to the user, relative to the root of each
+	// mount. It's created if it doesn't exist. Defaults to the mount root.
+	Root string `yaml:"root" toml:"root"`
+	// ReadOnly rejects the requests of the user modifying files.
+	ReadOnly bool `yaml:"readonly" toml:"readonly"`
+	// Quota is the maximum total size of the files of the user in each
+	// mount, in bytes. Zero means no limit.
+	Quota int64 `yaml:"quota" toml:"quota"`
+}
+
+// scopedUser is a user loaded from a users file, with its own WebDAV
+// handler.
+type scopedUser struct {
+	entry   userEntry
+	servers []*webdav.Server
+	roots   []*webdav.RootFileSystem
+	handler fiber.Handler
+
+	mu       sync.Mutex
+	verified [sha256.Size]byte // hash of the last password verified
+}
+
+// userStore serves the users of a users file, which can be reloaded.
+type userStore struct {
+	name   string
+	mounts []mountConfig
+	// taken are the usernames of the configuration file, which the users
+	// file can't reuse: their plain passwords would grant the scope.
+	taken map[string]bool
+	users atomic.Pointer[map[string]*scopedUser]
+}
+
+func loadUsersFile(name string, mounts []mountConfig, configUsers []userConfig) (*userStore, error) {
+	s := &userStore{name: name, mounts: mounts, taken: make(map[string]bool)}
+	for _, u := range configUsers {
+		s.taken[u.Username] = true
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the users file again. Users whose entry didn't change keep
+// their handler, and thus their locks.
+func (s *userStore) reload() error {
+	var f usersFile
+	if err := decodeFile(s.name, &f); err != nil {
+		return err
+	}
+
+	var old map[string]*scopedUser
+	if p := s.users.Load(); p != nil {
+		old = *p
+	}
+
+	users := make(map[string]*scopedUser, len(f.Users))
+	if err := s.loadUsers(f.Users, old, users); err != nil {
+		// Release the scopes created for the new entries
+		for name, u := range users {
+			if old[name] != u {
+				go u.close()
+			}
+		}
+		return err
+	}
+
+	s.users.Store(&users)
+	for name, u := range old {
+		if users[name] != u {
+			go u.close()
+		}
+	}
+	return nil
+}
+
+// loadUsers adds the users of entries to users, reusing the old users whose
+// entry didn't change.
+func (s *userStore) loadUsers(entries []userEntry, old, users map[string]*scopedUser) error {
+	for _, entry := range entries {
+		if entry.Username == "" {
+			return fmt.Errorf("%v: user without username", s.name)
+		} else if _, ok := users[entry.Username]; ok {
+			return fmt.Errorf("%v: duplicate user %q", s.name, entry.Username)
+		} else if s.taken[entry.Username] {
+			return fmt.Errorf("%v: user %q is also defined in the configuration file", s.name, entry.Username)
+		} else if _, err := bcrypt.Cost([]byte(entry.PasswordHash)); err != nil {
+			return fmt.Errorf("%v: user %q: invalid password hash: %w", s.name, entry.Username, err)
+		}
+
+		if u, ok := old[entry.Username]; ok && u.entry == entry {
+			users[entry.Username] = u
+			continue
+		}
+		u := &scopedUser{entry: entry}
+		if err := s.newServers(u); err != nil {
+			u.close()
+			return fmt.Errorf("%v: user %q: %w", s.name, entry.Username, err)
+		}
+		u.handler = webdav.Mounts(u.servers...)
+		users[entry.Username] = u
+	}
+	return nil
+}
+
+// newServers creates the WebDAV servers serving the scope of a user. Scopes
+// are served with a RootFileSystem, so that symbolic links can't escape
+// them.
+func (s *userStore) newServers(u *scopedUser) error {
+	for _, m := range s.mounts {
+		dir := filepath.Join(m.Root, filepath.FromSlash(path.Clean("/"+u.entry.Root)))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		root, err := webdav.NewRootFileSystem(dir, nil)
+		if err != nil {
+			return err
+		}
+		u.roots = append(u.roots, root)
+
+		var fs webdav.FileSystem = root
+		if u.entry.Quota > 0 {
+			fs = webdav.Quota(webdav.QuotaOptions{MaxBytes: u.entry.Quota})(fs)
+		}
+		c := m.webdavConfig(fs)
+		c.ReadOnly = m.ReadOnly || u.entry.ReadOnly
+		u.servers = append(u.servers, webdav.NewServer(c))
+	}
+	return nil
+}
+
+// close shuts down the servers of a user removed or changed by a reload,
+// waiting for their in-flight requests, and closes their roots.
+func (u *scopedUser) close() {
+	for _, s := range u.servers {
+		if err := s.Shutdown(context.Background()); err != nil {
+			log.Errorf("failed to shut down the WebDAV server of user %q: %v", u.entry.Username, err)
+		}
+	}
+	for _, root := range u.roots {
+		root.Close()
+	}
+}
+
+func (s *userStore) lookup(username string) *scopedUser {
+	return (*s.users.Load())[username]
+}
+
+// authorize checks the password of a user. Successful verifications are
+// remembered, since bcrypt is deliberately slow.
+func (s *userStore) authorize(username, password string) bool {
+	u := s.lookup(username)
+	if u == nil {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	u.mu.Lock()
+	verified := subtle.ConstantTimeCompare(sum[:], u.verified[:]) == 1
+	u.mu.Unlock()
+	if verified {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(u.entry.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+	u.mu.Lock()
+	u.verified = sum
+	u.mu.Unlock()
+	return true
+}
+
+// serve serves the requests of the users of the users file with their own
+// handler. Other requests are passed to the next handler.
+func (s *userStore) serve(c *fiber.Ctx) error {
+	username, _ := c.Locals("username").(string)
+	if u := s.lookup(username); u != nil {
+		return u.handler(c)
+	}
+	return c.Next()
+}
+
+// servers returns the WebDAV servers of all users.
+func (s *userStore) servers() []*webdav.Server {
+	var servers []*webdav.Server
+	for _, u := range *s.users.Load() {
+		servers = append(servers, u.servers...)
+	}
+	return servers
+}
+
+// reloadOnSIGHUP reloads the users file when the process receives SIGHUP.
+func reloadOnSIGHUP(s *userStore) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := s.reload(); err != nil {
+				log.Errorf("failed to reload users: %v", err)
+			} else {
+				log.Infof("reloaded users from %v", s.name)
+			}
+		}
+	}()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testHashes holds the password hashes of the test users, so rewriting a
+// users file keeps the entries of unchanged users.
+var testHashes = map[string]string{}
+
+// writeUsersFile writes a users file with the given entries, each user's
+// password being their username.
+func writeUsersFile(t *testing.T, name string, entries ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("users:\n")
+	for _, entry := range entries {
+		username, rest, _ := strings.Cut(entry, " ")
+		hash, ok := testHashes[username]
+		if !ok {
+			b, err := bcrypt.GenerateFromPassword([]byte(username), bcrypt.MinCost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash = string(b)
+			testHashes[username] = hash
+		}
+		b.WriteString("  - username: " + username + "\n    password_hash: " + hash + "\n")
+		for _, field := range strings.Fields(rest) {
+			k, v, _ := strings.Cut(field, "=")
+			b.WriteString("    " + k + ": " + v + "\n")
+		}
+	}
+	if err := os.WriteFile(name, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveUsers returns an app serving the users of s.
+func serveUsers(s *userStore) *fiber.App {
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(basicAuth(s.authorize), setUser, s.serve)
+	return app
+}
+
+func testStatus(t *testing.T, app *fiber.App, method, target, user, body string, want int) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if user != "" {
+		req.SetBasicAuth(user, user)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Errorf("%v %v as %q = %v, want %v", method, target, user, resp.StatusCode, want)
+	}
+}
+
+func TestUsersFile(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "users.yaml")
+	writeUsersFile(t, name, "alice root=../x", "bob readonly=true", "carol root=carol quota=10")
+	s, err := loadUsersFile(name, []mountConfig{{Prefix: "/", Root: root}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := serveUsers(s)
+
+	testStatus(t, app, http.MethodPut, "/a.txt", "alice", "a", http.StatusCreated)
+	testStatus(t, app, http.MethodPut, "/a.txt", "bob", "b", http.StatusForbidden)
+	testStatus(t, app, http.MethodPut, "/a.txt", "carol", strings.Repeat("c", 20), http.StatusInsufficientStorage)
+	testStatus(t, app, http.MethodPut, "/a.txt", "carol", "c", http.StatusCreated)
+	testStatus(t, app, http.MethodGet, "/a.txt", "", "", http.StatusUnauthorized)
+	testStatus(t, app, http.MethodGet, "/x/a.txt", "bob", "", http.StatusOK)
+
+	// Scopes are clamped to the mount root
+	for _, p := range []string{"root/x/a.txt", "root/carol/a.txt"} {
+		if _, err := os.Stat(filepath.Join(parent, p)); err != nil {
+			t.Errorf("%v: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "x")); err == nil {
+		t.Errorf("the scope of alice escaped the mount root")
+	}
+}
+
+func TestUsersFileReload(t *testing.T) {
+	root := t.TempDir()
+	name := filepath.Join(t.TempDir(), "users.yaml")
+	writeUsersFile(t, name, "alice root=alice", "bob root=bob readonly=true", "carol")
+	s, err := loadUsersFile(name, []mountConfig{{Prefix: "/", Root: root}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := s.lookup("alice"), s.lookup("bob")
+
+	writeUsersFile(t, name, "alice root=alice", "bob root=bob", "dave")
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged users keep their handler
+	if s.lookup("alice") != alice {
+		t.Errorf("the handler of alice changed")
+	}
+	if u := s.lookup("bob"); u == bob || u.entry.ReadOnly {
+		t.Errorf("the entry of bob wasn't reloaded")
+	}
+	if s.lookup("carol") != nil || s.lookup("dave") == nil {
+		t.Errorf("users weren't added or removed")
+	}
+	testStatus(t, serveUsers(s), http.MethodPut, "/a.txt", "bob", "b", http.StatusCreated)
+
+	// Invalid files keep the current users
+	writeUsersFile(t, name, "alice", "alice")
+	if err := s.reload(); err == nil {
+		t.Errorf("reload() of duplicate users = nil, want an error")
+	}
+	if s.lookup("alice") != alice {
+		t.Errorf("a failed reload changed the users")
+	}
+}
+
+func TestUsersFileConfigUsers(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "users.yaml")
+	writeUsersFile(t, name, "alice")
+	mounts := []mountConfig{{Prefix: "/", Root: t.TempDir()}}
+	if _, err := loadUsersFile(name, mounts, []userConfig{{Username: "alice", Password: "secret"}}); err == nil {
+		t.Errorf("loadUsersFile() of a user of the configuration file = nil, want an error")
+	}
+
+	s, err := loadUsersFile(name, mounts, []userConfig{{Username: "bob", Password: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeUsersFile(t, name, "alice", "bob")
+	if err := s.reload(); err == nil {
+		t.Errorf("reload() adding a user of the configuration file = nil, want an error")
+	}
+}
+
+func TestUsersAuthorize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "users.yaml")
+	writeUsersFile(t, name, "alice")
+	s, err := loadUsersFile(name, []mountConfig{{Prefix: "/", Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if !s.authorize("alice", "alice") {
+			t.Errorf("authorize() = false, want true")
+		}
+		// Remembered verifications don't let other passwords through
+		if s.authorize("alice", "wrong") || s.authorize("bob", "alice") {
+			t.Errorf("authorize() of invalid credentials = true")
+		}
+	}
+	if u := s.lookup("alice"); u.verified == [len(u.verified)]byte{} {
+		t.Errorf("successful verification not remembered")
+	}
+}
+
+func TestUsersFileSIGHUP(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "users.yaml")
+	writeUsersFile(t, name, "alice")
+	s, err := loadUsersFile(name, []mountConfig{{Prefix: "/", Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloadOnSIGHUP(s)
+
+	writeUsersFile(t, name, "alice", "bob")
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skip(err)
+	}
+	for start := time.Now(); s.lookup("bob") == nil; {
+		if time.Since(start) > time.Second {
+			t.Fatal("users file not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
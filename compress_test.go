@@ -0,0 +1,91 @@
+package webdav_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestCompress(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b/": ""})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), Compress: true}
+
+	for _, tc := range []struct {
+		name, method, target, acceptEncoding string
+		want                                 string
+	}{
+		{"gzip", "PROPFIND", "/", "gzip", "gzip"},
+		{"deflate", "PROPFIND", "/", "deflate", "deflate"},
+		{"preferred", "PROPFIND", "/", "deflate;q=0.9, gzip;q=0.5", "deflate"},
+		{"tie", "PROPFIND", "/", "deflate, gzip", "gzip"},
+		{"wildcard", "PROPFIND", "/", "*", "gzip"},
+		{"none", "PROPFIND", "/", "", ""},
+		{"unsupported", "PROPFIND", "/", "br", ""},
+		{"refused", "PROPFIND", "/", "gzip;q=0", ""},
+		{"invalid q", "PROPFIND", "/", "gzip;q=x", ""},
+		{"get", http.MethodGet, "/a.txt", "gzip", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(t, h, testRequest{
+				method: tc.method,
+				target: tc.target,
+				header: map[string]string{"Depth": "1", "Accept-Encoding": tc.acceptEncoding},
+			})
+			if got := w.Header().Get("Content-Encoding"); got != tc.want {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tc.want)
+			}
+			if tc.method == "PROPFIND" && w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+			}
+
+			var body io.Reader = w.Body
+			switch tc.want {
+			case "gzip":
+				zr, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() = %v", err)
+				}
+				body = zr
+			case "deflate":
+				zr, err := zlib.NewReader(body)
+				if err != nil {
+					t.Fatalf("zlib.NewReader() = %v", err)
+				}
+				body = zr
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if tc.method == "PROPFIND" && !strings.Contains(string(b), "/b/") {
+				t.Errorf("PROPFIND body = %q, want /b/", b)
+			}
+		})
+	}
+
+	// Errors are compressed like any other response body
+	w := checkStatus(t, h, testRequest{
+		method: "PROPFIND",
+		target: "/missing",
+		header: map[string]string{"Accept-Encoding": "gzip"},
+	}, http.StatusNotFound)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("404 Content-Encoding = %q, want gzip", got)
+	}
+
+	// Compression is disabled by default
+	h.Compress = false
+	w = serve(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Accept-Encoding": "gzip"}})
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q without Compress, want none", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("Vary = %q without Compress, want none", got)
+	}
+}
@@ -0,0 +1,124 @@
+package webdav_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestConditionalDates(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+	same := modTime.Format(http.TimeFormat)
+
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+			for _, name := range []string{"a.txt", "b.txt"} {
+				if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			tests := []struct {
+				req  testRequest
+				want int
+			}{
+				{testRequest{method: http.MethodPut, target: "/a.txt", body: "x", header: map[string]string{"If-Unmodified-Since": before}}, http.StatusPreconditionFailed},
+				{testRequest{method: http.MethodPut, target: "/a.txt", body: "x", header: map[string]string{"If-Modified-Since": same}}, http.StatusPreconditionFailed},
+				{testRequest{method: http.MethodDelete, target: "/a.txt", header: map[string]string{"If-Unmodified-Since": before}}, http.StatusPreconditionFailed},
+				{testRequest{method: http.MethodDelete, target: "/a.txt", header: map[string]string{"If-Modified-Since": after}}, http.StatusPreconditionFailed},
+				{testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/c.txt", "If-Unmodified-Since": before}}, http.StatusPreconditionFailed},
+				{testRequest{method: "MOVE", target: "/a.txt", header: map[string]string{"Destination": "/c.txt", "If-Modified-Since": after}}, http.StatusPreconditionFailed},
+				// Invalid dates are ignored, and so are dates overridden by
+				// entity tag conditions
+				{testRequest{method: http.MethodPut, target: "/b.txt", body: "x", header: map[string]string{"If-Unmodified-Since": "yesterday"}}, http.StatusNoContent},
+				{testRequest{method: http.MethodPut, target: "/a.txt", body: "x", header: map[string]string{"If-Match": "*", "If-Unmodified-Since": before}}, http.StatusNoContent},
+				// Missing resources have no date to compare
+				{testRequest{method: http.MethodPut, target: "/d.txt", body: "x", header: map[string]string{"If-Unmodified-Since": before}}, http.StatusCreated},
+			}
+			for _, tc := range tests {
+				checkStatus(t, h, tc.req, tc.want)
+			}
+			checkMissing(t, dir, "c.txt")
+
+			// Satisfied conditions let the changes through
+			if err := os.Chtimes(filepath.Join(dir, "a.txt"), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			checkStatus(t, h, testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/c.txt", "If-Unmodified-Since": same}}, http.StatusCreated)
+			checkStatus(t, h, testRequest{method: "MOVE", target: "/a.txt", header: map[string]string{"Destination": "/e.txt", "If-Modified-Since": before}}, http.StatusCreated)
+			checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/c.txt", header: map[string]string{"If-Modified-Since": before}}, http.StatusNoContent)
+			checkFile(t, dir, "e.txt", "x")
+			checkMissing(t, dir, "a.txt")
+			checkMissing(t, dir, "c.txt")
+		})
+	}
+}
+
+// openCountingFileSystem counts the files opened on a FileSystem.
+type openCountingFileSystem struct {
+	webdav.FileSystem
+	opened atomic.Int32
+}
+
+func (fs *openCountingFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	fs.opened.Add(1)
+	return fs.FileSystem.Open(ctx, name)
+}
+
+func TestNotModified(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	if err := os.Chtimes(filepath.Join(dir, "a.txt"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	fs := &openCountingFileSystem{FileSystem: webdav.LocalFileSystem(dir)}
+	h := &webdav.Handler{FileSystem: fs}
+	etag := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK).Header().Get("ETag")
+
+	tests := []struct {
+		method string
+		header map[string]string
+		want   int
+	}{
+		{http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{http.MethodHead, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-None-Match": `"x", ` + etag}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-Modified-Since": after}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-None-Match": `"x"`}, http.StatusOK},
+		{http.MethodGet, map[string]string{"If-Modified-Since": before}, http.StatusOK},
+		{http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+		// If-None-Match takes precedence over If-Modified-Since
+		{http.MethodGet, map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": after}, http.StatusOK},
+	}
+	for _, tc := range tests {
+		fs.opened.Store(0)
+		w := checkStatus(t, h, testRequest{method: tc.method, target: "/a.txt", header: tc.header}, tc.want)
+		if tc.want != http.StatusNotModified {
+			continue
+		}
+		if n := fs.opened.Load(); n != 0 {
+			t.Errorf("%v %v: opened the file %v times", tc.method, tc.header, n)
+		}
+		if w.Body.Len() != 0 || w.Header().Get("ETag") != etag || w.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+			t.Errorf("%v %v: 304 response with body %q and header %v", tc.method, tc.header, w.Body, w.Header())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package webdav
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// connCheckInterval is the interval at which connections of requests being
+// served are checked for closure.
+const connCheckInterval = time.Second
+
+// withConnContext cancels the context of requests served through the Fiber
+// adaptor when the client closes its connection or the server shuts down, so
+// that FileSystems stop working for aborted requests. The net/http server
+// already does so for its own requests.
+func withConnContext(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fctx, ok := r.Context().(*fasthttp.RequestCtx)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// The request context keeps the fasthttp user values, e.g. the user
+		// set by SetUser
+		ctx, cancel := context.WithCancel(fctx)
+		defer cancel()
+		ctx = context.WithValue(ctx, fasthttpCtxKey{}, fctx)
+		stop := watchConn(fctx.Conn(), cancel)
+		defer stop()
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type fasthttpCtxKey struct{}
+
+// fasthttpCtx returns the fasthttp context of a request served through the
+// Fiber adaptor, whose response writer can't stream.
+func fasthttpCtx(ctx context.Context) (*fasthttp.RequestCtx, bool) {
+	fctx, ok := ctx.Value(fasthttpCtxKey{}).(*fasthttp.RequestCtx)
+	return fctx, ok
+}
+
+// watchConn calls cancel if conn gets closed by the peer, until stop is
+// called.
+func watchConn(conn net.Conn, cancel func()) (stop func()) {
+	if tc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		// TLS connection
+		conn = tc.NetConn()
+	}
+	if conn == nil || !canCheckConn(conn) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(connCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if connClosed(conn) {
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package webdav
+
+import "net"
+
+func canCheckConn(conn net.Conn) bool {
+	return false
+}
+
+func connClosed(conn net.Conn) bool {
+	return false
+}
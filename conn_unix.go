@@ -0,0 +1,34 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package webdav
+
+import (
+	"net"
+	"syscall"
+)
+
+func canCheckConn(conn net.Conn) bool {
+	_, ok := conn.(syscall.Conn)
+	return ok
+}
+
+// connClosed reports whether the peer closed conn, without consuming
+// pending data.
+func connClosed(conn net.Conn) bool {
+	rc, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		return false
+	}
+	closed := false
+	err = rc.Control(func(fd uintptr) {
+		var b [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch err {
+		case nil:
+			closed = n == 0
+		case syscall.ECONNRESET:
+			closed = true
+		}
+	})
+	return err == nil && closed
+}
@@ -0,0 +1,92 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package webdav_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// blockingFileSystem blocks opening "/block" until the request context is
+// done, and reports the error of the context.
+type blockingFileSystem struct {
+	webdav.LocalFileSystem
+	opened chan struct{}
+	done   chan error
+}
+
+func (fs blockingFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if name != "/block" {
+		return fs.LocalFileSystem.Open(ctx, name)
+	}
+	close(fs.opened)
+	<-ctx.Done()
+	fs.done <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestClientDisconnect(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "block": "b"})
+	fs := blockingFileSystem{webdav.LocalFileSystem(dir), make(chan struct{}), make(chan error, 1)}
+
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods, DisableStartupMessage: true})
+	app.Use(webdav.New(webdav.Config{Root: fs}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	// Requests of connected clients complete
+	resp, err := http.Get("http://" + ln.Addr().String() + "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /a.txt = %v, want 200", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(conn)
+	if err := req.Write(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-fs.opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request didn't reach the file system")
+	}
+	conn.Close()
+
+	select {
+	case err := <-fs.done:
+		if err != context.Canceled {
+			t.Errorf("context error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the context wasn't canceled after the client disconnected")
+	}
+}
@@ -0,0 +1,76 @@
+package webdav
+
+import (
+	"io"
+	"net/http"
+	"path"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType.
+const sniffLen = 512
+
+// DetectContentType is the default content type resolver. It uses the
+// extension of the file name, looked up in the package-level MIMETypes, then
+// sniffs the first bytes of the content read from peek with
+// http.DetectContentType.
+func DetectContentType(name string, peek io.Reader) string {
+	return defaultMIMETypes.detectContentType(name, peek)
+}
+
+// detectContentType is like DetectContentType, with the types registered in
+// m.
+func (m *MIMETypes) detectContentType(name string, peek io.Reader) string {
+	if t := m.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	if peek == nil {
+		return ""
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(peek, buf)
+	if n == 0 && err != io.EOF {
+		return ""
+	}
+	return m.withCharset(http.DetectContentType(buf[:n]))
+}
+
+// contentType returns the media type of a file, reading the beginning of its
+// content from peek if needed.
+func (b *backend) contentType(fi *FileInfo, peek io.Reader) string {
+	if b.DetectContentType != nil {
+		return b.DetectContentType(fi.Path, io.LimitReader(peek, sniffLen))
+	}
+	// Types registered for the handler override the ones of the file system
+	if t := b.MIMETypes.lookup(path.Ext(fi.Path)); t != "" {
+		return b.MIMETypes.withCharset(t)
+	}
+	if fi.MIMEType != "" {
+		return b.MIMETypes.withCharset(fi.MIMEType)
+	}
+	return b.MIMETypes.detectContentType(fi.Path, io.LimitReader(peek, sniffLen))
+}
+
+// lazyReader opens a file on first read, so that content types known from the
+// file name don't require opening files.
+type lazyReader struct {
+	open func() (io.ReadCloser, error)
+	rc   io.ReadCloser
+	err  error
+}
+
+func (lr *lazyReader) Read(b []byte) (int, error) {
+	if lr.rc == nil && lr.err == nil {
+		lr.rc, lr.err = lr.open()
+	}
+	if lr.err != nil {
+		return 0, lr.err
+	}
+	return lr.rc.Read(b)
+}
+
+func (lr *lazyReader) Close() error {
+	if lr.rc == nil {
+		return nil
+	}
+	return lr.rc.Close()
+}
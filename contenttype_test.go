@@ -0,0 +1,64 @@
+package webdav_test
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestDetectContentTypeHook(t *testing.T) {
+	dir := t.TempDir()
+	content := "GLTF" + strings.Repeat("x", 1000)
+	writeFiles(t, dir, map[string]string{"model": content, "a.txt": "a", "empty": ""})
+
+	var peeked []string
+	detect := func(name string, peek io.Reader) string {
+		b, err := io.ReadAll(peek)
+		if err != nil {
+			t.Errorf("reading %v: %v", name, err)
+		}
+		peeked = append(peeked, name)
+		if strings.HasPrefix(string(b), "GLTF") {
+			return "model/gltf-binary"
+		}
+		return ""
+	}
+
+	for _, fs := range []webdav.FileSystem{webdav.LocalFileSystem(dir), streamFileSystem{webdav.LocalFileSystem(dir)}} {
+		h := &webdav.Handler{FileSystem: fs, DetectContentType: detect}
+
+		// The content read to detect the type is served too
+		w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/model"}, http.StatusOK)
+		if got := w.Header().Get("Content-Type"); got != "model/gltf-binary" {
+			t.Errorf("%T: Content-Type = %q, want model/gltf-binary", fs, got)
+		}
+		if w.Body.String() != content {
+			t.Errorf("%T: GET body has %v bytes, want %v", fs, w.Body.Len(), len(content))
+		}
+	}
+
+	// The hook overrides the types known from the file name
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), DetectContentType: detect}
+	peeked = nil
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	body := w.Body.String()
+	if !strings.Contains(body, ">model/gltf-binary</getcontenttype>") {
+		t.Errorf("PROPFIND response = %v, want the detected content type", body)
+	}
+	if strings.Contains(body, "text/plain") {
+		t.Errorf("PROPFIND response = %v, want no type from the file name", body)
+	}
+	if len(peeked) != 3 {
+		t.Errorf("hook called for %q, want the 3 files", peeked)
+	}
+
+	// Unknown types are reported as missing properties
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/empty", body: propFind(`<D:getcontenttype/>`), header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	if !regexp.MustCompile(`(?s)<getcontenttype[^>]*>.*404 Not Found`).MatchString(w.Body.String()) {
+		t.Errorf("PROPFIND response = %v, want getcontenttype not found", w.Body)
+	}
+}
@@ -0,0 +1,118 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testTokens maps bearer tokens to users.
+type testTokens map[string]string
+
+func (tokens testTokens) ValidateToken(ctx context.Context, token string) (string, error) {
+	user, ok := tokens[token]
+	if !ok {
+		return "", errors.New("invalid token")
+	}
+	return user, nil
+}
+
+// continueHeader returns the header of a PUT request sent with "Expect:
+// 100-continue".
+func continueHeader(target, token string, length int) *fasthttp.RequestHeader {
+	var header fasthttp.RequestHeader
+	header.SetMethod(http.MethodPut)
+	header.SetRequestURI(target)
+	header.Set("Expect", "100-continue")
+	header.SetContentLength(length)
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &header
+}
+
+func TestContinueHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"shared/a.txt": "a"})
+	_, check := webdav.NewContinue(webdav.Config{
+		Prefix:         "/dav",
+		Root:           webdav.Quota(webdav.QuotaOptions{MaxBytes: 100})(webdav.LocalFileSystem(dir)),
+		TokenValidator: testTokens{"alice-token": "alice", "bob-token": "bob"},
+		Permissions: testPermissions{
+			read:  map[string]string{"alice": "/", "bob": "/"},
+			write: map[string]string{"alice": "/", "bob": "/shared/"},
+		},
+		Authorize: func(c *fiber.Ctx, method, path string) error {
+			if user, _ := webdav.UserFromContext(c.UserContext()); strings.HasPrefix(path, "/shared/locked") && user != "alice" {
+				return fiber.ErrForbidden
+			}
+			return nil
+		},
+	})
+
+	tests := []struct {
+		name   string
+		header *fasthttp.RequestHeader
+		want   bool
+	}{
+		{"outside of the prefix", continueHeader("/other/a.txt", "", 10), true},
+		{"no token", continueHeader("/dav/shared/b.txt", "", 10), false},
+		{"invalid token", continueHeader("/dav/shared/b.txt", "eve-token", 10), false},
+		{"permitted", continueHeader("/dav/shared/b.txt", "bob-token", 10), true},
+		{"not permitted", continueHeader("/dav/b.txt", "bob-token", 10), false},
+		{"authorized", continueHeader("/dav/shared/locked.txt", "alice-token", 10), true},
+		{"not authorized", continueHeader("/dav/shared/locked.txt", "bob-token", 10), false},
+		{"missing parent", continueHeader("/dav/missing/b.txt", "alice-token", 10), false},
+		{"over quota", continueHeader("/dav/shared/b.txt", "alice-token", 1000), false},
+		{"replacing within quota", continueHeader("/dav/shared/a.txt", "alice-token", 99), true},
+	}
+	for _, tc := range tests {
+		if got := check(tc.header); got != tc.want {
+			t.Errorf("%v: continue = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestContinueHandlerHomeDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"alice/docs/": ""})
+	_, check := webdav.NewContinue(webdav.Config{
+		Root:           webdav.LocalFileSystem(dir),
+		HomeDirs:       true,
+		TokenValidator: testTokens{"alice-token": "alice"},
+	})
+
+	tests := []struct {
+		name   string
+		header *fasthttp.RequestHeader
+		want   bool
+	}{
+		{"home directory", continueHeader("/docs/a.txt", "alice-token", 10), true},
+		{"missing parent", continueHeader("/missing/a.txt", "alice-token", 10), false},
+		{"no token", continueHeader("/docs/a.txt", "", 10), false},
+	}
+	for _, tc := range tests {
+		if got := check(tc.header); got != tc.want {
+			t.Errorf("%v: continue = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestContinueHandlerExternalAuth(t *testing.T) {
+	// Users authenticated by other middlewares aren't known before the body
+	// is read
+	_, check := webdav.NewContinue(webdav.Config{
+		Root:        webdav.LocalFileSystem(t.TempDir()),
+		Permissions: testPermissions{write: map[string]string{"alice": "/"}},
+	})
+	if !check(continueHeader("/a.txt", "", 10)) {
+		t.Errorf("continue = false, want true")
+	}
+}
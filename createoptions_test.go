@@ -0,0 +1,55 @@
+package webdav_test
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// recordingFileSystem records the options of the last Create call.
+type recordingFileSystem struct {
+	webdav.FileSystem
+	opts webdav.CreateOptions
+}
+
+func (fs *recordingFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *webdav.CreateOptions) (*webdav.FileInfo, bool, error) {
+	fs.opts = *opts
+	return fs.FileSystem.Create(ctx, name, body, opts)
+}
+
+func TestCreateOptions(t *testing.T) {
+	fs := &recordingFileSystem{FileSystem: webdav.LocalFileSystem(t.TempDir())}
+	h := &webdav.Handler{FileSystem: fs, MetadataHeaders: []string{"content-language", "X-Amz-Meta-*"}}
+
+	tests := []struct {
+		req         testRequest
+		contentType string
+		length      int64
+		metadata    map[string]string
+	}{
+		{
+			testRequest{method: http.MethodPut, target: "/a.txt", body: "hello", header: map[string]string{
+				"Content-Type":      "text/plain",
+				"Content-Language":  "en",
+				"X-Amz-Meta-Author": "alice",
+				"X-Other":           "ignored",
+			}},
+			"text/plain", 5, map[string]string{"Content-Language": "en", "X-Amz-Meta-Author": "alice"},
+		},
+		{
+			testRequest{method: http.MethodPost, target: "/", body: "hi", header: map[string]string{"Content-Type": "image/png"}},
+			"image/png", 2, nil,
+		},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, http.StatusCreated)
+		if fs.opts.ContentType != tc.contentType || fs.opts.ContentLength != tc.length || !maps.Equal(fs.opts.Metadata, tc.metadata) {
+			t.Errorf("%v %v: CreateOptions = %q %v %v, want %q %v %v", tc.req.method, tc.req.target,
+				fs.opts.ContentType, fs.opts.ContentLength, fs.opts.Metadata, tc.contentType, tc.length, tc.metadata)
+		}
+	}
+}
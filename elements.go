@@ -11,6 +11,23 @@ var (
 	principalAlternateURISetName = xml.Name{"DAV:", "alternate-URI-set"}
 	principalURLName             = xml.Name{"DAV:", "principal-URL"}
 	groupMembershipName          = xml.Name{"DAV:", "group-membership"}
+	groupMemberSetName           = xml.Name{"DAV:", "group-member-set"}
+	principalCollectionSetName   = xml.Name{"DAV:", "principal-collection-set"}
+
+	principalPropertySearchName    = xml.Name{"DAV:", "principal-property-search"}
+	principalSearchPropertySetName = xml.Name{"DAV:", "principal-search-property-set"}
+
+	executableName = xml.Name{apacheNamespace, "executable"}
+	checksumsName  = xml.Name{ownCloudNamespace, "checksums"}
+)
+
+const (
+	// apacheNamespace is the namespace used by Apache's mod_dav for its
+	// properties.
+	apacheNamespace = "http://apache.org/dav/props/"
+	// ownCloudNamespace is the namespace used by ownCloud and Nextcloud for
+	// their properties.
+	ownCloudNamespace = "http://owncloud.org/ns"
 )
 
 // https://datatracker.ietf.org/doc/html/rfc3744#section-4.1
@@ -30,3 +47,77 @@ type groupMembership struct {
 	XMLName xml.Name        `xml:"DAV: group-membership"`
 	Hrefs   []internal.Href `xml:"href"`
 }
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-4.3
+type groupMemberSet struct {
+	XMLName xml.Name        `xml:"DAV: group-member-set"`
+	Hrefs   []internal.Href `xml:"href"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-5.8
+type principalCollectionSet struct {
+	XMLName xml.Name        `xml:"DAV: principal-collection-set"`
+	Hrefs   []internal.Href `xml:"href"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.4
+type principalPropertySearch struct {
+	XMLName                       xml.Name         `xml:"DAV: principal-property-search"`
+	Test                          string           `xml:"test,attr,omitempty"`
+	PropertySearch                []propertySearch `xml:"property-search"`
+	Prop                          *internal.Prop   `xml:"prop,omitempty"`
+	ApplyToPrincipalCollectionSet *struct{}        `xml:"apply-to-principal-collection-set,omitempty"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.4
+type propertySearch struct {
+	XMLName xml.Name      `xml:"DAV: property-search"`
+	Prop    internal.Prop `xml:"prop"`
+	Match   match         `xml:"match"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.4
+type match struct {
+	XMLName   xml.Name `xml:"DAV: match"`
+	MatchType string   `xml:"match-type,attr,omitempty"`
+	Value     string   `xml:",chardata"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.5
+type principalSearchPropertySet struct {
+	XMLName                 xml.Name                  `xml:"DAV: principal-search-property-set"`
+	PrincipalSearchProperty []principalSearchProperty `xml:"principal-search-property"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.5
+type principalSearchProperty struct {
+	XMLName     xml.Name      `xml:"DAV: principal-search-property"`
+	Prop        internal.Prop `xml:"prop"`
+	Description description   `xml:"description"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc3744#section-9.5
+type description struct {
+	XMLName xml.Name `xml:"DAV: description"`
+	Lang    string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+// https://httpd.apache.org/docs/current/mod/mod_dav.html
+type executable struct {
+	XMLName    xml.Name `xml:"http://apache.org/dav/props/ executable"`
+	Executable string   `xml:",chardata"`
+}
+
+// https://doc.owncloud.com/server/next/developer_manual/webdav_api/
+type checksums struct {
+	XMLName  xml.Name `xml:"http://owncloud.org/ns checksums"`
+	Checksum string   `xml:"checksum"`
+}
+
+func newExecutable(x bool) *executable {
+	if x {
+		return &executable{Executable: "T"}
+	}
+	return &executable{Executable: "F"}
+}
@@ -0,0 +1,31 @@
+package webdav
+
+import (
+	"net/http"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Sentinel errors for the common WebDAV failures. Any error carrying the same
+// HTTP status code, e.g. created with NewHTTPError or returned by the client,
+// matches them with errors.Is:
+//
+//	if errors.Is(err, webdav.ErrNotFound) { ... }
+//
+// Backends can return them directly, or wrap them with fmt.Errorf and %w to
+// add context. The handler replies with their status code.
+var (
+	ErrForbidden          error = &internal.HTTPError{Code: http.StatusForbidden}
+	ErrNotFound           error = &internal.HTTPError{Code: http.StatusNotFound}
+	ErrConflict           error = &internal.HTTPError{Code: http.StatusConflict}
+	ErrPreconditionFailed error = &internal.HTTPError{Code: http.StatusPreconditionFailed}
+	ErrLocked             error = &internal.HTTPError{Code: http.StatusLocked}
+	ErrQuotaExceeded      error = &internal.HTTPError{Code: http.StatusInsufficientStorage}
+)
+
+// HTTPStatus returns the HTTP status code associated with an error, e.g.
+// created with NewHTTPError or wrapping a sentinel error. It returns
+// http.StatusInternalServerError for other errors.
+func HTTPStatus(err error) int {
+	return internal.HTTPErrorFromError(err).Code
+}
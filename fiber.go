@@ -1,10 +1,23 @@
 package webdav
 
 import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
-	"strings"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Tryanks/fiber-webdav/internal"
 )
 
 const (
@@ -15,6 +28,7 @@ const (
 	MethodUnlock    = "UNLOCK"
 	MethodPropfind  = "PROPFIND"
 	MethodProppatch = "PROPPATCH"
+	MethodReport    = "REPORT"
 )
 
 var Methods = []string{
@@ -22,6 +36,7 @@ var Methods = []string{
 	MethodCopy, MethodMove,
 	MethodLock, MethodUnlock,
 	MethodPropfind, MethodProppatch,
+	MethodReport,
 }
 
 var ExtendedMethods = append(fiber.DefaultMethods[:], Methods...)
@@ -35,8 +50,225 @@ type Config struct {
 
 	// Lock enables WebDAV locking support
 	Lock bool
+
+	// LockSystem is the lock system used when locking is enabled, defaults
+	// to an in-memory one. Setting it enables locking.
+	LockSystem LockSystem
+
+	// Capabilities are extension compliance classes to advertise in OPTIONS
+	// responses
+	Capabilities []Capability
+
+	// ExtraMethods are advertised in the Allow header field of OPTIONS
+	// responses in addition to the methods served by WebDAV
+	ExtraMethods []string
+
+	// Checksum enables file checksums with the given algorithm
+	Checksum ChecksumAlgorithm
+
+	// PropertyStore stores dead properties, defaults to an in-memory store.
+	// Use CleanOrphanedProperties to periodically remove stale entries.
+	PropertyStore PropertyStore
+
+	// Reports maps REPORT names to their handlers, e.g. to add support for
+	// sync-collection or custom reports
+	Reports map[xml.Name]ReportFunc
+
+	// Compress enables gzip/deflate compression of PROPFIND and REPORT
+	// responses
+	Compress bool
+
+	// ReadOnly rejects all requests modifying resources with 403 Forbidden
+	ReadOnly bool
+
+	// AllowedMethods restricts the methods served on the mount, e.g.
+	// PROPFIND, GET and PUT only. If empty, all methods are allowed
+	AllowedMethods []string
+
+	// DeniedMethods lists methods rejected with 405 Method Not Allowed
+	DeniedMethods []string
+
+	// Authorize is called before serving each request with the request
+	// method and the resource path. For COPY and MOVE it's called a second
+	// time with the destination path. Returning an error created with
+	// NewHTTPError rejects the request with its status code (e.g. 401), other
+	// errors reject it with 403 Forbidden
+	Authorize func(c *fiber.Ctx, method, path string) error
+
+	// TokenValidator, if set, requires requests to carry a bearer token in
+	// the Authorization header field, e.g. a JWT checked by JWTValidator. The
+	// user identified by the token is available with UserFromContext
+	TokenValidator TokenValidator
+
+	// ClientCertificates, if set, authenticates requests made with a
+	// verified TLS client certificate as the user it maps the certificate
+	// to. Other requests need a bearer token if TokenValidator is set, and
+	// are rejected otherwise
+	ClientCertificates CertificateMapper
+
+	// URLSigner serves the URLs it signs without authentication, for the
+	// method and until the expiration time they were signed for, e.g. to
+	// hand out temporary download links. See URLSigner.SignURL
+	URLSigner *URLSigner
+
+	// HomeDirs serves each authenticated user (see UserFromContext and
+	// SetUser) from their own home directory, <Root>/<user>, created on first
+	// access. Root must implement SubFileSystem. Each user gets separate
+	// in-memory locks and properties, a LockSystem or PropertyStore set is
+	// shared with resources named by their path in Root, e.g. /<user>/a.txt
+	HomeDirs bool
+
+	// Limits restricts the request rate and the simultaneous transfers of
+	// each user or client IP. Exceeding requests get 429 Too Many Requests
+	Limits *Limits
+
+	// Logger logs every request with its method, path, depth, destination,
+	// user, status, duration and transferred bytes
+	Logger *slog.Logger
+
+	// Hooks are called after successful operations, e.g. to index uploads or
+	// invalidate caches
+	Hooks Hooks
+
+	// Webhooks posts signed JSON webhooks for the resources created,
+	// updated, deleted and moved through the mount, with retries
+	Webhooks *Webhooks
+
+	// Publisher publishes the changes made through the mount to a message
+	// bus, e.g. with a NATSPublisher or a KafkaPublisher
+	Publisher EventPublisher
+
+	// ChangeFeed streams the changes below a resource as Server-Sent Events
+	// to GET requests accepting "text/event-stream", e.g. for live-updating
+	// file browsers
+	ChangeFeed bool
+
+	// DetectContentType returns the media type of a file given its path and
+	// a reader for the beginning of its content, e.g. to classify
+	// extensionless or custom formats. Defaults to the DetectContentType
+	// function
+	DetectContentType func(name string, peek io.Reader) string
+
+	// MIMETypes maps file name extensions to media types and media types to
+	// default charsets for the mount, over the package-level registry, e.g.
+	// to label ".heic" or proprietary formats
+	MIMETypes *MIMETypes
+
+	// MetadataHeaders are the request headers passed to the FileSystem as
+	// CreateOptions.Metadata on uploads, e.g. "Cache-Control" or
+	// "X-Amz-Meta-*" for the headers with that prefix
+	MetadataHeaders []string
+
+	// ScanUpload is passed the content of each uploaded file once written,
+	// e.g. to run a virus scanner, before it replaces the existing file.
+	// Uploads it returns an error for are discarded, and fail with the
+	// status of the error or 403 Forbidden
+	ScanUpload func(ctx context.Context, name string, r io.Reader) error
+
+	// Processors are run in order in the background on each uploaded file,
+	// e.g. to generate thumbnails, and the properties they produce are set
+	// on the file
+	Processors []Processor
+
+	// AccessRules grant none, read or write access to resources matching
+	// glob patterns, optionally depending on the user, e.g. to make
+	// "/public/**" read-only and hide "/private/**" from unauthenticated
+	// users. Hidden resources are omitted from PROPFIND responses
+	AccessRules []AccessRule
+
+	// Permissions decide whether users may read, write, delete or lock
+	// resources, consulted before each operation and reported in the
+	// current-user-privilege-set property
+	Permissions Permissions
+
+	// Metrics collects Prometheus metrics about requests, see NewMetrics.
+	// It can be shared by several mounts
+	Metrics *Metrics
+
+	// DirectoryListing serves an HTML listing of collections on GET, or a
+	// JSON one to clients preferring application/json, to browse the mount
+	// with a web browser
+	DirectoryListing bool
+
+	// WebUI serves a single-page file manager to web browsers visiting a
+	// collection, to upload (including with drag and drop), download, rename
+	// and delete files and create folders
+	WebUI bool
+
+	// CollectionGet serves GET and HEAD requests on the root collection or
+	// on any collection like PROPFIND requests with Depth 1, for clients
+	// such as Sardine checking collections with them. Listings take
+	// precedence
+	CollectionGet CollectionGet
+
+	// TracerProvider enables OpenTelemetry tracing: a span is created for
+	// each request, with child spans for the FileSystem, LockSystem and
+	// PropertyStore calls, e.g. to find the backend calls slowing down a
+	// PROPFIND
+	TracerProvider trace.TracerProvider
+
+	// PropFindWorkers computes the PROPFIND responses of that many members
+	// of a collection concurrently, cutting the latency of Depth: 1 listings
+	// with backends making remote calls. Responses keep their order
+	PropFindWorkers int
+
+	// StatCacheTTL caches resource metadata and collection members for that
+	// long, so that sync clients polling with PROPFIND don't hit the
+	// FileSystem for unchanged entries. Writes through the mount invalidate
+	// the affected entries, as do external changes to a watchable
+	// FileSystem such as LocalFileSystem
+	StatCacheTTL time.Duration
+
+	// MaxPropFindResponses caps the number of responses of a PROPFIND
+	// request, truncating the multistatus with a 507 Insufficient Storage
+	// response for the request URI once reached. This bounds the work done
+	// for Depth: infinity requests on huge trees
+	MaxPropFindResponses int
+
+	// Precompressed serves a file's fresh name.br or name.gz sibling with the
+	// matching Content-Encoding to clients accepting it, sparing on-the-fly
+	// compression of static content
+	Precompressed bool
+
+	// RedirectCollections redirects GET, HEAD and PROPFIND requests on
+	// collections without a trailing slash to the path with one, for
+	// clients resolving relative links against the request URL
+	RedirectCollections bool
+
+	// SafeDownloads serves files as attachments with nosniff and a
+	// restrictive Content-Security-Policy, so that shared HTML can't be used
+	// for stored cross-site scripting against the serving domain
+	SafeDownloads bool
+
+	// NamePolicy restricts the names of the resources created by clients,
+	// e.g. &PortableNames to reject names Windows can't store
+	NamePolicy *NamePolicy
+
+	// ExternalURLs are the base URLs clients reach the server with, e.g.
+	// "https://dav.example.com" behind a TLS-terminating proxy. Destination
+	// headers of COPY and MOVE requests naming a host must match the scheme
+	// and host of one of them; any host is accepted if empty
+	ExternalURLs []string
+
+	// AuditSink receives an event (user, method, path, destination, status,
+	// bytes, duration) for each request modifying resources, e.g. a
+	// FileAuditSink writing JSON lines
+	AuditSink AuditSink
+
+	// SlowRequests logs requests taking longer than a duration and PROPFIND
+	// requests producing more than a number of responses, and optionally
+	// calls a hook for them
+	SlowRequests *SlowRequestLog
+
+	// Stats accumulates the bytes uploaded and downloaded and the requests
+	// of each user, e.g. a MemStatsStore. Serve them with StatsHandler
+	Stats StatsStore
 }
 
+// New creates a Fiber handler serving WebDAV. Several configurations with
+// different prefixes can be passed to serve multiple mounts, e.g. with
+// different roots or permissions. Requests are served by the mount with the
+// longest matching prefix, other requests are passed to the next handler.
 func New(config ...Config) fiber.Handler {
 	if len(config) == 0 {
 		log.Warn("webdav: configuration is nil - using empty handler")
@@ -44,16 +276,313 @@ func New(config ...Config) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).SendString("webdav: configuration required")
 		}
 	}
-	c := config[0]
-	prefix := c.Prefix
+	servers := make([]*Server, len(config))
+	for i, c := range config {
+		servers[i] = NewServer(c)
+	}
+	return Mounts(servers...)
+}
 
-	w := &Handler{FileSystem: c.Root}
-	if c.Lock {
+// NewContinue is like New, but additionally returns a function to install as
+// the ContinueHandler of the Fiber app's underlying fasthttp server:
+//
+//	handler, continueHandler := webdav.NewContinue(config)
+//	app.Server().ContinueHandler = continueHandler
+//	app.Use("/", handler)
+//
+// Fasthttp reads request bodies before invoking handlers. The continue handler
+// authenticates and authorizes uploads sent with "Expect: 100-continue" like
+// the handler, and evaluates their preconditions and the Quota of the Root
+// against their Content-Length, before the body is read. It rejects them with
+// "417 Expectation Failed" if they would fail anyway.
+//
+// Client certificates aren't known before the body is read, and neither are
+// users authenticated by other middlewares: uploads from these clients are
+// only checked by the handler if the Permissions, AccessRules, Authorize or
+// HomeDirs depend on the user. Authorize is called with a Fiber context
+// holding the request header and the user only.
+func NewContinue(config Config) (fiber.Handler, func(header *fasthttp.RequestHeader) bool) {
+	s := NewServer(config)
+	return s.FiberHandler(), newContinueHandler(config, s.handler)
+}
+
+func newHandler(c Config) *Handler {
+	w := &Handler{
+		Prefix:         c.Prefix,
+		FileSystem:     c.Root,
+		PropertyStore:  c.PropertyStore,
+		Capabilities:   c.Capabilities,
+		ExtraMethods:   c.ExtraMethods,
+		Checksum:       c.Checksum,
+		Reports:        c.Reports,
+		Compress:       c.Compress,
+		ReadOnly:       c.ReadOnly,
+		AllowedMethods: c.AllowedMethods,
+		DeniedMethods:  c.DeniedMethods,
+		Limits:         c.Limits,
+		Logger:         c.Logger,
+		Hooks:          c.Hooks,
+		Webhooks:       c.Webhooks,
+		Publisher:      c.Publisher,
+		ChangeFeed:     c.ChangeFeed,
+		CollectionGet:  c.CollectionGet,
+		ExternalURLs:   c.ExternalURLs,
+
+		DetectContentType: c.DetectContentType,
+		MIMETypes:         c.MIMETypes,
+		MetadataHeaders:   c.MetadataHeaders,
+		ScanUpload:        c.ScanUpload,
+		Processors:        c.Processors,
+		AccessRules:       c.AccessRules,
+		Permissions:       c.Permissions,
+		Metrics:           c.Metrics,
+		DirectoryListing:  c.DirectoryListing,
+		WebUI:             c.WebUI,
+		TracerProvider:    c.TracerProvider,
+		PropFindWorkers:   c.PropFindWorkers,
+		StatCacheTTL:      c.StatCacheTTL,
+		Precompressed:     c.Precompressed,
+		SafeDownloads:     c.SafeDownloads,
+		NamePolicy:        c.NamePolicy,
+		AuditSink:         c.AuditSink,
+		SlowRequests:      c.SlowRequests,
+		Stats:             c.Stats,
+
+		MaxPropFindResponses: c.MaxPropFindResponses,
+		RedirectCollections:  c.RedirectCollections,
+	}
+	if c.LockSystem != nil {
+		w.LockSystem = c.LockSystem
+	} else if c.Lock {
 		w.LockSystem = NewLockSystem()
 	}
-	handler := adaptor.HTTPHandler(w)
+	return w
+}
+
+func newFiberHandler(config Config, w http.Handler) fiber.Handler {
+	handler := adaptor.HTTPHandler(withConnContext(w))
 	return func(c *fiber.Ctx) error {
-		c.Path(strings.TrimLeft(c.Path(), prefix))
+		if config.URLSigner != nil {
+			ok, err := verifySignedURL(c, config.URLSigner)
+			if err != nil {
+				return serveAuthorizeError(c, err)
+			} else if ok {
+				return handler(c)
+			}
+		}
+
+		authenticated := false
+		if config.ClientCertificates != nil {
+			user, ok, err := authenticateCertificate(c.UserContext(), config.ClientCertificates, c.Context().TLSConnectionState())
+			if err != nil {
+				return serveAuthorizeError(c, err)
+			} else if ok {
+				SetUser(c, user)
+				authenticated = true
+			} else if config.TokenValidator == nil {
+				return serveAuthorizeError(c, errClientCertificateRequired)
+			}
+		}
+		if config.TokenValidator != nil && !authenticated {
+			if err := authenticateToken(c, config.TokenValidator); err != nil {
+				return serveAuthorizeError(c, err)
+			}
+		}
+		if config.Authorize != nil {
+			if err := authorize(c, config); err != nil {
+				return serveAuthorizeError(c, err)
+			}
+		}
+		// Reuse the request ID generated by the requestid middleware. The
+		// handler sets it in the response again.
+		if id := c.GetRespHeader(RequestIDHeader); id != "" {
+			if c.Get(RequestIDHeader) == "" {
+				c.Request().Header.Set(RequestIDHeader, id)
+			}
+			c.Response().Header.Del(RequestIDHeader)
+		}
 		return handler(c)
 	}
 }
+
+// authorize calls Config.Authorize for the resources touched by a request.
+// Paths are relative to the mount prefix.
+func authorize(c *fiber.Ctx, config Config) error {
+	prefix := cleanPrefix(config.Prefix)
+	method := c.Method()
+	p, err := normalizePath(fiberPath(c))
+	if err != nil {
+		// Let the handler reject the request
+		return nil
+	}
+	p, ok := stripPrefix(p, prefix)
+	if !ok {
+		// Let the handler reject the request
+		return nil
+	}
+	if err := config.Authorize(c, method, p); err != nil {
+		return err
+	}
+
+	if method != MethodCopy && method != MethodMove {
+		return nil
+	}
+	dest, err := internal.ParseDestination(c.Get("Destination"))
+	if err != nil {
+		return nil
+	}
+	destPath, err := resolveDestination(dest, prefix, config.ExternalURLs)
+	if err != nil {
+		return nil
+	}
+	return config.Authorize(c, method, destPath)
+}
+
+// verifySignedURL checks the signature of a request for a signed URL. It
+// returns false if the URL isn't signed.
+func verifySignedURL(c *fiber.Ctx, s *URLSigner) (bool, error) {
+	query, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return false, nil
+	}
+	return s.verify(c.Method(), fiberPath(c), query)
+}
+
+// fiberPath returns the path of a request handled by Fiber, decoded once
+// like the paths seen by the handler. Ctx.Path is only decoded if the app is
+// configured with UnescapePath, so the original path is decoded instead.
+func fiberPath(c *fiber.Ctx) string {
+	raw := string(c.Request().URI().PathOriginal())
+	if p, err := url.PathUnescape(raw); err == nil {
+		return p
+	}
+	return raw
+}
+
+// authenticateToken validates the bearer token of a request and stores the
+// user it identifies in the request context.
+func authenticateToken(c *fiber.Ctx, v TokenValidator) error {
+	token, ok := parseBearerToken(c.Get(fiber.HeaderAuthorization))
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return NewHTTPError(fiber.StatusUnauthorized, errors.New("webdav: bearer token required"))
+	}
+
+	user, err := v.ValidateToken(c.UserContext(), token)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return NewHTTPError(fiber.StatusUnauthorized, err)
+	}
+
+	SetUser(c, user)
+	return nil
+}
+
+// SetUser sets the authenticated user of a request handled by the Fiber
+// middleware. It can be used by authentication middlewares running before
+// it, e.g. to enable home directories.
+func SetUser(c *fiber.Ctx, user string) {
+	// The user context is used by Fiber handlers, the fasthttp user values
+	// by the http.Request context seen by the WebDAV handler
+	c.SetUserContext(ContextWithUser(c.UserContext(), user))
+	c.Context().SetUserValue(userContextKey{}, user)
+}
+
+func serveAuthorizeError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusForbidden
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) {
+		code = httpErr.Code
+	}
+	return c.Status(code).SendString(err.Error())
+}
+
+func newContinueHandler(config Config, h serverHandler) func(header *fasthttp.RequestHeader) bool {
+	prefix := cleanPrefix(config.Prefix)
+	return func(header *fasthttp.RequestHeader) bool {
+		u, err := url.ParseRequestURI(string(header.RequestURI()))
+		if err != nil {
+			return true
+		}
+		if p, err := normalizePath(u.Path); err != nil {
+			return true
+		} else if _, ok := stripPrefix(p, prefix); !ok {
+			// Not ours, let the request go through
+			return true
+		}
+
+		r, err := http.NewRequest(string(header.Method()), u.String(), nil)
+		if err != nil {
+			return true
+		}
+		header.VisitAll(func(k, v []byte) {
+			r.Header.Add(string(k), string(v))
+		})
+		r.ContentLength = max(int64(header.ContentLength()), 0)
+
+		r, ok, err := authenticateContinue(config, header, r)
+		if err == nil && ok {
+			err = h.checkContinue(r)
+		}
+		if err != nil {
+			log.Debugf("webdav: rejecting request with Expect: 100-continue: %v", err)
+			return false
+		}
+		return true
+	}
+}
+
+// authenticateContinue authenticates and authorizes a request before its
+// body is read, like the Fiber handler. It reports false if the user can't
+// be known yet and the handler depends on it.
+func authenticateContinue(config Config, header *fasthttp.RequestHeader, r *http.Request) (*http.Request, bool, error) {
+	if config.URLSigner != nil {
+		ok, err := config.URLSigner.verify(r.Method, r.URL.Path, r.URL.Query())
+		if err != nil {
+			return r, false, err
+		} else if ok {
+			return r, true, nil
+		}
+	}
+
+	userDependent := config.Permissions != nil || config.Authorize != nil || config.HomeDirs || len(config.AccessRules) > 0
+	switch {
+	case config.ClientCertificates != nil:
+		// The TLS connection state isn't known yet
+		return r, !userDependent, nil
+	case config.TokenValidator != nil:
+		token, ok := parseBearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			return r, false, NewHTTPError(http.StatusUnauthorized, errors.New("webdav: bearer token required"))
+		}
+		user, err := config.TokenValidator.ValidateToken(r.Context(), token)
+		if err != nil {
+			return r, false, NewHTTPError(http.StatusUnauthorized, err)
+		}
+		r = r.WithContext(ContextWithUser(r.Context(), user))
+	case userDependent:
+		// The user is authenticated by other middlewares
+		return r, false, nil
+	}
+
+	if config.Authorize != nil {
+		app := continueApp()
+		fctx := &fasthttp.RequestCtx{}
+		header.CopyTo(&fctx.Request.Header)
+		c := app.AcquireCtx(fctx)
+		defer app.ReleaseCtx(c)
+		if user, ok := UserFromContext(r.Context()); ok {
+			SetUser(c, user)
+		}
+		if err := authorize(c, config); err != nil {
+			return r, false, err
+		}
+	}
+	return r, true, nil
+}
+
+// continueApp is the Fiber app of the contexts passed to Config.Authorize by
+// continue handlers, which only get the request header.
+var continueApp = sync.OnceValue(func() *fiber.App {
+	return fiber.New()
+})
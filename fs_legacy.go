@@ -0,0 +1,655 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// AdaptLegacyFS returns a FileSystem backed by a golang.org/x/net/webdav
+// FileSystem, e.g. webdav.Dir or webdav.NewMemFS(), so that existing storage
+// layers written for x/net/webdav can be served by Handler, with its property
+// stores and lock systems.
+//
+// The legacy interface has no way to set modification times, so
+// CreateOptions.ModTime is ignored. Entity tags are the ones reported by
+// webdav.ETager FileInfos, or derived from the modification time and size.
+func AdaptLegacyFS(fs xwebdav.FileSystem) FileSystem {
+	return &legacyFileSystem{fs: fs}
+}
+
+// legacyFileSystem adapts an x/net/webdav FileSystem to FileSystem.
+type legacyFileSystem struct {
+	fs xwebdav.FileSystem
+}
+
+var _ FileSystem = (*legacyFileSystem)(nil)
+
+func (fs *legacyFileSystem) fileInfo(ctx context.Context, name string, fi os.FileInfo) *FileInfo {
+	info := fileInfoFromOS(name, fi)
+	if et, ok := fi.(xwebdav.ETager); ok {
+		if etag, err := et.ETag(ctx); err == nil {
+			weak := len(etag) > 2 && etag[:2] == "W/"
+			if weak {
+				etag = etag[2:]
+			}
+			if len(etag) >= 2 && etag[0] == '"' && etag[len(etag)-1] == '"' {
+				info.ETag, info.WeakETag = etag[1:len(etag)-1], weak
+			}
+		}
+	}
+	if ct, ok := fi.(xwebdav.ContentTyper); ok {
+		if typ, err := ct.ContentType(ctx); err == nil {
+			info.MIMEType = typ
+		}
+	}
+	return info
+}
+
+func (fs *legacyFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := fs.fs.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	// Files are seekable, ranges are served from them directly
+	return f, nil
+}
+
+func (fs *legacyFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	fi, err := fs.fs.Stat(ctx, name)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	return fs.fileInfo(ctx, name, fi), nil
+}
+
+func (fs *legacyFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	fi, err := fs.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	l := []FileInfo{*fi}
+	if !fi.IsDir {
+		return l, nil
+	}
+	err = fs.readDir(ctx, name, recursive, func(fi *FileInfo) {
+		l = append(l, *fi)
+	})
+	return l, err
+}
+
+// readDir calls fn for the members of the directory name, in name order,
+// and for their descendants if recursive is set.
+func (fs *legacyFileSystem) readDir(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo)) error {
+	f, err := fs.fs.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return errFromOS(err)
+	}
+	children, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return errFromOS(err)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	for _, child := range children {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p := path.Join(name, child.Name())
+		fn(fs.fileInfo(ctx, p, child))
+		if recursive && child.IsDir() {
+			if err := fs.readDir(ctx, p, true, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *legacyFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	fi, _ = fs.Stat(ctx, name)
+	created = fi == nil
+
+	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return nil, false, err
+	}
+	if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+		return nil, false, err
+	}
+	if fi != nil && fi.IsDir {
+		return nil, false, NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("resource is a collection"))
+	}
+
+	// Uploads to verify are staged next to the file, and renamed over it
+	// once verified
+	dst := name
+	if opts.Verify != nil {
+		dst = path.Join(path.Dir(name), stagingName(path.Base(name)))
+	}
+	f, err := fs.fs.OpenFile(ctx, dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.7.1
+		return nil, false, NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	} else if err != nil {
+		return nil, false, errFromOS(err)
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Don't leave a truncated upload behind
+		fs.fs.RemoveAll(ctx, dst)
+		return nil, false, errFromOS(err)
+	}
+	if dst != name {
+		err := opts.Verify(ctx, func() (io.ReadCloser, error) {
+			return fs.fs.OpenFile(ctx, dst, os.O_RDONLY, 0)
+		})
+		if err == nil {
+			err = errFromOS(fs.fs.Rename(ctx, dst, name))
+		}
+		if err != nil {
+			fs.fs.RemoveAll(context.WithoutCancel(ctx), dst)
+			return nil, false, err
+		}
+	}
+
+	fi, err = fs.Stat(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	return fi, created, nil
+}
+
+func (fs *legacyFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	// The legacy interface doesn't report missing resources
+	fi, err := fs.Stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return err
+	}
+	if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+		return err
+	}
+	return errFromOS(fs.fs.RemoveAll(ctx, name))
+}
+
+func (fs *legacyFileSystem) Mkdir(ctx context.Context, name string) error {
+	err := fs.fs.Mkdir(ctx, name, 0755)
+	if errors.Is(err, iofs.ErrExist) {
+		return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("resource already exists"))
+	} else if isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.3.1
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	}
+	return errFromOS(err)
+}
+
+// prepareDest checks whether dst may be replaced, and removes it if it
+// exists. It reports whether dst is created rather than replaced.
+func (fs *legacyFileSystem) prepareDest(ctx context.Context, dst string, noOverwrite bool) (created bool, err error) {
+	if _, err := fs.fs.Stat(ctx, dst); err != nil {
+		if !isMissingParent(err) {
+			return false, errFromOS(err)
+		}
+		return true, nil
+	}
+	if noOverwrite {
+		return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
+	}
+	return false, errFromOS(fs.fs.RemoveAll(ctx, dst))
+}
+
+func (fs *legacyFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	if path.Clean(dst) == "/" {
+		return false, errReplaceRoot
+	}
+	srcInfo, err := fs.fs.Stat(ctx, src)
+	if err != nil {
+		return false, errFromOS(err)
+	}
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
+	}
+	created, err = fs.prepareDest(ctx, dst, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+	if err := fs.copy(ctx, src, dst, srcInfo, !options.NoRecursive); err != nil {
+		if isMissingParent(err) {
+			// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.8.5
+			return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+		}
+		return false, errFromOS(err)
+	}
+	return created, nil
+}
+
+// copy copies the resource src, described by fi, to dst, with its members
+// if recursive is set.
+func (fs *legacyFileSystem) copy(ctx context.Context, src, dst string, fi os.FileInfo, recursive bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if err := fs.fs.Mkdir(ctx, dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		if !recursive {
+			return nil
+		}
+		f, err := fs.fs.OpenFile(ctx, src, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		children, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := fs.copy(ctx, path.Join(src, child.Name()), path.Join(dst, child.Name()), child, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	in, err := fs.fs.OpenFile(ctx, src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fs.fs.OpenFile(ctx, dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (fs *legacyFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	if path.Clean(dst) == "/" {
+		return false, errReplaceRoot
+	}
+	srcInfo, err := fs.fs.Stat(ctx, src)
+	if err != nil {
+		return false, errFromOS(err)
+	}
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
+	}
+	created, err = fs.prepareDest(ctx, dst, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+	if err := fs.fs.Rename(ctx, src, dst); isMissingParent(err) {
+		// The source exists, so the destination parent doesn't. Return 409
+		// Conflict as per RFC4918
+		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	} else if err != nil {
+		return false, errFromOS(err)
+	}
+	return created, nil
+}
+
+// ToLegacyFS returns a golang.org/x/net/webdav FileSystem backed by fs, so
+// that code written against x/net/webdav, such as its Handler, can use the
+// FileSystems of this package.
+//
+// Files opened for writing are replaced by what's written once closed, as if
+// os.O_TRUNC was set; os.O_APPEND isn't supported. Calling Stat on them
+// completes the write early, like x/net/webdav.Handler does before closing
+// uploaded files, so that it reports the entity tag of the new content.
+func ToLegacyFS(fs FileSystem) xwebdav.FileSystem {
+	return &toLegacyFileSystem{fs: fs}
+}
+
+// toLegacyFileSystem adapts a FileSystem to the x/net/webdav FileSystem
+// interface.
+type toLegacyFileSystem struct {
+	fs FileSystem
+}
+
+var _ xwebdav.FileSystem = (*toLegacyFileSystem)(nil)
+
+// legacyError returns err with the os errors expected by x/net/webdav for
+// HTTPErrors, e.g. os.ErrNotExist for "404 Not Found".
+func legacyError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var httpErr *internal.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	switch httpErr.Code {
+	case http.StatusNotFound, http.StatusConflict:
+		// Conflicts are missing parents
+		err = iofs.ErrNotExist
+	case http.StatusMethodNotAllowed, http.StatusPreconditionFailed:
+		err = iofs.ErrExist
+	case http.StatusForbidden:
+		err = iofs.ErrPermission
+	}
+	return &iofs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (fs *toLegacyFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return legacyError("mkdir", name, fs.fs.Mkdir(ctx, name))
+}
+
+func (fs *toLegacyFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (xwebdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		fi, err := fs.fs.Stat(ctx, name)
+		if err != nil {
+			return nil, legacyError("open", name, err)
+		}
+		return &legacyFile{ctx: ctx, fs: fs.fs, fi: fi}, nil
+	}
+
+	if flag&os.O_APPEND != 0 {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	}
+	opts := &CreateOptions{}
+	if flag&os.O_CREATE == 0 {
+		if _, err := fs.fs.Stat(ctx, name); err != nil {
+			return nil, legacyError("open", name, err)
+		}
+	} else {
+		// Report missing parents right away rather than on Close
+		if _, err := fs.fs.Stat(ctx, path.Dir(name)); err != nil {
+			return nil, legacyError("open", name, err)
+		}
+		if flag&os.O_EXCL != 0 {
+			opts.IfNoneMatch = "*"
+		}
+	}
+	pr, pw := io.Pipe()
+	f := &legacyWriteFile{name: name, pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		var err error
+		f.fi, _, err = fs.fs.Create(ctx, name, pr, opts)
+		f.err = legacyError("write", name, err)
+		pr.CloseWithError(f.err)
+	}()
+	return f, nil
+}
+
+func (fs *toLegacyFileSystem) RemoveAll(ctx context.Context, name string) error {
+	err := fs.fs.RemoveAll(ctx, name, &RemoveAllOptions{})
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound {
+		// Like os.RemoveAll
+		return nil
+	}
+	return legacyError("remove", name, err)
+}
+
+func (fs *toLegacyFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	_, err := fs.fs.Move(ctx, oldName, newName, &MoveOptions{})
+	return legacyError("rename", oldName, err)
+}
+
+func (fs *toLegacyFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	fi, err := fs.fs.Stat(ctx, name)
+	if err != nil {
+		return nil, legacyError("stat", name, err)
+	}
+	return legacyFileInfo{fi}, nil
+}
+
+// legacyFileInfo adapts a FileInfo to os.FileInfo, and reports its entity tag
+// and media type to x/net/webdav.
+type legacyFileInfo struct {
+	fi *FileInfo
+}
+
+var (
+	_ xwebdav.ETager       = legacyFileInfo{}
+	_ xwebdav.ContentTyper = legacyFileInfo{}
+)
+
+func (fi legacyFileInfo) Name() string       { return path.Base(fi.fi.Path) }
+func (fi legacyFileInfo) Size() int64        { return fi.fi.Size }
+func (fi legacyFileInfo) ModTime() time.Time { return fi.fi.ModTime }
+func (fi legacyFileInfo) IsDir() bool        { return fi.fi.IsDir }
+func (fi legacyFileInfo) Sys() any           { return fi.fi }
+
+func (fi legacyFileInfo) Mode() os.FileMode {
+	switch {
+	case fi.fi.IsDir:
+		return os.ModeDir | 0755
+	case fi.fi.Executable:
+		return 0755
+	}
+	return 0644
+}
+
+func (fi legacyFileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.fi.ETag == "" {
+		return "", xwebdav.ErrNotImplemented
+	}
+	etag := `"` + fi.fi.ETag + `"`
+	if fi.fi.WeakETag {
+		etag = "W/" + etag
+	}
+	return etag, nil
+}
+
+func (fi legacyFileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.fi.MIMEType != "" {
+		return fi.fi.MIMEType, nil
+	} else if typ := defaultMIMETypes.TypeByExtension(path.Ext(fi.fi.Path)); typ != "" {
+		return typ, nil
+	}
+	return "", xwebdav.ErrNotImplemented
+}
+
+// legacyFile is a file or directory opened for reading by
+// toLegacyFileSystem. Files are opened on first read, and reopened when
+// seeking backwards unless Open returns an io.Seeker.
+type legacyFile struct {
+	ctx context.Context
+	fs  FileSystem
+	fi  *FileInfo
+
+	rc  io.ReadCloser
+	pos int64 // offset of rc
+	off int64 // offset of the next read
+
+	children []os.FileInfo
+	listed   bool
+}
+
+var _ xwebdav.File = (*legacyFile)(nil)
+
+func (f *legacyFile) Read(b []byte) (int, error) {
+	if f.fi.IsDir {
+		return 0, &iofs.PathError{Op: "read", Path: f.fi.Path, Err: errors.New("is a directory")}
+	}
+	if f.rc != nil && f.pos != f.off {
+		if s, ok := f.rc.(io.Seeker); ok {
+			if _, err := s.Seek(f.off, io.SeekStart); err != nil {
+				return 0, err
+			}
+		} else if f.off > f.pos {
+			if _, err := io.CopyN(io.Discard, f.rc, f.off-f.pos); err != nil {
+				return 0, err
+			}
+		} else {
+			f.rc.Close()
+			f.rc = nil
+		}
+		f.pos = f.off
+	}
+	if f.rc == nil {
+		rc, err := f.open()
+		if err != nil {
+			return 0, legacyError("read", f.fi.Path, err)
+		}
+		f.rc, f.pos = rc, f.off
+	}
+	n, err := f.rc.Read(b)
+	f.pos += int64(n)
+	f.off = f.pos
+	return n, err
+}
+
+// open opens the file at the current offset.
+func (f *legacyFile) open() (io.ReadCloser, error) {
+	if f.off >= f.fi.Size {
+		return io.NopCloser(eofReader{}), nil
+	}
+	if f.off > 0 {
+		if rfs, ok := fileSystemAs[RangeFileSystem](f.fs); ok {
+			return rfs.OpenRange(f.ctx, f.fi.Path, f.off, f.fi.Size-f.off)
+		}
+	}
+	rc, err := f.fs.Open(f.ctx, f.fi.Path)
+	if err != nil || f.off == 0 {
+		return rc, err
+	}
+	if s, ok := rc.(io.Seeker); ok {
+		_, err = s.Seek(f.off, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, rc, f.off)
+	}
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (f *legacyFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.fi.Size
+	}
+	if offset < 0 {
+		return 0, &iofs.PathError{Op: "seek", Path: f.fi.Path, Err: iofs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *legacyFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.fi.IsDir {
+		return nil, &iofs.PathError{Op: "readdir", Path: f.fi.Path, Err: errors.New("not a directory")}
+	}
+	if !f.listed {
+		l, err := f.fs.ReadDir(f.ctx, f.fi.Path, false)
+		if err != nil {
+			return nil, legacyError("readdir", f.fi.Path, err)
+		}
+		for i := range l {
+			if path.Clean(l[i].Path) != path.Clean(f.fi.Path) {
+				f.children = append(f.children, legacyFileInfo{&l[i]})
+			}
+		}
+		f.listed = true
+	}
+	if count <= 0 {
+		l := f.children
+		f.children = nil
+		return l, nil
+	}
+	if len(f.children) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.children))
+	l := f.children[:n]
+	f.children = f.children[n:]
+	return l, nil
+}
+
+func (f *legacyFile) Stat() (os.FileInfo, error) {
+	return legacyFileInfo{f.fi}, nil
+}
+
+func (f *legacyFile) Write(b []byte) (int, error) {
+	return 0, &iofs.PathError{Op: "write", Path: f.fi.Path, Err: iofs.ErrPermission}
+}
+
+func (f *legacyFile) Close() error {
+	if f.rc != nil {
+		return f.rc.Close()
+	}
+	return nil
+}
+
+// eofReader is an empty io.Reader.
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// legacyWriteFile is a file opened for writing by toLegacyFileSystem. What's
+// written is streamed to Create, which completes on Close or Stat.
+type legacyWriteFile struct {
+	name    string
+	pw      *io.PipeWriter
+	written int64
+	done    chan struct{}
+	fi      *FileInfo
+	err     error
+}
+
+var _ xwebdav.File = (*legacyWriteFile)(nil)
+
+func (f *legacyWriteFile) Write(b []byte) (int, error) {
+	n, err := f.pw.Write(b)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *legacyWriteFile) Close() error {
+	f.pw.Close()
+	<-f.done
+	return f.err
+}
+
+func (f *legacyWriteFile) Stat() (os.FileInfo, error) {
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return legacyFileInfo{f.fi}, nil
+}
+
+func (f *legacyWriteFile) Read([]byte) (int, error) {
+	return 0, &iofs.PathError{Op: "read", Path: f.name, Err: iofs.ErrPermission}
+}
+
+func (f *legacyWriteFile) Seek(offset int64, whence int) (int64, error) {
+	// Only the current offset can be queried, e.g. by io.Copy
+	if offset == 0 && whence == io.SeekCurrent || offset == f.written && whence == io.SeekStart {
+		return f.written, nil
+	}
+	return 0, &iofs.PathError{Op: "seek", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *legacyWriteFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &iofs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+}
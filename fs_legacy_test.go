@@ -0,0 +1,75 @@
+package webdav_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestAdaptLegacyFS(t *testing.T) {
+	h := &webdav.Handler{FileSystem: webdav.AdaptLegacyFS(xwebdav.NewMemFS())}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: "MKCOL", target: "/docs"}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/docs/a.txt", body: "hello world"}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/missing/a.txt", body: "a"}, http.StatusConflict},
+		{testRequest{method: http.MethodPut, target: "/docs/a.txt", body: "hello", header: map[string]string{"If-None-Match": "*"}}, http.StatusPreconditionFailed},
+		{testRequest{method: http.MethodGet, target: "/docs/a.txt", header: map[string]string{"Range": "bytes=6-"}}, http.StatusPartialContent},
+		{testRequest{method: "COPY", target: "/docs/", header: map[string]string{"Destination": "/copy/"}}, http.StatusCreated},
+		{testRequest{method: "MOVE", target: "/copy/a.txt", header: map[string]string{"Destination": "/b.txt"}}, http.StatusCreated},
+		{testRequest{method: http.MethodGet, target: "/b.txt"}, http.StatusOK},
+		{testRequest{method: http.MethodDelete, target: "/docs/"}, http.StatusNoContent},
+		{testRequest{method: http.MethodGet, target: "/docs/a.txt"}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		w := checkStatus(t, h, tc.req, tc.want)
+		if tc.req.header["Range"] != "" && w.Body.String() != "world" {
+			t.Errorf("GET range = %q, want world", w.Body)
+		}
+	}
+
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	for _, name := range []string{"/b.txt", "/copy/"} {
+		if !strings.Contains(w.Body.String(), "<href>"+name+"</href>") {
+			t.Errorf("PROPFIND: missing %v in\n%s", name, w.Body)
+		}
+	}
+}
+
+func TestToLegacyFS(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/a.txt": "hello world"})
+	h := &xwebdav.Handler{
+		FileSystem: webdav.ToLegacyFS(webdav.LocalFileSystem(dir)),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodPut, target: "/docs/b.txt", body: "b"}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/missing/b.txt", body: "b"}, http.StatusConflict},
+		{testRequest{method: http.MethodGet, target: "/docs/a.txt", header: map[string]string{"Range": "bytes=6-"}}, http.StatusPartialContent},
+		{testRequest{method: "MKCOL", target: "/new"}, http.StatusCreated},
+		{testRequest{method: "MKCOL", target: "/new"}, http.StatusMethodNotAllowed},
+		{testRequest{method: "MOVE", target: "/docs/b.txt", header: map[string]string{"Destination": "/new/b.txt"}}, http.StatusCreated},
+		{testRequest{method: "PROPFIND", target: "/docs/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus},
+		{testRequest{method: http.MethodDelete, target: "/docs/"}, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		w := checkStatus(t, h, tc.req, tc.want)
+		if tc.req.header["Range"] != "" && w.Body.String() != "world" {
+			t.Errorf("GET range = %q, want world", w.Body)
+		}
+	}
+	checkFile(t, dir, "new/b.txt", "b")
+	checkMissing(t, dir, "docs")
+}
@@ -2,16 +2,20 @@ package webdav
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"mime"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/Tryanks/fiber-webdav/internal"
 )
@@ -19,7 +23,15 @@ import (
 // LocalFileSystem implements FileSystem for a local directory.
 type LocalFileSystem string
 
-var _ FileSystem = LocalFileSystem("")
+var (
+	_ FileSystem           = LocalFileSystem("")
+	_ ExecutableFileSystem = LocalFileSystem("")
+	_ SubFileSystem        = LocalFileSystem("")
+	_ WalkFileSystem       = LocalFileSystem("")
+)
+
+// walkBatchSize is the number of directory entries read at once by Walk.
+const walkBatchSize = 256
 
 func (fs LocalFileSystem) localPath(name string) (string, error) {
 	if (filepath.Separator != '/' && strings.IndexRune(name, filepath.Separator) >= 0) || strings.Contains(name, "\x00") {
@@ -37,7 +49,16 @@ func (fs LocalFileSystem) externalPath(name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return "/" + filepath.ToSlash(rel), nil
+	return path.Clean("/" + filepath.ToSlash(rel)), nil
+}
+
+// Sub returns a LocalFileSystem for the directory name.
+func (fs LocalFileSystem) Sub(name string) (FileSystem, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return LocalFileSystem(p), nil
 }
 
 func (fs LocalFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
@@ -50,12 +71,13 @@ func (fs LocalFileSystem) Open(ctx context.Context, name string) (io.ReadCloser,
 
 func fileInfoFromOS(p string, fi os.FileInfo) *FileInfo {
 	return &FileInfo{
-		Path:    p,
-		Size:    fi.Size(),
-		ModTime: fi.ModTime(),
-		IsDir:   fi.IsDir(),
-		// TODO: fallback to http.DetectContentType?
-		MIMEType: mime.TypeByExtension(path.Ext(p)),
+		Path:       p,
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime(),
+		IsDir:      fi.IsDir(),
+		Executable: !fi.IsDir() && fi.Mode()&0100 != 0,
+		// Content types of extensionless files are sniffed by the handler
+		MIMEType: defaultMIMETypes.TypeByExtension(path.Ext(p)),
 		// RFC 2616 section 13.3.3 describes strong ETags. Ideally these would
 		// be checksums or sequence numbers, however these are expensive to
 		// compute. The modification time with nanosecond granularity is good
@@ -72,17 +94,28 @@ func errFromOS(err error) error {
 		err = fmt.Errorf("%s: %w", perr.Op, perr.Err)
 	}
 
-	if errors.Is(err, fs.ErrNotExist) {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		// A path going through a file doesn't exist either
 		return NewHTTPError(http.StatusNotFound, err)
 	} else if errors.Is(err, fs.ErrPermission) {
 		return NewHTTPError(http.StatusForbidden, err)
-	} else if errors.Is(err, os.ErrDeadlineExceeded) {
+	} else if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
 		return NewHTTPError(http.StatusServiceUnavailable, err)
+	} else if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		// The disk is full or the user's quota is exhausted
+		return NewHTTPError(http.StatusInsufficientStorage, err)
 	} else {
 		return err
 	}
 }
 
+// isMissingParent reports whether err, returned when creating a file or a
+// directory, means that its parent collection doesn't exist, including when
+// one of its ancestors is a file.
+func isMissingParent(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
 func (fs LocalFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
 	p, err := fs.localPath(name)
 	if err != nil {
@@ -106,6 +139,9 @@ func (fs LocalFileSystem) ReadDir(ctx context.Context, name string, recursive bo
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		href, err := fs.externalPath(p)
 		if err != nil {
@@ -122,28 +158,143 @@ func (fs LocalFileSystem) ReadDir(ctx context.Context, name string, recursive bo
 	return l, errFromOS(err)
 }
 
+// Walk implements WalkFileSystem. Directories are read in batches, so that
+// large directories aren't loaded in memory at once. Members are enumerated
+// in directory order.
+func (fs LocalFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return errFromOS(err)
+	}
+	href, err := fs.externalPath(p)
+	if err != nil {
+		return err
+	}
+	if err := fn(fileInfoFromOS(href, fi)); err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+	return fs.walkDir(ctx, p, recursive, fn)
+}
+
+func (fs LocalFileSystem) walkDir(ctx context.Context, p string, recursive bool, fn func(fi *FileInfo) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return errFromOS(err)
+	}
+	defer f.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, readErr := f.ReadDir(walkBatchSize)
+		for _, entry := range entries {
+			child := filepath.Join(p, entry.Name())
+			fi, err := entry.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed since the directory was read
+				continue
+			} else if err != nil {
+				return errFromOS(err)
+			}
+			href, err := fs.externalPath(child)
+			if err != nil {
+				return err
+			}
+			if err := fn(fileInfoFromOS(href, fi)); err != nil {
+				return err
+			}
+			if recursive && fi.IsDir() {
+				if err := fs.walkDir(ctx, child, recursive, fn); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		} else if readErr != nil {
+			return errFromOS(readErr)
+		}
+	}
+}
+
+// SetExecutable implements ExecutableFileSystem. Like mod_dav, it toggles the
+// owner's executable bit.
+func (fs LocalFileSystem) SetExecutable(ctx context.Context, name string, executable bool) error {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return errFromOS(err)
+	}
+	if fi.IsDir() {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("collections can't be executable"))
+	}
+
+	mode := fi.Mode().Perm()
+	if executable {
+		mode |= 0100
+	} else {
+		mode &^= 0100
+	}
+	return errFromOS(os.Chmod(p, mode))
+}
+
+// checkConditionalDates evaluates If-Unmodified-Since and If-Modified-Since
+// dates against the resource fi, which is nil if it doesn't exist. They're
+// ignored for resources without modification time, and when the matching
+// entity tag condition is set.
+func checkConditionalDates(fi *FileInfo, ifMatch, ifNoneMatch ConditionalMatch, ifUnmodifiedSince, ifModifiedSince time.Time) error {
+	if fi == nil || fi.ModTime.IsZero() {
+		return nil
+	}
+	// HTTP dates have a one second resolution
+	modTime := fi.ModTime.Truncate(time.Second)
+	if !ifMatch.IsSet() && !ifUnmodifiedSince.IsZero() && modTime.After(ifUnmodifiedSince) {
+		return NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("If-Unmodified-Since condition failed"))
+	}
+	if !ifNoneMatch.IsSet() && !ifModifiedSince.IsZero() && !modTime.After(ifModifiedSince) {
+		return NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("If-Modified-Since condition failed"))
+	}
+	return nil
+}
+
 func checkConditionalMatches(fi *FileInfo, ifMatch, ifNoneMatch ConditionalMatch) error {
-	etag := ""
+	var etag string
+	var weak bool
 	if fi != nil {
-		etag = fi.ETag
+		etag, weak = fi.ETag, fi.WeakETag
 	}
 
 	if ifMatch.IsSet() {
-		if ok, err := ifMatch.MatchETag(etag); err != nil {
+		ok, err := ifMatch.MatchETag(etag)
+		if err != nil {
 			return NewHTTPError(http.StatusBadRequest, err)
-		} else if !ok {
+		}
+		if weak && !ifMatch.IsWildcard() {
+			// The strong comparison function never matches weak entity tags
+			ok = false
+		}
+		if !ok {
 			return NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("If-Match condition failed"))
 		}
 	}
-
 	if ifNoneMatch.IsSet() {
-		if ok, err := ifNoneMatch.MatchETag(etag); err != nil {
+		if ok, err := ifNoneMatch.MatchWeakETag(etag); err != nil {
 			return NewHTTPError(http.StatusBadRequest, err)
 		} else if ok {
 			return NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("If-None-Match condition failed"))
 		}
 	}
-
 	return nil
 }
 
@@ -158,35 +309,83 @@ func (fs LocalFileSystem) Create(ctx context.Context, name string, body io.ReadC
 	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
 		return nil, false, err
 	}
+	if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+		return nil, false, err
+	}
 
-	// Check if parent directory exists
-	dir := filepath.Dir(p)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
+	// Uploads to verify are staged next to the file, and renamed over it
+	// once verified
+	dst := p
+	if opts.Verify != nil {
+		dst = stagingPath(p)
+	}
+	wc, err := os.Create(dst)
+	if isMissingParent(err) {
 		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.7.1
 		return nil, false, NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	} else if err != nil {
+		return nil, false, errFromOS(err)
 	}
+	defer wc.Close()
 
-	wc, err := os.Create(p)
-	if err != nil {
+	if _, err := copyBufferContext(ctx, wc, body); err != nil {
+		// Don't leave a truncated upload behind
+		os.Remove(dst)
 		return nil, false, errFromOS(err)
 	}
-	defer wc.Close()
+	if !opts.ModTime.IsZero() {
+		if err := os.Chtimes(dst, time.Time{}, opts.ModTime); err != nil {
+			os.Remove(dst)
+			return nil, false, errFromOS(err)
+		}
+	}
 
-	if _, err := io.Copy(wc, body); err != nil {
-		os.Remove(p)
-		return nil, false, err
+	// Stat the open file rather than looking the path up again
+	osfi, err := wc.Stat()
+	if err != nil {
+		os.Remove(dst)
+		return nil, false, errFromOS(err)
 	}
 	if err := wc.Close(); err != nil {
-		os.Remove(p)
-		return nil, false, err
+		// Delayed allocation can report a full disk on close
+		os.Remove(dst)
+		return nil, false, errFromOS(err)
 	}
 
-	fi, err = fs.Stat(ctx, name)
-	if err != nil {
-		return nil, false, err
+	if dst != p {
+		err := opts.Verify(ctx, func() (io.ReadCloser, error) {
+			return os.Open(dst)
+		})
+		if err == nil {
+			if prev, statErr := os.Stat(p); statErr == nil {
+				// Keep the permissions of the replaced file
+				err = os.Chmod(dst, prev.Mode().Perm())
+			}
+		}
+		if err == nil {
+			err = errFromOS(os.Rename(dst, p))
+		}
+		if err != nil {
+			os.Remove(dst)
+			return nil, false, err
+		}
+		if osfi, err = os.Stat(p); err != nil {
+			return nil, false, errFromOS(err)
+		}
 	}
 
-	return fi, created, err
+	return fileInfoFromOS(name, osfi), created, nil
+}
+
+// stagingPath returns the path of a hidden temporary file next to p, where
+// its new content is staged.
+func stagingPath(p string) string {
+	return filepath.Join(filepath.Dir(p), stagingName(filepath.Base(p)))
+}
+
+// stagingName returns the base name of a staging file for the file base.
+func stagingName(base string) string {
+	return "." + base + ".upload-" + rand.Text()
 }
 
 func (fs LocalFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
@@ -195,18 +394,73 @@ func (fs LocalFileSystem) RemoveAll(ctx context.Context, name string, opts *Remo
 		return err
 	}
 
-	// WebDAV semantics are that it should return a "404 Not Found" error in
-	// case the resource doesn't exist. We need to Stat before RemoveAll.
-	fi, err := fs.Stat(ctx, name)
-	if err != nil {
+	// The resource only needs to be looked up beforehand to evaluate
+	// conditions. Otherwise, a missing resource is reported by removeAll.
+	if opts.IfMatch.IsSet() || opts.IfNoneMatch.IsSet() || !opts.IfUnmodifiedSince.IsZero() || !opts.IfModifiedSince.IsZero() {
+		fi, err := fs.Stat(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
+			return err
+		}
+		if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+			return err
+		}
+	}
+
+	errs := fs.removeAll(ctx, p, true)
+	if err := ctx.Err(); err != nil && len(errs) > 0 {
+		// Members left behind are the result of the interruption
 		return errFromOS(err)
+	} else if len(errs) == 1 && path.Clean(errs[0].Path) == path.Clean(name) {
+		return errs[0].Err
+	} else if len(errs) > 0 {
+		return &PartialError{Errors: errs}
+	}
+	return nil
+}
+
+// removeAll removes p and its descendants, carrying on when errors are
+// encountered. Collections which can't be removed because one of their
+// members couldn't be removed aren't reported, as their failure is implied.
+// Only a missing root is reported, as WebDAV semantics are that it should
+// return a "404 Not Found" error in case the resource doesn't exist. Once ctx
+// is done, the remaining resources fail with its error.
+func (fs LocalFileSystem) removeAll(ctx context.Context, p string, root bool) []MemberError {
+	fail := func(err error) []MemberError {
+		href, _ := fs.externalPath(p)
+		return []MemberError{{Path: href, Err: errFromOS(err)}}
+	}
+	if err := ctx.Err(); err != nil {
+		return fail(err)
 	}
 
-	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
-		return err
+	fi, err := os.Lstat(p)
+	if os.IsNotExist(err) && !root {
+		return nil
+	} else if err != nil {
+		return fail(err)
+	}
+
+	if fi.IsDir() {
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return fail(err)
+		}
+		var errs []MemberError
+		for _, entry := range entries {
+			errs = append(errs, fs.removeAll(ctx, filepath.Join(p, entry.Name()), false)...)
+		}
+		if len(errs) > 0 {
+			return errs
+		}
 	}
 
-	return errFromOS(os.RemoveAll(p))
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fail(err)
+	}
+	return nil
 }
 
 func (fs LocalFileSystem) Mkdir(ctx context.Context, name string) error {
@@ -215,57 +469,126 @@ func (fs LocalFileSystem) Mkdir(ctx context.Context, name string) error {
 		return err
 	}
 
-	// Check if the path already exists
-	fi, statErr := os.Stat(p)
-	if statErr == nil {
-		// Path exists, check if it's a directory
-		if fi.IsDir() {
+	err = os.Mkdir(p, 0755)
+	if os.IsExist(err) {
+		// The path only needs to be looked up to describe the failure
+		if fi, statErr := os.Stat(p); statErr == nil && fi.IsDir() {
 			// If it's already a directory, return 405 Method Not Allowed (RFC4918:S9.1)
 			return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("collection already exists"))
-		} else {
-			// If it's not a directory, return 405 Method Not Allowed
-			return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("resource exists and is not a collection"))
 		}
-	} else if !os.IsNotExist(statErr) {
-		// Some other error occurred
-		return errFromOS(statErr)
-	}
-
-	// Path doesn't exist, create the directory
-	if err := os.Mkdir(p, 0755); err != nil {
+		// If it's not a directory, return 405 Method Not Allowed
+		return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("resource exists and is not a collection"))
+	} else if isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.3.1
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	} else if err != nil {
 		return errFromOS(err)
 	}
 
 	return nil
 }
 
-func copyRegularFile(src, dst string, perm os.FileMode) error {
+// DefaultCopyWorkers is the number of files copied concurrently by
+// LocalFileSystem.Copy, and by RootFileSystem.Copy unless configured
+// otherwise with RootOptions.
+const DefaultCopyWorkers = 4
+
+// copyFileJob is a file to copy as part of a collection.
+type copyFileJob struct {
+	src, dst string
+	perm     os.FileMode
+	// href is the destination path reported on failure.
+	href string
+}
+
+// moveCopy is the state of a move which can't be done by renaming, e.g.
+// across devices, and copies the resources instead.
+type moveCopy struct {
+	progress func(copied int64)
+
+	mu       sync.Mutex
+	copied   int64
+	reported int64
+}
+
+// moveProgressInterval is the number of bytes copied between calls to
+// MoveOptions.Progress.
+const moveProgressInterval = 1 << 20
+
+// Write counts the bytes copied and reports progress.
+func (mc *moveCopy) Write(b []byte) (int, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.copied += int64(len(b))
+	if mc.copied-mc.reported >= moveProgressInterval {
+		mc.reported = mc.copied
+		mc.progress(mc.copied)
+	}
+	return len(b), nil
+}
+
+// done reports the final progress.
+func (mc *moveCopy) done() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.copied != mc.reported {
+		mc.reported = mc.copied
+		mc.progress(mc.copied)
+	}
+}
+
+// copyRegularFile copies the file src to dst, until ctx is done. When it's
+// part of a move, the copy is flushed to disk, as the source is removed
+// afterwards.
+func copyRegularFile(ctx context.Context, src, dst string, perm os.FileMode, mc *moveCopy) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return errFromOS(err)
 	}
 	defer srcFile.Close()
+	defer bindDeadline(ctx, srcFile)()
 
 	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
-	if os.IsNotExist(err) {
-		return NewHTTPError(http.StatusConflict, err)
+	if isMissingParent(err) {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
 	} else if err != nil {
 		return errFromOS(err)
 	}
-	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return err
+	var w io.Writer = dstFile
+	if mc != nil && mc.progress != nil {
+		w = io.MultiWriter(dstFile, mc)
 	}
-
-	return dstFile.Close()
+	_, err = copyBufferContext(ctx, w, srcFile)
+	if err == nil && mc != nil {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Don't leave a truncated copy behind
+		os.Remove(dst)
+		return errFromOS(err)
+	}
+	return nil
 }
 
 func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	return fs.copy(ctx, src, dst, options, nil)
+}
+
+func (fs LocalFileSystem) copy(ctx context.Context, src, dst string, options *CopyOptions, mc *moveCopy) (created bool, err error) {
 	srcPath, err := fs.localPath(src)
 	if err != nil {
 		return false, err
 	}
+	if path.Clean(dst) == "/" {
+		return false, errReplaceRoot
+	}
+	if err := checkDestination(src, dst); err != nil {
+		return false, err
+	}
 	dstPath, err := fs.localPath(dst)
 	if err != nil {
 		return false, err
@@ -276,19 +599,21 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 	if err != nil {
 		return false, errFromOS(err)
 	}
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
+	}
 	srcPerm := srcInfo.Mode() & os.ModePerm
 
-	// Check if destination parent directory exists
-	dstParent := filepath.Dir(dstPath)
-	if _, err := os.Stat(dstParent); os.IsNotExist(err) {
-		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.8.5
-		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	// Don't replace the destination once ctx is done
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 
-	// Check if destination exists
+	// Check if destination exists. A missing parent is detected when
+	// creating the destination.
 	_, err = os.Stat(dstPath)
 	if err != nil {
-		if !os.IsNotExist(err) {
+		if !isMissingParent(err) {
 			return false, errFromOS(err)
 		}
 		created = true
@@ -303,7 +628,10 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 
 	// If source is a directory, create the destination directory
 	if srcInfo.IsDir() {
-		if err := os.MkdirAll(dstPath, srcPerm); err != nil {
+		if err := os.Mkdir(dstPath, srcPerm); isMissingParent(err) {
+			// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.8.5
+			return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+		} else if err != nil {
 			return false, errFromOS(err)
 		}
 
@@ -312,21 +640,58 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 			return created, nil
 		}
 
-		// Otherwise, copy the contents
+		// Otherwise, copy the contents. Directories are created as the tree
+		// is walked, files are copied by a pool of workers. Failures are
+		// collected so that the rest of the tree can still be copied.
+		var (
+			mu   sync.Mutex
+			errs []MemberError
+			wg   sync.WaitGroup
+		)
+		addErr := func(href string, err error) {
+			mu.Lock()
+			errs = append(errs, MemberError{Path: href, Err: err})
+			mu.Unlock()
+		}
+		jobs := make(chan copyFileJob)
+		for range DefaultCopyWorkers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range jobs {
+					if err := copyRegularFile(ctx, job.src, job.dst, job.perm, mc); err != nil {
+						addErr(job.href, err)
+					}
+				}
+			}()
+		}
+
 		err = filepath.Walk(srcPath, func(p string, fi os.FileInfo, err error) error {
-			if err != nil {
-				return err
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
 			}
 
 			// Skip the root directory as we've already created it
-			if p == srcPath {
+			if p == srcPath && err == nil {
 				return nil
 			}
 
 			// Calculate the relative path from source root
-			relPath, err := filepath.Rel(srcPath, p)
+			relPath, relErr := filepath.Rel(srcPath, p)
+			if relErr != nil {
+				return relErr
+			}
+
+			href := path.Join(dst, filepath.ToSlash(relPath))
+			fail := func(err error) error {
+				addErr(href, err)
+				if fi != nil && fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if err != nil {
-				return err
+				return fail(errFromOS(err))
 			}
 
 			// Create the corresponding path in the destination
@@ -335,23 +700,33 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 			if fi.IsDir() {
 				// Create directory
 				if err := os.MkdirAll(dstItemPath, fi.Mode()&os.ModePerm); err != nil {
-					return errFromOS(err)
+					return fail(errFromOS(err))
 				}
 			} else {
-				// Copy file
-				if err := copyRegularFile(p, dstItemPath, fi.Mode()&os.ModePerm); err != nil {
-					return err
+				jobs <- copyFileJob{
+					src:  p,
+					dst:  dstItemPath,
+					perm: fi.Mode() & os.ModePerm,
+					href: href,
 				}
 			}
 
 			return nil
 		})
+		close(jobs)
+		wg.Wait()
 		if err != nil {
 			return false, errFromOS(err)
 		}
+		if len(errs) > 0 {
+			slices.SortFunc(errs, func(a, b MemberError) int {
+				return strings.Compare(a.Path, b.Path)
+			})
+			return created, &PartialError{Errors: errs}
+		}
 	} else {
 		// Source is a file, just copy it
-		if err := copyRegularFile(srcPath, dstPath, srcPerm); err != nil {
+		if err := copyRegularFile(ctx, srcPath, dstPath, srcPerm, mc); err != nil {
 			return false, err
 		}
 	}
@@ -364,28 +739,31 @@ func (fs LocalFileSystem) Move(ctx context.Context, src, dst string, options *Mo
 	if err != nil {
 		return false, err
 	}
+	if path.Clean(dst) == "/" {
+		return false, errReplaceRoot
+	}
+	if err := checkDestination(src, dst); err != nil {
+		return false, err
+	}
 	dstPath, err := fs.localPath(dst)
 	if err != nil {
 		return false, err
 	}
 
 	// Check if source exists
-	_, err = os.Stat(srcPath)
+	srcInfo, err := os.Stat(srcPath)
 	if err != nil {
 		return false, errFromOS(err)
 	}
-
-	// Check if destination parent directory exists
-	dstParent := filepath.Dir(dstPath)
-	if _, err := os.Stat(dstParent); os.IsNotExist(err) {
-		// Parent directory doesn't exist, return 409 Conflict as per RFC4918
-		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
 	}
 
-	// Check if destination exists
+	// Check if destination exists. A missing parent is detected when
+	// renaming.
 	_, err = os.Stat(dstPath)
 	if err != nil {
-		if !os.IsNotExist(err) {
+		if !isMissingParent(err) {
 			return false, errFromOS(err)
 		}
 		created = true
@@ -402,27 +780,45 @@ func (fs LocalFileSystem) Move(ctx context.Context, src, dst string, options *Mo
 	err = os.Rename(srcPath, dstPath)
 	if err == nil {
 		return created, nil
+	} else if isMissingParent(err) {
+		// The source exists, so the destination parent doesn't. Return 409
+		// Conflict as per RFC4918
+		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	} else if !errors.Is(err, syscall.EXDEV) {
+		return false, errFromOS(err)
 	}
 
-	// If os.Rename fails (e.g., cross-device move), fall back to copy and delete
-	// First, create a copy options with the same overwrite setting
+	// The destination is on another device, fall back to copy and delete
 	copyOptions := &CopyOptions{
 		NoOverwrite: options.NoOverwrite,
 		NoRecursive: false, // Always recursive for move
 	}
 
-	// Copy the source to the destination
-	_, err = fs.Copy(ctx, src, dst, copyOptions)
-	if err != nil {
+	// Copy the source to the destination, flushing files to disk. On
+	// failure, the source is kept intact and the partial copy is removed.
+	mc := &moveCopy{progress: options.Progress}
+	if _, err := fs.copy(ctx, src, dst, copyOptions, mc); err != nil {
+		os.RemoveAll(dstPath)
+		var partialErr *PartialError
+		if errors.As(err, &partialErr) {
+			// Nothing was moved, report the first failure
+			return false, partialErr.Errors[0].Err
+		}
 		return false, err
 	}
+	if mc.progress != nil {
+		mc.done()
+	}
+	if d, err := os.Open(filepath.Dir(dstPath)); err == nil {
+		d.Sync()
+		d.Close()
+	}
 
-	// Remove the source
-	if err := os.RemoveAll(srcPath); err != nil {
-		// If we can't remove the source, we should try to remove the destination
-		// to maintain atomicity, but we'll still return the error from removing the source
-		os.RemoveAll(dstPath)
-		return false, errFromOS(err)
+	// Remove the source. Part of it may already be gone on failure, so the
+	// destination is kept to avoid losing data. The move is complete once
+	// copied, so it's no longer interrupted.
+	if errs := fs.removeAll(context.WithoutCancel(ctx), srcPath, true); len(errs) > 0 {
+		return false, &PartialError{Errors: errs}
 	}
 
 	return created, nil
@@ -0,0 +1,44 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// createUploadFile creates a file for an upload with create, which opens it
+// with the extra flag. O_DIRECT is tried first if direct is set and
+// supported.
+func createUploadFile(create func(flag int) (*os.File, error), direct bool) (f *os.File, ok bool, err error) {
+	if direct && directIOFlag != 0 {
+		if f, err := create(directIOFlag); err == nil {
+			return f, true, nil
+		}
+	}
+	f, err = create(0)
+	return f, false, err
+}
+
+// writeUpload copies the body of an upload to a file created by
+// createUploadFile, until ctx is done.
+func writeUpload(ctx context.Context, f *os.File, direct bool, body io.Reader) (int64, error) {
+	if direct {
+		return writeDirect(f, contextReader{ctx, body})
+	}
+	return copyBufferContext(ctx, f, body)
+}
+
+// bindDeadline bounds blocking I/O on f by the deadline of ctx, and unblocks
+// it once ctx is done. Only pollable files such as pipes support deadlines,
+// regular files are left alone. The returned function releases f.
+func bindDeadline(ctx context.Context, f *os.File) (release func()) {
+	deadline, _ := ctx.Deadline()
+	if ctx.Done() == nil || f.SetDeadline(deadline) != nil {
+		return func() {}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		f.SetDeadline(time.Unix(1, 0))
+	})
+	return func() { stop() }
+}
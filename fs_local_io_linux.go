@@ -0,0 +1,80 @@
+//go:build linux
+
+package webdav
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// directIOAlignment is the alignment of the buffers, offsets and sizes of
+// O_DIRECT writes. It's the logical block size of most devices.
+const directIOAlignment = 4096
+
+func adviseReadAhead(f *os.File, n int64) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return
+	}
+	rc.Control(func(fd uintptr) {
+		unix.Fadvise(int(fd), 0, 0, unix.FADV_SEQUENTIAL)
+		unix.Fadvise(int(fd), 0, n, unix.FADV_WILLNEED)
+	})
+}
+
+// directIOFlag is the flag opening files for direct I/O.
+const directIOFlag = unix.O_DIRECT
+
+// writeDirect copies src to f, opened with O_DIRECT. The unaligned tail of the
+// content is written after clearing O_DIRECT.
+func writeDirect(f *os.File, src io.Reader) (int64, error) {
+	size := (int(copyBufferSize.Load()) + directIOAlignment - 1) &^ (directIOAlignment - 1)
+	raw := make([]byte, size+directIOAlignment)
+	off := int(-uintptr(unsafe.Pointer(&raw[0])) & (directIOAlignment - 1))
+	buf := raw[off : off+size]
+
+	var written int64
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n == len(buf) {
+			if _, err := f.Write(buf); err != nil {
+				return written, err
+			}
+		} else if n > 0 {
+			if err := clearDirect(f); err != nil {
+				return written, err
+			}
+			if _, err := f.Write(buf[:n]); err != nil {
+				return written, err
+			}
+		}
+		written += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return written, nil
+		} else if err != nil {
+			return written, err
+		}
+	}
+}
+
+func clearDirect(f *os.File) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fcntlErr error
+	err = rc.Control(func(fd uintptr) {
+		var flags int
+		flags, fcntlErr = unix.FcntlInt(fd, unix.F_GETFL, 0)
+		if fcntlErr == nil {
+			_, fcntlErr = unix.FcntlInt(fd, unix.F_SETFL, flags&^unix.O_DIRECT)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return fcntlErr
+}
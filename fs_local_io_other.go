@@ -0,0 +1,18 @@
+//go:build !linux
+
+package webdav
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+func adviseReadAhead(f *os.File, n int64) {}
+
+// directIOFlag is zero, direct I/O isn't supported.
+const directIOFlag = 0
+
+func writeDirect(f *os.File, src io.Reader) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
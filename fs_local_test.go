@@ -0,0 +1,238 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// cancelingReader cancels a context once read from, and keeps returning
+// data afterwards.
+type cancelingReader struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (r cancelingReader) Read(b []byte) (int, error) {
+	r.cancel()
+	return r.Reader.Read(b)
+}
+
+func TestFileSystemCancel(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		if name == "legacy" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{}
+			for _, c := range "abcdefgh" {
+				files["src/"+string(c)+".txt"] = string(c)
+			}
+			writeFiles(t, dir, files)
+			fs := testFileSystems(t, dir)[name]
+			canceled, cancel := context.WithCancel(t.Context())
+			cancel()
+
+			// Interrupted uploads don't leave a partial file behind
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			body := io.NopCloser(cancelingReader{io.LimitReader(zeroReader{}, 64<<20), cancel})
+			if _, _, err := fs.Create(ctx, "/big.bin", body, &webdav.CreateOptions{}); !errors.Is(err, context.Canceled) {
+				t.Errorf("Create() = %v, want %v", err, context.Canceled)
+			}
+
+			if _, err := fs.ReadDir(canceled, "/src/", true); !errors.Is(err, context.Canceled) {
+				t.Errorf("ReadDir() = %v, want %v", err, context.Canceled)
+			}
+			if _, err := fs.Copy(canceled, "/src/", "/dst/", &webdav.CopyOptions{}); !errors.Is(err, context.Canceled) {
+				t.Errorf("Copy() = %v, want %v", err, context.Canceled)
+			}
+			if _, err := fs.Copy(canceled, "/src/a.txt", "/src/b.txt", &webdav.CopyOptions{}); !errors.Is(err, context.Canceled) {
+				t.Errorf("Copy() over a file = %v, want %v", err, context.Canceled)
+			}
+			if err := fs.RemoveAll(canceled, "/src/", &webdav.RemoveAllOptions{}); !errors.Is(err, context.Canceled) {
+				t.Errorf("RemoveAll() = %v, want %v", err, context.Canceled)
+			}
+			checkMissing(t, dir, "big.bin")
+			checkMissing(t, dir, "dst")
+			for name, content := range files {
+				checkFile(t, dir, name, content)
+			}
+		})
+	}
+}
+
+// zeroReader returns an infinite stream of zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// crossDeviceDir returns a directory linked as name in dir, on another device
+// than dir, so that renames to it fail.
+func crossDeviceDir(t *testing.T, dir, name string) string {
+	t.Helper()
+	other, err := os.MkdirTemp("/dev/shm", "webdav-test")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(other) })
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(probe)
+	if err := os.Rename(probe, filepath.Join(other, "probe")); !errors.Is(err, syscall.EXDEV) {
+		t.Skipf("%v isn't on another device", other)
+	}
+	if err := os.Symlink(other, filepath.Join(dir, name)); err != nil {
+		t.Skip(err)
+	}
+	return other
+}
+
+func TestLocalFileSystemCrossDeviceMove(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("x", 3<<20)
+	writeFiles(t, dir, map[string]string{"a/b.txt": big, "a/c/d.txt": "d", "e.txt": big})
+	other := crossDeviceDir(t, dir, "other")
+	fs := webdav.LocalFileSystem(dir)
+
+	var reports []int64
+	opts := &webdav.MoveOptions{Progress: func(copied int64) { reports = append(reports, copied) }}
+	created, err := fs.Move(t.Context(), "/a/", "/other/a/", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("Move() created = false, want true")
+	}
+	checkFile(t, other, "a/b.txt", big)
+	checkFile(t, other, "a/c/d.txt", "d")
+	checkMissing(t, dir, "a")
+	if len(reports) < 2 || reports[len(reports)-1] != int64(len(big)+1) {
+		t.Errorf("progress reports = %v, want several up to %v", reports, len(big)+1)
+	}
+
+	// Interrupted moves remove the partial copy and keep the source
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	opts = &webdav.MoveOptions{Progress: func(copied int64) { cancel() }}
+	if _, err := fs.Move(ctx, "/e.txt", "/other/e.txt", opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("Move() = %v, want %v", err, context.Canceled)
+	}
+	checkFile(t, dir, "e.txt", big)
+	checkMissing(t, other, "e.txt")
+}
+
+func TestLocalFileSystemDiskFull(t *testing.T) {
+	// Writes to /dev/full fail with ENOSPC
+	dir := t.TempDir()
+	if err := os.Symlink("/dev/full", filepath.Join(dir, "full.txt")); err != nil {
+		t.Skip(err)
+	}
+	if f, err := os.OpenFile("/dev/full", os.O_WRONLY, 0); err != nil {
+		t.Skip(err)
+	} else {
+		f.Close()
+	}
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodPut, target: "/full.txt", body: "a"}, http.StatusInsufficientStorage)
+	if !strings.Contains(w.Body.String(), "no space left on device") {
+		t.Errorf("PUT error = %q, want ENOSPC", w.Body)
+	}
+	// The partial upload is removed
+	checkMissing(t, dir, "full.txt")
+}
+
+func TestLocalFileSystemWriteErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "d/b.txt": "b"})
+	fs := webdav.LocalFileSystem(dir)
+	ctx := context.Background()
+	conflict := webdav.NewHTTPError(http.StatusConflict, nil)
+	notFound := webdav.NewHTTPError(http.StatusNotFound, nil)
+	notAllowed := webdav.NewHTTPError(http.StatusMethodNotAllowed, nil)
+
+	create := func(name string) error {
+		_, _, err := fs.Create(ctx, name, io.NopCloser(strings.NewReader("x")), &webdav.CreateOptions{})
+		return err
+	}
+	copyTo := func(src, dst string) error {
+		_, err := fs.Copy(ctx, src, dst, &webdav.CopyOptions{})
+		return err
+	}
+	moveTo := func(src, dst string) error {
+		_, err := fs.Move(ctx, src, dst, &webdav.MoveOptions{})
+		return err
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Create in a missing parent", create("/missing/c.txt"), conflict},
+		{"Mkdir of an existing collection", fs.Mkdir(ctx, "/d"), notAllowed},
+		{"Mkdir of an existing file", fs.Mkdir(ctx, "/a.txt"), notAllowed},
+		{"Mkdir in a missing parent", fs.Mkdir(ctx, "/missing/e"), conflict},
+		{"RemoveAll of a missing resource", fs.RemoveAll(ctx, "/missing", &webdav.RemoveAllOptions{}), notFound},
+		{"Copy of a file to a missing parent", copyTo("/a.txt", "/missing/a.txt"), conflict},
+		{"Copy of a collection to a missing parent", copyTo("/d", "/missing/d"), conflict},
+		{"Copy of a missing resource", copyTo("/missing", "/c.txt"), notFound},
+		{"Move to a missing parent", moveTo("/a.txt", "/missing/a.txt"), conflict},
+		{"Move of a missing resource", moveTo("/missing", "/c.txt"), notFound},
+	}
+	for _, tc := range tests {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("%v = %v, want %v", tc.name, tc.err, tc.want)
+		}
+	}
+	checkFile(t, dir, "a.txt", "a")
+	checkFile(t, dir, "d/b.txt", "b")
+	checkMissing(t, dir, "missing")
+}
+
+func TestLocalFileSystemCreateInfo(t *testing.T) {
+	dir := t.TempDir()
+	fs := webdav.LocalFileSystem(dir)
+	ctx := context.Background()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Create returns the FileInfo of the written file
+	fi, created, err := fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("hello")), &webdav.CreateOptions{ModTime: modTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Errorf("Create() of a new file: created = false")
+	}
+	want, err := fs.Stat(ctx, "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Path != "/a.txt" || fi.Size != 5 || !fi.ModTime.Equal(modTime) || fi.ETag != want.ETag {
+		t.Errorf("Create() = %+v, want %+v", fi, want)
+	}
+
+	fi, created, err = fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("hi")), &webdav.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || fi.Size != 2 {
+		t.Errorf("Create() of an existing file = %+v, created = %v", fi, created)
+	}
+}
@@ -0,0 +1,764 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// RootFileSystem implements FileSystem for a local directory, like
+// LocalFileSystem, but resolves paths with an os.Root. The kernel then
+// prevents requests from escaping the directory, including through symbolic
+// links pointing outside of it or swapped in concurrently, instead of relying
+// on cleaning paths alone.
+//
+// Renaming and setting modification times through an os.Root require Go
+// 1.25. With older versions, MOVE requests copy the resource and remove the
+// source, and the modification times sent by sync clients are ignored.
+type RootFileSystem struct {
+	root *os.Root
+	opts RootOptions
+}
+
+// RootOptions tunes the I/O of a RootFileSystem.
+type RootOptions struct {
+	// CopyWorkers is the number of files copied concurrently when copying a
+	// collection. Directories are still created sequentially. Zero means
+	// DefaultCopyWorkers, one copies files sequentially.
+	CopyWorkers int
+
+	// ReadAhead, if positive, makes the file system advise the kernel that
+	// the files it opens are read sequentially, and to start reading their
+	// first ReadAhead bytes in the background. This speeds up serving large
+	// media files from slow disks. It's only supported on Linux.
+	ReadAhead int64
+
+	// DirectIO makes the file system write uploads with O_DIRECT, bypassing
+	// the page cache, so that large uploads don't evict frequently read
+	// files from memory. It's only supported on Linux, and ignored by
+	// filesystems without direct I/O support. Uploads are written with
+	// buffers of the copy buffer size rounded up to the direct I/O
+	// alignment.
+	DirectIO bool
+}
+
+var (
+	_ FileSystem           = (*RootFileSystem)(nil)
+	_ ExecutableFileSystem = (*RootFileSystem)(nil)
+	_ SubFileSystem        = (*RootFileSystem)(nil)
+	_ WalkFileSystem       = (*RootFileSystem)(nil)
+)
+
+// NewRootFileSystem opens the directory dir as a RootFileSystem. It stays
+// open until Close is called. opts may be nil to use the defaults.
+func NewRootFileSystem(dir string, opts *RootOptions) (*RootFileSystem, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	errPathEscapesOnce.Do(func() {
+		// Absolute paths are rejected before the file system is accessed
+		var perr *fs.PathError
+		if _, err := root.Stat(string(filepath.Separator)); errors.As(err, &perr) {
+			errPathEscapes = perr.Err
+		}
+	})
+	fs := &RootFileSystem{root: root}
+	if opts != nil {
+		fs.opts = *opts
+	}
+	if fs.opts.CopyWorkers <= 0 {
+		fs.opts.CopyWorkers = DefaultCopyWorkers
+	}
+	return fs, nil
+}
+
+// Close closes the directory. The FileSystem can't be used afterwards, but
+// FileSystems returned by Sub stay open.
+func (fs *RootFileSystem) Close() error {
+	return fs.root.Close()
+}
+
+// relPath returns the path of the resource name relative to the root.
+func (fs *RootFileSystem) relPath(name string) (string, error) {
+	if (filepath.Separator != '/' && strings.IndexRune(name, filepath.Separator) >= 0) || strings.Contains(name, "\x00") {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: invalid character in path")
+	}
+	name = path.Clean(name)
+	if !path.IsAbs(name) {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
+	}
+	if name == "/" {
+		return ".", nil
+	}
+	return filepath.FromSlash(name[1:]), nil
+}
+
+// externalRootPath returns the resource name of a path relative to the root
+// of a RootFileSystem.
+func externalRootPath(rel string) string {
+	return path.Clean("/" + filepath.ToSlash(rel))
+}
+
+// errPathEscapes is the error of os.Root operations on paths escaping the
+// root. The os package doesn't export it, so it's captured from the first
+// RootFileSystem opened.
+var (
+	errPathEscapes     error
+	errPathEscapesOnce sync.Once
+)
+
+// errReplaceRoot is returned by COPY and MOVE requests to the root, which
+// would remove the whole file system first.
+var errReplaceRoot = NewHTTPError(http.StatusForbidden, errors.New("webdav: the root collection can't be replaced"))
+
+// errCopyIntoSelf is returned by COPY and MOVE requests to the source or one
+// of its members, which would remove the source first or copy it without
+// bound.
+var errCopyIntoSelf = NewHTTPError(http.StatusForbidden, errors.New("webdav: the destination can't be the source or one of its members"))
+
+// checkDestination fails with errCopyIntoSelf if the resource dst is src or
+// lives below it.
+func checkDestination(src, dst string) error {
+	if isDescendant(path.Clean("/"+dst), path.Clean("/"+src)) {
+		return errCopyIntoSelf
+	}
+	return nil
+}
+
+// errFromRoot is like errFromOS, but also reports paths escaping the root,
+// e.g. through symbolic links, as forbidden.
+func errFromRoot(err error) error {
+	var perr *fs.PathError
+	if errPathEscapes != nil && errors.As(err, &perr) && errors.Is(perr.Err, errPathEscapes) {
+		return NewHTTPError(http.StatusForbidden, fmt.Errorf("%s: %w", perr.Op, perr.Err))
+	}
+	return errFromOS(err)
+}
+
+// Sub returns a RootFileSystem for the directory name, opened through the
+// root, with the same options.
+func (fs *RootFileSystem) Sub(name string) (FileSystem, error) {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := fs.root.OpenRoot(rel)
+	if err != nil {
+		return nil, errFromRoot(err)
+	}
+	return &RootFileSystem{root: root, opts: fs.opts}, nil
+}
+
+func (fs *RootFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.root.Open(rel)
+	if err != nil {
+		return nil, errFromRoot(err)
+	}
+	if fs.opts.ReadAhead > 0 {
+		adviseReadAhead(f, fs.opts.ReadAhead)
+	}
+	return f, nil
+}
+
+func (fs *RootFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.root.Stat(rel)
+	if err != nil {
+		return nil, errFromRoot(err)
+	}
+	return fileInfoFromOS(name, fi), nil
+}
+
+func (fs *RootFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	var l []FileInfo
+	err := fs.Walk(ctx, name, recursive, func(fi *FileInfo) error {
+		l = append(l, *fi)
+		return nil
+	})
+	return l, err
+}
+
+// Walk implements WalkFileSystem. Directories are read in batches, so that
+// large directories aren't loaded in memory at once. Members are enumerated
+// in directory order.
+func (fs *RootFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := fs.root.Stat(rel)
+	if err != nil {
+		return errFromRoot(err)
+	}
+	if err := fn(fileInfoFromOS(externalRootPath(rel), fi)); err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+	return fs.walkDir(ctx, rel, recursive, fn)
+}
+
+func (fs *RootFileSystem) walkDir(ctx context.Context, rel string, recursive bool, fn func(fi *FileInfo) error) error {
+	f, err := fs.root.Open(rel)
+	if err != nil {
+		return errFromRoot(err)
+	}
+	defer f.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, readErr := f.ReadDir(walkBatchSize)
+		for _, entry := range entries {
+			child := filepath.Join(rel, entry.Name())
+			fi, err := entry.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed since the directory was read
+				continue
+			} else if err != nil {
+				return errFromRoot(err)
+			}
+			if err := fn(fileInfoFromOS(externalRootPath(child), fi)); err != nil {
+				return err
+			}
+			if recursive && fi.IsDir() {
+				if err := fs.walkDir(ctx, child, recursive, fn); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		} else if readErr != nil {
+			return errFromRoot(readErr)
+		}
+	}
+}
+
+// SetExecutable implements ExecutableFileSystem. Like mod_dav, it toggles the
+// owner's executable bit.
+func (fs *RootFileSystem) SetExecutable(ctx context.Context, name string, executable bool) error {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return err
+	}
+	f, err := fs.root.Open(rel)
+	if err != nil {
+		return errFromRoot(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return errFromRoot(err)
+	}
+	if fi.IsDir() {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("collections can't be executable"))
+	}
+
+	mode := fi.Mode().Perm()
+	if executable {
+		mode |= 0100
+	} else {
+		mode &^= 0100
+	}
+	return errFromRoot(f.Chmod(mode))
+}
+
+func (fs *RootFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return nil, false, err
+	}
+	fi, _ = fs.Stat(ctx, name)
+	created = fi == nil
+
+	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return nil, false, err
+	}
+	if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+		return nil, false, err
+	}
+
+	// Uploads to verify are staged next to the file, and renamed over it
+	// once verified
+	dst := rel
+	if opts.Verify != nil {
+		dst = stagingPath(rel)
+	}
+	f, direct, err := createUploadFile(func(flag int) (*os.File, error) {
+		return fs.root.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|flag, 0666)
+	}, fs.opts.DirectIO)
+	if isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.7.1
+		return nil, false, NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	} else if err != nil {
+		return nil, false, errFromRoot(err)
+	}
+	defer f.Close()
+
+	if _, err := writeUpload(ctx, f, direct, body); err != nil {
+		// Don't leave a truncated upload behind
+		fs.root.Remove(dst)
+		return nil, false, errFromRoot(err)
+	}
+	if !opts.ModTime.IsZero() {
+		if err := rootChtimes(fs.root, dst, opts.ModTime); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			fs.root.Remove(dst)
+			return nil, false, errFromRoot(err)
+		}
+	}
+
+	// Stat the open file rather than looking the path up again
+	osfi, err := f.Stat()
+	if err != nil {
+		fs.root.Remove(dst)
+		return nil, false, errFromRoot(err)
+	}
+	if dst != rel && !created {
+		// Keep the permissions of the replaced file
+		if prev, err := fs.root.Stat(rel); err == nil {
+			f.Chmod(prev.Mode().Perm())
+		}
+	}
+	if err := f.Close(); err != nil {
+		// Delayed allocation can report a full disk on close
+		fs.root.Remove(dst)
+		return nil, false, errFromRoot(err)
+	}
+
+	if dst != rel {
+		if err := fs.commitStaged(ctx, dst, rel, opts.Verify); err != nil {
+			return nil, false, err
+		}
+		if osfi, err = fs.root.Stat(rel); err != nil {
+			return nil, false, errFromRoot(err)
+		}
+	}
+
+	return fileInfoFromOS(name, osfi), created, nil
+}
+
+// commitStaged verifies the content staged in the file staged, and renames
+// it over rel. The staged file is removed on failure.
+func (fs *RootFileSystem) commitStaged(ctx context.Context, staged, rel string, verify func(ctx context.Context, open func() (io.ReadCloser, error)) error) error {
+	err := verify(ctx, func() (io.ReadCloser, error) {
+		return fs.root.Open(staged)
+	})
+	if err == nil {
+		err = rootRename(fs.root, staged, rel)
+		if errors.Is(err, errors.ErrUnsupported) {
+			// Replace the content in place instead
+			err = fs.copyFile(ctx, staged, rel, 0666, nil)
+		}
+		err = errFromRoot(err)
+	}
+	fs.root.Remove(staged)
+	return err
+}
+
+func (fs *RootFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return err
+	}
+
+	// The resource only needs to be looked up beforehand to evaluate
+	// conditions. Otherwise, a missing resource is reported by removeAll.
+	if opts.IfMatch.IsSet() || opts.IfNoneMatch.IsSet() || !opts.IfUnmodifiedSince.IsZero() || !opts.IfModifiedSince.IsZero() {
+		fi, err := fs.Stat(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
+			return err
+		}
+		if err := checkConditionalDates(fi, opts.IfMatch, opts.IfNoneMatch, opts.IfUnmodifiedSince, opts.IfModifiedSince); err != nil {
+			return err
+		}
+	}
+
+	errs := fs.removeAll(ctx, rel, true)
+	if err := ctx.Err(); err != nil && len(errs) > 0 {
+		// Members left behind are the result of the interruption
+		return errFromOS(err)
+	} else if len(errs) == 1 && path.Clean(errs[0].Path) == path.Clean(name) {
+		return errs[0].Err
+	} else if len(errs) > 0 {
+		return &PartialError{Errors: errs}
+	}
+	return nil
+}
+
+// removeAll removes rel and its descendants like LocalFileSystem.removeAll.
+// Symbolic links are removed rather than followed.
+func (fs *RootFileSystem) removeAll(ctx context.Context, rel string, root bool) []MemberError {
+	fail := func(err error) []MemberError {
+		return []MemberError{{Path: externalRootPath(rel), Err: errFromRoot(err)}}
+	}
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+
+	fi, err := fs.root.Lstat(rel)
+	if os.IsNotExist(err) && !root {
+		return nil
+	} else if err != nil {
+		return fail(err)
+	}
+
+	if fi.IsDir() {
+		names, err := fs.readDirNames(rel)
+		if err != nil {
+			return fail(err)
+		}
+		var errs []MemberError
+		for _, name := range names {
+			errs = append(errs, fs.removeAll(ctx, filepath.Join(rel, name), false)...)
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+
+	if rel == "." {
+		// The root itself can't be removed, only emptied
+		return nil
+	}
+	if err := fs.root.Remove(rel); err != nil && !os.IsNotExist(err) {
+		return fail(err)
+	}
+	return nil
+}
+
+// readDirNames returns the names of the members of the directory rel.
+func (fs *RootFileSystem) readDirNames(rel string) ([]string, error) {
+	f, err := fs.root.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+func (fs *RootFileSystem) Mkdir(ctx context.Context, name string) error {
+	rel, err := fs.relPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = fs.root.Mkdir(rel, 0755)
+	if os.IsExist(err) {
+		// The path only needs to be looked up to describe the failure
+		if fi, statErr := fs.root.Stat(rel); statErr == nil && fi.IsDir() {
+			// If it's already a directory, return 405 Method Not Allowed (RFC4918:S9.1)
+			return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("collection already exists"))
+		}
+		// If it's not a directory, return 405 Method Not Allowed
+		return NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("resource exists and is not a collection"))
+	} else if isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.3.1
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	} else if err != nil {
+		return errFromRoot(err)
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst, both relative to the root, until ctx
+// is done. When it's part of a move, the copy is flushed to disk, as the
+// source is removed afterwards.
+func (fs *RootFileSystem) copyFile(ctx context.Context, src, dst string, perm os.FileMode, mc *moveCopy) error {
+	srcFile, err := fs.root.Open(src)
+	if err != nil {
+		return errFromRoot(err)
+	}
+	defer srcFile.Close()
+	defer bindDeadline(ctx, srcFile)()
+
+	dstFile, err := fs.root.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if isMissingParent(err) {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	} else if err != nil {
+		return errFromRoot(err)
+	}
+
+	var w io.Writer = dstFile
+	if mc != nil && mc.progress != nil {
+		w = io.MultiWriter(dstFile, mc)
+	}
+	_, err = copyBufferContext(ctx, w, srcFile)
+	if err == nil && mc != nil {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Don't leave a truncated copy behind
+		fs.root.Remove(dst)
+		return errFromRoot(err)
+	}
+	return nil
+}
+
+func (fs *RootFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	return fs.copy(ctx, src, dst, options, nil)
+}
+
+func (fs *RootFileSystem) copy(ctx context.Context, src, dst string, options *CopyOptions, mc *moveCopy) (created bool, err error) {
+	srcRel, err := fs.relPath(src)
+	if err != nil {
+		return false, err
+	}
+	dstRel, err := fs.relPath(dst)
+	if err != nil {
+		return false, err
+	}
+	if dstRel == "." {
+		return false, errReplaceRoot
+	}
+	if err := checkDestination(src, dst); err != nil {
+		return false, err
+	}
+
+	srcInfo, err := fs.root.Stat(srcRel)
+	if err != nil {
+		return false, errFromRoot(err)
+	}
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
+	}
+	srcPerm := srcInfo.Mode() & os.ModePerm
+
+	// Don't replace the destination once ctx is done
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	// A missing parent is detected when creating the destination
+	if _, err := fs.root.Stat(dstRel); err != nil {
+		if !isMissingParent(err) {
+			return false, errFromRoot(err)
+		}
+		created = true
+	} else {
+		if options.NoOverwrite {
+			return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
+		}
+		if errs := fs.removeAll(ctx, dstRel, true); len(errs) > 0 {
+			return false, &PartialError{Errors: errs}
+		}
+	}
+
+	if !srcInfo.IsDir() {
+		if err := fs.copyFile(ctx, srcRel, dstRel, srcPerm, mc); err != nil {
+			return false, err
+		}
+		return created, nil
+	}
+
+	if err := fs.root.Mkdir(dstRel, srcPerm); isMissingParent(err) {
+		// Parent directory doesn't exist, return 409 Conflict as per RFC4918:S9.8.5
+		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	} else if err != nil {
+		return false, errFromRoot(err)
+	}
+	if options.NoRecursive {
+		return created, nil
+	}
+
+	// Directories are created as the tree is walked, files are copied by a
+	// pool of workers. Failures are collected so that the rest of the tree
+	// can still be copied.
+	var (
+		mu   sync.Mutex
+		errs []MemberError
+		wg   sync.WaitGroup
+	)
+	addErr := func(href string, err error) {
+		mu.Lock()
+		errs = append(errs, MemberError{Path: href, Err: err})
+		mu.Unlock()
+	}
+	jobs := make(chan copyFileJob)
+	for range fs.opts.CopyWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := fs.copyFile(ctx, job.src, job.dst, job.perm, mc); err != nil {
+					addErr(job.href, err)
+				}
+			}
+		}()
+	}
+
+	err = fs.copyTree(ctx, srcRel, dstRel, dst, jobs, addErr)
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return false, errFromOS(err)
+	}
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b MemberError) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+		return created, &PartialError{Errors: errs}
+	}
+	return created, nil
+}
+
+// copyTree copies the members of the directory src to the existing directory
+// dst, whose resource name is href. Directories are created right away, files
+// are queued to jobs.
+func (fs *RootFileSystem) copyTree(ctx context.Context, src, dst, href string, jobs chan<- copyFileJob, addErr func(href string, err error)) error {
+	f, err := fs.root.Open(src)
+	if err != nil {
+		addErr(href, errFromRoot(err))
+		return nil
+	}
+	defer f.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, readErr := f.ReadDir(walkBatchSize)
+		for _, entry := range entries {
+			memberSrc := filepath.Join(src, entry.Name())
+			memberDst := filepath.Join(dst, entry.Name())
+			memberHref := path.Join(href, entry.Name())
+			fi, err := entry.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed since the directory was read
+				continue
+			} else if err != nil {
+				addErr(memberHref, errFromRoot(err))
+				continue
+			}
+			perm := fi.Mode() & os.ModePerm
+			if !fi.IsDir() {
+				jobs <- copyFileJob{
+					src:  memberSrc,
+					dst:  memberDst,
+					perm: perm,
+					href: memberHref,
+				}
+				continue
+			}
+			if err := fs.root.Mkdir(memberDst, perm); err != nil {
+				addErr(memberHref, errFromRoot(err))
+				continue
+			}
+			if err := fs.copyTree(ctx, memberSrc, memberDst, memberHref, jobs, addErr); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		} else if readErr != nil {
+			addErr(href, errFromRoot(readErr))
+			return nil
+		}
+	}
+}
+
+func (fs *RootFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	srcRel, err := fs.relPath(src)
+	if err != nil {
+		return false, err
+	}
+	dstRel, err := fs.relPath(dst)
+	if err != nil {
+		return false, err
+	}
+	if dstRel == "." {
+		return false, errReplaceRoot
+	}
+	if err := checkDestination(src, dst); err != nil {
+		return false, err
+	}
+
+	srcInfo, err := fs.root.Stat(srcRel)
+	if err != nil {
+		return false, errFromRoot(err)
+	}
+	if err := checkConditionalDates(&FileInfo{ModTime: srcInfo.ModTime()}, "", "", options.IfUnmodifiedSince, options.IfModifiedSince); err != nil {
+		return false, err
+	}
+
+	// A missing parent is detected when renaming
+	if _, err := fs.root.Stat(dstRel); err != nil {
+		if !isMissingParent(err) {
+			return false, errFromRoot(err)
+		}
+		created = true
+	} else {
+		if options.NoOverwrite {
+			return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
+		}
+		if errs := fs.removeAll(ctx, dstRel, true); len(errs) > 0 {
+			return false, &PartialError{Errors: errs}
+		}
+	}
+
+	// Renaming is atomic and doesn't copy any data
+	err = rootRename(fs.root, srcRel, dstRel)
+	if err == nil {
+		return created, nil
+	} else if isMissingParent(err) {
+		// The source exists, so the destination parent doesn't. Return 409
+		// Conflict as per RFC4918
+		return false, NewHTTPError(http.StatusConflict, fmt.Errorf("destination parent collection doesn't exist"))
+	}
+
+	// Fall back to copying and removing the source, e.g. across devices.
+	// On failure, the source is kept intact and the partial copy is removed.
+	mc := &moveCopy{progress: options.Progress}
+	if _, err := fs.copy(ctx, src, dst, &CopyOptions{}, mc); err != nil {
+		fs.removeAll(context.WithoutCancel(ctx), dstRel, true)
+		var partialErr *PartialError
+		if errors.As(err, &partialErr) {
+			// Nothing was moved, report the first failure
+			return false, partialErr.Errors[0].Err
+		}
+		return false, err
+	}
+	if mc.progress != nil {
+		mc.done()
+	}
+	if d, err := fs.root.Open(filepath.Dir(dstRel)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	// Remove the source. Part of it may already be gone on failure, so the
+	// destination is kept to avoid losing data. The move is complete once
+	// copied, so it's no longer interrupted.
+	if errs := fs.removeAll(context.WithoutCancel(ctx), srcRel, true); len(errs) > 0 {
+		return false, &PartialError{Errors: errs}
+	}
+	return created, nil
+}
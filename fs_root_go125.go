@@ -0,0 +1,16 @@
+//go:build go1.25
+
+package webdav
+
+import (
+	"os"
+	"time"
+)
+
+func rootRename(root *os.Root, oldname, newname string) error {
+	return root.Rename(oldname, newname)
+}
+
+func rootChtimes(root *os.Root, name string, mtime time.Time) error {
+	return root.Chtimes(name, time.Time{}, mtime)
+}
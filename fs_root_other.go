@@ -0,0 +1,19 @@
+//go:build !go1.25
+
+package webdav
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// os.Root can't rename files and set modification times before Go 1.25.
+
+func rootRename(root *os.Root, oldname, newname string) error {
+	return errors.ErrUnsupported
+}
+
+func rootChtimes(root *os.Root, name string, mtime time.Time) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,169 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestCopyMoveToRoot(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a/b.txt": "b", "c.txt": "c"})
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			for _, method := range []string{"COPY", "MOVE"} {
+				checkStatus(t, h, testRequest{method: method, target: "/a/", header: map[string]string{"Destination": "/"}}, http.StatusForbidden)
+			}
+			checkFile(t, dir, "a/b.txt", "b")
+			checkFile(t, dir, "c.txt", "c")
+		})
+	}
+}
+
+func TestCopyMoveIntoSelf(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"d/a.txt": "a", "b.txt": "b"})
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			for _, method := range []string{"COPY", "MOVE"} {
+				for _, tc := range []struct{ target, dest string }{
+					{"/d/", "/d/"},
+					{"/d/", "/d/sub/x/"},
+					{"/b.txt", "/b.txt"},
+				} {
+					checkStatus(t, h, testRequest{method: method, target: tc.target, header: map[string]string{"Destination": tc.dest, "Overwrite": "T"}}, http.StatusForbidden)
+				}
+			}
+			checkFile(t, dir, "d/a.txt", "a")
+			checkFile(t, dir, "b.txt", "b")
+			checkMissing(t, dir, "d/sub")
+		})
+	}
+}
+
+func TestRootFileSystemCopyIntoSelf(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"d/a.txt": "a", "b.txt": "b"})
+	root, err := webdav.NewRootFileSystem(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	tests := []struct {
+		src, dst string
+	}{
+		{"/d/", "/d/"},
+		{"/d", "/d/sub/x"},
+		{"/b.txt", "/b.txt"},
+	}
+	for _, tc := range tests {
+		if _, err := root.Copy(t.Context(), tc.src, tc.dst, &webdav.CopyOptions{}); webdav.HTTPStatus(err) != http.StatusForbidden {
+			t.Errorf("Copy(%v, %v) = %v, want 403", tc.src, tc.dst, err)
+		}
+		if _, err := root.Move(t.Context(), tc.src, tc.dst, &webdav.MoveOptions{}); webdav.HTTPStatus(err) != http.StatusForbidden {
+			t.Errorf("Move(%v, %v) = %v, want 403", tc.src, tc.dst, err)
+		}
+	}
+	checkFile(t, dir, "d/a.txt", "a")
+	checkFile(t, dir, "b.txt", "b")
+	checkMissing(t, dir, "d/sub")
+}
+
+func TestRootFileSystemEscape(t *testing.T) {
+	outside := t.TempDir()
+	writeFiles(t, outside, map[string]string{"secret.txt": "secret"})
+	dir := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+	root, err := webdav.NewRootFileSystem(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	h := &webdav.Handler{FileSystem: root}
+
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/link/secret.txt"}, http.StatusForbidden)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/link/new.txt", body: "new"}, http.StatusForbidden)
+	checkMissing(t, outside, "new.txt")
+}
+
+func TestRootFileSystemOptions(t *testing.T) {
+	for _, opts := range []*webdav.RootOptions{
+		nil,
+		{CopyWorkers: 1},
+		{CopyWorkers: 8, ReadAhead: 1 << 20, DirectIO: true},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"a/b.txt": "b", "a/c/d.txt": "d"})
+		root, err := webdav.NewRootFileSystem(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer root.Close()
+		h := &webdav.Handler{FileSystem: root}
+
+		big := strings.Repeat("x", 3*4096+10)
+		checkStatus(t, h, testRequest{method: http.MethodPut, target: "/big.txt", body: big}, http.StatusCreated)
+		if w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/big.txt"}, http.StatusOK); w.Body.String() != big {
+			t.Errorf("%+v: GET returned %v bytes, want %v", opts, w.Body.Len(), len(big))
+		}
+		checkStatus(t, h, testRequest{method: "COPY", target: "/a/", header: map[string]string{"Destination": "/e/"}}, http.StatusCreated)
+		checkFile(t, dir, "e/b.txt", "b")
+		checkFile(t, dir, "e/c/d.txt", "d")
+	}
+}
+
+func TestRootFileSystemDirectIO(t *testing.T) {
+	dir := t.TempDir()
+	root, err := webdav.NewRootFileSystem(dir, &webdav.RootOptions{ReadAhead: 4096, DirectIO: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	h := &webdav.Handler{FileSystem: root}
+
+	// Aligned, unaligned and empty uploads
+	for i, size := range []int{0, 10, 4096, 2 * 4096, 4096 + 1} {
+		content := strings.Repeat("y", size)
+		want := http.StatusNoContent
+		if i == 0 {
+			want = http.StatusCreated
+		}
+		checkStatus(t, h, testRequest{method: http.MethodPut, target: "/f.txt", body: content}, want)
+		checkFile(t, dir, "f.txt", content)
+	}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/f.txt", header: map[string]string{"Range": "bytes=4090-"}}, http.StatusPartialContent)
+	if got := w.Body.String(); got != strings.Repeat("y", 7) {
+		t.Errorf("GET range = %q, want 7 bytes", got)
+	}
+
+	// Failed uploads don't leave partial files behind
+	errRead := errors.New("read failed")
+	body := io.NopCloser(io.MultiReader(strings.NewReader(strings.Repeat("z", 3*4096)), iotest.ErrReader(errRead)))
+	if _, _, err := root.Create(context.Background(), "/g.txt", body, &webdav.CreateOptions{}); !errors.Is(err, errRead) {
+		t.Errorf("Create() = %v, want %v", err, errRead)
+	}
+	checkMissing(t, dir, "g.txt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := root.Create(ctx, "/h.txt", io.NopCloser(strings.NewReader("h")), &webdav.CreateOptions{}); err == nil {
+		t.Errorf("Create() with a canceled context = nil, want an error")
+	}
+	checkMissing(t, dir, "h.txt")
+}
@@ -0,0 +1,122 @@
+package webdav_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testRequest is a request sent to a handler by serve.
+type testRequest struct {
+	method, target string
+	body           string
+	header         map[string]string
+	// user is the authenticated user, if any.
+	user string
+}
+
+// serve sends req to h and returns the recorded response.
+func serve(t *testing.T, h http.Handler, req testRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	var body io.Reader
+	if req.body != "" {
+		body = strings.NewReader(req.body)
+	}
+	r := httptest.NewRequest(req.method, req.target, body)
+	if strings.HasPrefix(req.body, "<?xml") {
+		r.Header.Set("Content-Type", "application/xml")
+	}
+	for k, v := range req.header {
+		r.Header.Set(k, v)
+	}
+	if req.user != "" {
+		r = r.WithContext(webdav.ContextWithUser(r.Context(), req.user))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// checkStatus serves req and fails the test if the response status isn't
+// want.
+func checkStatus(t *testing.T, h http.Handler, req testRequest, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	w := serve(t, h, req)
+	if w.Code != want {
+		t.Errorf("%v %v = %v, want %v\n%s", req.method, req.target, w.Code, want, w.Body)
+	}
+	return w
+}
+
+// writeFiles creates files in dir, mapping slash-separated paths to content.
+// Directories are created as needed, a path ending with a slash creates an
+// empty directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of the file name in dir, and whether it
+// exists.
+func readFile(t *testing.T, dir, name string) (string, bool) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "", false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), true
+}
+
+// checkFile fails the test if the file name in dir doesn't have the content
+// want.
+func checkFile(t *testing.T, dir, name, want string) {
+	t.Helper()
+	if got, ok := readFile(t, dir, name); !ok {
+		t.Errorf("%v doesn't exist, want %q", name, want)
+	} else if got != want {
+		t.Errorf("%v = %q, want %q", name, got, want)
+	}
+}
+
+// readDirNames returns the names of the entries of the directory dir.
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names, nil
+}
+
+// checkMissing fails the test if the file name exists in dir.
+func checkMissing(t *testing.T, dir, name string) {
+	t.Helper()
+	if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+		t.Errorf("%v exists", name)
+	}
+}
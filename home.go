@@ -0,0 +1,241 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// homeHandler serves each user from their own home directory, /<user> in the
+// configured root. Home directories are created on first access.
+type homeHandler struct {
+	config Config
+
+	mu       sync.Mutex
+	handlers map[string]*Handler
+	drainer  drainer
+}
+
+func newHomeHandler(c Config) *homeHandler {
+	if _, ok := c.Root.(SubFileSystem); !ok {
+		log.Warn("webdav: home directories require a Root implementing SubFileSystem")
+	}
+	// The handlers of all users share a lock system
+	if c.Lock && c.LockSystem == nil {
+		c.LockSystem = NewLockSystem()
+	}
+	return &homeHandler{config: c, handlers: make(map[string]*Handler)}
+}
+
+func (hh *homeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !hh.drainer.acquire() {
+		serveShuttingDown(w)
+		return
+	}
+	defer hh.drainer.release()
+
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "webdav: authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	h, err := hh.handler(r.Context(), user)
+	if err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// checkContinue evaluates the preconditions of an upload before its body is
+// read, with the handler of the user. Requests of unknown users are let
+// through.
+func (hh *homeHandler) checkContinue(r *http.Request) error {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	h, err := hh.handler(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	return h.checkContinue(r)
+}
+
+// handler returns the handler serving the home directory of user.
+func (hh *homeHandler) handler(ctx context.Context, user string) (*Handler, error) {
+	if user == "" || user == "." || user == ".." || strings.ContainsAny(user, "/\\\x00") {
+		return nil, internal.HTTPErrorf(http.StatusForbidden, "webdav: invalid user name %q", user)
+	}
+	root, ok := hh.config.Root.(SubFileSystem)
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusInternalServerError, "webdav: home directories require a Root implementing SubFileSystem")
+	}
+
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+
+	if h, ok := hh.handlers[user]; ok {
+		return h, nil
+	}
+
+	home := "/" + user
+	if _, err := hh.config.Root.Stat(ctx, home); internal.IsNotFound(err) {
+		if err := hh.config.Root.Mkdir(ctx, home); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	fs, err := root.Sub(home)
+	if err != nil {
+		return nil, err
+	}
+	c := hh.config
+	c.Root = fs
+	if c.PropertyStore != nil {
+		c.PropertyStore = &homePropertyStore{ps: c.PropertyStore, home: home}
+	}
+	h := newHandler(c)
+	if h.LockSystem != nil {
+		h.LockSystem = &homeLockSystem{ls: h.LockSystem, home: home}
+	}
+	hh.handlers[user] = h
+	return h, nil
+}
+
+// homePropertyStore stores the properties of the resources of a home
+// directory in a PropertyStore shared by all users, under their path in the
+// root.
+type homePropertyStore struct {
+	ps   PropertyStore
+	home string
+}
+
+func (s *homePropertyStore) GetProperties(ctx context.Context, name string) (map[xml.Name]string, error) {
+	return s.ps.GetProperties(ctx, path.Join(s.home, name))
+}
+
+func (s *homePropertyStore) ListPropertyNames(ctx context.Context, name string) ([]xml.Name, error) {
+	return s.ps.ListPropertyNames(ctx, path.Join(s.home, name))
+}
+
+func (s *homePropertyStore) PatchProperties(ctx context.Context, name string, set map[xml.Name]string, remove []xml.Name) error {
+	return s.ps.PatchProperties(ctx, path.Join(s.home, name), set, remove)
+}
+
+func (s *homePropertyStore) DeleteProperties(ctx context.Context, name string) error {
+	return s.ps.DeleteProperties(ctx, path.Join(s.home, name))
+}
+
+func (s *homePropertyStore) CopyProperties(ctx context.Context, src, dst string, recursive bool) error {
+	return s.ps.CopyProperties(ctx, path.Join(s.home, src), path.Join(s.home, dst), recursive)
+}
+
+func (s *homePropertyStore) MoveProperties(ctx context.Context, src, dst string) error {
+	return s.ps.MoveProperties(ctx, path.Join(s.home, src), path.Join(s.home, dst))
+}
+
+func (s *homePropertyStore) WalkProperties(ctx context.Context, fn func(name string) error) error {
+	return s.ps.WalkProperties(ctx, func(name string) error {
+		if rel, ok := homeRelPath(s.home, name); ok {
+			return fn(rel)
+		}
+		return nil
+	})
+}
+
+// homeLockSystem locks the resources of a home directory in a LockSystem
+// shared by all users, under their path in the root.
+type homeLockSystem struct {
+	ls   LockSystem
+	home string
+}
+
+func (ls *homeLockSystem) Lock(ctx context.Context, name string, opts *LockOptions) (*Lock, error) {
+	return ls.lock(ls.ls.Lock(ctx, path.Join(ls.home, name), opts))
+}
+
+// Refresh looks the lock up first, so that the locks of other users are left
+// alone.
+func (ls *homeLockSystem) Refresh(ctx context.Context, token string, timeout time.Duration) (*Lock, error) {
+	if _, err := ls.Lookup(ctx, token); err != nil {
+		return nil, err
+	}
+	return ls.lock(ls.ls.Refresh(ctx, token, timeout))
+}
+
+// Unlock looks the lock up first, like Refresh.
+func (ls *homeLockSystem) Unlock(ctx context.Context, token string) error {
+	if _, err := ls.Lookup(ctx, token); err != nil {
+		return err
+	}
+	return ls.ls.Unlock(ctx, token)
+}
+
+func (ls *homeLockSystem) Lookup(ctx context.Context, token string) (*Lock, error) {
+	return ls.lock(ls.ls.Lookup(ctx, token))
+}
+
+func (ls *homeLockSystem) Confirm(ctx context.Context, name string, tokens []string) error {
+	return ls.ls.Confirm(ctx, path.Join(ls.home, name), tokens)
+}
+
+// lock returns l with its root relative to the home directory. Locks of other
+// users are reported as missing.
+func (ls *homeLockSystem) lock(l *Lock, err error) (*Lock, error) {
+	if err != nil {
+		return nil, err
+	}
+	rel, ok := homeRelPath(ls.home, l.Root)
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: lock token not found")
+	}
+	lock := *l
+	lock.Root = rel
+	return &lock, nil
+}
+
+// homeRelPath returns the path of name relative to the home directory, and
+// whether it's inside it.
+func homeRelPath(home, name string) (string, bool) {
+	if name == home {
+		return "/", true
+	}
+	rel, ok := strings.CutPrefix(name, home+"/")
+	return "/" + rel, ok
+}
+
+// Shutdown gracefully shuts down the handlers of all users, see
+// Handler.Shutdown. The shared lock system and property store are flushed
+// once the handlers are shut down, since the handlers only see them wrapped.
+func (hh *homeHandler) Shutdown(ctx context.Context) error {
+	err := hh.drainer.drain(ctx)
+
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	for _, h := range hh.handlers {
+		if hErr := h.Shutdown(ctx); hErr != nil {
+			err = errors.Join(err, hErr)
+		}
+	}
+	for _, v := range []interface{}{hh.config.LockSystem, hh.config.PropertyStore} {
+		if f, ok := v.(Flusher); ok {
+			if flushErr := f.Flush(ctx); flushErr != nil {
+				err = errors.Join(err, flushErr)
+			}
+		}
+	}
+	return err
+}
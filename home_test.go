@@ -0,0 +1,88 @@
+package webdav_test
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+const propPatchColor = `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:test">
+  <D:set><D:prop><Z:color>red</Z:color></D:prop></D:set>
+</D:propertyupdate>`
+
+const propFindColor = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:Z="urn:test"><D:prop><Z:color/></D:prop></D:propfind>`
+
+// TestHomeDirsSharedStores checks that the users of home directories don't
+// share properties or locks of resources with the same path.
+func TestHomeDirsSharedStores(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"alice/a.txt": "alice",
+		"bob/a.txt":   "bob",
+	})
+	props := webdav.NewMemPropertyStore()
+	h := webdav.NewServer(webdav.Config{
+		Root:          webdav.LocalFileSystem(dir),
+		HomeDirs:      true,
+		PropertyStore: props,
+		LockSystem:    webdav.NewLockSystem(),
+	}).HTTPHandler()
+
+	checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatchColor, user: "alice"}, http.StatusMultiStatus)
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propFindColor, header: map[string]string{"Depth": "0"}, user: "alice"}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), "red") {
+		t.Errorf("PROPFIND as alice: missing property in\n%s", w.Body)
+	}
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propFindColor, header: map[string]string{"Depth": "0"}, user: "bob"}, http.StatusMultiStatus)
+	if strings.Contains(w.Body.String(), "red") {
+		t.Errorf("PROPFIND as bob: property of alice in\n%s", w.Body)
+	}
+	if _, err := props.GetProperties(t.Context(), "/alice/a.txt"); err != nil {
+		t.Fatal(err)
+	} else if p, _ := props.GetProperties(t.Context(), "/a.txt"); p != nil {
+		t.Errorf("properties stored without the home directory: %v", p)
+	}
+
+	checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody, user: "alice"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "new", user: "alice"}, http.StatusLocked)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "new", user: "bob"}, http.StatusNoContent)
+	checkFile(t, dir, "alice/a.txt", "alice")
+	checkFile(t, dir, "bob/a.txt", "new")
+}
+
+func TestHomeDirsForeignLocks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"alice/a.txt": "alice", "bob/": ""})
+	ls := webdav.NewLockSystem()
+	h := webdav.NewServer(webdav.Config{
+		Root:       webdav.LocalFileSystem(dir),
+		HomeDirs:   true,
+		LockSystem: ls,
+	}).HTTPHandler()
+
+	w := checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody, header: map[string]string{"Timeout": "Second-3600"}, user: "alice"}, http.StatusOK)
+	token := w.Header().Get("Lock-Token")
+	if !regexp.MustCompile(`^<opaquelocktoken:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}>$`).MatchString(token) {
+		t.Errorf("Lock-Token = %q, want a random UUID", token)
+	}
+
+	// Other users can neither refresh nor remove the lock
+	checkStatus(t, h, testRequest{method: "LOCK", target: "/", header: map[string]string{"If": "(" + token + ")", "Timeout": "Second-1"}, user: "bob"}, http.StatusPreconditionFailed)
+	checkStatus(t, h, testRequest{method: "UNLOCK", target: "/", header: map[string]string{"Lock-Token": token}, user: "bob"}, http.StatusPreconditionFailed)
+	lock, err := ls.Lookup(t.Context(), strings.Trim(token, "<>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Root != "/alice/a.txt" || lock.Timeout != time.Hour {
+		t.Errorf("lock = %+v, want /alice/a.txt for an hour", lock)
+	}
+
+	checkStatus(t, h, testRequest{method: "UNLOCK", target: "/a.txt", header: map[string]string{"Lock-Token": token}, user: "alice"}, http.StatusNoContent)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "new", user: "alice"}, http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+)
+
+// Event describes an operation performed by the handler.
+type Event struct {
+	// Path is the resource the operation applied to.
+	Path string
+	// Destination is the target of COPY and MOVE operations.
+	Destination string
+	// FileInfo describes the resource, for GET and PUT operations.
+	FileInfo *FileInfo
+	// User is the authenticated user of the request, if any.
+	User string
+	// Copied is the number of bytes copied so far, for OnMoveProgress.
+	Copied int64
+	// Created reports whether PUT, COPY and MOVE operations created their
+	// target, rather than replacing an existing resource.
+	Created bool
+}
+
+// Hooks are functions called after successful operations, e.g. to index
+// uploaded files, send notifications or invalidate caches. They're called
+// synchronously before the response is completed, so slow work should be
+// performed in the background.
+type Hooks struct {
+	OnGet func(ctx context.Context, event *Event)
+	// OnPut is called for uploads, including members added with POST.
+	OnPut    func(ctx context.Context, event *Event)
+	OnDelete func(ctx context.Context, event *Event)
+	OnMove   func(ctx context.Context, event *Event)
+	OnCopy   func(ctx context.Context, event *Event)
+	OnMkcol  func(ctx context.Context, event *Event)
+	// OnMoveProgress is called periodically while a MOVE is carried out by
+	// copying the resource, e.g. across devices, which may take a while for
+	// large trees. Unlike other hooks, it's called before the operation
+	// succeeds.
+	OnMoveProgress func(ctx context.Context, event *Event)
+}
+
+// callHook calls hook, if set, with an event for the resource targeted by a
+// request.
+func callHook(hook func(context.Context, *Event), r *http.Request, event Event) {
+	if hook == nil {
+		return
+	}
+	if event.Path == "" {
+		event.Path = r.URL.Path
+	}
+	event.User, _ = UserFromContext(r.Context())
+	hook(r.Context(), &event)
+}
@@ -0,0 +1,84 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	var events []string
+	var last *webdav.Event
+	hook := func(name string) func(context.Context, *webdav.Event) {
+		return func(ctx context.Context, event *webdav.Event) {
+			events = append(events, name+" "+event.Path)
+			last = event
+		}
+	}
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		Hooks: webdav.Hooks{
+			OnGet:    hook("get"),
+			OnPut:    hook("put"),
+			OnDelete: hook("delete"),
+			OnMove:   hook("move"),
+			OnCopy:   hook("copy"),
+			OnMkcol:  hook("mkcol"),
+		},
+	}
+
+	tests := []struct {
+		req   testRequest
+		want  int
+		event string
+	}{
+		{testRequest{method: http.MethodGet, target: "/a.txt", user: "alice"}, http.StatusOK, "get /a.txt"},
+		{testRequest{method: http.MethodHead, target: "/a.txt"}, http.StatusOK, ""},
+		{testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated, "put /b.txt"},
+		{testRequest{method: "MKCOL", target: "/d"}, http.StatusCreated, "mkcol /d"},
+		{testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/d/a.txt"}}, http.StatusCreated, "copy /a.txt"},
+		{testRequest{method: "MOVE", target: "/b.txt", header: map[string]string{"Destination": "/d/b.txt"}}, http.StatusCreated, "move /b.txt"},
+		{testRequest{method: http.MethodDelete, target: "/d"}, http.StatusNoContent, "delete /d"},
+		// Failed operations don't call hooks
+		{testRequest{method: http.MethodGet, target: "/missing"}, http.StatusNotFound, ""},
+		{testRequest{method: http.MethodPut, target: "/missing/b.txt", body: "b"}, http.StatusConflict, ""},
+		{testRequest{method: "MKCOL", target: "/a.txt"}, http.StatusMethodNotAllowed, ""},
+		{testRequest{method: "COPY", target: "/missing", header: map[string]string{"Destination": "/c.txt"}}, http.StatusNotFound, ""},
+		{testRequest{method: "MOVE", target: "/missing", header: map[string]string{"Destination": "/c.txt"}}, http.StatusNotFound, ""},
+		{testRequest{method: http.MethodDelete, target: "/missing"}, http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		events, last = nil, nil
+		checkStatus(t, h, tc.req, tc.want)
+
+		var want []string
+		if tc.event != "" {
+			want = []string{tc.event}
+		}
+		if len(events) != len(want) || (len(want) > 0 && events[0] != want[0]) {
+			t.Errorf("%v %v: hooks called with %q, want %q", tc.req.method, tc.req.target, events, want)
+			continue
+		}
+		if last == nil {
+			continue
+		}
+		if last.User != tc.req.user {
+			t.Errorf("%v %v: event user = %q, want %q", tc.req.method, tc.req.target, last.User, tc.req.user)
+		}
+		switch tc.req.method {
+		case http.MethodGet, http.MethodPut:
+			if last.FileInfo == nil || last.FileInfo.Path != tc.req.target {
+				t.Errorf("%v %v: event FileInfo = %+v, want %v", tc.req.method, tc.req.target, last.FileInfo, tc.req.target)
+			}
+		case "COPY", "MOVE":
+			if last.Destination != tc.req.header["Destination"] || !last.Created {
+				t.Errorf("%v %v: event = %+v, want created destination %v", tc.req.method, tc.req.target, last, tc.req.header["Destination"])
+			}
+		}
+	}
+}
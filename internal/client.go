@@ -198,9 +198,14 @@ func (c *Client) Options(ctx context.Context, path string) (classes map[string]b
 
 // SyncCollection perform a `sync-collection` REPORT operation on a resource
 func (c *Client) SyncCollection(ctx context.Context, path, syncToken string, level Depth, limit *Limit, prop *Prop) (*MultiStatus, error) {
+	// RFC 6578 spells the infinite level differently than Depth
+	syncLevel := level.String()
+	if level == DepthInfinity {
+		syncLevel = "infinite"
+	}
 	q := SyncCollectionQuery{
 		SyncToken: syncToken,
-		SyncLevel: level.String(),
+		SyncLevel: syncLevel,
 		Limit:     limit,
 		Prop:      prop,
 	}
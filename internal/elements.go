@@ -14,16 +14,24 @@ import (
 const Namespace = "DAV:"
 
 var (
-	ResourceTypeName     = xml.Name{Namespace, "resourcetype"}
-	DisplayNameName      = xml.Name{Namespace, "displayname"}
-	GetContentLengthName = xml.Name{Namespace, "getcontentlength"}
-	GetContentTypeName   = xml.Name{Namespace, "getcontenttype"}
-	GetLastModifiedName  = xml.Name{Namespace, "getlastmodified"}
-	GetETagName          = xml.Name{Namespace, "getetag"}
-	SupportedLockName    = xml.Name{Namespace, "supportedlock"}
-	LockDiscoveryName    = xml.Name{Namespace, "lockdiscovery"}
+	ResourceTypeName       = xml.Name{Namespace, "resourcetype"}
+	DisplayNameName        = xml.Name{Namespace, "displayname"}
+	GetContentLengthName   = xml.Name{Namespace, "getcontentlength"}
+	GetContentTypeName     = xml.Name{Namespace, "getcontenttype"}
+	GetContentLanguageName = xml.Name{Namespace, "getcontentlanguage"}
+	GetLastModifiedName    = xml.Name{Namespace, "getlastmodified"}
+	GetETagName            = xml.Name{Namespace, "getetag"}
+	SupportedLockName      = xml.Name{Namespace, "supportedlock"}
+	LockDiscoveryName      = xml.Name{Namespace, "lockdiscovery"}
 
 	CurrentUserPrincipalName = xml.Name{Namespace, "current-user-principal"}
+	AddMemberName            = xml.Name{Namespace, "add-member"}
+	SupportedReportSetName   = xml.Name{Namespace, "supported-report-set"}
+	SupportedReportName      = xml.Name{Namespace, "supported-report"}
+
+	CurrentUserPrivilegeSetName = xml.Name{Namespace, "current-user-privilege-set"}
+
+	NumberOfMatchesWithinLimitsName = xml.Name{Namespace, "number-of-matches-within-limits"}
 )
 
 type Status struct {
@@ -36,7 +44,11 @@ func (s *Status) MarshalText() ([]byte, error) {
 	if text == "" {
 		text = http.StatusText(s.Code)
 	}
-	return []byte(fmt.Sprintf("HTTP/1.1 %v %v", s.Code, text)), nil
+	b := make([]byte, 0, len("HTTP/1.1 000 ")+len(text))
+	b = append(b, "HTTP/1.1 "...)
+	b = strconv.AppendInt(b, int64(s.Code), 10)
+	b = append(b, ' ')
+	return append(b, text...), nil
 }
 
 func (s *Status) UnmarshalText(b []byte) error {
@@ -50,7 +62,7 @@ func (s *Status) UnmarshalText(b []byte) error {
 	}
 	code, err := strconv.Atoi(parts[1])
 	if err != nil {
-		return fmt.Errorf("webdav: invalid HTTP status %q: failed to parse code: %v", s, err)
+		return fmt.Errorf("webdav: invalid HTTP status %q: failed to parse code: %w", s, err)
 	}
 
 	s.Code = code
@@ -211,24 +223,37 @@ func newPropError(name xml.Name, err error) error {
 }
 
 func (resp *Response) EncodeProp(code int, v interface{}) error {
-	raw, err := EncodeRawXMLElement(v)
-	if err != nil {
-		return err
-	}
+	resp.appendProp(code, RawXMLValue{out: v})
+	return nil
+}
 
+// appendProp adds a property to the propstat with the status code.
+func (resp *Response) appendProp(code int, raw RawXMLValue) {
 	for i := range resp.PropStats {
 		propstat := &resp.PropStats[i]
 		if propstat.Status.Code == code {
-			propstat.Prop.Raw = append(propstat.Prop.Raw, *raw)
-			return nil
+			propstat.Prop.Raw = append(propstat.Prop.Raw, raw)
+			return
 		}
 	}
 
 	resp.PropStats = append(resp.PropStats, PropStat{
 		Status: Status{Code: code},
-		Prop:   Prop{Raw: []RawXMLValue{*raw}},
+		Prop:   Prop{Raw: []RawXMLValue{raw}},
 	})
-	return nil
+}
+
+// NewTruncatedResponse returns the response for the request URI marking a
+// multistatus truncated by the server, as specified in RFC 5323 section 2.6.
+func NewTruncatedResponse(path string) *Response {
+	return &Response{
+		Hrefs:               []Href{{Path: path}},
+		Status:              &Status{Code: http.StatusInsufficientStorage},
+		ResponseDescription: "Too many responses, the result was truncated",
+		Error: &Error{Raw: []RawXMLValue{
+			*NewRawXMLElement(NumberOfMatchesWithinLimitsName, nil, nil),
+		}},
+	}
 }
 
 // https://tools.ietf.org/html/rfc4918#section-14.9
@@ -348,6 +373,12 @@ type GetContentType struct {
 	Type    string   `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4918#section-15.3
+type GetContentLanguage struct {
+	XMLName  xml.Name `xml:"DAV: getcontentlanguage"`
+	Language string   `xml:",chardata"`
+}
+
 // https://www.rfc-editor.org/rfc/rfc4918#section-15.10
 type SupportedLock struct {
 	XMLName     xml.Name    `xml:"DAV: supportedlock"`
@@ -373,8 +404,7 @@ func (t *Time) UnmarshalText(b []byte) error {
 }
 
 func (t *Time) MarshalText() ([]byte, error) {
-	s := time.Time(*t).UTC().Format(http.TimeFormat)
-	return []byte(s), nil
+	return time.Time(*t).UTC().AppendFormat(make([]byte, 0, len(http.TimeFormat)), http.TimeFormat), nil
 }
 
 // https://tools.ietf.org/html/rfc4918#section-15.7
@@ -385,8 +415,8 @@ type GetLastModified struct {
 
 // https://tools.ietf.org/html/rfc4918#section-15.6
 type GetETag struct {
-	XMLName xml.Name `xml:"DAV: getetag"`
-	ETag    ETag     `xml:",chardata"`
+	XMLName xml.Name  `xml:"DAV: getetag"`
+	ETag    EntityTag `xml:",chardata"`
 }
 
 type ETag string
@@ -394,18 +424,81 @@ type ETag string
 func (etag *ETag) UnmarshalText(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
-		return fmt.Errorf("webdav: failed to unquote ETag: %v", err)
+		return fmt.Errorf("webdav: failed to unquote ETag: %w", err)
 	}
 	*etag = ETag(s)
 	return nil
 }
 
 func (etag ETag) MarshalText() ([]byte, error) {
-	return []byte(etag.String()), nil
+	return strconv.AppendQuote(make([]byte, 0, len(etag)+2), string(etag)), nil
 }
 
 func (etag ETag) String() string {
-	return fmt.Sprintf("%q", string(etag))
+	return strconv.Quote(string(etag))
+}
+
+// EntityTag is an entity tag with its weakness indicator, as defined in RFC
+// 9110 section 8.8.3.
+type EntityTag struct {
+	Tag  string
+	Weak bool
+}
+
+// ParseEntityTags parses a comma-separated list of entity tags, as sent in
+// If-Match and If-None-Match header fields. Commas in quoted tags are kept.
+func ParseEntityTags(s string) ([]EntityTag, error) {
+	var tags []EntityTag
+	for {
+		s = strings.TrimLeft(s, " \t")
+		var t EntityTag
+		if strings.HasPrefix(s, "W/") {
+			t.Weak = true
+			s = s[len("W/"):]
+		}
+		if !strings.HasPrefix(s, `"`) {
+			return nil, fmt.Errorf("webdav: invalid entity tag: missing opening quote")
+		}
+		end := strings.IndexByte(s[1:], '"')
+		if end < 0 {
+			return nil, fmt.Errorf("webdav: invalid entity tag: missing closing quote")
+		}
+		t.Tag, s = s[1:end+1], s[end+2:]
+		tags = append(tags, t)
+
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return tags, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("webdav: invalid entity tag list: expected ','")
+		}
+		s = s[1:]
+	}
+}
+
+// String formats the entity tag, e.g. W/"xyzzy".
+func (t EntityTag) String() string {
+	s := `"` + t.Tag + `"`
+	if t.Weak {
+		s = "W/" + s
+	}
+	return s
+}
+
+func (t EntityTag) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EntityTag) UnmarshalText(b []byte) error {
+	tags, err := ParseEntityTags(string(b))
+	if err != nil {
+		return err
+	} else if len(tags) != 1 {
+		return fmt.Errorf("webdav: expected a single entity tag")
+	}
+	*t = tags[0]
+	return nil
 }
 
 // https://tools.ietf.org/html/rfc4918#section-14.5
@@ -432,6 +525,58 @@ type CurrentUserPrincipal struct {
 	Unauthenticated *struct{} `xml:"unauthenticated,omitempty"`
 }
 
+// https://tools.ietf.org/html/rfc5995#section-3.2
+type AddMember struct {
+	XMLName xml.Name `xml:"DAV: add-member"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3253#section-3.1.5
+type SupportedReportSet struct {
+	XMLName         xml.Name          `xml:"DAV: supported-report-set"`
+	SupportedReport []SupportedReport `xml:"supported-report"`
+}
+
+// https://tools.ietf.org/html/rfc3253#section-3.1.5
+type SupportedReport struct {
+	XMLName xml.Name `xml:"DAV: supported-report"`
+	Report  Report   `xml:"report"`
+}
+
+// https://tools.ietf.org/html/rfc3253#section-3.1.5
+type Report struct {
+	XMLName xml.Name      `xml:"DAV: report"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+func NewSupportedReportSet(names ...xml.Name) *SupportedReportSet {
+	l := make([]SupportedReport, len(names))
+	for i, name := range names {
+		l[i] = SupportedReport{Report: Report{Raw: xmlNamesToRaw([]xml.Name{name})}}
+	}
+	return &SupportedReportSet{SupportedReport: l}
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.4
+type CurrentUserPrivilegeSet struct {
+	XMLName   xml.Name    `xml:"DAV: current-user-privilege-set"`
+	Privilege []Privilege `xml:"privilege"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.3
+type Privilege struct {
+	XMLName xml.Name      `xml:"DAV: privilege"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+func NewCurrentUserPrivilegeSet(names ...xml.Name) *CurrentUserPrivilegeSet {
+	l := make([]Privilege, len(names))
+	for i, name := range names {
+		l[i] = Privilege{Raw: xmlNamesToRaw([]xml.Name{name})}
+	}
+	return &CurrentUserPrivilegeSet{Privilege: l}
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.19
 type PropertyUpdate struct {
 	XMLName xml.Name `xml:"DAV: propertyupdate"`
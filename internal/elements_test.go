@@ -3,6 +3,7 @@ package internal
 import (
 	"bytes"
 	"encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -41,6 +42,87 @@ func TestResponse_Err_error(t *testing.T) {
 	}
 }
 
+func TestHref_MarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"/dir/file.txt", "/dir/file.txt"},
+		{"/a b/c#d?e", "/a%20b/c%23d%3Fe"},
+		{"/100%.txt", "/100%25.txt"},
+		{"/ü/é.txt", "/%C3%BC/%C3%A9.txt"},
+	} {
+		b, err := (&Href{Path: tc.path}).MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%q) = %v", tc.path, err)
+		} else if string(b) != tc.want {
+			t.Errorf("MarshalText(%q) = %q, want %q", tc.path, b, tc.want)
+		}
+
+		var href Href
+		if err := href.UnmarshalText(b); err != nil {
+			t.Fatalf("UnmarshalText(%q) = %v", b, err)
+		} else if href.Path != tc.path {
+			t.Errorf("UnmarshalText(%q): path = %q, want %q", b, href.Path, tc.path)
+		}
+	}
+}
+
+func TestStatus_MarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		status Status
+		want   string
+	}{
+		{Status{Code: 200}, "HTTP/1.1 200 OK"},
+		{Status{Code: 404}, "HTTP/1.1 404 Not Found"},
+		{Status{Code: 423, Text: "Resource Locked"}, "HTTP/1.1 423 Resource Locked"},
+	} {
+		b, err := tc.status.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%v) = %v", tc.status.Code, err)
+		} else if string(b) != tc.want {
+			t.Errorf("MarshalText(%v) = %q, want %q", tc.status.Code, b, tc.want)
+		}
+	}
+
+	var s Status
+	if err := s.UnmarshalText([]byte("HTTP/1.1 404")); err == nil {
+		t.Errorf("UnmarshalText() = nil, expected an error for a missing status text")
+	}
+}
+
+func TestETag_MarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		etag ETag
+		want string
+	}{
+		{"abc", `"abc"`},
+		{"", `""`},
+		{`a"b`, `"a\"b"`},
+	} {
+		b, err := tc.etag.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%q) = %v", string(tc.etag), err)
+		} else if string(b) != tc.want {
+			t.Errorf("MarshalText(%q) = %q, want %q", string(tc.etag), b, tc.want)
+		}
+		if s := tc.etag.String(); s != tc.want {
+			t.Errorf("String(%q) = %q, want %q", string(tc.etag), s, tc.want)
+		}
+
+		var etag ETag
+		if err := etag.UnmarshalText(b); err != nil {
+			t.Fatalf("UnmarshalText(%q) = %v", b, err)
+		} else if etag != tc.etag {
+			t.Errorf("UnmarshalText(%q) = %q, want %q", b, string(etag), string(tc.etag))
+		}
+	}
+
+	var etag ETag
+	if err := etag.UnmarshalText([]byte("abc")); err == nil {
+		t.Errorf("UnmarshalText() = nil, expected an error for an unquoted ETag")
+	}
+}
+
 func TestTimeRoundTrip(t *testing.T) {
 	now := Time(time.Now().UTC())
 	want, err := now.MarshalText()
@@ -63,3 +145,69 @@ func TestTimeRoundTrip(t *testing.T) {
 		t.Fatalf("invalid round-trip:\ngot= %s\nwant=%s", got, want)
 	}
 }
+
+func TestActiveLock_depth(t *testing.T) {
+	b, err := xml.Marshal(&ActiveLock{Depth: DepthInfinity})
+	if err != nil {
+		t.Fatalf("xml.Marshal() = %v", err)
+	}
+	if !bytes.Contains(b, []byte("<depth>infinity</depth>")) {
+		t.Errorf("xml.Marshal() = %s, expected an infinity depth", b)
+	}
+
+	var lock ActiveLock
+	if err := xml.Unmarshal([]byte(`<activelock xmlns="DAV:"><depth>Infinity</depth></activelock>`), &lock); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	} else if lock.Depth != DepthInfinity {
+		t.Errorf("xml.Unmarshal(): depth = %v, want infinity", lock.Depth)
+	}
+}
+
+func TestActiveLock_timeout(t *testing.T) {
+	b, err := xml.Marshal(&ActiveLock{Timeout: &Timeout{Duration: time.Minute}})
+	if err != nil {
+		t.Fatalf("xml.Marshal() = %v", err)
+	}
+	if !bytes.Contains(b, []byte("<timeout>Second-60</timeout>")) {
+		t.Errorf("xml.Marshal() = %s, expected a 60 seconds timeout", b)
+	}
+
+	var lock ActiveLock
+	if err := xml.Unmarshal([]byte(`<activelock xmlns="DAV:"><timeout>Second-3600</timeout></activelock>`), &lock); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	} else if lock.Timeout == nil || lock.Timeout.Duration != time.Hour {
+		t.Errorf("xml.Unmarshal(): timeout = %v, want one hour", lock.Timeout)
+	}
+}
+
+func TestParseEntityTags(t *testing.T) {
+	tests := []struct {
+		s    string
+		tags []EntityTag
+	}{
+		{`"xyzzy"`, []EntityTag{{Tag: "xyzzy"}}},
+		{`W/"xyzzy"`, []EntityTag{{Tag: "xyzzy", Weak: true}}},
+		{`"a", W/"b" ,"c,d"`, []EntityTag{{Tag: "a"}, {Tag: "b", Weak: true}, {Tag: "c,d"}}},
+		{`""`, []EntityTag{{}}},
+		{``, nil},
+		{`xyzzy`, nil},
+		{`"xyzzy`, nil},
+		{`"a" "b"`, nil},
+		{`"a",`, nil},
+	}
+
+	for _, tc := range tests {
+		tags, err := ParseEntityTags(tc.s)
+		if tc.tags == nil {
+			if err == nil {
+				t.Errorf("ParseEntityTags(%q) = %v, expected an error", tc.s, tags)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseEntityTags(%q) = %v", tc.s, err)
+		} else if !reflect.DeepEqual(tags, tc.tags) {
+			t.Errorf("ParseEntityTags(%q) = %v, expected %v", tc.s, tags, tc.tags)
+		}
+	}
+}
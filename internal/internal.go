@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -25,14 +27,17 @@ const (
 	DepthInfinity Depth = -1
 )
 
-// ParseDepth parses a Depth header.
+// ParseDepth parses a Depth header. Surrounding whitespace is ignored, and
+// "infinity" is matched case-insensitively since several clients send it
+// capitalized.
 func ParseDepth(s string) (Depth, error) {
-	switch s {
+	switch s = strings.TrimSpace(s); s {
 	case "0":
 		return DepthZero, nil
 	case "1":
 		return DepthOne, nil
-	case "infinity":
+	}
+	if strings.EqualFold(s, "infinity") {
 		return DepthInfinity, nil
 	}
 	return 0, fmt.Errorf("webdav: invalid Depth value")
@@ -51,6 +56,25 @@ func (d Depth) String() string {
 	panic("webdav: invalid Depth value")
 }
 
+// MarshalText formats the depth for the DAV:depth XML element.
+func (d Depth) MarshalText() ([]byte, error) {
+	switch d {
+	case DepthZero, DepthOne, DepthInfinity:
+		return []byte(d.String()), nil
+	}
+	return nil, fmt.Errorf("webdav: invalid Depth value %d", int(d))
+}
+
+// UnmarshalText parses the DAV:depth XML element.
+func (d *Depth) UnmarshalText(b []byte) error {
+	depth, err := ParseDepth(string(b))
+	if err != nil {
+		return err
+	}
+	*d = depth
+	return nil
+}
+
 // ParseOverwrite parses an Overwrite header.
 func ParseOverwrite(s string) (bool, error) {
 	switch s {
@@ -71,6 +95,67 @@ func FormatOverwrite(overwrite bool) string {
 	}
 }
 
+// ParseTranslate parses a Translate header, sent by Microsoft clients. A false
+// value indicates that the source of the resource is requested rather than a
+// processed rendition of it.
+func ParseTranslate(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "t":
+		return true, nil
+	case "f":
+		return false, nil
+	}
+	return false, fmt.Errorf("webdav: invalid Translate value")
+}
+
+// ParseDestination parses a Destination header, defined in RFC 4918 section
+// 10.3 as an absolute URI or an absolute path. Percent signs which don't start
+// an escape sequence, left unescaped by some clients, are taken literally.
+// The path of the returned URL is decoded and cleaned, keeping any trailing
+// slash.
+func ParseDestination(s string) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("webdav: empty Destination value")
+	}
+	u, err := url.Parse(escapeStrayPercents(s))
+	if err != nil {
+		return nil, fmt.Errorf("webdav: malformed Destination value: %w", err)
+	}
+	if u.Opaque != "" || (u.Path == "" && u.Host == "") || (u.Path != "" && !strings.HasPrefix(u.Path, "/")) {
+		return nil, fmt.Errorf("webdav: Destination value %q is neither an absolute URI nor an absolute path", s)
+	}
+
+	dir := strings.HasSuffix(u.Path, "/")
+	u.Path = path.Clean("/" + u.Path)
+	if dir && u.Path != "/" {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	return u, nil
+}
+
+// escapeStrayPercents escapes the percent signs of s which don't start a
+// percent-encoded octet.
+func escapeStrayPercents(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && (i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2])) {
+			sb.WriteString("%25")
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
 type Timeout struct {
 	Duration time.Duration
 }
@@ -96,6 +181,21 @@ func (t Timeout) String() string {
 	return fmt.Sprintf("Second-%d", t.Duration/time.Second)
 }
 
+// MarshalText formats the timeout for the DAV:timeout XML element.
+func (t Timeout) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText parses the DAV:timeout XML element.
+func (t *Timeout) UnmarshalText(b []byte) error {
+	timeout, err := ParseTimeout(strings.TrimSpace(string(b)))
+	if err != nil {
+		return err
+	}
+	*t = timeout
+	return nil
+}
+
 func ParseLockToken(s string) (string, error) {
 	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") {
 		return "", fmt.Errorf("webdav: invalid Lock-Token value")
@@ -250,7 +350,8 @@ func HTTPErrorFromError(err error) *HTTPError {
 	if err == nil {
 		return nil
 	}
-	if httpErr, ok := err.(*HTTPError); ok {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
 		return httpErr
 	} else {
 		return &HTTPError{http.StatusInternalServerError, err}
@@ -281,3 +382,10 @@ func (err *HTTPError) Error() string {
 func (err *HTTPError) Unwrap() error {
 	return err.Err
 }
+
+// Is reports whether target is an HTTPError with the same status code and
+// without a cause, such as the sentinel errors of the webdav package.
+func (err *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Err == nil && t.Code == err.Code
+}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
@@ -97,3 +100,128 @@ func TestParseConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		s     string
+		depth Depth
+		ok    bool
+	}{
+		{"0", DepthZero, true},
+		{"1", DepthOne, true},
+		{"infinity", DepthInfinity, true},
+		{"Infinity", DepthInfinity, true},
+		{"INFINITY", DepthInfinity, true},
+		{" 1\t", DepthOne, true},
+		{"", 0, false},
+		{"2", 0, false},
+		{"-1", 0, false},
+		{"01", 0, false},
+		{"inf", 0, false},
+	}
+
+	for _, tc := range tests {
+		depth, err := ParseDepth(tc.s)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("ParseDepth(%q) = %v, expected an error", tc.s, depth)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDepth(%q) = %v", tc.s, err)
+		} else if depth != tc.depth {
+			t.Errorf("ParseDepth(%q) = %v, expected %v", tc.s, depth, tc.depth)
+		}
+	}
+}
+
+func TestParseTranslate(t *testing.T) {
+	tests := []struct {
+		s         string
+		translate bool
+		ok        bool
+	}{
+		{"t", true, true},
+		{"T", true, true},
+		{"f", false, true},
+		{"F", false, true},
+		{"", false, false},
+		{"false", false, false},
+	}
+
+	for _, tc := range tests {
+		translate, err := ParseTranslate(tc.s)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("ParseTranslate(%q) = %v, expected an error", tc.s, translate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTranslate(%q) = %v", tc.s, err)
+		} else if translate != tc.translate {
+			t.Errorf("ParseTranslate(%q) = %v, expected %v", tc.s, translate, tc.translate)
+		}
+	}
+}
+
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		s    string
+		host string
+		path string
+		ok   bool
+	}{
+		{"http://example.com/dav/file", "example.com", "/dav/file", true},
+		{"https://example.com:8443/dav/coll/", "example.com:8443", "/dav/coll/", true},
+		{" /dav/file ", "", "/dav/file", true},
+		{"http://example.com/dav/sp%20ace%23", "example.com", "/dav/sp ace#", true},
+		{"http://example.com/dav/100%.txt", "example.com", "/dav/100%.txt", true},
+		{"http://example.com/dav/a/../b//c", "example.com", "/dav/b/c", true},
+		{"/dav/../..", "", "/", true},
+		{"http://example.com", "example.com", "/", true},
+		{"", "", "", false},
+		{"file", "", "", false},
+		{"mailto:user@example.com", "", "", false},
+		{"http://[::1/dav", "", "", false},
+	}
+
+	for _, tc := range tests {
+		u, err := ParseDestination(tc.s)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("ParseDestination(%q) = %v, expected an error", tc.s, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDestination(%q) = %v", tc.s, err)
+		} else if u.Host != tc.host || u.Path != tc.path {
+			t.Errorf("ParseDestination(%q) = host %q, path %q, expected host %q, path %q", tc.s, u.Host, u.Path, tc.host, tc.path)
+		}
+	}
+}
+
+func TestHTTPError_Is(t *testing.T) {
+	notFound := &HTTPError{Code: http.StatusNotFound}
+	tests := []struct {
+		err error
+		is  bool
+	}{
+		{HTTPErrorf(http.StatusNotFound, "stat: no such file"), true},
+		{fmt.Errorf("open: %w", HTTPErrorf(http.StatusNotFound, "no such file")), true},
+		{fmt.Errorf("open: %w", notFound), true},
+		{HTTPErrorf(http.StatusForbidden, "access denied"), false},
+		{errors.New("not found"), false},
+	}
+
+	for _, tc := range tests {
+		if is := errors.Is(tc.err, notFound); is != tc.is {
+			t.Errorf("errors.Is(%q, 404) = %v, expected %v", tc.err, is, tc.is)
+		}
+	}
+	if errors.Is(notFound, HTTPErrorf(http.StatusNotFound, "with cause")) {
+		t.Errorf("errors.Is() matched an HTTPError with a cause")
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/xml"
 	"errors"
@@ -8,12 +9,23 @@ import (
 	"io"
 	"mime"
 	"net/http"
-	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 func ServeError(w http.ResponseWriter, err error) {
+	var startedErr *ResponseStartedError
+	if errors.As(err, &startedErr) {
+		return
+	}
+
+	var msErr *MultiStatusError
+	if errors.As(err, &msErr) {
+		ServeMultiStatus(w, NewMultiStatus(msErr.Responses...))
+		return
+	}
+
 	code := http.StatusInternalServerError
 	var httpErr *HTTPError
 	if errors.As(err, &httpErr) {
@@ -30,12 +42,26 @@ func ServeError(w http.ResponseWriter, err error) {
 	http.Error(w, err.Error(), code)
 }
 
+// MultiStatusError is an error reported to clients as a 207 Multi-Status
+// response, typically when an operation on a collection failed for some of
+// its members only.
+type MultiStatusError struct {
+	Responses []Response
+}
+
+func (err *MultiStatusError) Error() string {
+	return fmt.Sprintf("webdav: operation failed for %v resources", len(err.Responses))
+}
+
 func isContentXML(h http.Header) bool {
 	t, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
 	return t == "application/xml" || t == "text/xml"
 }
 
 func ensureRequestBodyEmpty(r *http.Request) error {
+	if r.Body == nil {
+		return nil
+	}
 	var b [1]byte
 	if _, err := r.Body.Read(b[:]); err != io.EOF {
 		return HTTPErrorf(http.StatusBadRequest, "webdav: unsupported request body")
@@ -43,17 +69,38 @@ func ensureRequestBodyEmpty(r *http.Request) error {
 	return nil
 }
 
+const (
+	// maxXMLRequestSize is the size limit of XML request bodies, replied to
+	// with "413 Request Entity Too Large" beyond it.
+	maxXMLRequestSize = 1 << 20
+	// maxXMLDepth is the nesting depth limit of XML request bodies.
+	maxXMLDepth = 64
+)
+
+// ErrEmptyBody is wrapped by the error returned by DecodeXMLRequest for a
+// request without a body, or with a body made of whitespace only.
+var ErrEmptyBody = errors.New("webdav: empty request body")
+
 func DecodeXMLRequest(r *http.Request, v interface{}) error {
 	if !isContentXML(r.Header) {
 		return HTTPErrorf(http.StatusBadRequest, "webdav: expected application/xml request")
 	}
+	if r.Body == nil {
+		return &HTTPError{http.StatusBadRequest, ErrEmptyBody}
+	}
 
 	// Read the entire body
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, maxXMLRequestSize+1))
 	if err != nil {
 		return &HTTPError{http.StatusBadRequest, err}
 	}
 	r.Body.Close()
+	if len(bodyBytes) > maxXMLRequestSize {
+		return HTTPErrorf(http.StatusRequestEntityTooLarge, "webdav: XML request body exceeds %v bytes", maxXMLRequestSize)
+	}
+	if len(bytes.TrimSpace(bodyBytes)) == 0 {
+		return &HTTPError{http.StatusBadRequest, ErrEmptyBody}
+	}
 
 	// Check for empty namespace declarations in the raw XML
 	// This is a more reliable way to detect xmlns:prefix="" declarations
@@ -108,12 +155,44 @@ func DecodeXMLRequest(r *http.Request, v interface{}) error {
 	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
 	// Decode the XML
-	if err := xml.NewDecoder(r.Body).Decode(v); err != nil {
+	tr := &requestTokenReader{d: xml.NewDecoder(r.Body)}
+	if err := xml.NewTokenDecoder(tr).Decode(v); err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return err
+		}
 		return &HTTPError{http.StatusBadRequest, err}
 	}
 	return nil
 }
 
+// requestTokenReader reads the tokens of an XML request body, rejecting
+// document type declarations, which could declare external or recursively
+// expanded entities, and elements nested beyond maxXMLDepth.
+type requestTokenReader struct {
+	d     *xml.Decoder
+	depth int
+}
+
+func (tr *requestTokenReader) Token() (xml.Token, error) {
+	tok, err := tr.d.Token()
+	if err != nil {
+		return tok, err
+	}
+	switch tok.(type) {
+	case xml.Directive:
+		return nil, HTTPErrorf(http.StatusBadRequest, "webdav: DTDs are not allowed in XML requests")
+	case xml.StartElement:
+		tr.depth++
+		if tr.depth > maxXMLDepth {
+			return nil, HTTPErrorf(http.StatusBadRequest, "webdav: XML request nested deeper than %v elements", maxXMLDepth)
+		}
+	case xml.EndElement:
+		tr.depth--
+	}
+	return tok, nil
+}
+
 func IsRequestBodyEmpty(r *http.Request) bool {
 	_, err := r.Body.Read(nil)
 	return err == io.EOF
@@ -125,6 +204,90 @@ func ServeXML(w http.ResponseWriter) *xml.Encoder {
 	return xml.NewEncoder(w)
 }
 
+// MultiStatusWriter streams a multistatus response, so that responses with
+// many members don't have to be held in memory. The status line is sent with
+// the first response.
+type MultiStatusWriter struct {
+	w       http.ResponseWriter
+	bw      *bufio.Writer
+	enc     *xml.Encoder
+	started bool
+}
+
+// multiStatusBufferPool holds the buffers of MultiStatusWriters. xml.Encoder
+// reuses a *bufio.Writer of the default size instead of allocating its own.
+var multiStatusBufferPool = sync.Pool{
+	New: func() any {
+		return bufio.NewWriter(nil)
+	},
+}
+
+func NewMultiStatusWriter(w http.ResponseWriter) *MultiStatusWriter {
+	return &MultiStatusWriter{w: w}
+}
+
+func (mw *MultiStatusWriter) start() error {
+	mw.started = true
+	mw.w.Header().Add("Content-Type", "application/xml; charset=\"utf-8\"")
+	mw.w.WriteHeader(http.StatusMultiStatus)
+	if _, err := mw.w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	mw.bw = multiStatusBufferPool.Get().(*bufio.Writer)
+	mw.bw.Reset(mw.w)
+	mw.enc = xml.NewEncoder(mw.bw)
+	return mw.enc.EncodeToken(xml.StartElement{Name: xml.Name{Space: "DAV:", Local: "multistatus"}})
+}
+
+// WriteResponse writes a response of the multistatus.
+func (mw *MultiStatusWriter) WriteResponse(resp *Response) error {
+	if !mw.started {
+		if err := mw.start(); err != nil {
+			return err
+		}
+	}
+	return mw.enc.Encode(resp)
+}
+
+// Started reports whether the status line has been sent. Errors can't be
+// replied to with a status code anymore.
+func (mw *MultiStatusWriter) Started() bool {
+	return mw.started
+}
+
+// Close ends the multistatus.
+func (mw *MultiStatusWriter) Close() error {
+	if !mw.started {
+		if err := mw.start(); err != nil {
+			return err
+		}
+	}
+	if err := mw.enc.EncodeToken(xml.EndElement{Name: xml.Name{Space: "DAV:", Local: "multistatus"}}); err != nil {
+		return err
+	}
+	if err := mw.enc.Flush(); err != nil {
+		return err
+	}
+	mw.bw.Reset(nil)
+	multiStatusBufferPool.Put(mw.bw)
+	mw.bw, mw.enc = nil, nil
+	return nil
+}
+
+// ResponseStartedError is an error which occurred after the response status
+// was sent. It can't be reported to the client anymore.
+type ResponseStartedError struct {
+	Err error
+}
+
+func (err *ResponseStartedError) Error() string {
+	return fmt.Sprintf("webdav: failed after the response was started: %v", err.Err)
+}
+
+func (err *ResponseStartedError) Unwrap() error {
+	return err.Err
+}
+
 func ServeMultiStatus(w http.ResponseWriter, ms *MultiStatus) error {
 	// TODO: streaming
 	w.WriteHeader(http.StatusMultiStatus)
@@ -133,10 +296,15 @@ func ServeMultiStatus(w http.ResponseWriter, ms *MultiStatus) error {
 
 type Backend interface {
 	Options(r *http.Request) (caps []string, allow []string, err error)
+	CheckPreconditions(r *http.Request) error
 	HeadGet(w http.ResponseWriter, r *http.Request) error
-	PropFind(r *http.Request, pf *PropFind, depth Depth) (*MultiStatus, error)
+	// PropFind calls emit with each response of a PROPFIND request, as the
+	// resources are walked.
+	PropFind(r *http.Request, pf *PropFind, depth Depth, emit func(*Response) error) error
 	PropPatch(r *http.Request, pu *PropertyUpdate) (*Response, error)
+	Report(w http.ResponseWriter, r *http.Request) error
 	Put(w http.ResponseWriter, r *http.Request) error
+	Post(w http.ResponseWriter, r *http.Request) error
 	Delete(r *http.Request) error
 	Mkcol(r *http.Request) error
 	Copy(r *http.Request, dest *Href, recursive, overwrite bool) (created bool, err error)
@@ -153,22 +321,31 @@ type Lock struct {
 
 type Handler struct {
 	Backend Backend
+	// OnError, if set, is called with the errors replied to clients.
+	OnError func(err error)
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var err error
 	if h.Backend == nil {
 		err = fmt.Errorf("webdav: no backend available")
-	} else {
+	} else if err = h.checkContinue(r); err == nil {
 		switch r.Method {
 		case http.MethodOptions:
 			err = h.handleOptions(w, r)
 		case http.MethodGet, http.MethodHead:
+			if s := r.Header.Get("Translate"); s != "" {
+				if _, err = ParseTranslate(s); err != nil {
+					err = &HTTPError{http.StatusBadRequest, err}
+					break
+				}
+			}
 			err = h.Backend.HeadGet(w, r)
 		case http.MethodPut:
 			err = h.Backend.Put(w, r)
+		case http.MethodPost:
+			err = h.Backend.Post(w, r)
 		case http.MethodDelete:
-			// TODO: send a multistatus in case of partial failure
 			err = h.Backend.Delete(r)
 			if err == nil {
 				w.WriteHeader(http.StatusNoContent)
@@ -177,6 +354,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			err = h.handlePropfind(w, r)
 		case "PROPPATCH":
 			err = h.handleProppatch(w, r)
+		case "REPORT":
+			err = h.Backend.Report(w, r)
 		case "MKCOL":
 			err = h.Backend.Mkcol(r)
 			if err == nil {
@@ -194,53 +373,94 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
+		if h.OnError != nil {
+			h.OnError(err)
+		}
+		if h.Backend != nil && HTTPErrorFromError(err).Code == http.StatusMethodNotAllowed {
+			// RFC 9110 section 15.5.6: a 405 response must list the methods
+			// supported by the target resource
+			if _, allow, optionsErr := h.Backend.Options(r); optionsErr == nil {
+				w.Header().Set("Allow", strings.Join(allow, ", "))
+			}
+		}
 		ServeError(w, err)
 	}
 }
 
+// checkContinue evaluates the preconditions of requests sent with "Expect:
+// 100-continue" before their body is read, so that clients don't upload data
+// only to have it rejected afterwards.
+func (h *Handler) checkContinue(r *http.Request) error {
+	if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		return nil
+	}
+	return h.Backend.CheckPreconditions(r)
+}
+
 func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) error {
 	caps, allow, err := h.Backend.Options(r)
 	if err != nil {
 		return err
 	}
-	caps = append([]string{"1", "3"}, caps...)
+	caps = append([]string{"1"}, caps...)
 
 	w.Header().Add("DAV", strings.Join(caps, ", "))
 	w.Header().Add("Allow", strings.Join(allow, ", "))
+	// Microsoft Office and the Windows Mini-Redirector look for this field
+	// before treating the server as WebDAV-capable
+	w.Header().Set("MS-Author-Via", "DAV")
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
-func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) error {
-	var propfind PropFind
-	if isContentXML(r.Header) {
-		if err := DecodeXMLRequest(r, &propfind); err != nil {
-			return err
-		}
-	} else {
+// DecodePropFind decodes the body of a PROPFIND request. A request without a
+// body is an allprop request, as specified in RFC 4918 section 9.1, even if
+// it has an XML Content-Type.
+func DecodePropFind(r *http.Request) (*PropFind, error) {
+	if !isContentXML(r.Header) {
 		if err := ensureRequestBodyEmpty(r); err != nil {
-			return err
+			return nil, err
 		}
+		return &PropFind{AllProp: &struct{}{}}, nil
+	}
+
+	var propfind PropFind
+	if err := DecodeXMLRequest(r, &propfind); errors.Is(err, ErrEmptyBody) {
 		propfind.AllProp = &struct{}{}
+	} else if err != nil {
+		return nil, err
+	}
+	return &propfind, nil
+}
+
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) error {
+	propfind, err := DecodePropFind(r)
+	if err != nil {
+		return err
 	}
 
 	depth := DepthInfinity
 	if s := r.Header.Get("Depth"); s != "" {
-		var err error
 		depth, err = ParseDepth(s)
 		if err != nil {
 			return &HTTPError{http.StatusBadRequest, err}
 		}
 	}
 
-	ms, err := h.Backend.PropFind(r, &propfind, depth)
-	if err != nil {
+	mw := NewMultiStatusWriter(w)
+	if err := h.Backend.PropFind(r, propfind, depth, mw.WriteResponse); err != nil {
+		if mw.Started() {
+			// Leave the multistatus unterminated, so that the client
+			// notices the failure
+			return &ResponseStartedError{Err: err}
+		}
 		return err
 	}
-
-	return ServeMultiStatus(w, ms)
+	return mw.Close()
 }
 
+// PropFindFunc computes the value of a property. raw is the requested
+// property element, or nil for allprop requests.
 type PropFindFunc func(raw *RawXMLValue) (interface{}, error)
 
 func PropFindValue(value interface{}) PropFindFunc {
@@ -249,63 +469,67 @@ func PropFindValue(value interface{}) PropFindFunc {
 	}
 }
 
+// emptyResourceType is the resource type of resources which aren't
+// collections. It's shared by responses, which only read it.
+var emptyResourceType = PropFindValue(NewResourceType())
+
 func NewPropFindResponse(path string, propfind *PropFind, props map[xml.Name]PropFindFunc) (*Response, error) {
 	resp := &Response{Hrefs: []Href{Href{Path: path}}}
 
 	if _, ok := props[ResourceTypeName]; !ok {
-		props[ResourceTypeName] = PropFindValue(NewResourceType())
+		props[ResourceTypeName] = emptyResourceType
+	}
+
+	// Most properties are found, size their propstat upfront
+	n := len(props)
+	if propfind.Prop != nil {
+		n = len(propfind.Prop.Raw)
+	}
+	resp.PropStats = make([]PropStat, 1, 2)
+	resp.PropStats[0] = PropStat{
+		Status: Status{Code: http.StatusOK},
+		Prop:   Prop{Raw: make([]RawXMLValue, 0, n)},
 	}
 
 	if propfind.PropName != nil {
 		for xmlName, _ := range props {
-			emptyVal := NewRawXMLElement(xmlName, nil, nil)
-			if err := resp.EncodeProp(http.StatusOK, emptyVal); err != nil {
-				return nil, err
-			}
+			resp.appendProp(http.StatusOK, *NewRawXMLElement(xmlName, nil, nil))
 		}
 	} else if propfind.AllProp != nil {
 		// TODO: add support for propfind.Include
 		for xmlName, f := range props {
-			emptyVal := NewRawXMLElement(xmlName, nil, nil)
-
-			val, err := f(emptyVal)
-
-			code := http.StatusOK
+			val, err := f(nil)
 			if err != nil {
 				// TODO: don't throw away error message here
-				code = HTTPErrorFromError(err).Code
-				val = emptyVal
+				resp.appendProp(HTTPErrorFromError(err).Code, *NewRawXMLElement(xmlName, nil, nil))
+				continue
 			}
 
-			if err := resp.EncodeProp(code, val); err != nil {
+			if err := resp.EncodeProp(http.StatusOK, val); err != nil {
 				return nil, err
 			}
 		}
 	} else if prop := propfind.Prop; prop != nil {
-		for _, raw := range prop.Raw {
+		for i := range prop.Raw {
+			raw := &prop.Raw[i]
 			xmlName, ok := raw.XMLName()
 			if !ok {
 				continue
 			}
 
-			emptyVal := NewRawXMLElement(xmlName, nil, nil)
-
-			var code int
-			var val interface{} = emptyVal
 			f, ok := props[xmlName]
-			if ok {
-				if v, err := f(&raw); err != nil {
-					// TODO: don't throw away error message here
-					code = HTTPErrorFromError(err).Code
-				} else {
-					code = http.StatusOK
-					val = v
-				}
-			} else {
-				code = http.StatusNotFound
+			if !ok {
+				resp.appendProp(http.StatusNotFound, *NewRawXMLElement(xmlName, nil, nil))
+				continue
+			}
+			val, err := f(raw)
+			if err != nil {
+				// TODO: don't throw away error message here
+				resp.appendProp(HTTPErrorFromError(err).Code, *NewRawXMLElement(xmlName, nil, nil))
+				continue
 			}
 
-			if err := resp.EncodeProp(code, val); err != nil {
+			if err := resp.EncodeProp(http.StatusOK, val); err != nil {
 				return nil, err
 			}
 		}
@@ -313,6 +537,9 @@ func NewPropFindResponse(path string, propfind *PropFind, props map[xml.Name]Pro
 		return nil, HTTPErrorf(http.StatusBadRequest, "webdav: request missing propname, allprop or prop element")
 	}
 
+	if len(resp.PropStats[0].Prop.Raw) == 0 {
+		resp.PropStats = resp.PropStats[1:]
+	}
 	return resp, nil
 }
 
@@ -331,20 +558,20 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) error
 	return ServeMultiStatus(w, ms)
 }
 
-func parseDestination(h http.Header) (*Href, error) {
-	destHref := h.Get("Destination")
-	if destHref == "" {
-		return nil, HTTPErrorf(http.StatusBadRequest, "webdav: missing Destination header in MOVE request")
+func parseDestination(r *http.Request) (*Href, error) {
+	s := r.Header.Get("Destination")
+	if s == "" {
+		return nil, HTTPErrorf(http.StatusBadRequest, "webdav: missing Destination header in %v request", r.Method)
 	}
-	dest, err := url.Parse(destHref)
+	dest, err := ParseDestination(s)
 	if err != nil {
-		return nil, HTTPErrorf(http.StatusBadRequest, "webdav: marlformed Destination header in MOVE request: %v", err)
+		return nil, &HTTPError{http.StatusBadRequest, err}
 	}
 	return (*Href)(dest), nil
 }
 
 func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) error {
-	dest, err := parseDestination(r.Header)
+	dest, err := parseDestination(r)
 	if err != nil {
 		return err
 	}
@@ -405,6 +632,12 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) error {
 		if err := DecodeXMLRequest(r, &lockInfo); err != nil {
 			return err
 		}
+		if lockInfo.LockScope.Exclusive == nil || lockInfo.LockScope.Shared != nil {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: only exclusive locks are supported")
+		}
+		if lockInfo.LockType.Write == nil {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: only write locks are supported")
+		}
 	} else {
 		if err := ensureRequestBodyEmpty(r); err != nil {
 			return err
@@ -419,13 +652,6 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) error {
 		refreshToken = conditions[0][0].Token
 	}
 
-	if lockInfo.LockScope.Exclusive == nil || lockInfo.LockScope.Shared != nil {
-		return HTTPErrorf(http.StatusBadRequest, "webdav: only exclusive locks are supported")
-	}
-	if lockInfo.LockType.Write == nil {
-		return HTTPErrorf(http.StatusBadRequest, "webdav: only write locks are supported")
-	}
-
 	depth := DepthInfinity
 	if s := r.Header.Get("Depth"); s != "" {
 		var err error
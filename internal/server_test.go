@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodePropFind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		allProp     bool
+		ok          bool
+	}{
+		{"no body", "", "", true, true},
+		{"empty XML body", "application/xml", "", true, true},
+		{"blank XML body", "text/xml; charset=utf-8", " \r\n", true, true},
+		{"allprop", "application/xml", `<propfind xmlns="DAV:"><allprop/></propfind>`, true, true},
+		{"prop", "application/xml", `<propfind xmlns="DAV:"><prop><getetag/></prop></propfind>`, false, true},
+		{"non-XML body", "text/plain", "allprop", false, false},
+		{"malformed XML body", "application/xml", "<propfind", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			r := httptest.NewRequest("PROPFIND", "/", body)
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+
+			propfind, err := DecodePropFind(r)
+			if !tc.ok {
+				if err == nil {
+					t.Errorf("DecodePropFind() = %+v, expected an error", propfind)
+				} else if code := HTTPErrorFromError(err).Code; code != http.StatusBadRequest {
+					t.Errorf("DecodePropFind() = %v, expected a %v error", err, http.StatusBadRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodePropFind() = %v", err)
+			}
+			if got := propfind.AllProp != nil; got != tc.allProp {
+				t.Errorf("DecodePropFind().AllProp set = %v, expected %v", got, tc.allProp)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLRequest_limits(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"valid", `<propfind xmlns="DAV:"><prop><getetag/></prop></propfind>`, 0},
+		{"external entity", `<?xml version="1.0"?><!DOCTYPE propfind [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><propfind xmlns="DAV:"><prop>&xxe;</prop></propfind>`, http.StatusBadRequest},
+		{"entity expansion", `<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;">]><propfind xmlns="DAV:">&lol2;</propfind>`, http.StatusBadRequest},
+		{"undeclared entity", `<propfind xmlns="DAV:">&lol;</propfind>`, http.StatusBadRequest},
+		{"nesting", `<propfind xmlns="DAV:"><prop>` + strings.Repeat("<a>", maxXMLDepth) + strings.Repeat("</a>", maxXMLDepth) + `</prop></propfind>`, http.StatusBadRequest},
+		{"size", `<propfind xmlns="DAV:"><prop>` + strings.Repeat(" ", maxXMLRequestSize) + `</prop></propfind>`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("PROPFIND", "/", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", "application/xml")
+
+			var propfind PropFind
+			err := DecodeXMLRequest(r, &propfind)
+			if tc.code == 0 {
+				if err != nil {
+					t.Errorf("DecodeXMLRequest() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("DecodeXMLRequest() = nil, expected a %v error", tc.code)
+			} else if code := HTTPErrorFromError(err).Code; code != tc.code {
+				t.Errorf("DecodeXMLRequest() = %v, expected a %v error", err, tc.code)
+			}
+		})
+	}
+}
+
+func TestNewPropFindResponse(t *testing.T) {
+	props := func() map[xml.Name]PropFindFunc {
+		return map[xml.Name]PropFindFunc{
+			DisplayNameName: func(*RawXMLValue) (interface{}, error) {
+				return &DisplayName{Name: "file"}, nil
+			},
+			GetETagName: func(*RawXMLValue) (interface{}, error) {
+				return nil, HTTPErrorf(http.StatusForbidden, "webdav: no etag")
+			},
+		}
+	}
+	// Props are encoded lazily, decode the response to inspect them
+	decode := func(t *testing.T, resp *Response) *Response {
+		b, err := xml.Marshal(resp)
+		if err != nil {
+			t.Fatalf("xml.Marshal() = %v", err)
+		}
+		var decoded Response
+		if err := xml.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("xml.Unmarshal() = %v", err)
+		}
+		return &decoded
+	}
+	propStat := func(resp *Response, code int) *PropStat {
+		for i := range resp.PropStats {
+			if resp.PropStats[i].Status.Code == code {
+				return &resp.PropStats[i]
+			}
+		}
+		return nil
+	}
+	names := func(ps *PropStat) map[xml.Name]bool {
+		m := make(map[xml.Name]bool)
+		for i := range ps.Prop.Raw {
+			if name, ok := ps.Prop.Raw[i].XMLName(); ok {
+				m[name] = true
+			}
+		}
+		return m
+	}
+
+	t.Run("prop", func(t *testing.T) {
+		propfind := NewPropNamePropFind(DisplayNameName, GetETagName, GetContentLengthName)
+		resp, err := NewPropFindResponse("/file", propfind, props())
+		if err != nil {
+			t.Fatalf("NewPropFindResponse() = %v", err)
+		}
+		resp = decode(t, resp)
+		if len(resp.PropStats) != 3 {
+			t.Fatalf("got %v propstats, want 3", len(resp.PropStats))
+		}
+		for code, name := range map[int]xml.Name{
+			http.StatusOK:        DisplayNameName,
+			http.StatusForbidden: GetETagName,
+			http.StatusNotFound:  GetContentLengthName,
+		} {
+			ps := propStat(resp, code)
+			if ps == nil {
+				t.Errorf("missing %v propstat", code)
+			} else if got := names(ps); len(got) != 1 || !got[name] {
+				t.Errorf("%v propstat = %v, want %v", code, got, name)
+			}
+		}
+
+		var display DisplayName
+		if err := resp.DecodeProp(&display); err != nil {
+			t.Errorf("DecodeProp() = %v", err)
+		} else if display.Name != "file" {
+			t.Errorf("DecodeProp() = %q, want %q", display.Name, "file")
+		}
+	})
+
+	t.Run("prop all missing", func(t *testing.T) {
+		propfind := NewPropNamePropFind(GetContentLengthName)
+		resp, err := NewPropFindResponse("/file", propfind, props())
+		if err != nil {
+			t.Fatalf("NewPropFindResponse() = %v", err)
+		}
+		if len(resp.PropStats) != 1 || resp.PropStats[0].Status.Code != http.StatusNotFound {
+			t.Errorf("propstats = %+v, want a single 404 propstat", resp.PropStats)
+		}
+	})
+
+	t.Run("propname", func(t *testing.T) {
+		resp, err := NewPropFindResponse("/file", &PropFind{PropName: &struct{}{}}, props())
+		if err != nil {
+			t.Fatalf("NewPropFindResponse() = %v", err)
+		}
+		resp = decode(t, resp)
+		if len(resp.PropStats) != 1 {
+			t.Fatalf("got %v propstats, want 1", len(resp.PropStats))
+		}
+		got := names(&resp.PropStats[0])
+		for _, name := range []xml.Name{DisplayNameName, GetETagName, ResourceTypeName} {
+			if !got[name] {
+				t.Errorf("propname response is missing %v", name)
+			}
+		}
+	})
+
+	t.Run("allprop", func(t *testing.T) {
+		resp, err := NewPropFindResponse("/file", &PropFind{AllProp: &struct{}{}}, props())
+		if err != nil {
+			t.Fatalf("NewPropFindResponse() = %v", err)
+		}
+		resp = decode(t, resp)
+		if ps := propStat(resp, http.StatusOK); ps == nil || !names(ps)[DisplayNameName] || !names(ps)[ResourceTypeName] {
+			t.Errorf("200 propstat = %+v, want displayname and resourcetype", ps)
+		}
+		if ps := propStat(resp, http.StatusForbidden); ps == nil || !names(ps)[GetETagName] {
+			t.Errorf("403 propstat = %+v, want getetag", ps)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewPropFindResponse("/file", &PropFind{}, props())
+		if code := HTTPErrorFromError(err).Code; code != http.StatusBadRequest {
+			t.Errorf("NewPropFindResponse() = %v, want a 400 error", err)
+		}
+	})
+}
+
+func TestMultiStatusWriter(t *testing.T) {
+	// Run twice so the second writer reuses a pooled buffer
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		mw := NewMultiStatusWriter(rec)
+		resp := NewOKResponse("/file")
+		if err := resp.EncodeProp(http.StatusOK, &DisplayName{Name: "file"}); err != nil {
+			t.Fatalf("EncodeProp() = %v", err)
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			t.Fatalf("WriteResponse() = %v", err)
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+
+		if rec.Code != http.StatusMultiStatus {
+			t.Errorf("status = %v, want %v", rec.Code, http.StatusMultiStatus)
+		}
+		var ms MultiStatus
+		if err := xml.Unmarshal(rec.Body.Bytes(), &ms); err != nil {
+			t.Fatalf("xml.Unmarshal() = %v", err)
+		}
+		if len(ms.Responses) != 1 || ms.Responses[0].Hrefs[0].Path != "/file" {
+			t.Fatalf("responses = %+v, want one for /file", ms.Responses)
+		}
+		var display DisplayName
+		if err := ms.Responses[0].DecodeProp(&display); err != nil || display.Name != "file" {
+			t.Errorf("DecodeProp() = %q, %v, want %q", display.Name, err, "file")
+		}
+	}
+
+	// Props are encoded lazily, so invalid values fail when written
+	rec := httptest.NewRecorder()
+	mw := NewMultiStatusWriter(rec)
+	resp := NewOKResponse("/file")
+	if err := resp.EncodeProp(http.StatusOK, make(chan int)); err != nil {
+		t.Fatalf("EncodeProp() = %v", err)
+	}
+	if err := mw.WriteResponse(resp); err == nil {
+		t.Errorf("WriteResponse() = nil, expected an error for an unencodable prop")
+	}
+	if !mw.Started() {
+		t.Errorf("Started() = false after WriteResponse()")
+	}
+}
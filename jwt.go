@@ -0,0 +1,398 @@
+package webdav
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTValidator is a TokenValidator for JSON Web Tokens (RFC 7519) signed with
+// HMAC, RSA, ECDSA or Ed25519 keys.
+type JWTValidator struct {
+	// Keys are the keys used to verify signatures, indexed by key ID. Values
+	// are []byte for HMAC keys, *rsa.PublicKey, *ecdsa.PublicKey or
+	// ed25519.PublicKey. A single key is used for tokens without key ID.
+	Keys map[string]interface{}
+	// JWKSURL is the URL of a JSON Web Key Set (RFC 7517) providing the keys,
+	// e.g. published by an identity provider. The set is refreshed when a
+	// token refers to an unknown key.
+	JWKSURL string
+	// HTTPClient is used to fetch the key set, defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Issuer and Audience, if set, must match the "iss" and "aud" claims.
+	Issuer   string
+	Audience string
+	// UserClaim is the claim holding the user name, defaults to "sub".
+	UserClaim string
+	// Leeway is the clock skew tolerated when checking validity times.
+	Leeway time.Duration
+
+	mu        sync.Mutex
+	jwks      map[string]interface{}
+	jwksFetch time.Time
+	// jwksDone is closed when the key set being fetched, if any, is stored
+	jwksDone chan struct{}
+}
+
+var _ TokenValidator = (*JWTValidator)(nil)
+
+// jwksMinRefreshInterval limits how often unknown key IDs trigger a key set
+// refresh.
+const jwksMinRefreshInterval = time.Minute
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// ValidateToken implements TokenValidator.
+func (v *JWTValidator) ValidateToken(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("webdav: malformed JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("webdav: malformed JWT signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return "", err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return "", err
+	}
+
+	claim := v.UserClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	user, _ := claims[claim].(string)
+	if user == "" {
+		return "", fmt.Errorf("webdav: JWT is missing the %q claim", claim)
+	}
+	return user, nil
+}
+
+func decodeJWTPart(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("webdav: malformed JWT: %w", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("webdav: malformed JWT: %w", err)
+	}
+	return nil
+}
+
+func (v *JWTValidator) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.Leeway)) {
+		return errors.New("webdav: JWT has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("webdav: JWT is not valid yet")
+	}
+	if v.Issuer != "" && claims["iss"] != v.Issuer {
+		return errors.New("webdav: JWT issuer mismatch")
+	}
+	if v.Audience != "" {
+		var found bool
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == v.Audience
+		case []interface{}:
+			for _, a := range aud {
+				found = found || a == v.Audience
+			}
+		}
+		if !found {
+			return errors.New("webdav: JWT audience mismatch")
+		}
+	}
+	return nil
+}
+
+// key returns the key with the given ID, fetching the key set if necessary.
+func (v *JWTValidator) key(ctx context.Context, kid string) (interface{}, error) {
+	if key, ok := lookupJWTKey(v.Keys, kid); ok {
+		return key, nil
+	}
+	if v.JWKSURL == "" {
+		return nil, fmt.Errorf("webdav: unknown JWT key %q", kid)
+	}
+
+	v.mu.Lock()
+	if key, ok := lookupJWTKey(v.jwks, kid); ok {
+		v.mu.Unlock()
+		return key, nil
+	}
+	if done := v.jwksDone; done != nil {
+		// Wait for the key set being fetched by another request
+		v.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		v.mu.Lock()
+	} else if time.Since(v.jwksFetch) >= jwksMinRefreshInterval {
+		// Fetch the key set without blocking requests with known keys
+		v.jwksFetch = time.Now()
+		done := make(chan struct{})
+		v.jwksDone = done
+		v.mu.Unlock()
+
+		keys, err := v.fetchJWKS(ctx)
+
+		v.mu.Lock()
+		if err == nil {
+			v.jwks = keys
+		}
+		v.jwksDone = nil
+		close(done)
+		if err != nil {
+			v.mu.Unlock()
+			return nil, err
+		}
+	}
+	key, ok := lookupJWTKey(v.jwks, kid)
+	v.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("webdav: unknown JWT key %q", kid)
+	}
+	return key, nil
+}
+
+func lookupJWTKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// jwk is a JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (v *JWTValidator) fetchJWKS(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webdav: failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webdav: failed to fetch JWKS: HTTP %v", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("webdav: malformed JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys of unsupported types
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("webdav: unsupported JWK curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("webdav: unsupported JWK curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("webdav: invalid Ed25519 JWK")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decode(k.K)
+	}
+	return nil, fmt.Errorf("webdav: unsupported JWK type %q", k.Kty)
+}
+
+// jwtCurves are the curves of the keys of the ECDSA algorithms.
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifyJWTSignature checks the signature of a JWT. The key type, and the
+// curve of ECDSA keys, must match the algorithm, which prevents algorithm
+// confusion attacks.
+func verifyJWTSignature(alg string, key interface{}, signed string, sig []byte) error {
+	errInvalid := errors.New("webdav: invalid JWT signature")
+
+	var hashFunc crypto.Hash
+	var newHash func() hash.Hash
+	switch alg {
+	case "HS256", "RS256", "PS256", "ES256":
+		hashFunc, newHash = crypto.SHA256, sha256.New
+	case "HS384", "RS384", "PS384", "ES384":
+		hashFunc, newHash = crypto.SHA384, sha512.New384
+	case "HS512", "RS512", "PS512", "ES512":
+		hashFunc, newHash = crypto.SHA512, sha512.New
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS") && newHash != nil:
+		secret, ok := key.([]byte)
+		if !ok {
+			break
+		}
+		mac := hmac.New(newHash, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errInvalid
+		}
+		return nil
+	case (strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")) && newHash != nil:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		h := newHash()
+		h.Write([]byte(signed))
+		var err error
+		if strings.HasPrefix(alg, "RS") {
+			err = rsa.VerifyPKCS1v15(pub, hashFunc, h.Sum(nil), sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hashFunc, h.Sum(nil), sig, nil)
+		}
+		if err != nil {
+			return errInvalid
+		}
+		return nil
+	case strings.HasPrefix(alg, "ES") && newHash != nil:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != jwtCurves[alg] {
+			break
+		}
+		// The signature is the concatenation of r and s, each the size of
+		// the curve order
+		if size := (pub.Curve.Params().BitSize + 7) / 8; len(sig) != 2*size {
+			return errInvalid
+		}
+		h := newHash()
+		h.Write([]byte(signed))
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(pub, h.Sum(nil), r, s) {
+			return errInvalid
+		}
+		return nil
+	case alg == "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			break
+		}
+		if !ed25519.Verify(pub, []byte(signed), sig) {
+			return errInvalid
+		}
+		return nil
+	}
+	return fmt.Errorf("webdav: unsupported JWT algorithm %q for key", alg)
+}
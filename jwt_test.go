@@ -0,0 +1,252 @@
+package webdav_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+type jwtTestKeys struct {
+	secret []byte
+	rsa    *rsa.PrivateKey
+	p256   *ecdsa.PrivateKey
+	p384   *ecdsa.PrivateKey
+	ed     ed25519.PrivateKey
+}
+
+func newJWTTestKeys(t *testing.T) *jwtTestKeys {
+	t.Helper()
+	keys := &jwtTestKeys{secret: []byte("0123456789abcdef0123456789abcdef")}
+	var err error
+	if keys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if keys.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if keys.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, keys.ed, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func jwtHash(alg string) (crypto.Hash, func() hash.Hash) {
+	switch alg[len(alg)-3:] {
+	case "384":
+		return crypto.SHA384, sha512.New384
+	case "512":
+		return crypto.SHA512, sha512.New
+	}
+	return crypto.SHA256, sha256.New
+}
+
+// signJWT returns a token with the header alg and kid, signed with key using
+// the algorithm sigAlg.
+func signJWT(t *testing.T, alg, sigAlg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+
+	hashFunc, newHash := jwtHash(sigAlg)
+	h := newHash()
+	h.Write([]byte(signed))
+	var sig []byte
+	var err error
+	switch sigAlg[:2] {
+	case "HS":
+		mac := hmac.New(newHash, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), hashFunc, h.Sum(nil))
+	case "PS":
+		sig, err = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), hashFunc, h.Sum(nil), nil)
+	case "ES":
+		priv := key.(*ecdsa.PrivateKey)
+		r, s, signErr := ecdsa.Sign(rand.Reader, priv, h.Sum(nil))
+		err = signErr
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	case "Ed":
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signed))
+	case "no":
+	default:
+		t.Fatalf("unknown algorithm %q", sigAlg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTValidator(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	rsaDER, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &webdav.JWTValidator{
+		Keys: map[string]interface{}{
+			"hs":   keys.secret,
+			"rs":   &keys.rsa.PublicKey,
+			"p256": &keys.p256.PublicKey,
+			"p384": &keys.p384.PublicKey,
+			"ed":   keys.ed.Public(),
+		},
+		Issuer:   "https://id.example.com",
+		Audience: "webdav",
+	}
+
+	now := time.Now().Unix()
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://id.example.com",
+			"aud": "webdav",
+			"exp": now + 60,
+		}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"HS256", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(nil)), true},
+		{"HS512", signJWT(t, "HS512", "HS512", "hs", keys.secret, claims(nil)), true},
+		{"RS256", signJWT(t, "RS256", "RS256", "rs", keys.rsa, claims(nil)), true},
+		{"PS384", signJWT(t, "PS384", "PS384", "rs", keys.rsa, claims(nil)), true},
+		{"ES256", signJWT(t, "ES256", "ES256", "p256", keys.p256, claims(nil)), true},
+		{"ES384", signJWT(t, "ES384", "ES384", "p384", keys.p384, claims(nil)), true},
+		{"EdDSA", signJWT(t, "EdDSA", "EdDSA", "ed", keys.ed, claims(nil)), true},
+		{"audience list", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"aud": []string{"other", "webdav"}})), true},
+
+		{"bad signature", signJWT(t, "HS256", "HS256", "hs", []byte("wrong secret"), claims(nil)), false},
+		{"bad RSA signature", signJWT(t, "RS256", "PS256", "rs", keys.rsa, claims(nil)), false},
+		{"none", signJWT(t, "none", "none", "hs", nil, claims(nil)), false},
+		{"HS with RSA key", signJWT(t, "HS256", "HS256", "rs", rsaDER, claims(nil)), false},
+		{"RS with HMAC key", signJWT(t, "RS256", "RS256", "hs", keys.rsa, claims(nil)), false},
+		{"ES384 with P-256 key", signJWT(t, "ES384", "ES384", "p256", keys.p256, claims(nil)), false},
+		{"ES256 with P-384 key", signJWT(t, "ES256", "ES256", "p384", keys.p384, claims(nil)), false},
+		{"unknown algorithm", signJWT(t, "XS256", "HS256", "hs", keys.secret, claims(nil)), false},
+		{"unknown key", signJWT(t, "HS256", "HS256", "other", keys.secret, claims(nil)), false},
+		{"no key ID", signJWT(t, "HS256", "HS256", "", keys.secret, claims(nil)), false},
+		{"expired", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"exp": now - 60})), false},
+		{"not valid yet", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"nbf": now + 60})), false},
+		{"issuer mismatch", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"iss": "https://evil.example.com"})), false},
+		{"missing issuer", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"iss": nil})), false},
+		{"audience mismatch", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"aud": "other"})), false},
+		{"missing user", signJWT(t, "HS256", "HS256", "hs", keys.secret, claims(map[string]interface{}{"sub": nil})), false},
+		{"malformed", "not.a-jwt", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			user, err := v.ValidateToken(t.Context(), tc.token)
+			if tc.valid && (err != nil || user != "alice") {
+				t.Errorf("ValidateToken() = %q, %v, want alice", user, err)
+			} else if !tc.valid && err == nil {
+				t.Errorf("ValidateToken() = %q, want error", user)
+			}
+		})
+	}
+}
+
+func TestJWTValidatorLeeway(t *testing.T) {
+	secret := []byte("secret")
+	v := &webdav.JWTValidator{Keys: map[string]interface{}{"hs": secret}, Leeway: time.Minute}
+	token := signJWT(t, "HS256", "HS256", "hs", secret, map[string]interface{}{
+		"sub": "alice",
+		"exp": time.Now().Add(-30 * time.Second).Unix(),
+	})
+	if user, err := v.ValidateToken(t.Context(), token); err != nil || user != "alice" {
+		t.Errorf("ValidateToken() = %q, %v, want alice", user, err)
+	}
+}
+
+func TestJWTValidatorJWKS(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	enc := base64.RawURLEncoding.EncodeToString
+	pub := keys.p256.PublicKey
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": %q, "e": "AQAB"}
+	]}`, enc(pub.X.FillBytes(make([]byte, 32))), enc(pub.Y.FillBytes(make([]byte, 32))), enc(keys.rsa.N.Bytes()))
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		// Let concurrent requests pile up on the fetch
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(jwks))
+	}))
+	defer srv.Close()
+
+	v := &webdav.JWTValidator{JWKSURL: srv.URL}
+	token := signJWT(t, "ES256", "ES256", "ec", keys.p256, map[string]interface{}{"sub": "alice"})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if user, err := v.ValidateToken(t.Context(), token); err != nil || user != "alice" {
+				t.Errorf("ValidateToken() = %q, %v, want alice", user, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("key set fetched %v times, want 1", n)
+	}
+
+	// Keys which aren't for signatures are ignored, and unknown keys don't
+	// refresh the key set more than once per minute
+	for _, kid := range []string{"enc", "other"} {
+		token := signJWT(t, "RS256", "RS256", kid, keys.rsa, map[string]interface{}{"sub": "alice"})
+		if _, err := v.ValidateToken(t.Context(), token); err == nil {
+			t.Errorf("ValidateToken() with key %q succeeded, want error", kid)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("key set fetched %v times, want 1", n)
+	}
+}
@@ -0,0 +1,153 @@
+package webdav
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits configures per-client request limits. Clients are identified by
+// their authenticated user (see UserFromContext), or by their IP address.
+// Requests exceeding the limits are rejected with "429 Too Many Requests".
+type Limits struct {
+	// RequestsPerSecond is the sustained request rate allowed for each
+	// client. Zero disables rate limiting.
+	RequestsPerSecond float64
+	// Burst is the number of requests a client can send at once, defaults to
+	// RequestsPerSecond.
+	Burst int
+	// MaxTransfers is the number of simultaneous uploads and downloads (GET,
+	// PUT and POST requests) allowed for each client. Zero means no limit.
+	MaxTransfers int
+}
+
+// transferMethods are the methods counted by Limits.MaxTransfers.
+var transferMethods = map[string]bool{
+	http.MethodGet:  true,
+	http.MethodPut:  true,
+	http.MethodPost: true,
+}
+
+// limiterSweepInterval is the interval at which idle clients are forgotten.
+const limiterSweepInterval = time.Minute
+
+// limiter enforces Limits.
+type limiter struct {
+	limits Limits
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimit
+	lastSweep time.Time
+}
+
+// clientLimit is the state of a client: a token bucket for the request rate
+// and the number of ongoing transfers.
+type clientLimit struct {
+	tokens    float64
+	last      time.Time
+	transfers int
+}
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{
+		limits:    limits,
+		clients:   make(map[string]*clientLimit),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *limiter) burst() float64 {
+	if l.limits.Burst > 0 {
+		return float64(l.limits.Burst)
+	}
+	return math.Max(l.limits.RequestsPerSecond, 1)
+}
+
+// clientKey identifies the client sending a request.
+func clientKey(r *http.Request) string {
+	if user, ok := UserFromContext(r.Context()); ok {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// acquire checks the limits of the client sending a request. If the request
+// is allowed, release must be called once it's been served. Otherwise,
+// retryAfter is the time the client should wait before trying again.
+func (l *limiter) acquire(r *http.Request) (release func(), retryAfter time.Duration, ok bool) {
+	key := clientKey(r)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		l.sweep(now)
+	}
+
+	c := l.clients[key]
+	if c == nil {
+		c = &clientLimit{tokens: l.burst(), last: now}
+		l.clients[key] = c
+	}
+
+	if rps := l.limits.RequestsPerSecond; rps > 0 {
+		c.tokens = math.Min(l.burst(), c.tokens+now.Sub(c.last).Seconds()*rps)
+		c.last = now
+		if c.tokens < 1 {
+			return nil, time.Duration((1 - c.tokens) / rps * float64(time.Second)), false
+		}
+	}
+
+	transfer := transferMethods[r.Method]
+	if transfer && l.limits.MaxTransfers > 0 && c.transfers >= l.limits.MaxTransfers {
+		return nil, time.Second, false
+	}
+
+	if l.limits.RequestsPerSecond > 0 {
+		c.tokens--
+	}
+	if !transfer {
+		return func() {}, 0, true
+	}
+	c.transfers++
+	return func() {
+		l.mu.Lock()
+		c.transfers--
+		l.mu.Unlock()
+	}, 0, true
+}
+
+// sweep forgets the clients which are back to their initial state.
+func (l *limiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for key, c := range l.clients {
+		full := l.limits.RequestsPerSecond <= 0 || c.tokens+now.Sub(c.last).Seconds()*l.limits.RequestsPerSecond >= l.burst()
+		if c.transfers == 0 && full {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// serveTooManyRequests replies to a request exceeding the limits.
+func serveTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	http.Error(w, "webdav: too many requests", http.StatusTooManyRequests)
+}
+
+// retryAfterSeconds formats a delay as the value of a Retry-After header
+// field, rounded up to at least one second.
+func retryAfterSeconds(d time.Duration) string {
+	secs := int(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
@@ -0,0 +1,73 @@
+package webdav_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestLimitsRate(t *testing.T) {
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		Limits:     &webdav.Limits{RequestsPerSecond: 0.1, Burst: 2},
+	}
+	depth0 := map[string]string{"Depth": "0"}
+	for range 2 {
+		checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth0, user: "alice"}, http.StatusMultiStatus)
+	}
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth0, user: "alice"}, http.StatusTooManyRequests)
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	// Clients are limited separately
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth0, user: "bob"}, http.StatusMultiStatus)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth0}, http.StatusMultiStatus)
+}
+
+// startedReader signals the first read of a request body.
+type startedReader struct {
+	io.Reader
+	started chan struct{}
+}
+
+func (r *startedReader) Read(b []byte) (int, error) {
+	if r.started != nil {
+		close(r.started)
+		r.started = nil
+	}
+	return r.Reader.Read(b)
+}
+
+func TestLimitsTransfers(t *testing.T) {
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		Limits:     &webdav.Limits{MaxTransfers: 1},
+	}
+
+	// Hold an upload open
+	pr, pw := io.Pipe()
+	started := make(chan struct{})
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/big.bin", &startedReader{pr, started}))
+		done <- w.Code
+	}()
+	<-started
+
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusTooManyRequests)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/big.bin"}, http.StatusTooManyRequests)
+	// Other methods aren't transfers
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	// Other clients have their own transfers
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a", user: "alice"}, http.StatusCreated)
+
+	pw.Close()
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("held PUT = %v, want %v", code, http.StatusCreated)
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated)
+}
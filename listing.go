@@ -0,0 +1,227 @@
+package webdav
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// listingEntry is a child of a collection listed in response to GET.
+type listingEntry struct {
+	Name    string    `json:"name"`
+	Href    string    `json:"href"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// listing is the content of a collection listed in response to GET.
+type listing struct {
+	Path    string         `json:"path"`
+	Parent  string         `json:"parent,omitempty"`
+	Entries []listingEntry `json:"entries"`
+}
+
+var listingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"size": formatSize,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 1em; text-align: left; }
+td.size { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{- if .Parent}}
+<tr><td><a href="{{.Parent}}">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Href}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td class="size">{{if not .IsDir}}{{size .Size}}{{end}}</td><td>{{if not .ModTime.IsZero}}{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// serveListing replies to a GET or HEAD request on a collection with an HTML
+// or JSON listing of its children, depending on the Accept header field.
+func (b *backend) serveListing(w http.ResponseWriter, r *http.Request) error {
+	children, err := b.FileSystem.ReadDir(r.Context(), r.URL.Path, false)
+	if err != nil {
+		return err
+	}
+
+	name := path.Clean(r.URL.Path)
+	l := listing{
+		Path:    b.href(collectionPath(name)),
+		Entries: make([]listingEntry, 0, len(children)),
+	}
+	if name != "/" {
+		l.Parent = escapePath(b.href(collectionPath(path.Dir(name))))
+	}
+	for _, child := range children {
+		if path.Clean(child.Path) == name || !b.readable(r.Context(), child.Path) {
+			continue
+		}
+		href := b.href(child.Path)
+		if child.IsDir {
+			href = collectionPath(href)
+		}
+		l.Entries = append(l.Entries, listingEntry{
+			Name:    path.Base(child.Path),
+			Href:    escapePath(href),
+			IsDir:   child.IsDir,
+			Size:    child.Size,
+			ModTime: child.ModTime,
+		})
+	}
+	sort.Slice(l.Entries, func(i, j int) bool {
+		a, b := l.Entries[i], l.Entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		return a.Name < b.Name
+	})
+
+	w.Header().Add("Vary", "Accept")
+	if prefersJSON(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return nil
+		}
+		return json.NewEncoder(w).Encode(&l)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	return listingTemplate.Execute(w, &l)
+}
+
+// CollectionGet selects how GET and HEAD requests on collections are served
+// when neither the directory listing nor the web UI are enabled.
+type CollectionGet int
+
+const (
+	// CollectionGetNone replies with "405 Method Not Allowed".
+	CollectionGetNone CollectionGet = iota
+	// CollectionGetRoot replies to requests on the root collection like to
+	// a PROPFIND request with Depth 1, for clients such as Sardine probing
+	// the mount with GET or HEAD.
+	CollectionGetRoot
+	// CollectionGetAll replies to requests on any collection like to a
+	// PROPFIND request with Depth 1.
+	CollectionGetAll
+)
+
+// propFindOnGet reports whether a GET or HEAD request on the collection name
+// is served like a PROPFIND request.
+func (b *backend) propFindOnGet(name string) bool {
+	switch b.CollectionGet {
+	case CollectionGetRoot:
+		return path.Clean(name) == "/"
+	case CollectionGetAll:
+		return true
+	}
+	return false
+}
+
+// serveCollectionPropFind replies to a GET or HEAD request on a collection
+// with the multistatus of an allprop PROPFIND request with Depth 1.
+func (b *backend) serveCollectionPropFind(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+		w.WriteHeader(http.StatusMultiStatus)
+		return nil
+	}
+
+	propfind := internal.PropFind{AllProp: &struct{}{}}
+	mw := internal.NewMultiStatusWriter(w)
+	if err := b.PropFind(r, &propfind, internal.DepthOne, mw.WriteResponse); err != nil {
+		if mw.Started() {
+			return &internal.ResponseStartedError{Err: err}
+		}
+		return err
+	}
+	return mw.Close()
+}
+
+// collectionPath returns p with a trailing slash.
+func collectionPath(p string) string {
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// escapePath percent-encodes a path to be used in a URL.
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// prefersJSON reports whether the Accept header field of a request ranks
+// application/json above text/html.
+func prefersJSON(accept string) bool {
+	var jsonQ, htmlQ float64
+	for _, s := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(s, ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+		q := qValue(params)
+
+		switch mediaRange {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > htmlQ
+}
+
+// qValue returns the weight in the parameters of an element of an Accept
+// header, 1 by default.
+func qValue(params string) float64 {
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(param, "=")
+		if strings.TrimSpace(k) != "q" {
+			continue
+		}
+		var err error
+		if q, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			q = 0
+		}
+	}
+	return q
+}
+
+// formatSize formats a number of bytes with a binary unit prefix.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "iB"
+}
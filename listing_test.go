@@ -0,0 +1,152 @@
+package webdav_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestDirectoryListing(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"d/b.txt":             strings.Repeat("b", 2048),
+		"d/a <script>.txt":    "a",
+		"d/sub/c.txt":         "c",
+		"d/secret/hidden.txt": "h",
+	})
+	h := &webdav.Handler{
+		Prefix:           "/dav",
+		FileSystem:       webdav.LocalFileSystem(dir),
+		DirectoryListing: true,
+		Permissions: testPermissions{
+			read:   map[string]string{"alice": "/"},
+			hidden: map[string]string{"alice": "/d/secret"},
+		},
+	}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/d", user: "alice"}, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want HTML", ct)
+	}
+	body := w.Body.String()
+	hrefs := regexp.MustCompile(`href="([^"]*)"`).FindAllStringSubmatch(body, -1)
+	var got []string
+	for _, href := range hrefs {
+		got = append(got, href[1])
+	}
+	// Collections are listed first, with escaped names
+	want := []string{"/dav/", "/dav/d/sub/", "/dav/d/a%20%3Cscript%3E.txt", "/dav/d/b.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("hrefs = %q, want %q", got, want)
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("listing contains an unescaped name:\n%s", body)
+	}
+	if !strings.Contains(body, "2.0 KiB") {
+		t.Errorf("listing doesn't contain the size of b.txt:\n%s", body)
+	}
+
+	// Clients preferring JSON get a JSON listing
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/d/", user: "alice", header: map[string]string{"Accept": "text/html;q=0.5, application/json"}}, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want JSON", ct)
+	}
+	var l struct {
+		Path    string
+		Parent  string
+		Entries []struct {
+			Name  string
+			IsDir bool
+			Size  int64
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil {
+		t.Fatalf("decoding JSON listing: %v", err)
+	}
+	if l.Path != "/dav/d/" || l.Parent != "/dav/" || len(l.Entries) != 3 || !l.Entries[0].IsDir || l.Entries[2].Size != 2048 {
+		t.Errorf("JSON listing = %+v", l)
+	}
+
+	w = checkStatus(t, h, testRequest{method: http.MethodHead, target: "/dav/d", user: "alice"}, http.StatusOK)
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD returned a body")
+	}
+
+	// The root has no parent
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/", user: "alice", header: map[string]string{"Accept": "application/json"}}, http.StatusOK)
+	if strings.Contains(w.Body.String(), `"parent"`) {
+		t.Errorf("root listing has a parent: %s", w.Body)
+	}
+
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/missing/", user: "alice"}, http.StatusNotFound)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/d/secret/", user: "alice"}, http.StatusForbidden)
+
+	// Listings are disabled by default
+	h.DirectoryListing = false
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/d", user: "alice"}, http.StatusMethodNotAllowed)
+}
+
+func TestCollectionGet(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+
+	tests := []struct {
+		mode   webdav.CollectionGet
+		target string
+		code   int
+		hrefs  []string
+	}{
+		{webdav.CollectionGetNone, "/", http.StatusMethodNotAllowed, nil},
+		{webdav.CollectionGetRoot, "/", http.StatusMultiStatus, []string{"/dav/", "/dav/a.txt", "/dav/sub/"}},
+		{webdav.CollectionGetRoot, "/sub/", http.StatusMethodNotAllowed, nil},
+		{webdav.CollectionGetAll, "/sub/", http.StatusMultiStatus, []string{"/dav/sub/", "/dav/sub/b.txt"}},
+		{webdav.CollectionGetAll, "/missing/", http.StatusNotFound, nil},
+	}
+	for _, tc := range tests {
+		h := &webdav.Handler{Prefix: "/dav", FileSystem: webdav.LocalFileSystem(dir), CollectionGet: tc.mode}
+		target := "/dav" + tc.target
+
+		w := checkStatus(t, h, testRequest{method: http.MethodGet, target: target}, tc.code)
+		if head := checkStatus(t, h, testRequest{method: http.MethodHead, target: target}, tc.code); tc.code == http.StatusMultiStatus && head.Body.Len() != 0 {
+			t.Errorf("mode %v: HEAD %v returned a body", tc.mode, target)
+		}
+
+		// The collection and its members are listed like with PROPFIND
+		body := w.Body.String()
+		for _, href := range tc.hrefs {
+			if !strings.Contains(body, "<href>"+href+"</href>") {
+				t.Errorf("mode %v: GET %v body is missing %v:\n%v", tc.mode, target, href, body)
+			}
+		}
+		if tc.hrefs != nil && !strings.Contains(body, "getcontentlength") {
+			t.Errorf("mode %v: GET %v body is missing allprop properties", tc.mode, target)
+		}
+	}
+
+	// Files are served as usual
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), CollectionGet: webdav.CollectionGetAll}
+	if w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK); w.Body.String() != "a" {
+		t.Errorf("GET /a.txt = %q, want %q", w.Body.String(), "a")
+	}
+
+	// Fiber mounts answer on the prefix itself
+	app := fiber.New()
+	app.Use(webdav.New(webdav.Config{Prefix: "/dav", Root: webdav.LocalFileSystem(dir), CollectionGet: webdav.CollectionGetRoot}))
+	for target, code := range map[string]int{"/dav": http.StatusMultiStatus, "/dav/sub/": http.StatusMethodNotAllowed} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("Fiber GET %v = %v, want %v", target, resp.StatusCode, code)
+		}
+	}
+}
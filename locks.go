@@ -1,16 +1,55 @@
 package webdav
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"net/http"
+	"path"
 	"sync"
 	"time"
 
 	"github.com/Tryanks/fiber-webdav/internal"
 )
 
-// LockSystem provides an in-memory implementation of WebDAV locks.
-type LockSystem struct {
+// LockSystem manages WebDAV locks. Implementations backed by a shared store
+// allow several server instances to share their locks.
+//
+// Names are resource paths. Errors created with NewHTTPError are reported
+// with their status code, e.g. "423 Locked" when a lock conflicts with an
+// existing one.
+type LockSystem interface {
+	// Lock creates a new exclusive write lock on the resource name.
+	Lock(ctx context.Context, name string, opts *LockOptions) (*Lock, error)
+	// Refresh resets the timeout of the lock identified by token.
+	Refresh(ctx context.Context, token string, timeout time.Duration) (*Lock, error)
+	// Unlock removes the lock identified by token.
+	Unlock(ctx context.Context, token string) error
+	// Lookup returns the lock identified by token, without changing it.
+	Lookup(ctx context.Context, token string) (*Lock, error)
+	// Confirm checks that tokens contains the tokens of all the locks
+	// covering the resource name.
+	Confirm(ctx context.Context, name string, tokens []string) error
+}
+
+// LockOptions holds options for LockSystem.Lock.
+type LockOptions struct {
+	// Recursive locks the descendants of the resource too.
+	Recursive bool
+	// Timeout is the lifetime of the lock. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Lock describes an active lock.
+type Lock struct {
+	Token     string
+	Root      string
+	Recursive bool
+	Timeout   time.Duration
+}
+
+// MemLockSystem provides an in-memory implementation of WebDAV locks.
+type MemLockSystem struct {
 	mu    sync.RWMutex
 	locks map[string]*lockInfo // Map of token -> lock info
 	paths map[string][]string  // Map of path -> tokens
@@ -18,83 +57,104 @@ type LockSystem struct {
 
 // lockInfo contains information about an active lock.
 type lockInfo struct {
-	Token   string
-	Root    string
-	Created time.Time
-	Timeout time.Duration
+	Token     string
+	Root      string
+	Recursive bool
+	Created   time.Time
+	Timeout   time.Duration
 }
 
-// Global lock system that can be used by all backends
-var globalLockSystem *LockSystem
+func (lock *lockInfo) expired(now time.Time) bool {
+	return lock.Timeout != 0 && now.Sub(lock.Created) > lock.Timeout
+}
+
+func (lock *lockInfo) lock() *Lock {
+	return &Lock{
+		Token:     lock.Token,
+		Root:      lock.Root,
+		Recursive: lock.Recursive,
+		Timeout:   lock.Timeout,
+	}
+}
+
+// globalLockSystem is the lock system returned by GetGlobalLockSystem.
+var globalLockSystem = sync.OnceValue(NewLockSystem)
+
+var _ LockSystem = (*MemLockSystem)(nil)
 
 // NewLockSystem creates a new in-memory lock system.
-func NewLockSystem() *LockSystem {
-	return &LockSystem{
+func NewLockSystem() *MemLockSystem {
+	return &MemLockSystem{
 		locks: make(map[string]*lockInfo),
 		paths: make(map[string][]string),
 	}
 }
 
 // GetGlobalLockSystem returns the global lock system, creating it if necessary.
-func GetGlobalLockSystem() *LockSystem {
-	if globalLockSystem == nil {
-		globalLockSystem = NewLockSystem()
-	}
-	return globalLockSystem
+func GetGlobalLockSystem() *MemLockSystem {
+	return globalLockSystem()
 }
 
-// Lock creates or refreshes a lock.
-func (ls *LockSystem) Lock(r *http.Request, depth internal.Depth, timeout time.Duration, refreshToken string) (*internal.Lock, bool, error) {
+// Lock creates a lock.
+func (ls *MemLockSystem) Lock(ctx context.Context, name string, opts *LockOptions) (*Lock, error) {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
-	path := r.URL.Path
-
-	// If refreshToken is provided, refresh the existing lock
-	if refreshToken != "" {
-		lock, ok := ls.locks[refreshToken]
-		if !ok {
-			return nil, false, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: lock token not found")
-		}
-
-		// Update the timeout
-		lock.Timeout = timeout
-		lock.Created = time.Now()
-
-		return &internal.Lock{
-			Href:    lock.Token,
-			Root:    lock.Root,
-			Timeout: lock.Timeout,
-		}, false, nil
-	}
+	// Both forms of a collection path are the same resource
+	name = path.Clean(name)
 
 	// Check if the path is already locked
-	if tokens, ok := ls.paths[path]; ok && len(tokens) > 0 {
-		return nil, false, internal.HTTPErrorf(http.StatusLocked, "webdav: path already locked")
+	if tokens, ok := ls.paths[name]; ok && len(tokens) > 0 {
+		return nil, internal.HTTPErrorf(http.StatusLocked, "webdav: path already locked")
 	}
 
 	// Create a new lock
-	token := generateToken()
 	lock := &lockInfo{
-		Token:   token,
-		Root:    path,
-		Created: time.Now(),
-		Timeout: timeout,
+		Token:     generateToken(),
+		Root:      name,
+		Recursive: opts.Recursive,
+		Created:   time.Now(),
+		Timeout:   opts.Timeout,
 	}
 
 	// Store the lock
-	ls.locks[token] = lock
-	ls.paths[path] = append(ls.paths[path], token)
+	ls.locks[lock.Token] = lock
+	ls.paths[name] = append(ls.paths[name], lock.Token)
 
-	return &internal.Lock{
-		Href:    token,
-		Root:    path,
-		Timeout: timeout,
-	}, true, nil
+	return lock.lock(), nil
+}
+
+// Refresh refreshes a lock.
+func (ls *MemLockSystem) Refresh(ctx context.Context, token string, timeout time.Duration) (*Lock, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	lock, ok := ls.locks[token]
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: lock token not found")
+	}
+
+	// Update the timeout
+	lock.Timeout = timeout
+	lock.Created = time.Now()
+
+	return lock.lock(), nil
+}
+
+// Lookup returns a lock.
+func (ls *MemLockSystem) Lookup(ctx context.Context, token string) (*Lock, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	lock, ok := ls.locks[token]
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: lock token not found")
+	}
+	return lock.lock(), nil
 }
 
 // Unlock removes a lock.
-func (ls *LockSystem) Unlock(r *http.Request, tokenHref string) error {
+func (ls *MemLockSystem) Unlock(ctx context.Context, tokenHref string) error {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
@@ -125,20 +185,59 @@ func (ls *LockSystem) Unlock(r *http.Request, tokenHref string) error {
 	return nil
 }
 
-// CleanExpiredLocks removes expired locks.
-func (ls *LockSystem) CleanExpiredLocks() {
-	ls.mu.Lock()
-	defer ls.mu.Unlock()
+// Confirm checks that tokens contains the tokens of all the locks covering
+// the resource name. It returns a "423 Locked" error otherwise.
+func (ls *MemLockSystem) Confirm(ctx context.Context, name string, tokens []string) error {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	if len(ls.locks) == 0 {
+		return nil
+	}
+
+	submitted := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		submitted[token] = true
+	}
 
+	name = path.Clean(name)
 	now := time.Now()
 	for token, lock := range ls.locks {
-		// Skip infinite locks
-		if lock.Timeout == 0 {
+		if lock.expired(now) || submitted[token] {
 			continue
 		}
+		root := path.Clean(lock.Root)
+		if root == name || (lock.Recursive && isDescendant(name, root)) {
+			return internal.HTTPErrorf(http.StatusLocked, "webdav: resource is locked")
+		}
+	}
+	return nil
+}
+
+// ActiveLocks returns the number of locks which haven't expired.
+func (ls *MemLockSystem) ActiveLocks() int {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	now := time.Now()
+	n := 0
+	for _, lock := range ls.locks {
+		if !lock.expired(now) {
+			n++
+		}
+	}
+	return n
+}
 
+// CleanExpiredLocks removes expired locks.
+func (ls *MemLockSystem) CleanExpiredLocks() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	for token, lock := range ls.locks {
 		// Check if the lock has expired
-		if now.Sub(lock.Created) > lock.Timeout {
+		if lock.expired(now) {
 			// Remove the lock from the paths map
 			path := lock.Root
 			tokens := ls.paths[path]
@@ -161,8 +260,94 @@ func (ls *LockSystem) CleanExpiredLocks() {
 	}
 }
 
-// generateToken creates a unique token for a lock.
+// generateToken creates a unique token for a lock, a random UUID as per
+// RFC4918:SC.1, so that tokens can't be guessed.
 func generateToken() string {
-	// Create a simple unique token using timestamp and random number
-	return fmt.Sprintf("opaquelocktoken:%d-%d", time.Now().UnixNano(), time.Now().Unix())
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("opaquelocktoken:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// submittedLockTokens returns the lock tokens submitted by a request in its
+// If header field.
+func submittedLockTokens(r *http.Request) []string {
+	conditions, err := internal.ParseConditions(r.Header.Get("If"))
+	if err != nil {
+		return nil
+	}
+	var tokens []string
+	for _, l := range conditions {
+		for _, cond := range l {
+			if cond.Token != "" && !cond.Not {
+				tokens = append(tokens, cond.Token)
+			}
+		}
+	}
+	return tokens
+}
+
+// checkIfHeader evaluates the entity tag conditions of the If header field of
+// a request, as specified in RFC 4918 section 10.4. Lock token conditions are
+// left to the LockSystem and deemed true here. Entity tags are compared with
+// the strong comparison function, like If-Match, so weak ones never match.
+func (b *backend) checkIfHeader(r *http.Request) error {
+	h := r.Header.Get("If")
+	if h == "" {
+		return nil
+	}
+	lists, err := internal.ParseConditions(h)
+	if err != nil {
+		return NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	stats := make(map[string]*FileInfo)
+	for _, l := range lists {
+		ok := true
+		for _, cond := range l {
+			if cond.ETag == "" {
+				continue
+			}
+			fi := b.ifHeaderResource(r, cond.Resource, stats)
+			match := false
+			if fi != nil && !fi.WeakETag {
+				match, _ = ConditionalMatch(cond.ETag).MatchETag(fi.ETag)
+			}
+			if match == cond.Not {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return nil
+		}
+	}
+	return NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("webdav: If header conditions failed"))
+}
+
+// ifHeaderResource returns the resource targeted by a list of an If header
+// field, either the tagged resource or the request URI, or nil if it doesn't
+// exist or isn't served by the handler.
+func (b *backend) ifHeaderResource(r *http.Request, resource string, stats map[string]*FileInfo) *FileInfo {
+	name := r.URL.Path
+	if resource != "" {
+		u, err := internal.ParseDestination(resource)
+		if err != nil {
+			return nil
+		}
+		if name, err = resolveDestination(u, b.Prefix, b.ExternalURLs); err != nil {
+			return nil
+		}
+	}
+	name = path.Clean(name)
+	if fi, ok := stats[name]; ok {
+		return fi
+	}
+	fi, err := b.FileSystem.Stat(r.Context(), name)
+	if err != nil {
+		fi = nil
+	}
+	stats[name] = fi
+	return fi
 }
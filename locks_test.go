@@ -0,0 +1,100 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// recordingLockSystem records the resources locked and confirmed through a
+// LockSystem.
+type recordingLockSystem struct {
+	webdav.LockSystem
+	mu        sync.Mutex
+	locked    []string
+	confirmed []string
+}
+
+func (ls *recordingLockSystem) Lock(ctx context.Context, name string, opts *webdav.LockOptions) (*webdav.Lock, error) {
+	ls.mu.Lock()
+	ls.locked = append(ls.locked, name)
+	ls.mu.Unlock()
+	return ls.LockSystem.Lock(ctx, name, opts)
+}
+
+func (ls *recordingLockSystem) Confirm(ctx context.Context, name string, tokens []string) error {
+	ls.mu.Lock()
+	ls.confirmed = append(ls.confirmed, name)
+	ls.mu.Unlock()
+	return ls.LockSystem.Confirm(ctx, name, tokens)
+}
+
+func TestCustomLockSystem(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	ls := &recordingLockSystem{LockSystem: webdav.NewLockSystem()}
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), LockSystem: ls}
+
+	w := checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+	token := w.Header().Get("Lock-Token")
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "b"}, http.StatusLocked)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "c", header: map[string]string{"If": "(" + token + ")"}}, http.StatusNoContent)
+	checkFile(t, dir, "a.txt", "c")
+
+	if len(ls.locked) != 1 || ls.locked[0] != "/a.txt" {
+		t.Errorf("locked %v, want [/a.txt]", ls.locked)
+	}
+	if len(ls.confirmed) < 2 {
+		t.Errorf("confirmed %v, want both uploads", ls.confirmed)
+	}
+}
+
+func TestGlobalLockSystem(t *testing.T) {
+	var wg sync.WaitGroup
+	systems := make([]*webdav.MemLockSystem, 8)
+	for i := range systems {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			systems[i] = webdav.GetGlobalLockSystem()
+		}()
+	}
+	wg.Wait()
+	for _, ls := range systems {
+		if ls == nil || ls != systems[0] {
+			t.Fatalf("GetGlobalLockSystem() returned different lock systems")
+		}
+	}
+}
+
+func TestIfHeader(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+
+	tests := []struct {
+		ifHeader string
+		want     int
+	}{
+		{`(["other"])`, http.StatusPreconditionFailed},
+		{`(Not [ETAG])`, http.StatusPreconditionFailed},
+		{`(["other"]) (Not ["other"])`, http.StatusNoContent},
+		{`([ETAG])`, http.StatusNoContent},
+		// Malformed headers aren't ignored
+		{`["other"]`, http.StatusBadRequest},
+		{`(["other"]`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		// ETAG stands for the current entity tag of the file
+		etag := checkStatus(t, h, testRequest{method: http.MethodHead, target: "/a.txt"}, http.StatusOK).Header().Get("ETag")
+		ifHeader := strings.ReplaceAll(tc.ifHeader, "ETAG", etag)
+		w := checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "b", header: map[string]string{"If": ifHeader}}, tc.want)
+		if tc.want == http.StatusPreconditionFailed && !strings.Contains(w.Body.String(), "webdav: If header conditions failed") {
+			t.Errorf("If %v: response = %q", ifHeader, w.Body)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package webdav
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// statusWriter records the status code and the size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	err    error
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w}
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Status returns the status code of the response.
+func (sw *statusWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(b)
+	cr.bytes += int64(n)
+	return n, err
+}
+
+// logRequest logs a served request. Server errors are logged at the error
+// level, other requests at the info level.
+func logRequest(logger *slog.Logger, r *http.Request, sw *statusWriter, body *countingReader, duration time.Duration) {
+	level := slog.LevelInfo
+	if sw.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+	if id, ok := RequestIDFromContext(r.Context()); ok {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if depth := r.Header.Get("Depth"); depth != "" {
+		attrs = append(attrs, slog.String("depth", depth))
+	}
+	if dest := r.Header.Get("Destination"); dest != "" {
+		attrs = append(attrs, slog.String("destination", dest))
+	}
+	if user, ok := UserFromContext(r.Context()); ok {
+		attrs = append(attrs, slog.String("user", user))
+	}
+	attrs = append(attrs,
+		slog.Int("status", sw.Status()),
+		slog.Duration("duration", duration),
+		slog.Int64("bytes_in", body.bytes),
+		slog.Int64("bytes_out", sw.bytes),
+	)
+	if sw.err != nil {
+		attrs = append(attrs, slog.String("error", sw.err.Error()))
+	}
+
+	logger.LogAttrs(r.Context(), level, "webdav request", attrs...)
+}
@@ -0,0 +1,110 @@
+package webdav_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// logRecords decodes the records written by a JSON slog handler.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("decoding log record: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "hello"})
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), Logger: logger}
+
+	tests := []struct {
+		req   testRequest
+		want  int
+		attrs map[string]any
+	}{
+		{
+			testRequest{method: http.MethodPut, target: "/b.txt", body: "world!", user: "alice"},
+			http.StatusCreated,
+			map[string]any{"level": "INFO", "method": "PUT", "path": "/b.txt", "user": "alice", "bytes_in": 6.0},
+		},
+		{
+			testRequest{method: http.MethodGet, target: "/a.txt"},
+			http.StatusOK,
+			map[string]any{"level": "INFO", "method": "GET", "status": 200.0, "bytes_out": 5.0},
+		},
+		{
+			testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}},
+			http.StatusMultiStatus,
+			map[string]any{"level": "INFO", "depth": "1", "status": 207.0},
+		},
+		{
+			testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/c.txt"}},
+			http.StatusCreated,
+			map[string]any{"level": "INFO", "destination": "/c.txt"},
+		},
+		// Client errors are logged with their cause at the info level
+		{
+			testRequest{method: http.MethodGet, target: "/missing"},
+			http.StatusNotFound,
+			map[string]any{"level": "INFO", "status": 404.0},
+		},
+	}
+	for _, tc := range tests {
+		buf.Reset()
+		checkStatus(t, h, tc.req, tc.want)
+		records := logRecords(t, &buf)
+		if len(records) != 1 {
+			t.Fatalf("%v %v: got %v log records, want 1", tc.req.method, tc.req.target, len(records))
+		}
+		record := records[0]
+		if record["msg"] != "webdav request" {
+			t.Errorf("%v %v: msg = %v, want webdav request", tc.req.method, tc.req.target, record["msg"])
+		}
+		if _, ok := record["duration"]; !ok {
+			t.Errorf("%v %v: record %v has no duration", tc.req.method, tc.req.target, record)
+		}
+		for k, want := range tc.attrs {
+			if got := record[k]; got != want {
+				t.Errorf("%v %v: %v = %v, want %v", tc.req.method, tc.req.target, k, got, want)
+			}
+		}
+	}
+
+	// Server errors are logged at the error level with their cause
+	buf.Reset()
+	h = &webdav.Handler{FileSystem: failingStatFileSystem{webdav.LocalFileSystem(dir)}, Logger: logger}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt"}, http.StatusInternalServerError)
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("got %v log records for a server error, want 1", len(records))
+	}
+	if records[0]["level"] != "ERROR" || records[0]["status"] != 500.0 {
+		t.Errorf("server error record = %v, want level ERROR and status 500", records[0])
+	}
+	if records[0]["error"] == nil {
+		t.Errorf("server error record = %v, want an error", records[0])
+	}
+
+	// Nothing is logged without a Logger
+	buf.Reset()
+	h = &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	if buf.Len() != 0 {
+		t.Errorf("logged %q without a Logger", buf.String())
+	}
+}
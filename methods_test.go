@@ -0,0 +1,91 @@
+package webdav_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// checkAllow fails the test if the Allow header field of w doesn't list
+// exactly the methods in want, in any order.
+func checkAllow(t *testing.T, w http.ResponseWriter, want ...string) {
+	t.Helper()
+	got := strings.Split(w.Header().Get("Allow"), ", ")
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+}
+
+func TestReadOnlyConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "c/": ""})
+	h := webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(dir), ReadOnly: true, Lock: true}).HTTPHandler()
+
+	w := checkStatus(t, h, testRequest{method: http.MethodOptions, target: "/a.txt"}, http.StatusNoContent)
+	checkAllow(t, w, "OPTIONS", "PROPFIND", "HEAD", "GET")
+	if dav := w.Header().Get("DAV"); strings.Contains(dav, "2") {
+		t.Errorf("DAV = %q, want no class 2 without LOCK", dav)
+	}
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+
+	destination := map[string]string{"Destination": "http://example.com/b.txt"}
+	for _, req := range []testRequest{
+		{method: http.MethodPut, target: "/a.txt", body: "b"},
+		{method: http.MethodPut, target: "/b.txt", body: "b"},
+		{method: http.MethodDelete, target: "/a.txt"},
+		{method: "MKCOL", target: "/d"},
+		{method: "COPY", target: "/a.txt", header: destination},
+		{method: "MOVE", target: "/a.txt", header: destination},
+		{method: "PROPPATCH", target: "/a.txt", body: propPatchColor},
+		{method: "LOCK", target: "/a.txt", body: lockBody},
+	} {
+		checkStatus(t, h, req, http.StatusForbidden)
+	}
+	checkFile(t, dir, "a.txt", "a")
+	checkMissing(t, dir, "b.txt")
+	checkMissing(t, dir, "d")
+}
+
+func TestAllowedMethods(t *testing.T) {
+	destination := map[string]string{"Destination": "http://example.com/b.txt"}
+	tests := []struct {
+		name            string
+		allowed         []string
+		denied          []string
+		allow           []string
+		move, proppatch int
+	}{
+		{"allowed", []string{"PROPFIND", "GET", "PUT"}, nil, []string{"OPTIONS", "PROPFIND", "GET", "PUT"}, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed},
+		{"denied", nil, []string{"MOVE", "COPY", "PROPPATCH"}, []string{"OPTIONS", "DELETE", "PROPFIND", "HEAD", "GET", "PUT"}, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed},
+		{"denied allowed", []string{"GET", "MOVE"}, []string{"MOVE"}, []string{"OPTIONS", "GET"}, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed},
+		{"unrestricted", nil, nil, []string{"OPTIONS", "DELETE", "PROPFIND", "PROPPATCH", "COPY", "MOVE", "HEAD", "GET", "PUT"}, http.StatusCreated, http.StatusMultiStatus},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "a"})
+			h := webdav.NewServer(webdav.Config{
+				Root:           webdav.LocalFileSystem(dir),
+				AllowedMethods: tc.allowed,
+				DeniedMethods:  tc.denied,
+			}).HTTPHandler()
+
+			w := checkStatus(t, h, testRequest{method: http.MethodOptions, target: "/a.txt"}, http.StatusNoContent)
+			checkAllow(t, w, tc.allow...)
+			checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatchColor}, tc.proppatch)
+			w = checkStatus(t, h, testRequest{method: "MOVE", target: "/a.txt", header: destination}, tc.move)
+			if tc.move == http.StatusMethodNotAllowed {
+				// Rejected requests list the methods allowed instead
+				checkAllow(t, w, tc.allow...)
+				checkFile(t, dir, "a.txt", "a")
+				checkMissing(t, dir, "b.txt")
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Metrics collects Prometheus metrics about the requests served by handlers:
+//
+//   - webdav_requests_total: requests by method and status code
+//   - webdav_request_duration_seconds: request latency by method
+//   - webdav_uploaded_bytes_total, webdav_downloaded_bytes_total: bytes of
+//     request and response bodies
+//   - webdav_propfind_requests_total: PROPFIND requests by depth
+//   - webdav_active_locks: locks held in lock systems reporting their count,
+//     such as MemLockSystem
+//   - webdav_backend_duration_seconds: FileSystem call latency by operation
+//
+// Metrics can be shared by several handlers. It implements
+// prometheus.Collector, so it can be registered in an existing registry, or
+// served on its own with Handler.
+type Metrics struct {
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	uploadedBytes   prometheus.Counter
+	downloadedBytes prometheus.Counter
+	propfindDepth   *prometheus.CounterVec
+	backendDuration *prometheus.HistogramVec
+	activeLocks     *prometheus.Desc
+
+	mu          sync.Mutex
+	lockSystems map[activeLocker]struct{}
+
+	registryOnce sync.Once
+	handler      http.Handler
+}
+
+// activeLocker is implemented by lock systems reporting the number of locks
+// they hold.
+type activeLocker interface {
+	ActiveLocks() int
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+// NewMetrics creates a new metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "webdav",
+			Name:      "requests_total",
+			Help:      "Number of WebDAV requests by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "webdav",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of WebDAV requests by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		uploadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "webdav",
+			Name:      "uploaded_bytes_total",
+			Help:      "Bytes received in request bodies.",
+		}),
+		downloadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "webdav",
+			Name:      "downloaded_bytes_total",
+			Help:      "Bytes sent in response bodies.",
+		}),
+		propfindDepth: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "webdav",
+			Name:      "propfind_requests_total",
+			Help:      "Number of PROPFIND requests by depth.",
+		}, []string{"depth"}),
+		backendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "webdav",
+			Name:      "backend_duration_seconds",
+			Help:      "Latency of FileSystem calls by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		activeLocks: prometheus.NewDesc("webdav_active_locks", "Number of active WebDAV locks.", nil, nil),
+		lockSystems: make(map[activeLocker]struct{}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.requestDuration.Describe(ch)
+	m.uploadedBytes.Describe(ch)
+	m.downloadedBytes.Describe(ch)
+	m.propfindDepth.Describe(ch)
+	m.backendDuration.Describe(ch)
+	ch <- m.activeLocks
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.requestDuration.Collect(ch)
+	m.uploadedBytes.Collect(ch)
+	m.downloadedBytes.Collect(ch)
+	m.propfindDepth.Collect(ch)
+	m.backendDuration.Collect(ch)
+
+	m.mu.Lock()
+	locks := 0
+	for ls := range m.lockSystems {
+		locks += ls.ActiveLocks()
+	}
+	m.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(m.activeLocks, prometheus.GaugeValue, float64(locks))
+}
+
+// Handler returns an HTTP handler exposing the metrics in the Prometheus
+// text format, e.g. on /metrics. It uses a dedicated registry: to expose the
+// metrics along others, register Metrics in a shared registry instead.
+func (m *Metrics) Handler() http.Handler {
+	m.registryOnce.Do(func() {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(m)
+		m.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	})
+	return m.handler
+}
+
+// addLockSystem counts the locks of ls in webdav_active_locks, if it reports
+// them.
+func (m *Metrics) addLockSystem(ls LockSystem) {
+	al, ok := ls.(activeLocker)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	m.lockSystems[al] = struct{}{}
+	m.mu.Unlock()
+}
+
+// observeRequest records a served request.
+func (m *Metrics) observeRequest(r *http.Request, sw *statusWriter, body *countingReader, duration time.Duration) {
+	method := methodLabel(r.Method)
+	m.requests.WithLabelValues(method, strconv.Itoa(sw.Status())).Inc()
+	m.requestDuration.WithLabelValues(method).Observe(duration.Seconds())
+	m.uploadedBytes.Add(float64(body.bytes))
+	m.downloadedBytes.Add(float64(sw.bytes))
+
+	if r.Method == "PROPFIND" {
+		// A missing Depth header means infinity
+		depth := internal.DepthInfinity
+		if d, err := internal.ParseDepth(r.Header.Get("Depth")); err == nil {
+			depth = d
+		}
+		m.propfindDepth.WithLabelValues(depth.String()).Inc()
+	}
+}
+
+// methodLabel returns the label of a request method. Unknown methods are
+// grouped to bound the number of series. Known methods are returned as
+// constants, since r.Method may share memory with a reused request buffer.
+func methodLabel(method string) string {
+	for _, m := range ExtendedMethods {
+		if m == method {
+			return m
+		}
+	}
+	return "OTHER"
+}
+
+// instrumentedFileSystem records the latency of the calls to a FileSystem.
+type instrumentedFileSystem struct {
+	fs      FileSystem
+	metrics *Metrics
+}
+
+// Unwrap returns the underlying FileSystem, so that its optional interfaces
+// are still used.
+func (ifs *instrumentedFileSystem) Unwrap() FileSystem {
+	return ifs.fs
+}
+
+func (ifs *instrumentedFileSystem) observe(operation string, start time.Time) {
+	ifs.metrics.backendDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (ifs *instrumentedFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	defer ifs.observe("open", time.Now())
+	return ifs.fs.Open(ctx, name)
+}
+
+func (ifs *instrumentedFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	defer ifs.observe("stat", time.Now())
+	return ifs.fs.Stat(ctx, name)
+}
+
+func (ifs *instrumentedFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	defer ifs.observe("readdir", time.Now())
+	return ifs.fs.ReadDir(ctx, name, recursive)
+}
+
+func (ifs *instrumentedFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	defer ifs.observe("walk", time.Now())
+	return walk(ctx, ifs.fs, name, recursive, fn)
+}
+
+func (ifs *instrumentedFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	defer ifs.observe("create", time.Now())
+	return ifs.fs.Create(ctx, name, body, opts)
+}
+
+func (ifs *instrumentedFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	defer ifs.observe("removeall", time.Now())
+	return ifs.fs.RemoveAll(ctx, name, opts)
+}
+
+func (ifs *instrumentedFileSystem) Mkdir(ctx context.Context, name string) error {
+	defer ifs.observe("mkdir", time.Now())
+	return ifs.fs.Mkdir(ctx, name)
+}
+
+func (ifs *instrumentedFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	defer ifs.observe("copy", time.Now())
+	return ifs.fs.Copy(ctx, name, dest, options)
+}
+
+func (ifs *instrumentedFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	defer ifs.observe("move", time.Now())
+	return ifs.fs.Move(ctx, name, dest, options)
+}
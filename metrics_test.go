@@ -0,0 +1,66 @@
+package webdav_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// scrapeMetrics returns the metrics exposed by m in the text format.
+func scrapeMetrics(t *testing.T, m *webdav.Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %v", w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "hello"})
+	m := webdav.NewMetrics()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), LockSystem: webdav.NewLockSystem(), Metrics: m}
+
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/missing"}, http.StatusNotFound)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "world!"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/"}, http.StatusMultiStatus)
+	checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+	// Unknown methods are grouped
+	checkStatus(t, h, testRequest{method: "BREW", target: "/a.txt"}, http.StatusMethodNotAllowed)
+
+	metrics := scrapeMetrics(t, m)
+	for _, want := range []string{
+		`webdav_requests_total{code="200",method="GET"} 1`,
+		`webdav_requests_total{code="404",method="GET"} 1`,
+		`webdav_requests_total{code="201",method="PUT"} 1`,
+		`webdav_requests_total{code="405",method="OTHER"} 1`,
+		`webdav_request_duration_seconds_count{method="GET"} 2`,
+		fmt.Sprintf("webdav_uploaded_bytes_total %v", len("world!")+len(lockBody)),
+		`webdav_propfind_requests_total{depth="1"} 1`,
+		`webdav_propfind_requests_total{depth="infinity"} 1`,
+		`webdav_active_locks 1`,
+		`webdav_backend_duration_seconds_count{operation="create"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics don't contain %q:\n%s", want, metrics)
+		}
+	}
+	if strings.Contains(metrics, `method="BREW"`) {
+		t.Errorf("metrics contain a series for an unknown method:\n%s", metrics)
+	}
+
+	// Metrics are shared by handlers
+	h2 := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), Metrics: m}
+	checkStatus(t, h2, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	if metrics := scrapeMetrics(t, m); !strings.Contains(metrics, `webdav_requests_total{code="200",method="GET"} 2`) {
+		t.Errorf("requests of another handler aren't counted:\n%s", metrics)
+	}
+}
@@ -0,0 +1,899 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// FileSystemMiddleware decorates a FileSystem, e.g. to restrict or observe
+// its operations. The decorators of this package implement
+// Unwrap() FileSystem, so that the optional interfaces of the decorated
+// FileSystem, such as RangeFileSystem or WatchableFileSystem, are still
+// used by the handler.
+type FileSystemMiddleware func(FileSystem) FileSystem
+
+// Chain returns a FileSystemMiddleware applying middlewares in order: the
+// first one is the outermost, seeing calls first.
+//
+//	fs := webdav.Chain(
+//		webdav.Logging(webdav.LoggingOptions{}),
+//		webdav.Filter(webdav.FilterOptions{Exclude: []string{".*"}}),
+//		webdav.Quota(webdav.QuotaOptions{MaxBytes: 1 << 30}),
+//	)(webdav.LocalFileSystem("/srv/dav"))
+func Chain(middlewares ...FileSystemMiddleware) FileSystemMiddleware {
+	return func(fs FileSystem) FileSystem {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			fs = middlewares[i](fs)
+		}
+		return fs
+	}
+}
+
+// fsDecorator forwards the calls of a decorator to the decorated FileSystem.
+// Decorators embed it and override the methods they decorate.
+type fsDecorator struct {
+	FileSystem
+}
+
+// Unwrap returns the underlying FileSystem, so that its optional interfaces
+// are still used.
+func (d fsDecorator) Unwrap() FileSystem {
+	return d.FileSystem
+}
+
+// Walk implements WalkFileSystem, so that collections are still streamed if
+// the underlying FileSystem supports it.
+func (d fsDecorator) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	return walk(ctx, d.FileSystem, name, recursive, fn)
+}
+
+// ReadOnlyOptions configures the ReadOnly middleware.
+type ReadOnlyOptions struct {
+	// Paths are the collections and files made read-only, with their
+	// descendants. If empty, the whole FileSystem is.
+	Paths []string
+}
+
+// ReadOnly returns a FileSystemMiddleware rejecting the changes to resources
+// with "403 Forbidden". Unlike Handler.ReadOnly, it can protect parts of a
+// FileSystem, and applies to any user of the FileSystem. Properties are
+// still writable, unless the FileSystem is the PropertyStore.
+func ReadOnly(opts ReadOnlyOptions) FileSystemMiddleware {
+	paths := make([]string, len(opts.Paths))
+	for i, p := range opts.Paths {
+		paths[i] = path.Clean("/" + p)
+	}
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	return func(fs FileSystem) FileSystem {
+		return &readOnlyFileSystem{fsDecorator{fs}, paths}
+	}
+}
+
+type readOnlyFileSystem struct {
+	fsDecorator
+	paths []string
+}
+
+// check fails if changing name would change a read-only resource, including
+// when name is one of their ancestors.
+func (fs *readOnlyFileSystem) check(names ...string) error {
+	for _, name := range names {
+		name = path.Clean("/" + name)
+		for _, p := range fs.paths {
+			if isDescendant(name, p) || isDescendant(p, name) {
+				return NewHTTPError(http.StatusForbidden, fmt.Errorf("webdav: %v is read-only", p))
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *readOnlyFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	if err := fs.check(name); err != nil {
+		return nil, false, err
+	}
+	return fs.FileSystem.Create(ctx, name, body, opts)
+}
+
+func (fs *readOnlyFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	if err := fs.check(name); err != nil {
+		return err
+	}
+	return fs.FileSystem.RemoveAll(ctx, name, opts)
+}
+
+func (fs *readOnlyFileSystem) Mkdir(ctx context.Context, name string) error {
+	if err := fs.check(name); err != nil {
+		return err
+	}
+	return fs.FileSystem.Mkdir(ctx, name)
+}
+
+func (fs *readOnlyFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	if err := fs.check(dest); err != nil {
+		return false, err
+	}
+	return fs.FileSystem.Copy(ctx, name, dest, options)
+}
+
+func (fs *readOnlyFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	if err := fs.check(name, dest); err != nil {
+		return false, err
+	}
+	return fs.FileSystem.Move(ctx, name, dest, options)
+}
+
+// SetExecutable implements ExecutableFileSystem, so that the executable bit
+// of read-only files can't be changed through the underlying FileSystem.
+func (fs *readOnlyFileSystem) SetExecutable(ctx context.Context, name string, executable bool) error {
+	if err := fs.check(name); err != nil {
+		return err
+	}
+	xfs, ok := fileSystemAs[ExecutableFileSystem](fs.FileSystem)
+	if !ok {
+		return NewHTTPError(http.StatusForbidden, fmt.Errorf("webdav: executable bit not supported"))
+	}
+	return xfs.SetExecutable(ctx, name, executable)
+}
+
+// QuotaOptions configures the Quota middleware.
+type QuotaOptions struct {
+	// MaxBytes is the maximum total size of the files.
+	MaxBytes int64
+}
+
+// Quota returns a FileSystemMiddleware limiting the total size of the files
+// of a FileSystem. Uploads and copies exceeding it fail with "507
+// Insufficient Storage". The usage is computed by walking the FileSystem on
+// first use, then kept up to date as files are written and removed through
+// the middleware; changes made behind its back aren't accounted for.
+// Concurrent uploads reserve the space they use, so that together they
+// can't exceed the quota.
+func Quota(opts QuotaOptions) FileSystemMiddleware {
+	return func(fs FileSystem) FileSystem {
+		return &quotaFileSystem{fsDecorator: fsDecorator{fs}, quota: opts.MaxBytes}
+	}
+}
+
+type quotaFileSystem struct {
+	fsDecorator
+	quota int64
+
+	mu       sync.Mutex
+	used     int64 // total size of the files, if known
+	known    bool
+	reserved int64                    // bytes reserved by writes in progress
+	writing  map[string]chan struct{} // files being uploaded
+}
+
+var errQuotaExceeded = NewHTTPError(http.StatusInsufficientStorage, fmt.Errorf("webdav: quota exceeded"))
+
+// usage returns the total size of the files of the resource name.
+func (qfs *quotaFileSystem) usage(ctx context.Context, name string) (int64, error) {
+	var size int64
+	err := walk(ctx, qfs.FileSystem, name, true, func(fi *FileInfo) error {
+		if !fi.IsDir {
+			size += fi.Size
+		}
+		return nil
+	})
+	return size, err
+}
+
+// loadUsage returns the total size of the files, walking the FileSystem if
+// it isn't known. qfs.mu must be held.
+func (qfs *quotaFileSystem) loadUsage(ctx context.Context) (int64, error) {
+	if !qfs.known {
+		used, err := qfs.usage(ctx, "/")
+		if err != nil {
+			return 0, err
+		}
+		qfs.used, qfs.known = used, true
+	}
+	return qfs.used, nil
+}
+
+// release releases the reserved bytes of a write, and adds delta to the
+// usage if it succeeded. Failed writes may have changed the files
+// partially, the usage is then computed again on next use.
+func (qfs *quotaFileSystem) release(reserved, delta int64, err error) {
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	qfs.reserved -= reserved
+	if err != nil {
+		qfs.known = false
+	} else {
+		qfs.used += delta
+	}
+}
+
+// fileSize returns the size of the file name, or zero if it doesn't exist
+// or is a collection.
+func (qfs *quotaFileSystem) fileSize(ctx context.Context, name string) int64 {
+	if fi, err := qfs.Stat(ctx, name); err == nil && !fi.IsDir {
+		return fi.Size
+	}
+	return 0
+}
+
+// remaining returns the size the file name may have, replacing its current
+// content if it exists.
+func (qfs *quotaFileSystem) remaining(ctx context.Context, name string) (int64, error) {
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	used, err := qfs.loadUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return qfs.quota - used - qfs.reserved + qfs.fileSize(ctx, name), nil
+}
+
+// startUpload waits for other uploads of the file name to complete, so that
+// the size of the replaced content is known. The returned function ends the
+// upload.
+func (qfs *quotaFileSystem) startUpload(ctx context.Context, name string) (done func(), err error) {
+	name = path.Clean(name)
+	qfs.mu.Lock()
+	for {
+		ch, ok := qfs.writing[name]
+		if !ok {
+			break
+		}
+		qfs.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		qfs.mu.Lock()
+	}
+	if qfs.writing == nil {
+		qfs.writing = make(map[string]chan struct{})
+	}
+	ch := make(chan struct{})
+	qfs.writing[name] = ch
+	qfs.mu.Unlock()
+
+	return func() {
+		qfs.mu.Lock()
+		delete(qfs.writing, name)
+		qfs.mu.Unlock()
+		close(ch)
+	}, nil
+}
+
+func (qfs *quotaFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	done, err := qfs.startUpload(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer done()
+
+	// The declared length is reserved up front, the rest of the body as it
+	// is read
+	r := &quotaReader{ReadCloser: body, qfs: qfs, replaced: qfs.fileSize(ctx, name)}
+	if err := r.reserve(ctx, opts.ContentLength); err != nil {
+		return nil, false, err
+	}
+	fi, created, err := qfs.FileSystem.Create(ctx, name, r, opts)
+	var delta int64
+	if err == nil {
+		delta = fi.Size - r.replaced
+	}
+	qfs.release(r.reserved, delta, err)
+	return fi, created, err
+}
+
+// treeSize returns the total size of the files of the resource name, or zero
+// if it doesn't exist.
+func (qfs *quotaFileSystem) treeSize(ctx context.Context, name string) (int64, error) {
+	fi, err := qfs.Stat(ctx, name)
+	if internal.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	} else if !fi.IsDir {
+		return fi.Size, nil
+	}
+	return qfs.usage(ctx, name)
+}
+
+func (qfs *quotaFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	src, err := qfs.Stat(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	size := src.Size
+	if src.IsDir {
+		if options.NoRecursive {
+			size = 0
+		} else if size, err = qfs.usage(ctx, name); err != nil {
+			return false, err
+		}
+	}
+	replaced, err := qfs.treeSize(ctx, dest)
+	if err != nil {
+		return false, err
+	}
+
+	qfs.mu.Lock()
+	used, err := qfs.loadUsage(ctx)
+	if err != nil {
+		qfs.mu.Unlock()
+		return false, err
+	}
+	reserved := max(size-replaced, 0)
+	if used+qfs.reserved+reserved > qfs.quota {
+		qfs.mu.Unlock()
+		return false, errQuotaExceeded
+	}
+	qfs.reserved += reserved
+	qfs.mu.Unlock()
+
+	created, err := qfs.FileSystem.Copy(ctx, name, dest, options)
+	qfs.release(reserved, size-replaced, err)
+	return created, err
+}
+
+func (qfs *quotaFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	replaced, err := qfs.treeSize(ctx, dest)
+	if err != nil {
+		return false, err
+	}
+	created, err := qfs.FileSystem.Move(ctx, name, dest, options)
+	qfs.release(0, -replaced, err)
+	return created, err
+}
+
+func (qfs *quotaFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	removed, err := qfs.treeSize(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = qfs.FileSystem.RemoveAll(ctx, name, opts)
+	qfs.release(0, -removed, err)
+	return err
+}
+
+// quotaReader reserves the bytes of an upload as they are read, and fails
+// once the quota is exceeded. The size of the replaced file is available to
+// the upload.
+type quotaReader struct {
+	io.ReadCloser
+	qfs      *quotaFileSystem
+	replaced int64
+	read     int64
+	reserved int64
+}
+
+// reserve reserves the bytes needed by an upload of n bytes.
+func (r *quotaReader) reserve(ctx context.Context, n int64) error {
+	need := n - r.replaced - r.reserved
+	if need <= 0 {
+		return nil
+	}
+	qfs := r.qfs
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	used, err := qfs.loadUsage(ctx)
+	if err != nil {
+		return err
+	}
+	if used+qfs.reserved+need > qfs.quota {
+		return errQuotaExceeded
+	}
+	qfs.reserved += need
+	r.reserved += need
+	return nil
+}
+
+func (r *quotaReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.read += int64(n)
+	if rerr := r.reserve(context.Background(), r.read); rerr != nil {
+		return n, rerr
+	}
+	return n, err
+}
+
+// CacheOptions configures the Cache middleware.
+type CacheOptions struct {
+	// TTL is the duration for which metadata and collection members are
+	// cached.
+	TTL time.Duration
+}
+
+// Cache returns a FileSystemMiddleware caching the results of Stat and
+// ReadDir, like Handler.StatCacheTTL. Changes made through the FileSystem
+// invalidate the affected entries; changes made behind its back show up
+// once entries expire.
+func Cache(opts CacheOptions) FileSystemMiddleware {
+	return func(fs FileSystem) FileSystem {
+		return newStatCacheFileSystem(fs, opts.TTL)
+	}
+}
+
+// ThrottleOptions configures the Throttle middleware.
+type ThrottleOptions struct {
+	// MaxConcurrent is the maximum number of concurrent operations, reads
+	// counting until the files are closed. Zero means no limit.
+	MaxConcurrent int
+	// BytesPerSecond is the maximum rate at which files are read and
+	// written, shared by all transfers. Zero means no limit.
+	BytesPerSecond int64
+}
+
+// Throttle returns a FileSystemMiddleware bounding the load put on a
+// FileSystem, e.g. a shared disk or a metered object store. Operations wait
+// for their turn until their context is done.
+func Throttle(opts ThrottleOptions) FileSystemMiddleware {
+	return func(fs FileSystem) FileSystem {
+		tfs := &throttledFileSystem{fsDecorator: fsDecorator{fs}}
+		if opts.MaxConcurrent > 0 {
+			tfs.sem = make(chan struct{}, opts.MaxConcurrent)
+		}
+		if opts.BytesPerSecond > 0 {
+			tfs.rate = &byteRate{rate: float64(opts.BytesPerSecond)}
+		}
+		return tfs
+	}
+}
+
+type throttledFileSystem struct {
+	fsDecorator
+	sem  chan struct{}
+	rate *byteRate
+}
+
+// acquire waits for an operation slot. release must be called once the
+// operation is done.
+func (tfs *throttledFileSystem) acquire(ctx context.Context) (release func(), err error) {
+	if tfs.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case tfs.sem <- struct{}{}:
+		return func() { <-tfs.sem }, nil
+	case <-ctx.Done():
+		return nil, NewHTTPError(http.StatusServiceUnavailable, ctx.Err())
+	}
+}
+
+// reader throttles the reads of rc, and releases the operation slot once
+// closed. Seekable files stay seekable.
+func (tfs *throttledFileSystem) reader(ctx context.Context, rc io.ReadCloser, release func()) io.ReadCloser {
+	tr := &throttledReader{ReadCloser: rc, ctx: ctx, rate: tfs.rate, release: sync.OnceFunc(release)}
+	if s, ok := rc.(io.Seeker); ok {
+		return &throttledReadSeeker{tr, s}
+	}
+	return tr
+}
+
+func (tfs *throttledFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := tfs.FileSystem.Open(ctx, name)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return tfs.reader(ctx, rc, release), nil
+}
+
+// OpenRange implements RangeFileSystem, so that ranges read from the
+// underlying FileSystem are throttled too.
+func (tfs *throttledFileSystem) OpenRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	rfs, ok := fileSystemAs[RangeFileSystem](tfs.FileSystem)
+	if !ok {
+		rc, err := tfs.Open(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, rc, off); err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(rc, length), rc}, nil
+	}
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := rfs.OpenRange(ctx, name, off, length)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return tfs.reader(ctx, rc, release), nil
+}
+
+func (tfs *throttledFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return tfs.FileSystem.Stat(ctx, name)
+}
+
+func (tfs *throttledFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return tfs.FileSystem.ReadDir(ctx, name, recursive)
+}
+
+func (tfs *throttledFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return walk(ctx, tfs.FileSystem, name, recursive, fn)
+}
+
+func (tfs *throttledFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+	if tfs.rate != nil {
+		body = &throttledReader{ReadCloser: body, ctx: ctx, rate: tfs.rate, release: func() {}}
+	}
+	return tfs.FileSystem.Create(ctx, name, body, opts)
+}
+
+func (tfs *throttledFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return tfs.FileSystem.RemoveAll(ctx, name, opts)
+}
+
+func (tfs *throttledFileSystem) Mkdir(ctx context.Context, name string) error {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return tfs.FileSystem.Mkdir(ctx, name)
+}
+
+func (tfs *throttledFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return tfs.FileSystem.Copy(ctx, name, dest, options)
+}
+
+func (tfs *throttledFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	release, err := tfs.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return tfs.FileSystem.Move(ctx, name, dest, options)
+}
+
+// throttledChunk bounds the size of the reads of throttledReader, so that
+// transfers share the rate fairly.
+const throttledChunk = 32 << 10
+
+// throttledReader limits the rate of the reads from an io.ReadCloser.
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	rate    *byteRate
+	release func()
+}
+
+func (r *throttledReader) Read(b []byte) (int, error) {
+	if r.rate == nil {
+		return r.ReadCloser.Read(b)
+	}
+	if len(b) > throttledChunk {
+		b = b[:throttledChunk]
+	}
+	n, err := r.ReadCloser.Read(b)
+	if waitErr := r.rate.wait(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+func (r *throttledReader) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
+type throttledReadSeeker struct {
+	*throttledReader
+	s io.Seeker
+}
+
+func (r *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.s.Seek(offset, whence)
+}
+
+// byteRate paces transfers to a number of bytes per second, allowing
+// bursts of one second.
+type byteRate struct {
+	rate float64
+
+	mu   sync.Mutex
+	next time.Time // when the bytes transferred so far are due
+}
+
+// wait blocks until n more bytes may be transferred, or ctx is done.
+func (r *byteRate) wait(ctx context.Context, n int) error {
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	r.next = r.next.Add(time.Duration(float64(n) / r.rate * float64(time.Second)))
+	d := r.next.Sub(now) - time.Second
+	r.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FilterOptions configures the Filter middleware.
+type FilterOptions struct {
+	// Exclude lists patterns, in the syntax of path.Match, matched against
+	// each name of the paths of resources, e.g. ".*" to hide dotfiles or
+	// "*.tmp".
+	Exclude []string
+	// Func, if set, reports whether the resource name, an absolute path, is
+	// excluded too.
+	Func func(name string) bool
+}
+
+// Filter returns a FileSystemMiddleware hiding resources: they're missing
+// from collection listings, reading or removing them fails with "404 Not
+// Found" and creating them with "403 Forbidden". Excluded members of copied
+// or moved collections go along with them.
+func Filter(opts FilterOptions) FileSystemMiddleware {
+	return func(fs FileSystem) FileSystem {
+		return &filterFileSystem{fsDecorator{fs}, opts}
+	}
+}
+
+type filterFileSystem struct {
+	fsDecorator
+	opts FilterOptions
+}
+
+// excluded reports whether the resource name or one of its ancestors is
+// excluded.
+func (ffs *filterFileSystem) excluded(name string) bool {
+	name = path.Clean("/" + name)
+	for _, elem := range strings.Split(name[1:], "/") {
+		for _, pattern := range ffs.opts.Exclude {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return ffs.opts.Func != nil && ffs.opts.Func(name)
+}
+
+func (ffs *filterFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if ffs.excluded(name) {
+		return nil, ErrNotFound
+	}
+	return ffs.FileSystem.Open(ctx, name)
+}
+
+func (ffs *filterFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	if ffs.excluded(name) {
+		return nil, ErrNotFound
+	}
+	return ffs.FileSystem.Stat(ctx, name)
+}
+
+func (ffs *filterFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	if ffs.excluded(name) {
+		return nil, ErrNotFound
+	}
+	l, err := ffs.FileSystem.ReadDir(ctx, name, recursive)
+	if err != nil {
+		return nil, err
+	}
+	filtered := l[:0]
+	for _, fi := range l {
+		if !ffs.excluded(fi.Path) {
+			filtered = append(filtered, fi)
+		}
+	}
+	return filtered, nil
+}
+
+func (ffs *filterFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	if ffs.excluded(name) {
+		return ErrNotFound
+	}
+	return walk(ctx, ffs.FileSystem, name, recursive, func(fi *FileInfo) error {
+		if ffs.excluded(fi.Path) {
+			return nil
+		}
+		return fn(fi)
+	})
+}
+
+func (ffs *filterFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	if ffs.excluded(name) {
+		return nil, false, ErrForbidden
+	}
+	return ffs.FileSystem.Create(ctx, name, body, opts)
+}
+
+func (ffs *filterFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	if ffs.excluded(name) {
+		return ErrNotFound
+	}
+	return ffs.FileSystem.RemoveAll(ctx, name, opts)
+}
+
+func (ffs *filterFileSystem) Mkdir(ctx context.Context, name string) error {
+	if ffs.excluded(name) {
+		return ErrForbidden
+	}
+	return ffs.FileSystem.Mkdir(ctx, name)
+}
+
+func (ffs *filterFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	if ffs.excluded(name) {
+		return false, ErrNotFound
+	} else if ffs.excluded(dest) {
+		return false, ErrForbidden
+	}
+	return ffs.FileSystem.Copy(ctx, name, dest, options)
+}
+
+func (ffs *filterFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	if ffs.excluded(name) {
+		return false, ErrNotFound
+	} else if ffs.excluded(dest) {
+		return false, ErrForbidden
+	}
+	return ffs.FileSystem.Move(ctx, name, dest, options)
+}
+
+// LoggingOptions configures the Logging middleware.
+type LoggingOptions struct {
+	// Logger receives the records, defaults to slog.Default().
+	Logger *slog.Logger
+	// Level is the level of the records of successful operations, defaults
+	// to slog.LevelDebug. Failures are logged with slog.LevelWarn, or Level
+	// if higher.
+	Level slog.Leveler
+}
+
+// Logging returns a FileSystemMiddleware logging each operation on a
+// FileSystem with its path, duration and error, e.g. to debug a backend.
+// Reads and writes of file contents aren't logged individually.
+func Logging(opts LoggingOptions) FileSystemMiddleware {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelDebug
+	}
+	return func(fs FileSystem) FileSystem {
+		return &loggingFileSystem{fsDecorator{fs}, opts}
+	}
+}
+
+type loggingFileSystem struct {
+	fsDecorator
+	opts LoggingOptions
+}
+
+// log starts timing an operation. The returned function records it once
+// done, with the error pointed to by errp:
+//
+//	defer lfs.log(ctx, "stat", name, "")(&err)
+func (lfs *loggingFileSystem) log(ctx context.Context, op, name, dest string) func(errp *error) {
+	start := time.Now()
+	return func(errp *error) {
+		lfs.record(ctx, op, name, dest, start, *errp)
+	}
+}
+
+func (lfs *loggingFileSystem) record(ctx context.Context, op, name, dest string, start time.Time, err error) {
+	level := lfs.opts.Level.Level()
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("path", name),
+	}
+	if dest != "" {
+		attrs = append(attrs, slog.String("dest", dest))
+	}
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		level = max(level, slog.LevelWarn)
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if id, ok := RequestIDFromContext(ctx); ok {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	lfs.opts.Logger.LogAttrs(ctx, level, "webdav: file system operation", attrs...)
+}
+
+func (lfs *loggingFileSystem) Open(ctx context.Context, name string) (rc io.ReadCloser, err error) {
+	defer lfs.log(ctx, "open", name, "")(&err)
+	return lfs.FileSystem.Open(ctx, name)
+}
+
+func (lfs *loggingFileSystem) Stat(ctx context.Context, name string) (fi *FileInfo, err error) {
+	defer lfs.log(ctx, "stat", name, "")(&err)
+	return lfs.FileSystem.Stat(ctx, name)
+}
+
+func (lfs *loggingFileSystem) ReadDir(ctx context.Context, name string, recursive bool) (l []FileInfo, err error) {
+	defer lfs.log(ctx, "readdir", name, "")(&err)
+	return lfs.FileSystem.ReadDir(ctx, name, recursive)
+}
+
+func (lfs *loggingFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) (err error) {
+	defer lfs.log(ctx, "walk", name, "")(&err)
+	return walk(ctx, lfs.FileSystem, name, recursive, fn)
+}
+
+func (lfs *loggingFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	defer lfs.log(ctx, "create", name, "")(&err)
+	return lfs.FileSystem.Create(ctx, name, body, opts)
+}
+
+func (lfs *loggingFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) (err error) {
+	defer lfs.log(ctx, "removeall", name, "")(&err)
+	return lfs.FileSystem.RemoveAll(ctx, name, opts)
+}
+
+func (lfs *loggingFileSystem) Mkdir(ctx context.Context, name string) (err error) {
+	defer lfs.log(ctx, "mkdir", name, "")(&err)
+	return lfs.FileSystem.Mkdir(ctx, name)
+}
+
+func (lfs *loggingFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (created bool, err error) {
+	defer lfs.log(ctx, "copy", name, dest)(&err)
+	return lfs.FileSystem.Copy(ctx, name, dest, options)
+}
+
+func (lfs *loggingFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (created bool, err error) {
+	defer lfs.log(ctx, "move", name, dest)(&err)
+	return lfs.FileSystem.Move(ctx, name, dest, options)
+}
@@ -0,0 +1,269 @@
+package webdav_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestQuota(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": strings.Repeat("a", 40)})
+	h := &webdav.Handler{FileSystem: webdav.Quota(webdav.QuotaOptions{MaxBytes: 100})(webdav.LocalFileSystem(dir))}
+	body := func(n int) string { return strings.Repeat("x", n) }
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodPut, target: "/b.txt", body: body(61)}, http.StatusInsufficientStorage},
+		{testRequest{method: http.MethodPut, target: "/b.txt", body: body(60)}, http.StatusCreated},
+		// Replaced content is freed
+		{testRequest{method: http.MethodPut, target: "/a.txt", body: body(40)}, http.StatusNoContent},
+		{testRequest{method: http.MethodPut, target: "/a.txt", body: body(41)}, http.StatusInsufficientStorage},
+		{testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusInsufficientStorage},
+		// Overwritten destinations are freed
+		{testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/b.txt"}}, http.StatusNoContent},
+		{testRequest{method: "MOVE", target: "/a.txt", header: map[string]string{"Destination": "/b.txt"}}, http.StatusNoContent},
+		{testRequest{method: http.MethodPut, target: "/c.txt", body: body(60)}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/d.txt", body: body(1)}, http.StatusInsufficientStorage},
+		// Removed files are freed
+		{testRequest{method: http.MethodDelete, target: "/c.txt"}, http.StatusNoContent},
+		{testRequest{method: http.MethodPut, target: "/d.txt", body: body(60)}, http.StatusCreated},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, tc.want)
+	}
+	checkMissing(t, dir, "a.txt")
+	checkMissing(t, dir, "c.txt")
+}
+
+// slowReader delays reads, so that concurrent uploads overlap.
+type slowReader struct {
+	io.Reader
+}
+
+func (r slowReader) Read(b []byte) (int, error) {
+	time.Sleep(10 * time.Millisecond)
+	return r.Reader.Read(b)
+}
+
+func TestQuotaConcurrentUploads(t *testing.T) {
+	for _, declared := range []bool{true, false} {
+		dir := t.TempDir()
+		fs := webdav.Quota(webdav.QuotaOptions{MaxBytes: 100})(webdav.LocalFileSystem(dir))
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			uploaded int
+		)
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				opts := &webdav.CreateOptions{}
+				if declared {
+					opts.ContentLength = 40
+				}
+				body := io.NopCloser(slowReader{strings.NewReader(strings.Repeat("x", 40))})
+				_, _, err := fs.Create(t.Context(), "/"+string(rune('a'+i))+".txt", body, opts)
+				if errors.Is(err, webdav.ErrQuotaExceeded) {
+					return
+				} else if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				uploaded++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		names, err := readDirNames(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uploaded != 2 || len(names) != 2 {
+			t.Errorf("declared length %v: %v uploads succeeded, stored %v, want 2", declared, uploaded, names)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/a.txt": "a", "b.txt": "b"})
+	h := &webdav.Handler{FileSystem: webdav.ReadOnly(webdav.ReadOnlyOptions{Paths: []string{"docs"}})(webdav.LocalFileSystem(dir))}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodPut, target: "/docs/a.txt", body: "x"}, http.StatusForbidden},
+		{testRequest{method: http.MethodPut, target: "/docs/c.txt", body: "x"}, http.StatusForbidden},
+		{testRequest{method: http.MethodDelete, target: "/docs/"}, http.StatusForbidden},
+		{testRequest{method: "MKCOL", target: "/docs/sub/"}, http.StatusForbidden},
+		{testRequest{method: "MOVE", target: "/docs/a.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/b.txt", header: map[string]string{"Destination": "/docs/b.txt"}}, http.StatusForbidden},
+		// Reading and copying out of read-only collections is allowed
+		{testRequest{method: http.MethodGet, target: "/docs/a.txt"}, http.StatusOK},
+		{testRequest{method: "COPY", target: "/docs/a.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/b.txt", body: "x"}, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, tc.want)
+	}
+	checkFile(t, dir, "docs/a.txt", "a")
+	checkFile(t, dir, "c.txt", "a")
+	checkMissing(t, dir, "docs/c.txt")
+}
+
+func TestFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", ".secret": "s", "docs/.git/config": "c", "docs/b.txt": "b"})
+	h := &webdav.Handler{FileSystem: webdav.Filter(webdav.FilterOptions{
+		Exclude: []string{".*"},
+		Func:    func(name string) bool { return strings.HasSuffix(name, ".tmp") },
+	})(webdav.LocalFileSystem(dir))}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodGet, target: "/.secret"}, http.StatusNotFound},
+		{testRequest{method: http.MethodGet, target: "/docs/.git/config"}, http.StatusNotFound},
+		{testRequest{method: http.MethodDelete, target: "/.secret"}, http.StatusNotFound},
+		{testRequest{method: http.MethodPut, target: "/.hidden", body: "x"}, http.StatusForbidden},
+		{testRequest{method: http.MethodPut, target: "/c.tmp", body: "x"}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/.a.txt"}}, http.StatusForbidden},
+		{testRequest{method: http.MethodGet, target: "/docs/b.txt"}, http.StatusOK},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, tc.want)
+	}
+
+	rec := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "infinity"}}, http.StatusMultiStatus)
+	if body := rec.Body.String(); strings.Contains(body, ".secret") || strings.Contains(body, ".git") || !strings.Contains(body, "<href>/docs/b.txt</href>") {
+		t.Errorf("PROPFIND response = %v, want the excluded resources hidden", body)
+	}
+	checkFile(t, dir, ".secret", "s")
+	checkMissing(t, dir, ".hidden")
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	counting := &countingFileSystem{FileSystem: webdav.LocalFileSystem(dir)}
+	fs := webdav.Cache(webdav.CacheOptions{TTL: time.Hour})(counting)
+
+	for range 3 {
+		if _, err := fs.Stat(t.Context(), "/a.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := counting.lookups.Load(); n != 1 {
+		t.Errorf("cached Stat made %v lookups, want 1", n)
+	}
+
+	// Changes made through the FileSystem invalidate the cache
+	if _, _, err := fs.Create(t.Context(), "/a.txt", io.NopCloser(strings.NewReader("aa")), &webdav.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fs.Stat(t.Context(), "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size != 2 {
+		t.Errorf("size = %v after Create, want 2", fi.Size)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": strings.Repeat("b", 3000)})
+
+	// Open files hold their slot until closed
+	fs := webdav.Throttle(webdav.ThrottleOptions{MaxConcurrent: 1})(webdav.LocalFileSystem(dir))
+	rc, err := fs.Open(t.Context(), "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fs.Stat(ctx, "/a.txt"); webdav.HTTPStatus(err) != http.StatusServiceUnavailable {
+		t.Errorf("Stat() with a busy slot = %v, want 503", err)
+	}
+	rc.Close()
+	if _, err := fs.Stat(t.Context(), "/a.txt"); err != nil {
+		t.Errorf("Stat() after Close() = %v", err)
+	}
+
+	// Transfers beyond the rate wait until their context is done
+	fs = webdav.Throttle(webdav.ThrottleOptions{BytesPerSecond: 1000})(webdav.LocalFileSystem(dir))
+	ctx, cancel = context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	rc, err = fs.Open(ctx, "/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadAll() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	h := &webdav.Handler{FileSystem: webdav.Logging(webdav.LoggingOptions{Logger: logger, Level: slog.LevelInfo})(webdav.LocalFileSystem(dir))}
+
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/a.txt", header: map[string]string{"Destination": "/b.txt"}}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/missing.txt"}, http.StatusNotFound)
+
+	out := buf.String()
+	for _, want := range []string{
+		"level=INFO msg=\"webdav: file system operation\" op=move path=/a.txt dest=/b.txt",
+		"level=WARN msg=\"webdav: file system operation\" op=stat path=/missing.txt",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs = %v, want %q", out, want)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".secret": "s"})
+	var buf bytes.Buffer
+	logging := webdav.Logging(webdav.LoggingOptions{Logger: slog.New(slog.NewTextHandler(&buf, nil)), Level: slog.LevelInfo})
+	filter := webdav.Filter(webdav.FilterOptions{Exclude: []string{".*"}})
+
+	// The first middleware sees calls first
+	for _, tc := range []struct {
+		fs     webdav.FileSystem
+		logged bool
+	}{
+		{webdav.Chain(filter, logging)(webdav.LocalFileSystem(dir)), false},
+		{webdav.Chain(logging, filter)(webdav.LocalFileSystem(dir)), true},
+	} {
+		buf.Reset()
+		if _, err := tc.fs.Stat(t.Context(), "/.secret"); !errors.Is(err, webdav.ErrNotFound) {
+			t.Errorf("Stat() = %v, want %v", err, webdav.ErrNotFound)
+		}
+		if logged := strings.Contains(buf.String(), "path=/.secret"); logged != tc.logged {
+			t.Errorf("logged = %v, want %v", logged, tc.logged)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package webdav
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MIMETypes is a registry of media types, mapping file name extensions to
+// media types, and media types to their default charset. It overrides the
+// tables of the mime package, which are global and loaded from the system.
+//
+// The package-level registry, see AddMIMEType and SetDefaultCharset, applies
+// to all handlers and to the MIMEType of the FileInfos of LocalFileSystem and
+// RootFileSystem. A registry set as Handler.MIMETypes takes precedence for
+// the handler. The zero value is an empty registry, safe for concurrent use.
+// Lookups on a nil registry only consult the package-level one.
+type MIMETypes struct {
+	mu       sync.RWMutex
+	types    map[string]string
+	charsets map[string]string
+}
+
+// defaultMIMETypes is the package-level registry.
+var defaultMIMETypes MIMETypes
+
+// AddMIMEType registers typ as the media type of the files with the extension
+// ext, e.g. ".heic", in the package-level registry.
+func AddMIMEType(ext, typ string) error {
+	return defaultMIMETypes.Add(ext, typ)
+}
+
+// SetDefaultCharset sets the charset added to the media type typ, e.g.
+// "text/markdown", when it has none, in the package-level registry. An empty
+// charset removes the default.
+func SetDefaultCharset(typ, charset string) {
+	defaultMIMETypes.SetDefaultCharset(typ, charset)
+}
+
+// Add registers typ as the media type of the files with the extension ext,
+// which starts with a dot. Extensions are matched case-insensitively.
+func (m *MIMETypes) Add(ext, typ string) error {
+	if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+		return fmt.Errorf("webdav: invalid file name extension %q", ext)
+	}
+	if _, _, err := mime.ParseMediaType(typ); err != nil {
+		return fmt.Errorf("webdav: invalid media type %q: %w", typ, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.types == nil {
+		m.types = make(map[string]string)
+	}
+	m.types[strings.ToLower(ext)] = typ
+	return nil
+}
+
+// SetDefaultCharset sets the charset added to the media type typ when it has
+// none. An empty charset removes the default.
+func (m *MIMETypes) SetDefaultCharset(typ, charset string) {
+	typ = strings.ToLower(typ)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if charset == "" {
+		delete(m.charsets, typ)
+		return
+	}
+	if m.charsets == nil {
+		m.charsets = make(map[string]string)
+	}
+	m.charsets[typ] = charset
+}
+
+// TypeByExtension returns the media type of the files with the extension ext,
+// looked up in m, then in the package-level registry, then with the mime
+// package, with its default charset. It returns an empty string if the
+// extension is unknown.
+func (m *MIMETypes) TypeByExtension(ext string) string {
+	typ := m.lookup(ext)
+	if typ == "" && m != &defaultMIMETypes {
+		typ = defaultMIMETypes.lookup(ext)
+	}
+	if typ == "" {
+		typ = mime.TypeByExtension(ext)
+	}
+	return m.withCharset(typ)
+}
+
+// ExtensionByType returns the extension of the files of the media type typ,
+// looked up like TypeByExtension. It returns an empty string if the media
+// type is unknown.
+func (m *MIMETypes) ExtensionByType(typ string) string {
+	base, _, err := mime.ParseMediaType(typ)
+	if err != nil {
+		return ""
+	}
+	if ext := m.extension(base); ext != "" {
+		return ext
+	}
+	if m != &defaultMIMETypes {
+		if ext := defaultMIMETypes.extension(base); ext != "" {
+			return ext
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(base); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// lookup returns the media type registered in m for ext.
+func (m *MIMETypes) lookup(ext string) string {
+	if m == nil || ext == "" {
+		return ""
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.types[strings.ToLower(ext)]
+}
+
+// extension returns the first extension, in lexical order, registered in m
+// for the media type base.
+func (m *MIMETypes) extension(base string) string {
+	if m == nil {
+		return ""
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var exts []string
+	for ext, typ := range m.types {
+		if t, _, err := mime.ParseMediaType(typ); err == nil && t == base {
+			exts = append(exts, ext)
+		}
+	}
+	if len(exts) == 0 {
+		return ""
+	}
+	return slices.Min(exts)
+}
+
+// withCharset adds the default charset of typ, from m or the package-level
+// registry, unless it already has one.
+func (m *MIMETypes) withCharset(typ string) string {
+	if typ == "" {
+		return ""
+	}
+	base, params, err := mime.ParseMediaType(typ)
+	if err != nil || params["charset"] != "" {
+		return typ
+	}
+	charset := m.charset(base)
+	if charset == "" && m != &defaultMIMETypes {
+		charset = defaultMIMETypes.charset(base)
+	}
+	if charset == "" {
+		return typ
+	}
+	params["charset"] = charset
+	return mime.FormatMediaType(base, params)
+}
+
+func (m *MIMETypes) charset(base string) string {
+	if m == nil {
+		return ""
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.charsets[base]
+}
@@ -0,0 +1,99 @@
+package webdav_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestMIMETypes(t *testing.T) {
+	var m webdav.MIMETypes
+	for _, ext := range []string{"heic", ".", ""} {
+		if err := m.Add(ext, "image/heic"); err == nil {
+			t.Errorf("Add(%q) = nil, want an error", ext)
+		}
+	}
+	if err := m.Add(".heic", "not a type/"); err == nil {
+		t.Errorf("Add() of an invalid media type = nil, want an error")
+	}
+	if err := m.Add(".HEIC", "image/heic"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(".md", "text/markdown"); err != nil {
+		t.Fatal(err)
+	}
+	m.SetDefaultCharset("text/markdown", "utf-8")
+
+	tests := []struct {
+		ext, want string
+	}{
+		{".heic", "image/heic"},
+		{".Heic", "image/heic"},
+		{".md", "text/markdown; charset=utf-8"},
+		// Unregistered extensions fall back to the mime package
+		{".png", "image/png"},
+		{".unknown-ext", ""},
+	}
+	for _, tc := range tests {
+		if got := m.TypeByExtension(tc.ext); got != tc.want {
+			t.Errorf("TypeByExtension(%q) = %q, want %q", tc.ext, got, tc.want)
+		}
+	}
+	if ext := m.ExtensionByType("image/heic; q=1"); ext != ".heic" {
+		t.Errorf("ExtensionByType() = %q, want .heic", ext)
+	}
+
+	m.SetDefaultCharset("text/markdown", "")
+	if got := m.TypeByExtension(".md"); got != "text/markdown" {
+		t.Errorf("TypeByExtension() after removing the charset = %q, want text/markdown", got)
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"a.png", "", "image/png"},
+		{"a.unknown-ext", "%PDF-1.7", "application/pdf"},
+		{"noext", "<html><body></body></html>", "text/html; charset=utf-8"},
+	}
+	for _, tc := range tests {
+		if got := webdav.DetectContentType(tc.name, strings.NewReader(tc.content)); got != tc.want {
+			t.Errorf("DetectContentType(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHandlerMIMETypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.heic": "heic", "notes.md": "# notes", "page": "<html><body></body></html>"})
+	m := &webdav.MIMETypes{}
+	m.Add(".heic", "image/heic")
+	m.Add(".md", "text/markdown")
+	m.SetDefaultCharset("text/markdown", "utf-8")
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), MIMETypes: m}
+
+	for target, want := range map[string]string{
+		"/a.heic":   "image/heic",
+		"/notes.md": "text/markdown; charset=utf-8",
+		"/page":     "text/html; charset=utf-8",
+	} {
+		rec := checkStatus(t, h, testRequest{method: http.MethodGet, target: target}, http.StatusOK)
+		if ct := rec.Header().Get("Content-Type"); ct != want {
+			t.Errorf("GET %v: Content-Type = %q, want %q", target, ct, want)
+		}
+	}
+
+	rec := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.heic", header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	if body := rec.Body.String(); !strings.Contains(body, ">image/heic</getcontenttype>") {
+		t.Errorf("PROPFIND response = %v, want the registered content type", body)
+	}
+
+	// Members added with POST are named after their content type
+	rec = checkStatus(t, h, testRequest{method: http.MethodPost, target: "/", body: "heic", header: map[string]string{"Content-Type": "image/heic"}}, http.StatusCreated)
+	if loc := rec.Header().Get("Location"); !strings.HasSuffix(loc, ".heic") {
+		t.Errorf("Location = %q, want a .heic member", loc)
+	}
+}
@@ -0,0 +1,77 @@
+package webdav
+
+import (
+	"path"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// mount is a WebDAV server mounted on a URL path prefix.
+type mount struct {
+	prefix  string
+	handler fiber.Handler
+}
+
+// cleanPrefix normalizes a mount prefix to an absolute path without trailing
+// slash, "/" being the root.
+func cleanPrefix(prefix string) string {
+	return path.Clean("/" + prefix)
+}
+
+// newMountsHandler creates a handler serving each request with the mount
+// whose prefix matches the request path. Requests outside of all mounts are
+// passed to the next handler.
+func newMountsHandler(mounts []*mount) fiber.Handler {
+	// Prefer the most specific mount
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+
+	return func(c *fiber.Ctx) error {
+		p := fiberPath(c)
+		m := matchMount(mounts, p)
+		if m == nil && c.Method() == fiber.MethodOptions {
+			m = matchDiscoveryMount(mounts, p)
+		}
+		if m == nil {
+			return c.Next()
+		}
+
+		if method := c.Method(); method == MethodCopy || method == MethodMove {
+			if dest, err := internal.ParseDestination(c.Get("Destination")); err == nil {
+				if matchMount(mounts, dest.Path) != m {
+					// Resources can't be copied or moved across mounts, which
+					// are handled like different servers
+					return c.Status(fiber.StatusBadGateway).SendString("webdav: destination is on another mount")
+				}
+			}
+		}
+
+		return m.handler(c)
+	}
+}
+
+func matchMount(mounts []*mount, p string) *mount {
+	p = path.Clean(p)
+	for _, m := range mounts {
+		if isDescendant(p, m.prefix) {
+			return m
+		}
+	}
+	return nil
+}
+
+// matchDiscoveryMount returns the mount answering OPTIONS requests for "*" or
+// for a parent of the mounts, sent by clients to discover WebDAV support: the
+// least specific one.
+func matchDiscoveryMount(mounts []*mount, p string) *mount {
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if p == "*" || isDescendant(mounts[i].prefix, path.Clean(p)) {
+			return mounts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package webdav_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestMounts(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeFiles(t, dir1, map[string]string{"a.txt": "a1", "sub/a.txt": "shadowed"})
+	writeFiles(t, dir2, map[string]string{"a.txt": "a2"})
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(webdav.New(
+		webdav.Config{Prefix: "/dav", Root: webdav.LocalFileSystem(dir1)},
+		webdav.Config{Prefix: "/dav/sub/", Root: webdav.LocalFileSystem(dir2)},
+	))
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusTeapot)
+	})
+
+	do := func(method, target string, header map[string]string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	// The most specific mount serves requests
+	for target, want := range map[string]string{"/dav/a.txt": "a1", "/dav/sub/a.txt": "a2"} {
+		if status, body := do(http.MethodGet, target, nil); status != http.StatusOK || body != want {
+			t.Errorf("GET %v = %v %q, want 200 %q", target, status, body, want)
+		}
+	}
+
+	// Requests outside of the mounts are passed to the next handler
+	for _, target := range []string{"/a.txt", "/davx/a.txt"} {
+		if status, _ := do(http.MethodGet, target, nil); status != http.StatusTeapot {
+			t.Errorf("GET %v = %v, want the next handler", target, status)
+		}
+	}
+
+	// Clients discover WebDAV support on parents of the mounts
+	if status, _ := do(http.MethodOptions, "/", nil); status != http.StatusNoContent {
+		t.Errorf("OPTIONS / = %v, want 204", status)
+	}
+
+	// Resources can't be copied across nested mounts
+	for _, tc := range []struct {
+		target, dest string
+		want         int
+	}{
+		{"/dav/a.txt", "/dav/sub/b.txt", http.StatusBadGateway},
+		{"/dav/sub/a.txt", "/dav/b.txt", http.StatusBadGateway},
+		{"/dav/sub/a.txt", "/dav/sub/b.txt", http.StatusCreated},
+	} {
+		if status, _ := do("COPY", tc.target, map[string]string{"Destination": tc.dest}); status != tc.want {
+			t.Errorf("COPY %v to %v = %v, want %v", tc.target, tc.dest, status, tc.want)
+		}
+	}
+	checkMissing(t, dir1, "b.txt")
+	checkMissing(t, dir1, "sub/b.txt")
+	checkFile(t, dir2, "b.txt", "a2")
+
+	// A configuration is required
+	app = fiber.New()
+	app.Use(webdav.New())
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET without configuration = %v, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,42 @@
+package webdav_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestOCMtime(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		if name == "legacy" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			w := checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a", header: map[string]string{"X-OC-Mtime": "1700000000"}}, http.StatusCreated)
+			if got := w.Header().Get("X-OC-Mtime"); got != "accepted" {
+				t.Errorf("X-OC-Mtime = %q, want accepted", got)
+			}
+			fi, err := os.Stat(filepath.Join(dir, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := time.Unix(1700000000, 0); !fi.ModTime().Equal(want) {
+				t.Errorf("modification time = %v, want %v", fi.ModTime(), want)
+			}
+
+			w = checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated)
+			if got := w.Header().Get("X-OC-Mtime"); got != "" {
+				t.Errorf("X-OC-Mtime = %q without a modification time", got)
+			}
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/c.txt", body: "c", header: map[string]string{"X-OC-Mtime": "yesterday"}}, http.StatusBadRequest)
+			checkMissing(t, dir, "c.txt")
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package webdav
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// NamePolicy restricts the names of the resources created by clients, so
+// that shares stay usable from every platform. It applies to the targets of
+// PUT and MKCOL requests and to the destinations of COPY and MOVE requests.
+// Rejected requests get "400 Bad Request" describing the problem. Existing
+// resources can still be read and removed.
+type NamePolicy struct {
+	// MaxDepth, if positive, is the maximum number of segments of paths
+	// below the mount prefix.
+	MaxDepth int
+	// MaxNameLength, if positive, is the maximum length of names in bytes.
+	MaxNameLength int
+	// RejectReserved rejects the device names reserved by Windows, such as
+	// CON, NUL or COM1, with or without an extension.
+	RejectReserved bool
+	// RejectControl rejects names containing control characters.
+	RejectControl bool
+	// RejectTrailing rejects names ending with a dot or a space, which
+	// Windows strips.
+	RejectTrailing bool
+}
+
+// PortableNames is a NamePolicy accepting names valid on Windows, macOS and
+// Linux filesystems.
+var PortableNames = NamePolicy{
+	MaxNameLength:  255,
+	RejectReserved: true,
+	RejectControl:  true,
+	RejectTrailing: true,
+}
+
+// check returns an error if the policy rejects the resource name.
+func (p *NamePolicy) check(name string) error {
+	name = path.Clean(name)
+	if name == "/" {
+		return nil
+	}
+	if p.MaxDepth > 0 && strings.Count(name, "/") > p.MaxDepth {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: path %q is deeper than %d levels", name, p.MaxDepth)
+	}
+
+	base := path.Base(name)
+	if p.MaxNameLength > 0 && len(base) > p.MaxNameLength {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: name %q is longer than %d bytes", base, p.MaxNameLength)
+	}
+	if p.RejectControl && strings.ContainsFunc(base, isControl) {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: name %q contains control characters", base)
+	}
+	if p.RejectTrailing && (strings.HasSuffix(base, ".") || strings.HasSuffix(base, " ")) {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: name %q ends with a dot or a space", base)
+	}
+	if p.RejectReserved && isReservedName(base) {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: name %q is reserved on Windows", base)
+	}
+	return nil
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// reservedNames are the device names of Windows.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"COM¹": true, "COM²": true, "COM³": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	"LPT¹": true, "LPT²": true, "LPT³": true,
+}
+
+// isReservedName reports whether Windows treats a name as a device, which is
+// case-insensitive and ignores extensions and trailing spaces.
+func isReservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))]
+}
+
+// checkNames returns an error if the NamePolicy rejects the resource created
+// by a request.
+func (b *backend) checkNames(r *http.Request) error {
+	if b.NamePolicy == nil {
+		return nil
+	}
+	switch r.Method {
+	case http.MethodPut, "MKCOL":
+		return b.NamePolicy.check(r.URL.Path)
+	case "COPY", "MOVE":
+		dest, err := internal.ParseDestination(r.Header.Get("Destination"))
+		if err != nil {
+			// Let the handler reject the request
+			return nil
+		}
+		destPath, err := b.destinationPath((*internal.Href)(dest))
+		if err != nil {
+			return nil
+		}
+		return b.NamePolicy.check(destPath)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestNamePolicy(t *testing.T) {
+	policy := webdav.PortableNames
+	policy.MaxDepth = 3
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"a.txt", true},
+		{"con.txt", false},
+		{"CON", false},
+		{"Con .txt", false},
+		{"COM¹", false},
+		{"com1.tar.gz", false},
+		{"console.txt", true},
+		{"COM10", true},
+		{"a\x01b.txt", false},
+		{"a\x7fb.txt", false},
+		{"a.", false},
+		{"a ", false},
+		{".a", true},
+		{strings.Repeat("a", 255), true},
+		{strings.Repeat("a", 256), false},
+		{"x/y/z.txt", true},
+		{"x/y/z/w.txt", false},
+	}
+	for _, method := range []string{http.MethodPut, "MKCOL", "COPY", "MOVE"} {
+		for _, tc := range tests {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"src.txt": "src", "x/y/z/": ""})
+			h := &webdav.Handler{Prefix: "/dav", FileSystem: webdav.LocalFileSystem(dir), NamePolicy: &policy}
+
+			target := (&url.URL{Path: "/dav/" + tc.name}).EscapedPath()
+			req := testRequest{method: method, target: target}
+			switch method {
+			case http.MethodPut:
+				req.body = "new"
+			case "COPY", "MOVE":
+				req.target = "/dav/src.txt"
+				req.header = map[string]string{"Destination": target}
+			}
+			w := serve(t, h, req)
+			if tc.ok && w.Code >= 300 {
+				t.Errorf("%v %q = %v, want success\n%s", method, tc.name, w.Code, w.Body)
+			} else if !tc.ok && w.Code != http.StatusBadRequest {
+				t.Errorf("%v %q = %v, want 400", method, tc.name, w.Code)
+			}
+		}
+	}
+}
+
+func TestNamePolicyExisting(t *testing.T) {
+	// Existing resources can still be read and removed
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), NamePolicy: &webdav.PortableNames}
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a."}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.", body: "b"}, http.StatusBadRequest)
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/a."}, http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package webdav_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestOptionsLocking(t *testing.T) {
+	tests := []struct {
+		name   string
+		config webdav.Config
+		dav    string
+		lock   int
+		unlock int
+	}{
+		{"without locks", webdav.Config{}, "1, 3", http.StatusMethodNotAllowed, http.StatusMethodNotAllowed},
+		{"with locks", webdav.Config{Lock: true}, "1, 2, 3", http.StatusOK, http.StatusNoContent},
+		{"with a lock system", webdav.Config{LockSystem: webdav.NewLockSystem()}, "1, 2, 3", http.StatusOK, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "a"})
+			tc.config.Root = webdav.LocalFileSystem(dir)
+			h := webdav.NewServer(tc.config).HTTPHandler()
+
+			w := checkStatus(t, h, testRequest{method: http.MethodOptions, target: "/a.txt"}, http.StatusNoContent)
+			if dav := w.Header().Get("DAV"); dav != tc.dav {
+				t.Errorf("DAV = %q, want %q", dav, tc.dav)
+			}
+			if allow := w.Header().Get("Allow"); strings.Contains(allow, "LOCK") != (tc.lock == http.StatusOK) {
+				t.Errorf("Allow = %q", allow)
+			}
+			w = checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, tc.lock)
+			token := w.Header().Get("Lock-Token")
+			if token == "" {
+				token = "<opaquelocktoken:missing>"
+			}
+			checkStatus(t, h, testRequest{method: "UNLOCK", target: "/a.txt", header: map[string]string{"Lock-Token": token}}, tc.unlock)
+		})
+	}
+}
+
+func TestSeparateLockSystems(t *testing.T) {
+	// Each server has its own locks
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h1 := webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(dir), Lock: true}).HTTPHandler()
+	h2 := webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(dir), Lock: true}).HTTPHandler()
+	checkStatus(t, h1, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+	checkStatus(t, h2, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+}
+
+func TestOptionsDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{Prefix: "/dav/files", FileSystem: webdav.LocalFileSystem(dir), ExtraMethods: []string{"SEARCH"}}
+
+	tests := []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodOptions, "*", http.StatusNoContent},
+		{http.MethodOptions, "/", http.StatusNoContent},
+		{http.MethodOptions, "/dav", http.StatusNoContent},
+		{http.MethodOptions, "/dav/files/a.txt", http.StatusNoContent},
+		// Only OPTIONS requests are answered outside of the mount
+		{http.MethodOptions, "/other", http.StatusNotFound},
+		{http.MethodOptions, "/dav/other", http.StatusNotFound},
+		{"PROPFIND", "/dav", http.StatusNotFound},
+		{http.MethodGet, "/", http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		w := checkStatus(t, h, testRequest{method: tc.method, target: tc.target}, tc.want)
+		if tc.want != http.StatusNoContent {
+			continue
+		}
+		if dav := w.Header().Get("DAV"); dav == "" {
+			t.Errorf("OPTIONS %v: no DAV header field", tc.target)
+		}
+		if allow := w.Header().Get("Allow"); !strings.Contains(allow, "PROPFIND") || !strings.Contains(allow, "SEARCH") {
+			t.Errorf("OPTIONS %v: Allow = %q, want PROPFIND and SEARCH", tc.target, allow)
+		}
+	}
+}
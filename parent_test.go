@@ -0,0 +1,39 @@
+package webdav_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestMissingParent(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "a", "c/": "", "e.txt": "e"})
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name], LockSystem: webdav.NewLockSystem()}
+
+			// Parents which don't exist or are files conflict
+			for _, parent := range []string{"/missing", "/e.txt"} {
+				for _, req := range []testRequest{
+					{method: http.MethodPut, target: parent + "/b.txt", body: "b"},
+					{method: "MKCOL", target: parent + "/d"},
+					{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": parent + "/b.txt"}},
+					{method: "COPY", target: "/c", header: map[string]string{"Destination": parent + "/d"}},
+					{method: "MOVE", target: "/a.txt", header: map[string]string{"Destination": parent + "/b.txt"}},
+					{method: "LOCK", target: parent + "/b.txt", body: lockBody},
+				} {
+					checkStatus(t, h, req, http.StatusConflict)
+				}
+				checkStatus(t, h, testRequest{method: http.MethodGet, target: parent + "/b.txt"}, http.StatusNotFound)
+			}
+			checkFile(t, dir, "a.txt", "a")
+			checkFile(t, dir, "e.txt", "e")
+			checkMissing(t, dir, "missing")
+
+			// Unmapped URLs in existing collections can be locked
+			checkStatus(t, h, testRequest{method: "LOCK", target: "/c/b.txt", body: lockBody}, http.StatusCreated)
+		})
+	}
+}
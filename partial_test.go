@@ -0,0 +1,112 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// partialFileSystem fails to remove some members of collections.
+type partialFileSystem struct {
+	webdav.FileSystem
+	errs []webdav.MemberError
+}
+
+func (fs partialFileSystem) RemoveAll(ctx context.Context, name string, opts *webdav.RemoveAllOptions) error {
+	return &webdav.PartialError{Errors: fs.errs}
+}
+
+func TestDeletePartialFailure(t *testing.T) {
+	fs := partialFileSystem{FileSystem: webdav.LocalFileSystem(t.TempDir()), errs: []webdav.MemberError{
+		{Path: "/d/a.txt", Err: webdav.NewHTTPError(http.StatusForbidden, errors.New("denied"))},
+		{Path: "/d/e", Err: webdav.ErrLocked},
+	}}
+	h := &webdav.Handler{Prefix: "/dav", FileSystem: fs}
+	w := checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/dav/d"}, http.StatusMultiStatus)
+	body := w.Body.String()
+	for _, s := range []string{"<href>/dav/d/a.txt</href>", "403 Forbidden", "<href>/dav/d/e</href>", "423 Locked"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("multistatus without %v\n%s", s, body)
+		}
+	}
+
+	// Failures of the resource itself aren't partial
+	h = &webdav.Handler{FileSystem: webdav.LocalFileSystem(t.TempDir())}
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/missing"}, http.StatusNotFound)
+}
+
+func TestLocalFileSystemDeletePartialFailure(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't apply to root")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"d/a.txt": "a", "d/e/b.txt": "b"})
+	if err := os.Chmod(filepath.Join(dir, "d/e"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(dir, "d/e"), 0755) })
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/d"}, http.StatusMultiStatus)
+	// The collections containing the member aren't reported
+	if body := w.Body.String(); !strings.Contains(body, "<href>/d/e/b.txt</href>") || strings.Count(body, "<response ") != 1 {
+		t.Errorf("multistatus doesn't list /d/e/b.txt only\n%s", body)
+	}
+	checkMissing(t, dir, "d/a.txt")
+	checkFile(t, dir, "d/e/b.txt", "b")
+}
+
+// writeUnreadableTree writes the collection d, with a member which can't be
+// read, to dir.
+func writeUnreadableTree(t *testing.T, dir string) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{"d/a.txt": "a", "d/c/b.txt": "b"})
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "d/c/link")); err != nil {
+		t.Skip(err)
+	}
+}
+
+func TestCopyPartialFailure(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		if name == "legacy" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeUnreadableTree(t, dir)
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+
+			// Members which can't be read fail alone
+			w := checkStatus(t, h, testRequest{method: "COPY", target: "/d", header: map[string]string{"Destination": "/e"}}, http.StatusMultiStatus)
+			if body := w.Body.String(); !strings.Contains(body, "<href>/e/c/link</href>") || strings.Count(body, "<response ") != 1 {
+				t.Errorf("multistatus doesn't list /e/c/link only\n%s", body)
+			}
+			checkFile(t, dir, "e/a.txt", "a")
+			checkFile(t, dir, "e/c/b.txt", "b")
+		})
+	}
+}
+
+func TestLocalFileSystemMovePartialFailure(t *testing.T) {
+	// Moves to other devices copy the members, then remove the source
+	dir := t.TempDir()
+	writeUnreadableTree(t, dir)
+	other := crossDeviceDir(t, dir, "other")
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+
+	// Nothing is moved if a member can't be, and the first failure is
+	// reported
+	w := checkStatus(t, h, testRequest{method: "MOVE", target: "/d", header: map[string]string{"Destination": "/other/d"}}, http.StatusNotFound)
+	if body := w.Body.String(); strings.Contains(body, "multistatus") {
+		t.Errorf("failed move replied with a multistatus\n%s", body)
+	}
+	checkMissing(t, other, "d")
+	checkFile(t, dir, "d/a.txt", "a")
+	checkFile(t, dir, "d/c/b.txt", "b")
+}
@@ -0,0 +1,69 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestNormalizePath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/b.txt": "b"})
+	h := &webdav.Handler{Prefix: "/dav", FileSystem: webdav.LocalFileSystem(dir)}
+
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/dav/a/b.txt", http.StatusOK},
+		{"/dav//a///b.txt", http.StatusOK},
+		{"/dav/x/../a/./b.txt", http.StatusOK},
+		{"/dav/../../dav/a/b.txt", http.StatusOK},
+		{"/dav/../a/b.txt", http.StatusNotFound},
+		{"/dav/a/b%00.txt", http.StatusBadRequest},
+		{"/dav/" + strings.Repeat("a", 4096), http.StatusRequestURITooLong},
+	}
+	for _, tc := range tests {
+		w := serve(t, h, testRequest{method: http.MethodGet, target: tc.target})
+		if w.Code != tc.code {
+			t.Errorf("GET %.40v = %v, want %v", tc.target, w.Code, tc.code)
+		} else if tc.code == http.StatusOK && w.Body.String() != "b" {
+			t.Errorf("GET %v = %q, want %q", tc.target, w.Body.String(), "b")
+		}
+	}
+
+	// Collections keep their trailing slash
+	checkStatus(t, h, testRequest{method: "MKCOL", target: "/dav//c//"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/dav/c/./d.txt", body: "d"}, http.StatusCreated)
+	checkFile(t, dir, "c/d.txt", "d")
+
+	// Destinations are normalized too
+	copyTo := func(dest string, code int) {
+		t.Helper()
+		checkStatus(t, h, testRequest{method: "COPY", target: "/dav/a/b.txt", header: map[string]string{"Destination": dest}}, code)
+	}
+	copyTo("/dav//a/./x/../e.txt", http.StatusCreated)
+	checkFile(t, dir, "a/e.txt", "b")
+	copyTo("/dav/a/f%00.txt", http.StatusBadRequest)
+	copyTo("/dav/../f.txt", http.StatusBadGateway)
+	checkMissing(t, dir, "f.txt")
+
+	// Fiber mounts normalize paths before authorizing requests
+	app := fiber.New()
+	app.Use(webdav.New(webdav.Config{Prefix: "/dav", Root: webdav.LocalFileSystem(dir)}))
+	for target, code := range map[string]int{"/dav//a/./b.txt": http.StatusOK, "/dav/a/b%00.txt": http.StatusBadRequest} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("Fiber GET %v = %v, want %v", target, resp.StatusCode, code)
+		}
+	}
+}
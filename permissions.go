@@ -0,0 +1,109 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Permissions decides which operations users may perform on resources, e.g.
+// to enforce an application's sharing model without switching on request
+// methods in middleware. user is the name returned by UserFromContext, empty
+// for unauthenticated requests, and name is the path of the resource below
+// the mount prefix.
+//
+// Permissions are consulted before each operation, in addition to access
+// rules, for the request resource and the destination of COPY and MOVE
+// requests. Removing, moving or copying a collection is also checked against
+// each of its members, and their destination. Refused operations get "403
+// Forbidden".
+type Permissions interface {
+	// CanRead reports whether the user may read the content and properties
+	// of a resource, with GET, HEAD, PROPFIND, REPORT, or as the source of
+	// COPY. Members that can't be read are omitted from PROPFIND responses
+	// and directory listings.
+	CanRead(ctx context.Context, user, name string) bool
+	// CanWrite reports whether the user may create or modify a resource,
+	// with PUT, POST, MKCOL, PROPPATCH, or as the destination of COPY and
+	// MOVE.
+	CanWrite(ctx context.Context, user, name string) bool
+	// CanDelete reports whether the user may remove a resource, with DELETE
+	// or as the source of MOVE.
+	CanDelete(ctx context.Context, user, name string) bool
+	// CanLock reports whether the user may lock and unlock a resource.
+	CanLock(ctx context.Context, user, name string) bool
+}
+
+// permitted reports whether the Permissions allow the request user to use a
+// method on the resource name. For COPY and MOVE, name is the source.
+func (b *backend) permitted(ctx context.Context, method, name string) bool {
+	if b.Permissions == nil {
+		return true
+	}
+	user, _ := UserFromContext(ctx)
+	switch method {
+	case http.MethodGet, http.MethodHead, "PROPFIND", "REPORT", "COPY":
+		return b.Permissions.CanRead(ctx, user, name)
+	case http.MethodPut, http.MethodPost, "MKCOL", "PROPPATCH":
+		return b.Permissions.CanWrite(ctx, user, name)
+	case http.MethodDelete, "MOVE":
+		return b.Permissions.CanDelete(ctx, user, name)
+	case "LOCK", "UNLOCK":
+		return b.Permissions.CanLock(ctx, user, name)
+	}
+	return true
+}
+
+func (b *backend) requirePermission(ctx context.Context, method, name string) error {
+	if !b.permitted(ctx, method, name) {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: %v on %v not permitted", method, name)
+	}
+	return nil
+}
+
+// readable reports whether the request user may see the resource name in
+// PROPFIND responses and directory listings.
+func (b *backend) readable(ctx context.Context, name string) bool {
+	return b.access(ctx, name) != AccessNone && b.permitted(ctx, http.MethodGet, name)
+}
+
+// privileges returns the RFC 3744 privileges of the request user on the
+// resource name, as reported by the current-user-privilege-set property.
+// They account for the methods enabled on the handler, access rules and
+// Permissions.
+func (b *backend) privileges(ctx context.Context, name string) []xml.Name {
+	access := b.access(ctx, name)
+	allowed := func(method string) bool {
+		return b.checkMethod(method) == nil && access >= requiredAccess(method) && b.permitted(ctx, method, name)
+	}
+
+	var privs []xml.Name
+	if allowed(http.MethodGet) {
+		privs = append(privs, privilegeRead)
+	}
+	write, remove := allowed(http.MethodPut), allowed(http.MethodDelete)
+	if write && remove {
+		privs = append(privs, privilegeWrite)
+	}
+	if write {
+		privs = append(privs, privilegeWriteContent, privilegeWriteProperties)
+	}
+	if remove {
+		privs = append(privs, privilegeUnbind)
+	}
+	if b.LockSystem != nil && allowed("UNLOCK") {
+		privs = append(privs, privilegeUnlock)
+	}
+	return privs
+}
+
+var (
+	privilegeRead            = xml.Name{Space: internal.Namespace, Local: "read"}
+	privilegeWrite           = xml.Name{Space: internal.Namespace, Local: "write"}
+	privilegeWriteContent    = xml.Name{Space: internal.Namespace, Local: "write-content"}
+	privilegeWriteProperties = xml.Name{Space: internal.Namespace, Local: "write-properties"}
+	privilegeUnbind          = xml.Name{Space: internal.Namespace, Local: "unbind"}
+	privilegeUnlock          = xml.Name{Space: internal.Namespace, Local: "unlock"}
+)
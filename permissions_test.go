@@ -0,0 +1,149 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testPermissions grants operations per user on resources below a path.
+// Resources below the hidden path of a user can't be read.
+type testPermissions struct {
+	read, write, remove, lock map[string]string
+	hidden                    map[string]string
+}
+
+func allowedBelow(grants map[string]string, user, name string) bool {
+	prefix, ok := grants[user]
+	return ok && strings.HasPrefix(name, prefix)
+}
+
+func (p testPermissions) CanRead(ctx context.Context, user, name string) bool {
+	return allowedBelow(p.read, user, name) && !allowedBelow(p.hidden, user, name)
+}
+
+func (p testPermissions) CanWrite(ctx context.Context, user, name string) bool {
+	return allowedBelow(p.write, user, name)
+}
+
+func (p testPermissions) CanDelete(ctx context.Context, user, name string) bool {
+	return allowedBelow(p.remove, user, name)
+}
+
+func (p testPermissions) CanLock(ctx context.Context, user, name string) bool {
+	return allowedBelow(p.lock, user, name)
+}
+
+func TestPermissions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/a.txt":  "a",
+		"shared/b.txt":  "b",
+		"private/c.txt": "c",
+	})
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		LockSystem: webdav.NewLockSystem(),
+		Permissions: testPermissions{
+			read:   map[string]string{"alice": "/", "bob": "/"},
+			hidden: map[string]string{"bob": "/private/"},
+			write:  map[string]string{"alice": "/", "bob": "/shared/"},
+			remove: map[string]string{"alice": "/"},
+			lock:   map[string]string{"alice": "/"},
+		},
+	}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodGet, target: "/private/c.txt", user: "alice"}, http.StatusOK},
+		{testRequest{method: http.MethodGet, target: "/private/c.txt", user: "bob"}, http.StatusForbidden},
+		{testRequest{method: http.MethodGet, target: "/shared/a.txt", user: "bob"}, http.StatusOK},
+		{testRequest{method: http.MethodGet, target: "/shared/a.txt"}, http.StatusForbidden},
+		{testRequest{method: "PROPFIND", target: "/private/", header: map[string]string{"Depth": "0"}, user: "bob"}, http.StatusForbidden},
+		{testRequest{method: http.MethodPut, target: "/shared/new.txt", body: "new", user: "bob"}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: "/private/new.txt", body: "new", user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "MKCOL", target: "/private/dir", user: "bob"}, http.StatusForbidden},
+		{testRequest{method: http.MethodDelete, target: "/shared/b.txt", user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "MOVE", target: "/shared/b.txt", header: map[string]string{"Destination": "/shared/moved.txt"}, user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/shared/b.txt", header: map[string]string{"Destination": "/private/b.txt"}, user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/private/c.txt", header: map[string]string{"Destination": "/shared/c.txt"}, user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "COPY", target: "/shared/b.txt", header: map[string]string{"Destination": "/shared/copy.txt"}, user: "bob"}, http.StatusCreated},
+		{testRequest{method: "LOCK", target: "/shared/a.txt", body: lockBody, user: "bob"}, http.StatusForbidden},
+		{testRequest{method: "LOCK", target: "/shared/a.txt", body: lockBody, user: "alice"}, http.StatusOK},
+		{testRequest{method: http.MethodDelete, target: "/shared/b.txt", user: "alice"}, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		checkStatus(t, h, tc.req, tc.want)
+	}
+	checkMissing(t, dir, "private/new.txt")
+	checkMissing(t, dir, "shared/c.txt")
+	checkFile(t, dir, "shared/new.txt", "new")
+
+	// Unreadable members are left out of listings
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "infinity"}, user: "bob"}, http.StatusMultiStatus)
+	if body := w.Body.String(); strings.Contains(body, "c.txt") || !strings.Contains(body, "a.txt") {
+		t.Errorf("PROPFIND: unexpected members in\n%s", body)
+	}
+}
+
+func TestPermissionsPrivilegeSet(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"shared/a.txt": "a"})
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		LockSystem: webdav.NewLockSystem(),
+		Permissions: testPermissions{
+			read:  map[string]string{"alice": "/", "bob": "/"},
+			write: map[string]string{"alice": "/"},
+			lock:  map[string]string{"alice": "/"},
+		},
+	}
+
+	tests := []struct {
+		user       string
+		want, deny []string
+	}{
+		{"alice", []string{"read", "write-content", "write-properties", "unlock"}, []string{"write", "unbind"}},
+		{"bob", []string{"read"}, []string{"write", "write-content", "write-properties", "unbind", "unlock"}},
+	}
+	for _, tc := range tests {
+		w := checkStatus(t, h, testRequest{
+			method: "PROPFIND",
+			target: "/shared/a.txt",
+			body:   propFindPrivileges,
+			header: map[string]string{"Depth": "0"},
+			user:   tc.user,
+		}, http.StatusMultiStatus)
+		body := w.Body.String()
+		i := strings.Index(body, "current-user-privilege-set")
+		if i < 0 {
+			t.Errorf("PROPFIND as %v: missing privilege set in\n%s", tc.user, body)
+			continue
+		}
+		set := body[i:]
+		for _, priv := range tc.want {
+			if !strings.Contains(set, "<"+priv+" ") {
+				t.Errorf("PROPFIND as %v: privilege %v missing in\n%s", tc.user, priv, set)
+			}
+		}
+		for _, priv := range tc.deny {
+			if strings.Contains(set, "<"+priv+" ") {
+				t.Errorf("PROPFIND as %v: unexpected privilege %v in\n%s", tc.user, priv, set)
+			}
+		}
+	}
+}
+
+const lockBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:">
+  <D:lockscope><D:exclusive/></D:lockscope>
+  <D:locktype><D:write/></D:locktype>
+</D:lockinfo>`
+
+const propFindPrivileges = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:current-user-privilege-set/></D:prop></D:propfind>`
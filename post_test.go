@@ -0,0 +1,38 @@
+package webdav_test
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestPostAddMember(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "d/": ""})
+	h := &webdav.Handler{Prefix: "/dav", FileSystem: webdav.LocalFileSystem(dir)}
+
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/dav/d/", header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), "<add-member xmlns=\"DAV:\"><href>/dav/d/</href></add-member>") {
+		t.Errorf("PROPFIND without add-member\n%s", w.Body)
+	}
+
+	var names []string
+	for range 2 {
+		w = checkStatus(t, h, testRequest{method: http.MethodPost, target: "/dav/d/", body: "hello", header: map[string]string{"Content-Type": "image/png"}}, http.StatusCreated)
+		loc := w.Header().Get("Location")
+		if !strings.HasPrefix(loc, "/dav/d/") || path.Ext(loc) != ".png" {
+			t.Fatalf("Location = %q, want a PNG in /dav/d/", loc)
+		}
+		names = append(names, path.Base(loc))
+		checkFile(t, dir, "d/"+path.Base(loc), "hello")
+	}
+	if names[0] == names[1] {
+		t.Errorf("members created with the same name %v", names[0])
+	}
+
+	checkStatus(t, h, testRequest{method: http.MethodPost, target: "/dav/a.txt", body: "x"}, http.StatusMethodNotAllowed)
+	checkStatus(t, h, testRequest{method: http.MethodPost, target: "/dav/missing/", body: "x"}, http.StatusNotFound)
+}
@@ -0,0 +1,66 @@
+package webdav
+
+import (
+	"net/http"
+	"strings"
+)
+
+// precompressedEncodings are the content codings of precompressed variants of
+// files, by order of preference, with the extensions of their names.
+var precompressedEncodings = []struct {
+	coding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// encodingQ returns the weight of a content coding in an Accept-Encoding
+// header, as specified in RFC 9110 section 12.5.3. Zero means the coding is
+// not acceptable.
+func encodingQ(acceptEncoding, coding string) float64 {
+	q, wildcard := -1.0, 0.0
+	for _, s := range strings.Split(acceptEncoding, ",") {
+		c, params, _ := strings.Cut(s, ";")
+		switch strings.ToLower(strings.TrimSpace(c)) {
+		case coding:
+			q = qValue(params)
+		case "*":
+			wildcard = qValue(params)
+		}
+	}
+	if q < 0 {
+		return wildcard
+	}
+	return q
+}
+
+// precompressedVariant returns the precompressed sibling of fi preferred by
+// the client and its content coding, or nil if there's none. Variants older
+// than fi are stale and ignored.
+func (b *backend) precompressedVariant(r *http.Request, fi *FileInfo) (*FileInfo, string) {
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	if acceptEncoding == "" {
+		return nil, ""
+	}
+
+	var (
+		variant *FileInfo
+		coding  string
+		bestQ   float64
+	)
+	for _, enc := range precompressedEncodings {
+		q := encodingQ(acceptEncoding, enc.coding)
+		if q <= bestQ {
+			continue
+		}
+		vfi, err := b.FileSystem.Stat(r.Context(), r.URL.Path+enc.ext)
+		if err != nil || vfi.IsDir || vfi.ModTime.Before(fi.ModTime) {
+			continue
+		}
+		if !b.readable(r.Context(), vfi.Path) {
+			continue
+		}
+		variant, coding, bestQ = vfi, enc.coding, q
+	}
+	return variant, coding
+}
@@ -0,0 +1,90 @@
+package webdav_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestPrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":     "original",
+		"a.txt.br":  "brotli",
+		"a.txt.gz":  "gzip",
+		"b.txt":     "original",
+		"b.txt.gz":  "stale",
+		"c.txt":     "original",
+		"c.txt.gz/": "",
+		"d.txt":     "original",
+		"d.txt.br":  "hidden",
+		"d.txt.gz":  "gzip",
+	})
+	// Siblings are fresh unless older than the file
+	modTime := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := modTime.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.txt.gz"), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	h := &webdav.Handler{
+		FileSystem:    webdav.LocalFileSystem(dir),
+		Precompressed: true,
+		Permissions: testPermissions{
+			read:   map[string]string{"alice": "/"},
+			hidden: map[string]string{"alice": "/d.txt.br"},
+		},
+	}
+
+	tests := []struct {
+		name, target, acceptEncoding string
+		body, encoding               string
+	}{
+		{"no accept-encoding", "/a.txt", "", "original", ""},
+		{"preferred coding", "/a.txt", "gzip, br", "brotli", "br"},
+		{"weighted coding", "/a.txt", "br;q=0.5, gzip", "gzip", "gzip"},
+		{"refused coding", "/a.txt", "br;q=0, *", "gzip", "gzip"},
+		{"wildcard", "/a.txt", "*", "brotli", "br"},
+		{"unsupported coding", "/a.txt", "deflate", "original", ""},
+		{"all refused", "/a.txt", "gzip;q=0, br;q=0", "original", ""},
+		{"stale sibling", "/b.txt", "gzip", "original", ""},
+		{"directory sibling", "/c.txt", "gzip", "original", ""},
+		{"unreadable sibling", "/d.txt", "br, gzip", "gzip", "gzip"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := testRequest{method: http.MethodGet, target: tc.target, user: "alice"}
+			if tc.acceptEncoding != "" {
+				req.header = map[string]string{"Accept-Encoding": tc.acceptEncoding}
+			}
+			w := checkStatus(t, h, req, http.StatusOK)
+			if got := w.Body.String(); got != tc.body {
+				t.Errorf("body = %q, want %q", got, tc.body)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tc.encoding)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want the type of the original file", got)
+			}
+		})
+	}
+
+	// Siblings are ignored unless enabled
+	h.Precompressed = false
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", user: "alice", header: map[string]string{"Accept-Encoding": "br"}}, http.StatusOK)
+	if w.Body.String() != "original" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("GET with Precompressed unset = %q (Content-Encoding %q), want the original file", w.Body.String(), w.Header().Get("Content-Encoding"))
+	}
+}
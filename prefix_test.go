@@ -0,0 +1,94 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestPrefix(t *testing.T) {
+	// Names made of the characters of the prefix
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"vad.txt": "v", "d/a.txt": "a"})
+	h := &webdav.Handler{Prefix: "/dav/", FileSystem: webdav.LocalFileSystem(dir)}
+
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/dav/vad.txt"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/vad.txt"}, http.StatusNotFound)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/davvad.txt"}, http.StatusNotFound)
+
+	// Hrefs include the prefix
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/dav", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	hrefs := regexp.MustCompile(`<href>([^<]*)</href>`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(hrefs) == 0 {
+		t.Errorf("no hrefs in %s", w.Body)
+	}
+	for _, href := range hrefs {
+		if !strings.HasPrefix(href[1], "/dav/") {
+			t.Errorf("href %q outside of the prefix", href[1])
+		}
+	}
+
+	// Destinations are relative to the prefix too
+	for dest, want := range map[string]int{
+		"http://example.com/dav/b.txt": http.StatusCreated,
+		"/dav/d/b.txt":                 http.StatusCreated,
+		"/b.txt":                       http.StatusBadGateway,
+		"/davb.txt":                    http.StatusBadGateway,
+		"/other/dav/c.txt":             http.StatusBadGateway,
+	} {
+		checkStatus(t, h, testRequest{method: "COPY", target: "/dav/vad.txt", header: map[string]string{"Destination": dest}}, want)
+	}
+	checkFile(t, dir, "b.txt", "v")
+	checkFile(t, dir, "d/b.txt", "v")
+	names, err := readDirNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Errorf("files = %v, want vad.txt, d and b.txt", names)
+	}
+}
+
+func TestPrefixMounts(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeFiles(t, dir1, map[string]string{"a.txt": "a"})
+	writeFiles(t, dir2, map[string]string{"b.txt": "b"})
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(webdav.Mounts(
+		webdav.NewServer(webdav.Config{Prefix: "/dav", Root: webdav.LocalFileSystem(dir1)}),
+		webdav.NewServer(webdav.Config{Prefix: "/dav2", Root: webdav.LocalFileSystem(dir2)}),
+	))
+
+	tests := []struct {
+		target, dest string
+		want         int
+	}{
+		// Resources can't be moved to other mounts
+		{"/dav/a.txt", "/dav2/a.txt", http.StatusBadGateway},
+		{"/dav2/b.txt", "http://example.com/dav/b.txt", http.StatusBadGateway},
+		{"/dav2/b.txt", "/dav2/c.txt", http.StatusCreated},
+		{"/dav/a.txt", "/dav/c.txt", http.StatusCreated},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("MOVE", tc.target, nil)
+		req.Header.Set("Destination", tc.dest)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("MOVE %v to %v = %v, want %v", tc.target, tc.dest, resp.StatusCode, tc.want)
+		}
+	}
+	checkFile(t, dir1, "c.txt", "a")
+	checkFile(t, dir2, "c.txt", "b")
+	checkMissing(t, dir1, "b.txt")
+	checkMissing(t, dir2, "a.txt")
+}
@@ -0,0 +1,363 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Principal describes a user or a group of users.
+//
+// https://datatracker.ietf.org/doc/html/rfc3744#section-2
+type Principal struct {
+	// Path is the principal URL path.
+	Path        string
+	DisplayName string
+	// AlternateURIs are other URIs identifying the principal, e.g. "mailto:"
+	// URIs.
+	AlternateURIs []string
+	// Members contains the principal URL paths of the members of a group.
+	// It's nil for principals which aren't groups.
+	Members []string
+}
+
+// PrincipalDirectory looks up principals, e.g. in a user database or in an
+// LDAP directory.
+type PrincipalDirectory interface {
+	// GetPrincipal returns the principal with the given URL path. It returns
+	// a 404 error if the principal doesn't exist.
+	GetPrincipal(ctx context.Context, path string) (*Principal, error)
+	// ListPrincipals returns all principals of the directory.
+	ListPrincipals(ctx context.Context) ([]Principal, error)
+	// GroupMemberships returns the principal URL paths of the groups the
+	// principal is a direct member of.
+	GroupMemberships(ctx context.Context, path string) ([]string, error)
+}
+
+// ServePrincipalOptions holds options for ServePrincipal.
+type ServePrincipalOptions struct {
+	CurrentUserPrincipalPath string
+	Capabilities             []Capability
+	// Directory provides the principals. If set, ServePrincipal serves the
+	// principal collection and the principals it contains, including the
+	// principal-property-search and principal-search-property-set reports.
+	Directory PrincipalDirectory
+	// PrincipalCollectionPath is the path of the collection containing the
+	// principals of Directory.
+	PrincipalCollectionPath string
+}
+
+// ServePrincipal replies to requests for a principal URL.
+func ServePrincipal(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions) {
+	var err error
+	switch r.Method {
+	case http.MethodOptions:
+		caps := []string{"1", "3"}
+		for _, c := range options.Capabilities {
+			caps = append(caps, string(c))
+		}
+		allow := []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL"}
+		w.Header().Add("DAV", strings.Join(caps, ", "))
+		w.Header().Add("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	case "PROPFIND":
+		err = servePrincipalPropfind(w, r, options)
+	case "REPORT":
+		err = servePrincipalReport(w, r, options)
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+
+	if err != nil {
+		internal.ServeError(w, err)
+	}
+}
+
+func servePrincipalPropfind(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions) error {
+	propfind, err := internal.DecodePropFind(r)
+	if err != nil {
+		return err
+	}
+
+	if options.Directory == nil {
+		props := map[xml.Name]internal.PropFindFunc{
+			internal.ResourceTypeName: func(*internal.RawXMLValue) (interface{}, error) {
+				return internal.NewResourceType(principalName), nil
+			},
+			internal.CurrentUserPrincipalName: func(*internal.RawXMLValue) (interface{}, error) {
+				return &internal.CurrentUserPrincipal{Href: internal.Href{Path: options.CurrentUserPrincipalPath}}, nil
+			},
+		}
+
+		resp, err := internal.NewPropFindResponse(r.URL.Path, propfind, props)
+		if err != nil {
+			return err
+		}
+
+		ms := internal.NewMultiStatus(*resp)
+		return internal.ServeMultiStatus(w, ms)
+	}
+
+	depth := internal.DepthInfinity
+	if s := r.Header.Get("Depth"); s != "" {
+		depth, err = internal.ParseDepth(s)
+		if err != nil {
+			return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	ctx := r.Context()
+	var resps []internal.Response
+	if options.isPrincipalCollection(r.URL.Path) {
+		resp, err := internal.NewPropFindResponse(options.principalCollectionHref(), propfind, options.principalCollectionProps())
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+
+		if depth != internal.DepthZero {
+			principals, err := options.Directory.ListPrincipals(ctx)
+			if err != nil {
+				return err
+			}
+			for i := range principals {
+				resp, err := options.propFindPrincipal(ctx, propfind, &principals[i])
+				if err != nil {
+					return err
+				}
+				resps = append(resps, *resp)
+			}
+		}
+	} else {
+		p, err := options.Directory.GetPrincipal(ctx, r.URL.Path)
+		if err != nil {
+			return err
+		}
+		resp, err := options.propFindPrincipal(ctx, propfind, p)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+
+	return internal.ServeMultiStatus(w, internal.NewMultiStatus(resps...))
+}
+
+func (options *ServePrincipalOptions) isPrincipalCollection(p string) bool {
+	return options.PrincipalCollectionPath != "" && path.Clean(p) == path.Clean(options.PrincipalCollectionPath)
+}
+
+func (options *ServePrincipalOptions) principalCollectionHref() string {
+	return strings.TrimSuffix(path.Clean(options.PrincipalCollectionPath), "/") + "/"
+}
+
+func (options *ServePrincipalOptions) principalCollectionProps() map[xml.Name]internal.PropFindFunc {
+	return map[xml.Name]internal.PropFindFunc{
+		internal.ResourceTypeName: internal.PropFindValue(internal.NewResourceType(internal.CollectionName)),
+		internal.CurrentUserPrincipalName: internal.PropFindValue(&internal.CurrentUserPrincipal{
+			Href: internal.Href{Path: options.CurrentUserPrincipalPath},
+		}),
+		principalCollectionSetName: internal.PropFindValue(&principalCollectionSet{
+			Hrefs: []internal.Href{{Path: options.principalCollectionHref()}},
+		}),
+		internal.SupportedReportSetName: internal.PropFindValue(internal.NewSupportedReportSet(
+			principalPropertySearchName,
+			principalSearchPropertySetName,
+		)),
+	}
+}
+
+func (options *ServePrincipalOptions) propFindPrincipal(ctx context.Context, propfind *internal.PropFind, p *Principal) (*internal.Response, error) {
+	props := map[xml.Name]internal.PropFindFunc{
+		internal.ResourceTypeName: internal.PropFindValue(internal.NewResourceType(principalName)),
+		internal.CurrentUserPrincipalName: internal.PropFindValue(&internal.CurrentUserPrincipal{
+			Href: internal.Href{Path: options.CurrentUserPrincipalPath},
+		}),
+		principalURLName: internal.PropFindValue(&principalURL{
+			Href: internal.Href{Path: p.Path},
+		}),
+		principalAlternateURISetName: func(*internal.RawXMLValue) (interface{}, error) {
+			hrefs := make([]internal.Href, len(p.AlternateURIs))
+			for i, uri := range p.AlternateURIs {
+				u, err := url.Parse(uri)
+				if err != nil {
+					return nil, err
+				}
+				hrefs[i] = internal.Href(*u)
+			}
+			return &principalAlternateURISet{Hrefs: hrefs}, nil
+		},
+		groupMembershipName: func(*internal.RawXMLValue) (interface{}, error) {
+			groups, err := options.Directory.GroupMemberships(ctx, p.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &groupMembership{Hrefs: newHrefs(groups)}, nil
+		},
+		internal.SupportedReportSetName: internal.PropFindValue(internal.NewSupportedReportSet(
+			principalPropertySearchName,
+			principalSearchPropertySetName,
+		)),
+	}
+
+	if p.DisplayName != "" {
+		props[internal.DisplayNameName] = internal.PropFindValue(&internal.DisplayName{Name: p.DisplayName})
+	}
+	if p.Members != nil {
+		props[groupMemberSetName] = internal.PropFindValue(&groupMemberSet{Hrefs: newHrefs(p.Members)})
+	}
+	if options.PrincipalCollectionPath != "" {
+		props[principalCollectionSetName] = internal.PropFindValue(&principalCollectionSet{
+			Hrefs: []internal.Href{{Path: options.principalCollectionHref()}},
+		})
+	}
+
+	return internal.NewPropFindResponse(p.Path, propfind, props)
+}
+
+func newHrefs(paths []string) []internal.Href {
+	hrefs := make([]internal.Href, len(paths))
+	for i, p := range paths {
+		hrefs[i] = internal.Href{Path: p}
+	}
+	return hrefs
+}
+
+func servePrincipalReport(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions) error {
+	var report internal.RawXMLValue
+	if err := internal.DecodeXMLRequest(r, &report); err != nil {
+		return err
+	}
+	name, _ := report.XMLName()
+	if options.Directory == nil {
+		return errUnsupportedReport()
+	}
+
+	switch name {
+	case principalPropertySearchName:
+		var search principalPropertySearch
+		if err := report.Decode(&search); err != nil {
+			return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+		return servePrincipalPropertySearch(w, r, options, &search)
+	case principalSearchPropertySetName:
+		// https://datatracker.ietf.org/doc/html/rfc3744#section-9.5
+		set := principalSearchPropertySet{
+			PrincipalSearchProperty: []principalSearchProperty{
+				newPrincipalSearchProperty(internal.DisplayNameName, "Display name"),
+				newPrincipalSearchProperty(principalAlternateURISetName, "Alternate URIs"),
+			},
+		}
+		return internal.ServeXML(w).Encode(&set)
+	default:
+		return errUnsupportedReport()
+	}
+}
+
+func newPrincipalSearchProperty(name xml.Name, desc string) principalSearchProperty {
+	return principalSearchProperty{
+		Prop:        internal.Prop{Raw: []internal.RawXMLValue{*internal.NewRawXMLElement(name, nil, nil)}},
+		Description: description{Lang: "en", Value: desc},
+	}
+}
+
+func servePrincipalPropertySearch(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions, search *principalPropertySearch) error {
+	if len(search.PropertySearch) == 0 {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: missing property-search element")
+	}
+
+	ctx := r.Context()
+	var principals []Principal
+	if search.ApplyToPrincipalCollectionSet != nil || options.isPrincipalCollection(r.URL.Path) {
+		var err error
+		principals, err = options.Directory.ListPrincipals(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		p, err := options.Directory.GetPrincipal(ctx, r.URL.Path)
+		if err != nil {
+			return err
+		}
+		principals = []Principal{*p}
+	}
+
+	propfind := internal.PropFind{Prop: search.Prop}
+	if propfind.Prop == nil {
+		propfind.AllProp = &struct{}{}
+	}
+
+	resps := []internal.Response{}
+	for i := range principals {
+		if !search.match(&principals[i]) {
+			continue
+		}
+		resp, err := options.propFindPrincipal(ctx, &propfind, &principals[i])
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+
+	return internal.ServeMultiStatus(w, internal.NewMultiStatus(resps...))
+}
+
+// match reports whether a principal matches the search. By default all
+// property-search elements need to match, the "anyof" test requires at least
+// one.
+func (search *principalPropertySearch) match(p *Principal) bool {
+	anyOf := search.Test == "anyof"
+	for _, ps := range search.PropertySearch {
+		if ps.match(p) == anyOf {
+			return anyOf
+		}
+	}
+	return !anyOf
+}
+
+// match reports whether any of the searched properties of a principal
+// matches.
+func (ps *propertySearch) match(p *Principal) bool {
+	for _, raw := range ps.Prop.Raw {
+		name, ok := raw.XMLName()
+		if !ok {
+			continue
+		}
+
+		var values []string
+		switch name {
+		case internal.DisplayNameName:
+			values = []string{p.DisplayName}
+		case principalAlternateURISetName:
+			values = p.AlternateURIs
+		}
+
+		for _, v := range values {
+			if ps.Match.match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match performs a caseless comparison of a property value with the searched
+// string.
+func (m *match) match(v string) bool {
+	v, s := strings.ToLower(v), strings.ToLower(m.Value)
+	switch m.MatchType {
+	case "starts-with":
+		return strings.HasPrefix(v, s)
+	case "ends-with":
+		return strings.HasSuffix(v, s)
+	case "equals":
+		return v == s
+	default:
+		return strings.Contains(v, s)
+	}
+}
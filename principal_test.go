@@ -0,0 +1,112 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testDirectory is a PrincipalDirectory listing its principals.
+type testDirectory []webdav.Principal
+
+func (d testDirectory) GetPrincipal(ctx context.Context, path string) (*webdav.Principal, error) {
+	for i := range d {
+		if d[i].Path == path {
+			return &d[i], nil
+		}
+	}
+	return nil, webdav.NewHTTPError(http.StatusNotFound, errors.New("no such principal"))
+}
+
+func (d testDirectory) ListPrincipals(ctx context.Context) ([]webdav.Principal, error) {
+	return d, nil
+}
+
+func (d testDirectory) GroupMemberships(ctx context.Context, path string) ([]string, error) {
+	var groups []string
+	for _, p := range d {
+		if slices.Contains(p.Members, path) {
+			groups = append(groups, p.Path)
+		}
+	}
+	return groups, nil
+}
+
+// principalSearch returns the body of a principal-property-search REPORT
+// matching the display name.
+func principalSearch(test, matchType, value string, collection bool) string {
+	apply := ""
+	if collection {
+		apply = "<D:apply-to-principal-collection-set/>"
+	}
+	return `<?xml version="1.0" encoding="utf-8"?>
+<D:principal-property-search xmlns:D="DAV:" test="` + test + `">
+  <D:property-search><D:prop><D:displayname/></D:prop><D:match match-type="` + matchType + `">` + value + `</D:match></D:property-search>
+  <D:property-search><D:prop><D:alternate-URI-set/></D:prop><D:match>mailto:bob</D:match></D:property-search>
+  <D:prop><D:displayname/></D:prop>` + apply + `
+</D:principal-property-search>`
+}
+
+func TestServePrincipalDirectory(t *testing.T) {
+	dir := testDirectory{
+		{Path: "/principals/alice/", DisplayName: "Alice", AlternateURIs: []string{"mailto:alice@example.com"}},
+		{Path: "/principals/bob/", DisplayName: "Bob", AlternateURIs: []string{"mailto:bob@example.com"}},
+		{Path: "/principals/staff/", DisplayName: "Staff", Members: []string{"/principals/alice/"}},
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webdav.ServePrincipal(w, r, &webdav.ServePrincipalOptions{
+			CurrentUserPrincipalPath: "/principals/alice/",
+			Directory:                dir,
+			PrincipalCollectionPath:  "/principals/",
+		})
+	})
+	responses := regexp.MustCompile(`<response xmlns="DAV:"><href>([^<]*)</href>`)
+	hrefs := func(body string) []string {
+		var l []string
+		for _, m := range responses.FindAllStringSubmatch(body, -1) {
+			l = append(l, m[1])
+		}
+		return l
+	}
+
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/principals/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	if got, want := hrefs(w.Body.String()), []string{"/principals/", "/principals/alice/", "/principals/bob/", "/principals/staff/"}; !slices.Equal(got, want) {
+		t.Errorf("principal collection = %v, want %v", got, want)
+	}
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/principals/alice/", body: propFind(`<D:group-membership/>`), header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), "<group-membership xmlns=\"DAV:\"><href>/principals/staff/</href>") {
+		t.Errorf("group-membership of alice = %s", w.Body)
+	}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/principals/eve/", header: map[string]string{"Depth": "0"}}, http.StatusNotFound)
+
+	tests := []struct {
+		name, target, body string
+		want               []string
+	}{
+		{"anyof", "/principals/", principalSearch("anyof", "", "LIC", false), []string{"/principals/alice/", "/principals/bob/"}},
+		{"allof", "/principals/", principalSearch("allof", "", "b", false), []string{"/principals/bob/"}},
+		{"starts-with", "/principals/", principalSearch("anyof", "starts-with", "st", false), []string{"/principals/bob/", "/principals/staff/"}},
+		{"equals", "/principals/bob/", principalSearch("anyof", "equals", "Staff", false), []string{"/principals/bob/"}},
+		{"collection set", "/principals/bob/", principalSearch("anyof", "equals", "Staff", true), []string{"/principals/bob/", "/principals/staff/"}},
+		{"no match", "/principals/", principalSearch("allof", "ends-with", "x", false), nil},
+	}
+	for _, tc := range tests {
+		w := checkStatus(t, h, testRequest{method: "REPORT", target: tc.target, body: tc.body}, http.StatusMultiStatus)
+		if got := hrefs(w.Body.String()); !slices.Equal(got, tc.want) {
+			t.Errorf("%v: principal-property-search = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	w = checkStatus(t, h, testRequest{method: "REPORT", target: "/principals/", body: `<?xml version="1.0"?><D:principal-search-property-set xmlns:D="DAV:"/>`}, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "displayname") {
+		t.Errorf("principal-search-property-set = %s", w.Body)
+	}
+	checkStatus(t, h, testRequest{method: "REPORT", target: "/principals/", body: `<?xml version="1.0"?><D:principal-property-search xmlns:D="DAV:"/>`}, http.StatusBadRequest)
+	checkStatus(t, h, testRequest{method: "REPORT", target: "/principals/", body: `<?xml version="1.0"?><D:sync-collection xmlns:D="DAV:"/>`}, http.StatusForbidden)
+}
@@ -0,0 +1,200 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Processor processes files once uploaded, e.g. to generate thumbnails,
+// extract text for search indexing or compute checksums, and records its
+// results as dead properties of the files.
+//
+// Processors are set as Handler.Processors, and run in the background after
+// uploads complete.
+type Processor interface {
+	Process(ctx context.Context, upload *Upload) error
+}
+
+// ProcessorFunc is a function implementing Processor.
+type ProcessorFunc func(ctx context.Context, upload *Upload) error
+
+// Process calls f.
+func (f ProcessorFunc) Process(ctx context.Context, upload *Upload) error {
+	return f(ctx, upload)
+}
+
+// Upload is an uploaded file passed to Processors.
+type Upload struct {
+	// FileInfo describes the file.
+	FileInfo *FileInfo
+	// FileSystem holds the file, e.g. to store derived files such as
+	// thumbnails next to it.
+	FileSystem FileSystem
+	// User is the user who uploaded the file, if any.
+	User string
+	// Properties are the dead properties set on the file once all
+	// processors ran. Processors add their results, and may read the ones of
+	// the processors preceding them.
+	Properties map[xml.Name]string
+}
+
+// Open opens the content of the file.
+func (u *Upload) Open(ctx context.Context) (io.ReadCloser, error) {
+	return u.FileSystem.Open(ctx, u.FileInfo.Path)
+}
+
+// ChecksumProcessor returns a Processor computing the checksum of files,
+// reported as the oc:checksums property like with Handler.Checksum, without
+// slowing down uploads.
+func ChecksumProcessor(alg ChecksumAlgorithm) Processor {
+	return ProcessorFunc(func(ctx context.Context, upload *Upload) error {
+		sum, err := computeChecksum(ctx, upload.FileSystem, upload.FileInfo.Path, alg)
+		if err != nil {
+			return err
+		}
+		upload.Properties[checksumsName] = sum.String()
+		return nil
+	})
+}
+
+// uploadQueueSize is the number of uploads waiting for processing beyond
+// which new ones are skipped.
+const uploadQueueSize = 1000
+
+// uploadPipeline runs processors on uploaded files, one at a time in upload
+// order.
+type uploadPipeline struct {
+	processors []Processor
+	fs         FileSystem
+	props      PropertyStore
+	logger     *slog.Logger
+	ctx        context.Context // canceled by close
+	cancel     context.CancelFunc
+
+	mu      sync.Mutex
+	queue   chan *Upload
+	closed  bool
+	pending sync.WaitGroup
+}
+
+var _ Flusher = (*uploadPipeline)(nil)
+
+func newUploadPipeline(processors []Processor, fs FileSystem, props PropertyStore, logger *slog.Logger) *uploadPipeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &uploadPipeline{processors: processors, fs: fs, props: props, logger: logger, ctx: ctx, cancel: cancel}
+}
+
+// hooks returns the hooks queuing uploads for processing.
+func (p *uploadPipeline) hooks() Hooks {
+	return Hooks{OnPut: func(ctx context.Context, event *Event) {
+		p.enqueue(&Upload{FileInfo: event.FileInfo, FileSystem: p.fs, User: event.User})
+	}}
+}
+
+func (p *uploadPipeline) enqueue(upload *Upload) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if p.queue == nil {
+		p.queue = make(chan *Upload, uploadQueueSize)
+		go p.run()
+	}
+	p.pending.Add(1)
+	select {
+	case p.queue <- upload:
+	default:
+		p.pending.Done()
+		p.warn("webdav: upload processing queue full, upload skipped", upload, nil)
+	}
+}
+
+// Flush implements Flusher by waiting for the queued uploads to be
+// processed, until ctx is done.
+func (p *uploadPipeline) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close waits for the queued uploads to be processed until ctx is done,
+// then stops processing. Processors still running are canceled and the
+// remaining uploads skipped.
+func (p *uploadPipeline) close(ctx context.Context) error {
+	err := p.Flush(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.cancel()
+		if p.queue != nil {
+			close(p.queue)
+		}
+	}
+	return err
+}
+
+func (p *uploadPipeline) run() {
+	for upload := range p.queue {
+		if p.ctx.Err() == nil {
+			p.process(upload)
+		}
+		p.pending.Done()
+	}
+}
+
+// process runs the processors on an upload, and stores their results unless
+// the file changed meanwhile. Files which changed since uploaded are skipped,
+// the upload which changed them being queued too.
+func (p *uploadPipeline) process(upload *Upload) {
+	ctx := p.ctx
+	if !p.unchanged(ctx, upload.FileInfo) {
+		return
+	}
+	upload.Properties = make(map[xml.Name]string)
+	for _, proc := range p.processors {
+		// Processors are independent enough for the others to be useful
+		// when one fails
+		if err := proc.Process(ctx, upload); err != nil {
+			p.warn("webdav: upload processing failed", upload, err)
+		}
+	}
+	if len(upload.Properties) == 0 || !p.unchanged(ctx, upload.FileInfo) {
+		return
+	}
+	if err := p.props.PatchProperties(ctx, upload.FileInfo.Path, upload.Properties, nil); err != nil {
+		p.warn("webdav: failed to store upload processing results", upload, err)
+	}
+}
+
+// unchanged reports whether a file is still the one described by fi.
+func (p *uploadPipeline) unchanged(ctx context.Context, fi *FileInfo) bool {
+	cur, err := p.fs.Stat(ctx, fi.Path)
+	if err != nil {
+		return false
+	}
+	return cur.Size == fi.Size && cur.ModTime.Equal(fi.ModTime) && cur.ETag == fi.ETag
+}
+
+func (p *uploadPipeline) warn(msg string, upload *Upload, err error) {
+	if p.logger == nil {
+		return
+	}
+	args := []any{"path", upload.FileInfo.Path}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	p.logger.Warn(msg, args...)
+}
@@ -0,0 +1,90 @@
+package webdav_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestProcessors(t *testing.T) {
+	dir := t.TempDir()
+	props := webdav.NewMemPropertyStore()
+	var users []string
+	h := &webdav.Handler{
+		FileSystem:    webdav.LocalFileSystem(dir),
+		PropertyStore: props,
+		Processors: []webdav.Processor{
+			webdav.ProcessorFunc(func(ctx context.Context, upload *webdav.Upload) error {
+				return errors.New("failing processors don't stop the others")
+			}),
+			webdav.ProcessorFunc(func(ctx context.Context, upload *webdav.Upload) error {
+				rc, err := upload.Open(ctx)
+				if err != nil {
+					return err
+				}
+				defer rc.Close()
+				b, err := io.ReadAll(rc)
+				if err != nil {
+					return err
+				}
+				users = append(users, upload.User)
+				upload.Properties[xml.Name{Space: "urn:test", Local: "color"}] = string(b)
+				return nil
+			}),
+		},
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "red", user: "alice"}, http.StatusCreated)
+	if err := h.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 1 || users[0] != "alice" {
+		t.Errorf("processed uploads of %v, want [alice]", users)
+	}
+	got, err := props.GetProperties(t.Context(), "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got[xml.Name{Space: "urn:test", Local: "color"}]; v != "red" {
+		t.Errorf("color = %q, want red", v)
+	}
+}
+
+func TestProcessorsShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var canceled, processed atomic.Int32
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		Processors: []webdav.Processor{webdav.ProcessorFunc(func(ctx context.Context, upload *webdav.Upload) error {
+			processed.Add(1)
+			started <- struct{}{}
+			<-ctx.Done()
+			canceled.Add(1)
+			return ctx.Err()
+		})},
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated)
+	<-started
+
+	// Processors still running when ctx is done are canceled, and the
+	// remaining uploads skipped
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	for start := time.Now(); canceled.Load() == 0 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	if processed.Load() != 1 || canceled.Load() != 1 {
+		t.Errorf("processed %v uploads, %v canceled, want 1 and 1", processed.Load(), canceled.Load())
+	}
+}
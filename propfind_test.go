@@ -0,0 +1,170 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestMaxPropFindResponses(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "c/d.txt": "d", "c/e.txt": "e"})
+	for _, workers := range []int{0, 4} {
+		h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), MaxPropFindResponses: 3, PropFindWorkers: workers}
+
+		w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "infinity"}}, http.StatusMultiStatus)
+		body := w.Body.String()
+		// The limit, then the truncation marker for the request URI
+		if n := strings.Count(body, "<response "); n != 4 {
+			t.Errorf("workers %v: %v responses, want 4\n%s", workers, n, body)
+		}
+		if !strings.Contains(body, "507 Insufficient Storage") || !strings.Contains(body, "number-of-matches-within-limits") {
+			t.Errorf("workers %v: multistatus not truncated\n%s", workers, body)
+		}
+
+		w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/c", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+		if body := w.Body.String(); strings.Contains(body, "507") {
+			t.Errorf("workers %v: multistatus within the limit truncated\n%s", workers, body)
+		}
+	}
+}
+
+// failingWalkFileSystem fails walks after enumerating n resources.
+type failingWalkFileSystem struct {
+	webdav.LocalFileSystem
+	n int
+}
+
+func (fs failingWalkFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *webdav.FileInfo) error) error {
+	n := 0
+	return fs.LocalFileSystem.Walk(ctx, name, recursive, func(fi *webdav.FileInfo) error {
+		if n == fs.n {
+			return errors.New("walk failed")
+		}
+		n++
+		return fn(fi)
+	})
+}
+
+func TestPropFindStreaming(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+	depth1 := map[string]string{"Depth": "1"}
+
+	// Errors before the first response are replied to with a status
+	h := &webdav.Handler{FileSystem: failingWalkFileSystem{webdav.LocalFileSystem(dir), 0}}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth1}, http.StatusInternalServerError)
+
+	// Later errors leave the multistatus unterminated
+	h = &webdav.Handler{FileSystem: failingWalkFileSystem{webdav.LocalFileSystem(dir), 2}}
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth1}, http.StatusMultiStatus)
+	if body := w.Body.String(); strings.Count(body, "<response ") != 2 || strings.Contains(body, "</multistatus>") {
+		t.Errorf("failed multistatus = %s, want 2 responses and no end", body)
+	}
+}
+
+func TestLocalFileSystemWalk(t *testing.T) {
+	// More members than read in a batch
+	dir := t.TempDir()
+	files := map[string]string{"c/d/e.txt": "e"}
+	for i := range 300 {
+		files[fmt.Sprintf("%03d.txt", i)] = "x"
+	}
+	writeFiles(t, dir, files)
+	fs := webdav.LocalFileSystem(dir)
+
+	// The collection itself, then its members and descendants
+	for recursive, n := range map[bool]int{false: 302, true: 304} {
+		var got []string
+		err := fs.Walk(context.Background(), "/", recursive, func(fi *webdav.FileInfo) error {
+			got = append(got, fi.Path)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := fs.ReadDir(context.Background(), "/", recursive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, fi := range l {
+			want = append(want, fi.Path)
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if len(got) != n || !slices.Equal(got, want) {
+			t.Errorf("Walk(recursive %v) = %v, want %v", recursive, got, want)
+		}
+	}
+
+	if err := fs.Walk(context.Background(), "/missing", false, func(*webdav.FileInfo) error { return nil }); webdav.HTTPStatus(err) != http.StatusNotFound {
+		t.Errorf("Walk() of a missing collection = %v, want 404", err)
+	}
+}
+
+// slowFileSystem delays opening files, recording the largest number of
+// files opened concurrently.
+type slowFileSystem struct {
+	webdav.LocalFileSystem
+	open, maxOpen *atomic.Int32
+}
+
+func (fs slowFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	n := fs.open.Add(1)
+	defer fs.open.Add(-1)
+	for {
+		most := fs.maxOpen.Load()
+		if n <= most || fs.maxOpen.CompareAndSwap(most, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return fs.LocalFileSystem.Open(ctx, name)
+}
+
+func TestPropFindWorkers(t *testing.T) {
+	// Files without extensions are opened to detect their content type
+	dir := t.TempDir()
+	files := map[string]string{}
+	for i := range 20 {
+		files[fmt.Sprintf("f%02d", i)] = "x"
+	}
+	writeFiles(t, dir, files)
+	depth1 := map[string]string{"Depth": "1"}
+	hrefs := regexp.MustCompile(`<response xmlns="DAV:"><href>[^<]*</href>`)
+
+	fs := slowFileSystem{webdav.LocalFileSystem(dir), new(atomic.Int32), new(atomic.Int32)}
+	w := checkStatus(t, &webdav.Handler{FileSystem: fs}, testRequest{method: "PROPFIND", target: "/", header: depth1}, http.StatusMultiStatus)
+	want := hrefs.FindAllString(w.Body.String(), -1)
+	if n := fs.maxOpen.Load(); n != 1 {
+		t.Errorf("sequential PROPFIND opened %v files at once", n)
+	}
+
+	fs.maxOpen.Store(0)
+	w = checkStatus(t, &webdav.Handler{FileSystem: fs, PropFindWorkers: 4}, testRequest{method: "PROPFIND", target: "/", header: depth1}, http.StatusMultiStatus)
+	// Responses are in walk order
+	if got := hrefs.FindAllString(w.Body.String(), -1); len(got) != 21 || !slices.Equal(got, want) {
+		t.Errorf("concurrent responses = %v, want %v", got, want)
+	}
+	if n := fs.maxOpen.Load(); n < 2 || n > 4 {
+		t.Errorf("concurrent PROPFIND opened %v files at once, want 2 to 4", n)
+	}
+
+	// Walk errors stop the workers
+	h := &webdav.Handler{FileSystem: failingWalkFileSystem{webdav.LocalFileSystem(dir), 2}, PropFindWorkers: 4}
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/", header: depth1}, http.StatusMultiStatus)
+	if body := w.Body.String(); strings.Count(body, "<response ") != 2 || strings.Contains(body, "</multistatus>") {
+		t.Errorf("failed multistatus = %s, want 2 responses and no end", body)
+	}
+}
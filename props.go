@@ -0,0 +1,233 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// PropertyStore stores dead properties of WebDAV resources.
+//
+// A FileSystem with native support for metadata (e.g. extended attributes or
+// object tags) can implement PropertyStore itself. In that case the handler
+// sets the PreserveProperties flag in CopyOptions and MoveOptions and expects
+// the FileSystem to carry properties over on its own.
+type PropertyStore interface {
+	// GetProperties returns the properties of a resource. A resource without
+	// properties yields a nil map.
+	GetProperties(ctx context.Context, name string) (map[xml.Name]string, error)
+	// ListPropertyNames returns the names of the properties of a resource.
+	ListPropertyNames(ctx context.Context, name string) ([]xml.Name, error)
+	// PatchProperties sets and removes properties of a resource.
+	PatchProperties(ctx context.Context, name string, set map[xml.Name]string, remove []xml.Name) error
+	// DeleteProperties removes the properties of a resource and all of its
+	// descendants.
+	DeleteProperties(ctx context.Context, name string) error
+	// CopyProperties replaces the properties of dst with the ones of src. If
+	// recursive is set, the properties of the descendants are copied too.
+	CopyProperties(ctx context.Context, src, dst string, recursive bool) error
+	// MoveProperties moves the properties of src and all of its descendants
+	// to dst.
+	MoveProperties(ctx context.Context, src, dst string) error
+	// WalkProperties calls fn for each resource with properties.
+	WalkProperties(ctx context.Context, fn func(name string) error) error
+}
+
+// memPropertyStore is an in-memory PropertyStore.
+type memPropertyStore struct {
+	mu    sync.RWMutex
+	props map[string]map[xml.Name]string
+}
+
+var _ PropertyStore = (*memPropertyStore)(nil)
+
+// NewMemPropertyStore creates a new in-memory property store.
+func NewMemPropertyStore() PropertyStore {
+	return &memPropertyStore{props: make(map[string]map[xml.Name]string)}
+}
+
+func propKey(name string) string {
+	return path.Clean("/" + name)
+}
+
+// isDescendant reports whether the resource p is name or lives below it.
+func isDescendant(p, name string) bool {
+	return p == name || name == "/" || strings.HasPrefix(p, name+"/")
+}
+
+func (s *memPropertyStore) GetProperties(ctx context.Context, name string) (map[xml.Name]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	props := s.props[propKey(name)]
+	if props == nil {
+		return nil, nil
+	}
+	m := make(map[xml.Name]string, len(props))
+	for k, v := range props {
+		m[k] = v
+	}
+	return m, nil
+}
+
+func (s *memPropertyStore) ListPropertyNames(ctx context.Context, name string) ([]xml.Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	props := s.props[propKey(name)]
+	names := make([]xml.Name, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	return names, nil
+}
+
+func (s *memPropertyStore) PatchProperties(ctx context.Context, name string, set map[xml.Name]string, remove []xml.Name) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := propKey(name)
+	props := s.props[key]
+	if props == nil {
+		props = make(map[xml.Name]string)
+	}
+	for _, k := range remove {
+		delete(props, k)
+	}
+	for k, v := range set {
+		props[k] = v
+	}
+
+	if len(props) == 0 {
+		delete(s.props, key)
+	} else {
+		s.props[key] = props
+	}
+	return nil
+}
+
+func (s *memPropertyStore) DeleteProperties(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteLocked(propKey(name))
+	return nil
+}
+
+func (s *memPropertyStore) deleteLocked(key string) {
+	for p := range s.props {
+		if isDescendant(p, key) {
+			delete(s.props, p)
+		}
+	}
+}
+
+func (s *memPropertyStore) CopyProperties(ctx context.Context, src, dst string, recursive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.copyLocked(propKey(src), propKey(dst), recursive)
+	return nil
+}
+
+func (s *memPropertyStore) copyLocked(src, dst string, recursive bool) {
+	copied := make(map[string]map[xml.Name]string)
+	for p, props := range s.props {
+		if p != src && !(recursive && isDescendant(p, src)) {
+			continue
+		}
+		m := make(map[xml.Name]string, len(props))
+		for k, v := range props {
+			m[k] = v
+		}
+		copied[path.Join(dst, strings.TrimPrefix(p, src))] = m
+	}
+
+	s.deleteLocked(dst)
+	for p, props := range copied {
+		s.props[p] = props
+	}
+}
+
+func (s *memPropertyStore) MoveProperties(ctx context.Context, src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, dst = propKey(src), propKey(dst)
+	s.copyLocked(src, dst, true)
+	if !isDescendant(dst, src) {
+		s.deleteLocked(src)
+	}
+	return nil
+}
+
+func (s *memPropertyStore) WalkProperties(ctx context.Context, fn func(name string) error) error {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.props))
+	for p := range s.props {
+		names = append(names, p)
+	}
+	s.mu.RUnlock()
+
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanOrphanedProperties removes the properties of resources which no longer
+// exist in fs, e.g. because they have been deleted without going through
+// WebDAV. It returns the number of resources whose properties have been
+// removed.
+func CleanOrphanedProperties(ctx context.Context, fs FileSystem, store PropertyStore) (int, error) {
+	var orphans []string
+	err := store.WalkProperties(ctx, func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := fs.Stat(ctx, name)
+		if internal.IsNotFound(err) {
+			orphans = append(orphans, name)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range orphans {
+		if err := store.DeleteProperties(ctx, name); err != nil {
+			return 0, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// newDeadProp returns an XML value suitable for encoding a dead property.
+func newDeadProp(name xml.Name, value string) interface{} {
+	// Handle properties with empty namespaces differently to avoid invalid XML
+	if name.Space == "" {
+		return &struct {
+			XMLName xml.Name `xml:","`
+			Value   string   `xml:",chardata"`
+		}{
+			XMLName: xml.Name{Local: name.Local},
+			Value:   value,
+		}
+	}
+
+	return &struct {
+		XMLName xml.Name `xml:""`
+		Value   string   `xml:",chardata"`
+	}{
+		XMLName: name,
+		Value:   value,
+	}
+}
@@ -0,0 +1,274 @@
+package webdav_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// propPatch returns the body of a PROPPATCH request with the given set or
+// remove instructions, with the "D" prefix bound to DAV: and "Z" to
+// urn:test.
+func propPatch(instructions string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:test">` + instructions + `</D:propertyupdate>`
+}
+
+// propFind returns the body of a PROPFIND request for the given properties,
+// with the same prefixes as propPatch.
+func propFind(props string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:Z="urn:test"><D:prop>` + props + `</D:prop></D:propfind>`
+}
+
+func TestContentLanguage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+	depth0 := map[string]string{"Depth": "0"}
+
+	w := checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatch(`<D:set><D:prop><D:getcontentlanguage>fr</D:getcontentlanguage></D:prop></D:set>`)}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), "200 OK") {
+		t.Errorf("PROPPATCH getcontentlanguage failed\n%s", w.Body)
+	}
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	if got := w.Header().Get("Content-Language"); got != "fr" {
+		t.Errorf("Content-Language = %q, want fr", got)
+	}
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propFind(`<D:getcontentlanguage/>`), header: depth0}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), ">fr</getcontentlanguage>") {
+		t.Errorf("PROPFIND getcontentlanguage = %s", w.Body)
+	}
+
+	// Other DAV: properties are computed by the server
+	w = checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatch(`<D:set><D:prop><D:getcontenttype>text/html</D:getcontenttype></D:prop></D:set>`)}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), "403 Forbidden") {
+		t.Errorf("PROPPATCH getcontenttype wasn't rejected\n%s", w.Body)
+	}
+
+	checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatch(`<D:remove><D:prop><D:getcontentlanguage/></D:prop></D:remove>`)}, http.StatusMultiStatus)
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+	if got := w.Header().Get("Content-Language"); got != "" {
+		t.Errorf("Content-Language = %q after removing the property", got)
+	}
+}
+
+// nativePropsFileSystem is a FileSystem storing properties itself, which
+// records the options of Copy and Move.
+type nativePropsFileSystem struct {
+	webdav.LocalFileSystem
+	webdav.PropertyStore
+	copyOpts webdav.CopyOptions
+	moveOpts webdav.MoveOptions
+}
+
+func (fs *nativePropsFileSystem) Copy(ctx context.Context, src, dst string, opts *webdav.CopyOptions) (bool, error) {
+	fs.copyOpts = *opts
+	return fs.LocalFileSystem.Copy(ctx, src, dst, opts)
+}
+
+func (fs *nativePropsFileSystem) Move(ctx context.Context, src, dst string, opts *webdav.MoveOptions) (bool, error) {
+	fs.moveOpts = *opts
+	return fs.LocalFileSystem.Move(ctx, src, dst, opts)
+}
+
+func TestPropertyStoreCopyMove(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/b.txt": "b", "c.txt": "c"})
+	props := webdav.NewMemPropertyStore()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), PropertyStore: props}
+	color := func(name string) string {
+		t.Helper()
+		p, err := props.GetProperties(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p[xml.Name{Space: "urn:test", Local: "color"}]
+	}
+	for _, name := range []string{"/a", "/a/b.txt"} {
+		checkStatus(t, h, testRequest{method: "PROPPATCH", target: name, body: propPatchColor}, http.StatusMultiStatus)
+	}
+
+	copyTo := func(dest, depth string) {
+		t.Helper()
+		checkStatus(t, h, testRequest{method: "COPY", target: "/a", header: map[string]string{"Destination": dest, "Depth": depth}}, http.StatusCreated)
+	}
+	copyTo("/d", "infinity")
+	copyTo("/e", "0")
+	if color("/d") != "red" || color("/d/b.txt") != "red" || color("/e") != "red" {
+		t.Errorf("properties not copied")
+	}
+	if color("/e/b.txt") != "" {
+		t.Errorf("properties of members copied with Depth: 0")
+	}
+
+	// Moves carry the properties over, including to replaced resources
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/a/b.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusNoContent)
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/c.txt", header: map[string]string{"Destination": "/f.txt"}}, http.StatusCreated)
+	if color("/f.txt") != "red" || color("/c.txt") != "" || color("/a/b.txt") != "" {
+		t.Errorf("properties not moved")
+	}
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/d"}, http.StatusNoContent)
+	if color("/d") != "" || color("/d/b.txt") != "" {
+		t.Errorf("properties of deleted resources kept")
+	}
+
+	// FileSystems storing properties carry them over themselves
+	fs := &nativePropsFileSystem{LocalFileSystem: webdav.LocalFileSystem(dir), PropertyStore: webdav.NewMemPropertyStore()}
+	h = &webdav.Handler{FileSystem: fs}
+	checkStatus(t, h, testRequest{method: "COPY", target: "/f.txt", header: map[string]string{"Destination": "/g.txt"}}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/g.txt", header: map[string]string{"Destination": "/h.txt"}}, http.StatusCreated)
+	if !fs.copyOpts.PreserveProperties || !fs.moveOpts.PreserveProperties {
+		t.Errorf("PreserveProperties = %v, %v, want true", fs.copyOpts.PreserveProperties, fs.moveOpts.PreserveProperties)
+	}
+	h = &webdav.Handler{FileSystem: fs, PropertyStore: props}
+	checkStatus(t, h, testRequest{method: "COPY", target: "/f.txt", header: map[string]string{"Destination": "/i.txt"}}, http.StatusCreated)
+	if fs.copyOpts.PreserveProperties || color("/i.txt") != "red" {
+		t.Errorf("properties of a separate store not copied by the handler")
+	}
+}
+
+func TestExecutable(t *testing.T) {
+	setExecutable := func(v string) string {
+		return `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:A="http://apache.org/dav/props/"><D:set><D:prop><A:executable>` + v + `</A:executable></D:prop></D:set></D:propertyupdate>`
+	}
+	const removeExecutable = `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:A="http://apache.org/dav/props/"><D:remove><D:prop><A:executable/></D:prop></D:remove></D:propertyupdate>`
+	const findExecutable = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:A="http://apache.org/dav/props/"><D:prop><A:executable/></D:prop></D:propfind>`
+
+	for name := range testFileSystems(t, t.TempDir()) {
+		if name == "legacy" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.sh": "echo", "d/": ""})
+			h := &webdav.Handler{FileSystem: testFileSystems(t, dir)[name]}
+			checkMode := func(want os.FileMode) {
+				t.Helper()
+				fi, err := os.Stat(filepath.Join(dir, "a.sh"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := fi.Mode() & 0100; got != want {
+					t.Errorf("owner executable bit = %v, want %v", got, want)
+				}
+			}
+			checkProp := func(want string) {
+				t.Helper()
+				w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.sh", body: findExecutable, header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+				if !strings.Contains(w.Body.String(), ">"+want+"</executable>") {
+					t.Errorf("PROPFIND executable = %s, want %v", w.Body, want)
+				}
+			}
+
+			checkProp("F")
+			checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.sh", body: setExecutable("T")}, http.StatusMultiStatus)
+			checkMode(0100)
+			checkProp("T")
+			checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.sh", body: removeExecutable}, http.StatusMultiStatus)
+			checkMode(0)
+			checkProp("F")
+
+			// Only T and F are valid, and only files can be executable
+			w := checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.sh", body: setExecutable("yes")}, http.StatusMultiStatus)
+			if !strings.Contains(w.Body.String(), "403 Forbidden") {
+				t.Errorf("PROPPATCH executable=yes wasn't rejected\n%s", w.Body)
+			}
+			checkMode(0)
+			checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/d", body: setExecutable("T")}, http.StatusConflict)
+		})
+	}
+}
+
+// failingStatFileSystem fails to look up resources.
+type failingStatFileSystem struct {
+	webdav.FileSystem
+}
+
+func (fs failingStatFileSystem) Stat(ctx context.Context, name string) (*webdav.FileInfo, error) {
+	return nil, errors.New("stat failed")
+}
+
+func TestCleanOrphanedProperties(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "d/c.txt": "c"})
+	props := webdav.NewMemPropertyStore()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), PropertyStore: props}
+	for _, name := range []string{"/a.txt", "/b.txt", "/d", "/d/c.txt"} {
+		checkStatus(t, h, testRequest{method: "PROPPATCH", target: name, body: propPatchColor}, http.StatusMultiStatus)
+	}
+
+	// Removed without going through WebDAV
+	for _, name := range []string{"b.txt", "d"} {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.CleanOrphanedProperties(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("CleanOrphanedProperties() with a canceled context = %v", err)
+	}
+	if _, err := webdav.CleanOrphanedProperties(context.Background(), failingStatFileSystem{h.FileSystem}, props); err == nil {
+		t.Errorf("CleanOrphanedProperties() with failing lookups = nil, want an error")
+	}
+
+	n, err := h.CleanOrphanedProperties(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("CleanOrphanedProperties() = %v, want 3", n)
+	}
+	for name, want := range map[string]bool{"/a.txt": true, "/b.txt": false, "/d": false, "/d/c.txt": false} {
+		if p, _ := props.GetProperties(context.Background(), name); (p != nil) != want {
+			t.Errorf("properties of %v = %v", name, p)
+		}
+	}
+}
+
+// failingNamesStore fails to list property names.
+type failingNamesStore struct {
+	webdav.PropertyStore
+}
+
+func (s failingNamesStore) ListPropertyNames(ctx context.Context, name string) ([]xml.Name, error) {
+	return nil, errors.New("list failed")
+}
+
+func TestPropName(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	props := webdav.NewMemPropertyStore()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), PropertyStore: props}
+	const propName = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:propname/></D:propfind>`
+	depth0 := map[string]string{"Depth": "0"}
+
+	checkStatus(t, h, testRequest{method: "PROPPATCH", target: "/a.txt", body: propPatchColor}, http.StatusMultiStatus)
+	w := checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propName, header: depth0}, http.StatusMultiStatus)
+	body := w.Body.String()
+	for _, name := range []string{`<color xmlns="urn:test">`, "<getcontentlength"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("propname response without %v\n%s", name, body)
+		}
+	}
+	// Only names are listed
+	if strings.Contains(body, "red") || strings.Contains(body, ">1</") {
+		t.Errorf("propname response with values\n%s", body)
+	}
+
+	h = &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), PropertyStore: failingNamesStore{props}}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propName, header: depth0}, http.StatusInternalServerError)
+}
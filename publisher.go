@@ -0,0 +1,112 @@
+package webdav
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// EventPublisher publishes the changes made through a handler to a message
+// bus, so that WebDAV activity can be streamed into event-driven
+// infrastructure. NATSPublisher and KafkaPublisher are reference
+// implementations; others can be written with any client library.
+//
+// Set it as Handler.Publisher.
+type EventPublisher interface {
+	// Publish publishes an event, and returns once it's been accepted by
+	// the bus.
+	Publish(ctx context.Context, event *ChangeEvent) error
+}
+
+// publisherQueueSize is the number of events waiting for publication beyond
+// which new ones are dropped.
+const publisherQueueSize = 1000
+
+// publisherQueue publishes events in the background, in order, so that
+// requests don't wait for the message bus.
+type publisherQueue struct {
+	publisher EventPublisher
+	logger    *slog.Logger
+	ctx       context.Context // canceled by close
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	queue   chan *ChangeEvent
+	closed  bool
+	pending sync.WaitGroup
+}
+
+var _ Flusher = (*publisherQueue)(nil)
+
+func newPublisherQueue(publisher EventPublisher, logger *slog.Logger) *publisherQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &publisherQueue{publisher: publisher, logger: logger, ctx: ctx, cancel: cancel}
+}
+
+func (q *publisherQueue) send(event *ChangeEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	if q.queue == nil {
+		q.queue = make(chan *ChangeEvent, publisherQueueSize)
+		go q.run()
+	}
+	q.pending.Add(1)
+	select {
+	case q.queue <- event:
+	default:
+		q.pending.Done()
+		if q.logger != nil {
+			q.logger.Warn("webdav: event publisher queue full, event dropped", "id", event.ID, "type", event.Type, "path", event.Path)
+		}
+	}
+}
+
+// Flush implements Flusher by waiting for the queued events to be published,
+// or to fail, until ctx is done.
+func (q *publisherQueue) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close waits for the queued events to be published until ctx is done, then
+// stops publishing. Publications in progress are canceled and the remaining
+// events dropped.
+func (q *publisherQueue) close(ctx context.Context) error {
+	err := q.Flush(ctx)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		q.cancel()
+		if q.queue != nil {
+			close(q.queue)
+		}
+	}
+	return err
+}
+
+func (q *publisherQueue) run() {
+	for event := range q.queue {
+		if q.ctx.Err() != nil {
+			// Closed, drop the remaining events
+			q.pending.Done()
+			continue
+		}
+		if err := q.publisher.Publish(q.ctx, event); err != nil && q.logger != nil {
+			q.logger.Warn("webdav: failed to publish event", "id", event.ID, "type", event.Type, "path", event.Path, "error", err)
+		}
+		q.pending.Done()
+	}
+}
@@ -0,0 +1,89 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaPublisher is an EventPublisher producing events to a Kafka topic
+// through a Kafka REST Proxy (API v2), as ChangeEvents encoded in JSON keyed
+// by path, so that the events of a resource land in the same partition and
+// stay in order. Going through the proxy keeps Kafka client libraries out of
+// the dependencies; with a native client, implement EventPublisher instead.
+type KafkaPublisher struct {
+	// URL is the base URL of the REST Proxy, e.g. "http://localhost:8082".
+	URL string
+	// Topic is the topic events are produced to.
+	Topic string
+	// Header is added to requests, e.g. for authentication.
+	Header http.Header
+	// Client sends the requests, defaults to a client with a 10 seconds
+	// timeout.
+	Client *http.Client
+}
+
+var _ EventPublisher = (*KafkaPublisher)(nil)
+
+// Publish implements EventPublisher, and returns once the event has been
+// written to the topic.
+func (p *KafkaPublisher) Publish(ctx context.Context, event *ChangeEvent) error {
+	type record struct {
+		Key   string       `json:"key"`
+		Value *ChangeEvent `json:"value"`
+	}
+	body, err := json.Marshal(struct {
+		Records []record `json:"records"`
+	}{[]record{{Key: event.Path, Value: event}}})
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimSuffix(p.URL, "/") + "/topics/" + url.PathEscape(p.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Message string `json:"message"`
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result)
+	if resp.StatusCode/100 != 2 {
+		if result.Message != "" {
+			return fmt.Errorf("webdav: Kafka REST Proxy replied %v: %v", resp.Status, result.Message)
+		}
+		return fmt.Errorf("webdav: Kafka REST Proxy replied %v", resp.Status)
+	}
+	for _, o := range result.Offsets {
+		if o.ErrorCode != nil || o.Error != "" {
+			return fmt.Errorf("webdav: failed to produce Kafka record: %v", o.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package webdav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSPublisher is an EventPublisher publishing events to a NATS server, as
+// ChangeEvents encoded in JSON, on the subject Subject + "." + type, e.g.
+// "webdav.create". The event ID is sent in the Nats-Msg-Id header, so that
+// JetStream streams can discard duplicates.
+//
+// It speaks the NATS client protocol, and keeps a connection open until
+// Close is called. It's safe for concurrent use.
+type NATSPublisher struct {
+	// URL is the address of the server, e.g. "nats://localhost:4222", or
+	// "tls://localhost:4222" to require TLS. Credentials in its user
+	// information are sent as user and password, or as token if there's no
+	// password.
+	URL string
+	// Subject is the prefix of the subjects, defaults to "webdav".
+	Subject string
+	// TLSConfig configures TLS connections.
+	TLSConfig *tls.Config
+	// Timeout bounds connecting and publishing, defaults to 10 seconds.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	headers bool
+}
+
+var _ EventPublisher = (*NATSPublisher)(nil)
+
+// Publish implements EventPublisher, and returns once the server
+// acknowledged the message.
+func (p *NATSPublisher) Publish(ctx context.Context, event *ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	prefix := p.Subject
+	if prefix == "" {
+		prefix = "webdav"
+	}
+	subject := prefix + "." + string(event.Type)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reused := p.conn != nil
+	err = p.publish(ctx, subject, event.ID, payload)
+	if err != nil && reused && ctx.Err() == nil {
+		// The server may have closed the idle connection
+		p.closeConn()
+		err = p.publish(ctx, subject, event.ID, payload)
+	}
+	if err != nil {
+		p.closeConn()
+	}
+	return err
+}
+
+// Close closes the connection to the server, if any.
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeConn()
+}
+
+func (p *NATSPublisher) closeConn() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.r = nil, nil
+	return err
+}
+
+func (p *NATSPublisher) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return 10 * time.Second
+}
+
+// publish sends a message followed by a PING, and waits for the PONG
+// confirming that the server processed it.
+func (p *NATSPublisher) publish(ctx context.Context, subject, id string, payload []byte) error {
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	stop := p.setDeadline(ctx)
+	defer stop()
+
+	var buf bytes.Buffer
+	if p.headers && id != "" {
+		hdr := "NATS/1.0\r\nNats-Msg-Id: " + id + "\r\n\r\n"
+		fmt.Fprintf(&buf, "HPUB %s %d %d\r\n%s", subject, len(hdr), len(hdr)+len(payload), hdr)
+	} else {
+		fmt.Fprintf(&buf, "PUB %s %d\r\n", subject, len(payload))
+	}
+	buf.Write(payload)
+	buf.WriteString("\r\nPING\r\n")
+	if _, err := p.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return p.waitPong()
+}
+
+// setDeadline bounds the next operations on the connection by the timeout
+// and ctx. The returned function clears the deadline.
+func (p *NATSPublisher) setDeadline(ctx context.Context) func() {
+	conn := p.conn
+	deadline := time.Now().Add(p.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		stop()
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// connect connects and authenticates to the server.
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	d := net.Dialer{Timeout: p.timeout()}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	p.conn, p.r = conn, bufio.NewReader(conn)
+	stop := p.setDeadline(ctx)
+	defer stop()
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("webdav: unexpected NATS greeting %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		Headers     bool `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("webdav: malformed NATS server info: %w", err)
+	}
+
+	useTLS := u.Scheme == "tls" || info.TLSRequired
+	if useTLS {
+		cfg := &tls.Config{}
+		if p.TLSConfig != nil {
+			cfg = p.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		p.conn, p.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	opts := map[string]any{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": useTLS,
+		"name":         "fiber-webdav",
+		"lang":         "go",
+		"version":      "0",
+		"protocol":     1,
+		"headers":      info.Headers,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts["user"], opts["pass"] = u.User.Username(), pass
+		} else {
+			opts["auth_token"] = u.User.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", connectJSON); err != nil {
+		return err
+	}
+	if err := p.waitPong(); err != nil {
+		return err
+	}
+	p.headers = info.Headers
+	return nil
+}
+
+// waitPong reads server messages until a PONG, answering PINGs.
+func (p *NATSPublisher) waitPong() error {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("webdav: NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates are ignored
+	}
+}
+
+func (p *NATSPublisher) readLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,214 @@
+package webdav_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// testPublisher records the events published, failing for the path fail.
+type testPublisher struct {
+	mu     sync.Mutex
+	events []*webdav.ChangeEvent
+	fail   string
+}
+
+func (p *testPublisher) Publish(ctx context.Context, event *webdav.ChangeEvent) error {
+	if event.Path == p.fail {
+		return fmt.Errorf("failed to publish %v", event.Path)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	publisher := &testPublisher{fail: "/b.txt"}
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(t.TempDir()), Publisher: publisher}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a", user: "alice"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "aa"}, http.StatusNoContent)
+	if err := h.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed publications don't hold up the following events
+	if len(publisher.events) != 2 {
+		t.Fatalf("published %v events, want 2", len(publisher.events))
+	}
+	if e := publisher.events[0]; e.Type != webdav.ChangeCreate || e.Path != "/a.txt" || e.User != "alice" || e.Size == nil || *e.Size != 1 {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := publisher.events[1]; e.Type != webdav.ChangeUpdate || e.Path != "/a.txt" {
+		t.Errorf("second event = %+v", e)
+	}
+}
+
+func TestPublisherShutdown(t *testing.T) {
+	canceled := make(chan struct{})
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		Publisher: publisherFunc(func(ctx context.Context, event *webdav.ChangeEvent) error {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		}),
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusCreated)
+
+	// Publications still in progress when ctx is done are canceled
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Errorf("publication not canceled")
+	}
+}
+
+type publisherFunc func(ctx context.Context, event *webdav.ChangeEvent) error
+
+func (f publisherFunc) Publish(ctx context.Context, event *webdav.ChangeEvent) error {
+	return f(ctx, event)
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var records []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/topics/webdav%2Fevents" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" || r.Header.Get("X-Api-Key") != "key" {
+			t.Errorf("unexpected request %v %v %v", r.URL, r.Header.Get("Content-Type"), r.Header.Get("X-Api-Key"))
+		}
+		var body struct {
+			Records []struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			} `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		for _, rec := range body.Records {
+			if rec.Key == "/fail.txt" {
+				io.WriteString(w, `{"offsets": [{"error_code": 50002, "error": "broker unavailable"}]}`)
+				return
+			}
+			records = append(records, rec.Value)
+		}
+		io.WriteString(w, `{"offsets": [{"partition": 0, "offset": 1}]}`)
+	}))
+	defer srv.Close()
+
+	p := &webdav.KafkaPublisher{URL: srv.URL + "/", Topic: "webdav/events", Header: http.Header{"X-Api-Key": {"key"}}}
+	if err := p.Publish(t.Context(), &webdav.ChangeEvent{ID: "1", Type: webdav.ChangeCreate, Path: "/a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(t.Context(), &webdav.ChangeEvent{ID: "2", Type: webdav.ChangeCreate, Path: "/fail.txt"}); err == nil || !strings.Contains(err.Error(), "broker unavailable") {
+		t.Errorf("Publish() = %v, want the error of the record", err)
+	}
+	if len(records) != 1 || !strings.Contains(string(records[0]), `"path":"/a.txt"`) {
+		t.Errorf("records = %s", records)
+	}
+}
+
+// natsMessage is a message received by a test NATS server.
+type natsMessage struct {
+	subject, header, payload string
+}
+
+// serveNATS accepts a connection on ln and speaks enough of the NATS
+// protocol to receive messages, sent to msgs. The CONNECT options are sent
+// to connect.
+func serveNATS(t *testing.T, ln net.Listener, connect chan<- string, msgs chan<- natsMessage) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	io.WriteString(conn, "INFO {\"server_id\":\"test\",\"headers\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "CONNECT":
+			connect <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+		case fields[0] == "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case fields[0] == "HPUB" && len(fields) == 4:
+			var hlen, total int
+			fmt.Sscan(fields[2], &hlen)
+			fmt.Sscan(fields[3], &total)
+			b := make([]byte, total+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				t.Error(err)
+				return
+			}
+			msgs <- natsMessage{fields[1], string(b[:hlen]), string(b[hlen:total])}
+		default:
+			t.Errorf("unexpected NATS command %q", line)
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	connect := make(chan string, 1)
+	msgs := make(chan natsMessage, 2)
+	go serveNATS(t, ln, connect, msgs)
+
+	p := &webdav.NATSPublisher{URL: "nats://alice:secret@" + ln.Addr().String(), Subject: "dav"}
+	defer p.Close()
+	for _, event := range []*webdav.ChangeEvent{
+		{ID: "1", Type: webdav.ChangeCreate, Path: "/a.txt"},
+		{ID: "2", Type: webdav.ChangeDelete, Path: "/a.txt"},
+	} {
+		if err := p.Publish(t.Context(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var opts struct {
+		User    string `json:"user"`
+		Pass    string `json:"pass"`
+		Headers bool   `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(<-connect), &opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.User != "alice" || opts.Pass != "secret" || !opts.Headers {
+		t.Errorf("CONNECT options = %+v", opts)
+	}
+	for _, want := range []natsMessage{
+		{"dav.create", "Nats-Msg-Id: 1", `"path":"/a.txt"`},
+		{"dav.delete", "Nats-Msg-Id: 2", `"type":"delete"`},
+	} {
+		msg := <-msgs
+		if msg.subject != want.subject || !strings.Contains(msg.header, want.header) || !strings.Contains(msg.payload, want.payload) {
+			t.Errorf("message = %+v, want %+v", msg, want)
+		}
+	}
+}
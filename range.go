@@ -0,0 +1,99 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// RangeFileSystem is implemented by FileSystems which can read part of a file
+// natively, e.g. object stores supporting ranged downloads. It's used to serve
+// GET requests with a Range header when Open doesn't return an io.Seeker,
+// enabling video scrubbing and resumed downloads.
+type RangeFileSystem interface {
+	// OpenRange opens length bytes of the file name, starting at offset off.
+	// The range is always within the size returned by Stat.
+	OpenRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error)
+}
+
+// byteRange is a range of bytes of a file.
+type byteRange struct {
+	start, length int64
+}
+
+func (br *byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// requestedRange returns the byte range of fi requested by r, or nil if the
+// whole file must be served. Multiple ranges, malformed Range headers and
+// failing If-Range conditions are ignored, as allowed by RFC 9110 section
+// 14.2.
+func requestedRange(w http.ResponseWriter, r *http.Request, fi *FileInfo) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !ok || strings.Contains(spec, ",") || !ifRangeMatches(r.Header.Get("If-Range"), fi) {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	var br byteRange
+	if first == "" {
+		// Suffix range: the last bytes of the file
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || fi.Size == 0 {
+			return nil, rangeNotSatisfiable(w, fi)
+		}
+		br.length = min(n, fi.Size)
+		br.start = fi.Size - br.length
+		return &br, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := fi.Size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return nil, nil
+		}
+	}
+	if start >= fi.Size {
+		return nil, rangeNotSatisfiable(w, fi)
+	}
+	br.start = start
+	br.length = min(end, fi.Size-1) - start + 1
+	return &br, nil
+}
+
+func rangeNotSatisfiable(w http.ResponseWriter, fi *FileInfo) error {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size))
+	return &internal.HTTPError{Code: http.StatusRequestedRangeNotSatisfiable}
+}
+
+// ifRangeMatches evaluates an If-Range header, which must strongly match the
+// ETag or exactly match the modification time of the file. Weak ETags never
+// match.
+func ifRangeMatches(ifRange string, fi *FileInfo) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		ok, err := ConditionalMatch(ifRange).MatchETag(fi.ETag)
+		return err == nil && ok && !fi.WeakETag
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !fi.ModTime.IsZero() && fi.ModTime.Truncate(time.Second).Equal(t)
+}
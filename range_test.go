@@ -0,0 +1,117 @@
+package webdav_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// rangeFileSystem serves files as plain readers, and ranges natively.
+type rangeFileSystem struct {
+	streamFileSystem
+	ranges *[]string
+}
+
+func (fs rangeFileSystem) OpenRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	*fs.ranges = append(*fs.ranges, name)
+	f, err := fs.LocalFileSystem.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.(io.Seeker).Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+func TestOpenRange(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "0123456789", "page": "<html><body>" + strings.Repeat("x", 1000) + "</body></html>"})
+	var ranges []string
+	h := &webdav.Handler{FileSystem: rangeFileSystem{streamFileSystem{webdav.LocalFileSystem(dir)}, &ranges}}
+
+	etag := checkStatus(t, h, testRequest{method: http.MethodHead, target: "/a.txt"}, http.StatusOK).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	tests := []struct {
+		rangeHeader, ifRange string
+		want                 int
+		body, contentRange   string
+	}{
+		{"bytes=2-5", "", http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"bytes=7-", "", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=-3", "", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=8-100", "", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"bytes=-100", "", http.StatusPartialContent, "0123456789", "bytes 0-9/10"},
+		{"bytes=2-5", etag, http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		// Unsatisfiable ranges
+		{"bytes=10-", "", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"bytes=-0", "", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		// Ignored ranges
+		{"bytes=0-1,4-5", "", http.StatusOK, "0123456789", ""},
+		{"bytes=5-2", "", http.StatusOK, "0123456789", ""},
+		{"bytes=x-2", "", http.StatusOK, "0123456789", ""},
+		{"items=0-1", "", http.StatusOK, "0123456789", ""},
+		{"bytes=2-5", `"other"`, http.StatusOK, "0123456789", ""},
+		{"bytes=2-5", "W/" + etag, http.StatusOK, "0123456789", ""},
+		{"bytes=2-5", "Mon, 02 Jan 2006 15:04:05 GMT", http.StatusOK, "0123456789", ""},
+	}
+	for _, tc := range tests {
+		header := map[string]string{"Range": tc.rangeHeader}
+		if tc.ifRange != "" {
+			header["If-Range"] = tc.ifRange
+		}
+		w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", header: header}, tc.want)
+		if tc.want == http.StatusRequestedRangeNotSatisfiable {
+			if got := w.Header().Get("Content-Range"); got != tc.contentRange {
+				t.Errorf("Range %q: Content-Range = %q, want %q", tc.rangeHeader, got, tc.contentRange)
+			}
+			continue
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("Range %q: body = %q, want %q", tc.rangeHeader, w.Body, tc.body)
+		}
+		if got := w.Header().Get("Content-Range"); got != tc.contentRange {
+			t.Errorf("Range %q: Content-Range = %q, want %q", tc.rangeHeader, got, tc.contentRange)
+		}
+		if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+			t.Errorf("Range %q: Accept-Ranges = %q, want bytes", tc.rangeHeader, got)
+		}
+	}
+
+	if len(ranges) == 0 {
+		t.Errorf("ranges not served with OpenRange")
+	}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodHead, target: "/a.txt", header: map[string]string{"Range": "bytes=2-5"}}, http.StatusPartialContent)
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "4" {
+		t.Errorf("HEAD = %q with Content-Length %q, want no body and 4", w.Body, w.Header().Get("Content-Length"))
+	}
+
+	// Content types are detected from the beginning of the file
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/page", header: map[string]string{"Range": "bytes=500-509"}}, http.StatusPartialContent)
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want HTML", ct)
+	}
+	if w.Body.String() != strings.Repeat("x", 10) {
+		t.Errorf("body = %q", w.Body)
+	}
+
+	// Ranges of file systems without OpenRange are served in full
+	h = &webdav.Handler{FileSystem: streamFileSystem{webdav.LocalFileSystem(dir)}}
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", header: map[string]string{"Range": "bytes=2-5"}}, http.StatusOK)
+	if w.Body.String() != "0123456789" {
+		t.Errorf("body without OpenRange = %q", w.Body)
+	}
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/missing", header: map[string]string{"Range": "bytes=2-5"}}, http.StatusNotFound)
+}
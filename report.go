@@ -0,0 +1,83 @@
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// ReportFunc handles a REPORT request. The request body holds the XML report
+// element and can be decoded again by the function, e.g. with xml.NewDecoder.
+// Errors created with NewHTTPError are reported with their status code.
+type ReportFunc func(w http.ResponseWriter, r *http.Request) error
+
+// HandleReport registers the function handling REPORT requests whose body
+// root element is name, e.g. sync-collection or expand-property.
+func (h *Handler) HandleReport(name xml.Name, f ReportFunc) {
+	if h.Reports == nil {
+		h.Reports = make(map[xml.Name]ReportFunc)
+	}
+	h.Reports[name] = f
+}
+
+// reportNames returns the names of the supported reports, sorted for stable
+// PROPFIND output.
+func (b *backend) reportNames() []xml.Name {
+	names := make([]xml.Name, 0, len(b.Reports))
+	for name := range b.Reports {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Space != names[j].Space {
+			return names[i].Space < names[j].Space
+		}
+		return names[i].Local < names[j].Local
+	})
+	return names
+}
+
+func (b *backend) Report(w http.ResponseWriter, r *http.Request) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	var report internal.RawXMLValue
+	if err := internal.DecodeXMLRequest(r, &report); err != nil {
+		return err
+	}
+	name, ok := report.XMLName()
+	if !ok {
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: missing report element")
+	}
+
+	f, ok := b.Reports[name]
+	if !ok {
+		return errUnsupportedReport()
+	}
+
+	if _, err := b.FileSystem.Stat(r.Context(), r.URL.Path); err != nil {
+		return err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return f(w, r)
+}
+
+// errUnsupportedReport returns the error reported for REPORT requests the
+// resource doesn't support.
+//
+// https://tools.ietf.org/html/rfc3253#section-3.6
+func errUnsupportedReport() error {
+	return &internal.HTTPError{
+		Code: http.StatusForbidden,
+		Err: &internal.Error{Raw: []internal.RawXMLValue{
+			*internal.NewRawXMLElement(internal.SupportedReportName, nil, nil),
+		}},
+	}
+}
@@ -0,0 +1,58 @@
+package webdav_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestReports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+	h.HandleReport(xml.Name{Space: "urn:test", Local: "echo"}, func(w http.ResponseWriter, r *http.Request) error {
+		var report struct {
+			Value string `xml:"urn:test value"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&report); err != nil {
+			return err
+		}
+		if report.Value == "" {
+			return webdav.NewHTTPError(http.StatusUnprocessableEntity, errors.New("missing value"))
+		}
+		fmt.Fprintf(w, "%v: %v", r.URL.Path, report.Value)
+		return nil
+	})
+	report := func(name, value string) string {
+		return `<?xml version="1.0" encoding="utf-8"?>
+<Z:` + name + ` xmlns:Z="urn:test"><Z:value>` + value + `</Z:value></Z:` + name + `>`
+	}
+
+	w := checkStatus(t, h, testRequest{method: "REPORT", target: "/a.txt", body: report("echo", "hello")}, http.StatusOK)
+	if got := w.Body.String(); got != "/a.txt: hello" {
+		t.Errorf("REPORT = %q, want %q", got, "/a.txt: hello")
+	}
+	checkStatus(t, h, testRequest{method: "REPORT", target: "/a.txt", body: report("echo", "")}, http.StatusUnprocessableEntity)
+	checkStatus(t, h, testRequest{method: "REPORT", target: "/missing", body: report("echo", "hello")}, http.StatusNotFound)
+	checkStatus(t, h, testRequest{method: "REPORT", target: "/a.txt", body: `<?xml version="1.0"?><Z:echo xmlns:Z="urn:test">`}, http.StatusBadRequest)
+	// Unknown reports are rejected with a precondition code
+	w = checkStatus(t, h, testRequest{method: "REPORT", target: "/a.txt", body: report("other", "hello")}, http.StatusForbidden)
+	if !strings.Contains(w.Body.String(), "supported-report") {
+		t.Errorf("unsupported REPORT error = %s", w.Body)
+	}
+
+	// Registered reports are advertised
+	w = checkStatus(t, h, testRequest{method: http.MethodOptions, target: "/a.txt"}, http.StatusNoContent)
+	if !strings.Contains(w.Header().Get("Allow"), "REPORT") {
+		t.Errorf("Allow = %q, want REPORT", w.Header().Get("Allow"))
+	}
+	w = checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt", body: propFind(`<D:supported-report-set/>`), header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus)
+	if !strings.Contains(w.Body.String(), `<echo xmlns="urn:test">`) {
+		t.Errorf("supported-report-set without the report\n%s", w.Body)
+	}
+}
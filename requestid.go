@@ -0,0 +1,68 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is the header field carrying request IDs. Handlers reuse
+// the request ID sent by clients or proxies, or generate one, and send it
+// back in responses so that failures reported by clients can be correlated
+// with server logs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen is the maximum length of request IDs accepted from clients.
+const maxRequestIDLen = 128
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request being served, e.g. to
+// include it in backend logs.
+func RequestIDFromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(requestIDContextKey{}).(string)
+	return id, ok
+}
+
+// withRequestID attaches the ID of a request to its context and sets it in the
+// response header.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	} else {
+		// The header may share memory with a buffer reused for other
+		// requests
+		id = strings.Clone(id)
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(ContextWithRequestID(r.Context(), id))
+}
+
+// validRequestID checks that a request ID sent by a client is safe to log and
+// send back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
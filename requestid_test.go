@@ -0,0 +1,76 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestRequestID(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	var got string
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		Hooks: webdav.Hooks{OnGet: func(ctx context.Context, event *webdav.Event) {
+			got, _ = webdav.RequestIDFromContext(ctx)
+		}},
+	}
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	tests := []struct {
+		name, id string
+		reused   bool
+	}{
+		{"missing", "", false},
+		{"valid", "abc-123_XYZ", true},
+		{"too long", strings.Repeat("a", 129), false},
+		{"space", "abc 123", false},
+		{"control character", "abc\x7f", false},
+		{"non-ASCII", "abcé", false},
+	}
+	for _, tc := range tests {
+		got = ""
+		w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", header: map[string]string{webdav.RequestIDHeader: tc.id}}, http.StatusOK)
+		id := w.Header().Get(webdav.RequestIDHeader)
+		if tc.reused && id != tc.id {
+			t.Errorf("%v: response ID = %q, want %q", tc.name, id, tc.id)
+		} else if !tc.reused && !generated.MatchString(id) {
+			t.Errorf("%v: response ID = %q, want a generated ID", tc.name, id)
+		}
+		if got != id {
+			t.Errorf("%v: context ID = %q, want %q", tc.name, got, id)
+		}
+	}
+
+	// Error responses carry the ID too
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/missing"}, http.StatusNotFound)
+	if !generated.MatchString(w.Header().Get(webdav.RequestIDHeader)) {
+		t.Errorf("404 response ID = %q, want a generated ID", w.Header().Get(webdav.RequestIDHeader))
+	}
+
+	// IDs generated by the requestid middleware are reused
+	app := fiber.New(fiber.Config{RequestMethods: webdav.ExtendedMethods})
+	app.Use(requestid.New(requestid.Config{Generator: func() string { return "fiber-id" }}))
+	app.Use(webdav.New(webdav.Config{Root: webdav.LocalFileSystem(dir), Hooks: h.Hooks}))
+	got = ""
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ids := resp.Header.Values(webdav.RequestIDHeader); len(ids) != 1 || ids[0] != "fiber-id" {
+		t.Errorf("Fiber response IDs = %q, want fiber-id", ids)
+	}
+	if got != "fiber-id" {
+		t.Errorf("Fiber context ID = %q, want fiber-id", got)
+	}
+}
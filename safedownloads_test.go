@@ -0,0 +1,44 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestSafeDownloads(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"x.html": "<script>alert(1)</script>", "é.html": "<p>"})
+
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), SafeDownloads: true}
+	tests := []struct{ name, disposition string }{
+		{"/x.html", `attachment; filename=x.html`},
+		{"/é.html", `attachment; filename*=utf-8''%C3%A9.html`},
+	}
+	for _, tc := range tests {
+		target := (&url.URL{Path: tc.name}).EscapedPath()
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			w := checkStatus(t, h, testRequest{method: method, target: target}, http.StatusOK)
+			if got := w.Header().Get("Content-Disposition"); got != tc.disposition {
+				t.Errorf("%v %v: Content-Disposition = %q, want %q", method, tc.name, got, tc.disposition)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("%v %v: X-Content-Type-Options = %q, want nosniff", method, tc.name, got)
+			}
+			if got := w.Header().Get("Content-Security-Policy"); !strings.Contains(got, "sandbox") {
+				t.Errorf("%v %v: Content-Security-Policy = %q, want a sandbox", method, tc.name, got)
+			}
+		}
+	}
+
+	h = &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir)}
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/x.html"}, http.StatusOK)
+	for _, k := range []string{"Content-Disposition", "X-Content-Type-Options", "Content-Security-Policy"} {
+		if got := w.Header().Get(k); got != "" {
+			t.Errorf("without SafeDownloads: %v = %q", k, got)
+		}
+	}
+}
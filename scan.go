@@ -0,0 +1,82 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// uploadVerifier verifies the file name uploaded with PUT or POST before it
+// replaces the existing file, see CreateOptions.Verify: check, if set, then
+// the ScanUpload function.
+type uploadVerifier struct {
+	b      *backend
+	name   string
+	check  func() error
+	called bool
+}
+
+// newUploadVerifier returns a verifier for the upload of the file name, or
+// nil if there's nothing to verify.
+func (b *backend) newUploadVerifier(name string, check func() error) *uploadVerifier {
+	if check == nil && b.ScanUpload == nil {
+		return nil
+	}
+	return &uploadVerifier{b: b, name: name, check: check}
+}
+
+// options sets the Verify function of opts.
+func (v *uploadVerifier) options(opts *CreateOptions) {
+	if v != nil {
+		opts.Verify = v.verify
+	}
+}
+
+// verify runs the check and passes the content of the upload to the
+// ScanUpload function. A rejected upload fails with the status of the error,
+// or "403 Forbidden" if it has none.
+func (v *uploadVerifier) verify(ctx context.Context, open func() (io.ReadCloser, error)) error {
+	v.called = true
+	if v.check != nil {
+		if err := v.check(); err != nil {
+			return err
+		}
+	}
+	if v.b.ScanUpload == nil {
+		return nil
+	}
+	f, err := open()
+	if err != nil {
+		return err
+	}
+	err = v.b.ScanUpload(ctx, v.name, f)
+	f.Close()
+	if err == nil {
+		return nil
+	}
+
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) {
+		return err
+	}
+	return &internal.HTTPError{Code: http.StatusForbidden, Err: err}
+}
+
+// finish verifies the upload once written if the FileSystem didn't stage it.
+// A rejected file is removed if the upload created it, a replaced file can't
+// be restored.
+func (v *uploadVerifier) finish(ctx context.Context, created bool) error {
+	if v == nil || v.called {
+		return nil
+	}
+	err := v.verify(ctx, func() (io.ReadCloser, error) {
+		return v.b.FileSystem.Open(ctx, v.name)
+	})
+	if err != nil && created {
+		v.b.FileSystem.RemoveAll(ctx, v.name, &RemoveAllOptions{})
+	}
+	return err
+}
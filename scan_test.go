@@ -0,0 +1,69 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// rejectInfected rejects uploads containing "virus".
+func rejectInfected(ctx context.Context, name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(b), "virus") {
+		return errors.New("infected")
+	}
+	return nil
+}
+
+func TestScanUpload(t *testing.T) {
+	for name := range testFileSystems(t, t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"a.txt": "old", "inbox/": ""})
+			h := &webdav.Handler{
+				FileSystem: testFileSystems(t, dir)[name],
+				ScanUpload: rejectInfected,
+			}
+
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "virus"}, http.StatusForbidden)
+			checkFile(t, dir, "a.txt", "old")
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "virus"}, http.StatusForbidden)
+			checkMissing(t, dir, "b.txt")
+			checkStatus(t, h, testRequest{method: http.MethodPost, target: "/inbox/", body: "virus"}, http.StatusForbidden)
+			if names, err := readDirNames(filepath.Join(dir, "inbox")); err != nil {
+				t.Fatal(err)
+			} else if len(names) != 0 {
+				t.Errorf("inbox entries = %v, want none", names)
+			}
+
+			checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "clean"}, http.StatusNoContent)
+			checkFile(t, dir, "a.txt", "clean")
+			if names, err := readDirNames(dir); err != nil {
+				t.Fatal(err)
+			} else if len(names) != 2 {
+				t.Errorf("directory entries = %v, want [a.txt inbox]", names)
+			}
+		})
+	}
+}
+
+func TestScanUploadStatus(t *testing.T) {
+	dir := t.TempDir()
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		ScanUpload: func(ctx context.Context, name string, r io.Reader) error {
+			return webdav.NewHTTPError(http.StatusUnavailableForLegalReasons, errors.New("blocked"))
+		},
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusUnavailableForLegalReasons)
+	checkMissing(t, dir, "a.txt")
+}
@@ -1,19 +1,39 @@
 package webdav
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gofiber/fiber/v2/log"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/Tryanks/fiber-webdav/internal"
 )
 
 // FileSystem is a WebDAV server backend.
+//
+// FileSystems report the failures of write operations themselves, so that the
+// handler doesn't need to look resources up beforehand: Create, Mkdir, Copy
+// and Move return a 409 Conflict HTTPError if the parent collection of the
+// destination doesn't exist, RemoveAll returns a 404 Not Found HTTPError for missing
+// resources, and Create returns the FileInfo of the written file.
 type FileSystem interface {
 	Open(ctx context.Context, name string) (io.ReadCloser, error)
 	Stat(ctx context.Context, name string) (*FileInfo, error)
@@ -25,41 +45,596 @@ type FileSystem interface {
 	Move(ctx context.Context, name, dest string, options *MoveOptions) (created bool, err error)
 }
 
+// ExecutableFileSystem is implemented by FileSystems which can change the
+// executable bit of files. It backs the executable property used by Apache's
+// mod_dav and clients such as cadaver and davfs2.
+type ExecutableFileSystem interface {
+	SetExecutable(ctx context.Context, name string, executable bool) error
+}
+
+// WalkFileSystem is implemented by FileSystems which can enumerate
+// collections incrementally. It's used instead of ReadDir to stream PROPFIND
+// responses, bounding memory for collections with many members.
+type WalkFileSystem interface {
+	// Walk calls fn for the resource name, then for its members and, if
+	// recursive is set, their descendants, like the results of ReadDir.
+	// Walking stops at the first error returned by fn.
+	Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error
+}
+
+// walk enumerates the resource name and its members with Walk if fs
+// implements WalkFileSystem, or ReadDir otherwise.
+func walk(ctx context.Context, fs FileSystem, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	if wfs, ok := fs.(WalkFileSystem); ok {
+		return wfs.Walk(ctx, name, recursive, fn)
+	}
+	children, err := fs.ReadDir(ctx, name, recursive)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		if err := fn(&children[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubFileSystem is implemented by FileSystems which can be restricted to one
+// of their directories, similarly to io/fs.SubFS.
+type SubFileSystem interface {
+	// Sub returns a FileSystem whose root is the directory name. Paths
+	// outside of it must not be reachable from the returned FileSystem.
+	Sub(name string) (FileSystem, error)
+}
+
+// fileSystemAs returns fs as an optional interface T, looking through
+// FileSystems wrapping others with an Unwrap method.
+func fileSystemAs[T any](fs FileSystem) (T, bool) {
+	for {
+		if t, ok := fs.(T); ok {
+			return t, true
+		}
+		u, ok := fs.(interface{ Unwrap() FileSystem })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		fs = u.Unwrap()
+	}
+}
+
 // Handler handles WebDAV HTTP requests. It can be used to create a WebDAV
 // server.
 type Handler struct {
+	// Prefix is the URL path prefix the handler is mounted on. It's removed
+	// from request paths and Destination header fields, and added to the
+	// hrefs of responses.
+	Prefix     string
 	FileSystem FileSystem
-	LockSystem *LockSystem
-	// Property store for custom properties
-	propStore map[string]map[xml.Name]string
+	// LockSystem enables locking. If nil, LOCK and UNLOCK aren't supported
+	// and compliance class 2 isn't advertised.
+	LockSystem LockSystem
+	// PropertyStore stores dead properties. If nil, the FileSystem is used
+	// when it implements PropertyStore, otherwise properties are kept in
+	// memory.
+	PropertyStore PropertyStore
+	// Capabilities are extension compliance classes advertised in the DAV
+	// header field of OPTIONS responses, e.g. "access-control".
+	Capabilities []Capability
+	// ExtraMethods are advertised in the Allow header field of OPTIONS
+	// responses in addition to the methods served by the handler, e.g.
+	// methods served by another handler on the same paths.
+	ExtraMethods []string
+	// Checksum is the algorithm used to compute the checksums exposed in the
+	// ownCloud checksums property. Checksums are computed on upload. If
+	// empty, checksums are disabled.
+	Checksum ChecksumAlgorithm
+	// Reports maps the names of the supported REPORT requests to their
+	// handlers. See HandleReport.
+	Reports map[xml.Name]ReportFunc
+	// Compress enables gzip and deflate compression of PROPFIND and REPORT
+	// responses for clients accepting it.
+	Compress bool
+	// ReadOnly rejects requests modifying resources with "403 Forbidden".
+	ReadOnly bool
+	// AllowedMethods restricts the methods served by the handler. If empty,
+	// all methods are allowed.
+	AllowedMethods []string
+	// DeniedMethods lists methods the handler rejects. Rejected methods are
+	// replied to with "405 Method Not Allowed". OPTIONS is always allowed.
+	DeniedMethods []string
+	// Limits restricts the request rate and the simultaneous transfers of
+	// each client. If nil, clients aren't limited.
+	Limits *Limits
+	// Logger, if set, logs every request with its method, path, user,
+	// status, duration and transferred bytes.
+	Logger *slog.Logger
+	// Hooks are called after successful operations.
+	Hooks Hooks
+	// Webhooks, if set, posts webhooks for the resources created, updated,
+	// deleted and moved through the handler.
+	Webhooks *Webhooks
+	// Publisher, if set, publishes the changes made through the handler to
+	// a message bus, e.g. NATS or Kafka, see EventPublisher. Events are
+	// published in the background, in order; failures are logged with
+	// Logger. Handler.Shutdown waits for pending events.
+	Publisher EventPublisher
+	// ChangeFeed enables streaming the changes made through the handler as
+	// Server-Sent Events: GET requests accepting "text/event-stream", like
+	// the ones of EventSource, receive the changes below the requested
+	// resource, as ChangeEvents encoded in JSON, until they disconnect.
+	// Changes of resources the user can't read are left out.
+	ChangeFeed bool
+	// DetectContentType returns the media type of a file, given its path and
+	// a reader for the beginning of its content. If nil, the MIMEType of the
+	// FileInfo is used, falling back to DetectContentType.
+	DetectContentType func(name string, peek io.Reader) string
+	// MIMETypes, if set, maps file name extensions to media types and
+	// media types to default charsets for the handler, taking precedence
+	// over the MIMEType of FileInfos and the package-level registry, see
+	// AddMIMEType. It's also used to name the resources created with POST.
+	MIMETypes *MIMETypes
+	// MetadataHeaders are the names of the request headers passed to the
+	// FileSystem in CreateOptions.Metadata on PUT and POST, e.g.
+	// "Content-Language" or "Cache-Control". A name ending with "*" matches
+	// the headers starting with it, e.g. "X-Amz-Meta-*".
+	MetadataHeaders []string
+	// ScanUpload, if set, is passed the content of each file uploaded with
+	// PUT or POST once written, e.g. to run a virus scanner or enforce a
+	// content policy. If it returns an error, the upload is discarded and
+	// the request fails with the status of the error, e.g. created with
+	// NewHTTPError(http.StatusUnavailableForLegalReasons, err), or "403
+	// Forbidden". Uploads are scanned before they replace the existing
+	// file, unless the FileSystem doesn't stage them, see
+	// CreateOptions.Verify.
+	ScanUpload func(ctx context.Context, name string, r io.Reader) error
+	// Processors are run in order on each file uploaded with PUT or POST,
+	// in the background once the upload completed, e.g. to generate
+	// thumbnails or extract text for search indexing. The properties they
+	// produce are set on the file, unless it changed meanwhile. Failures are
+	// logged with Logger and don't stop the following processors.
+	// Handler.Shutdown waits for pending processing.
+	Processors []Processor
+	// AccessRules restrict the access to resources matching path patterns,
+	// depending on the request user. See AccessRule.
+	AccessRules []AccessRule
+	// Permissions, if set, decide whether the request user may read, write,
+	// delete or lock the request resource. See Permissions.
+	Permissions Permissions
+	// Metrics, if set, collects Prometheus metrics about requests.
+	Metrics *Metrics
+	// DirectoryListing replies to GET requests on collections with an HTML
+	// listing of their children, or a JSON one if preferred by the client.
+	// If false, these requests are replied to with "405 Method Not Allowed".
+	DirectoryListing bool
+	// WebUI serves a file manager to web browsers on GET requests on
+	// collections, to upload, download, rename and delete files. It implies
+	// the JSON directory listing, which the file manager relies on.
+	WebUI bool
+	// CollectionGet selects how GET and HEAD requests on collections are
+	// served when neither DirectoryListing nor WebUI are set. By default,
+	// they're replied to with "405 Method Not Allowed".
+	CollectionGet CollectionGet
+	// TracerProvider, if set, creates an OpenTelemetry span for each request
+	// and child spans for the calls to the FileSystem, LockSystem and
+	// PropertyStore.
+	TracerProvider trace.TracerProvider
+	// PropFindWorkers is the number of members of a collection whose
+	// PROPFIND responses are computed concurrently, e.g. for backends
+	// fetching properties with remote calls. Responses are still sent in
+	// order. Zero or one computes them sequentially.
+	PropFindWorkers int
+	// StatCacheTTL, if positive, caches the metadata of resources and the
+	// members of collections for that long, sparing the FileSystem repeated
+	// PROPFIND polling. Changes made through the handler invalidate the
+	// cache, as do the changes reported by FileSystems implementing
+	// WatchableFileSystem. Others are only seen once entries expire.
+	StatCacheTTL time.Duration
+	// MaxPropFindResponses, if positive, caps the number of responses of a
+	// PROPFIND request. Once reached, the multistatus is truncated with a
+	// 507 Insufficient Storage response for the request URI, as specified
+	// in RFC 5323 section 2.6, bounding the work done for Depth: infinity
+	// requests on large trees.
+	MaxPropFindResponses int
+	// Precompressed, if set, serves GET requests for files with a fresh
+	// sibling compressed with Brotli (name.br) or gzip (name.gz) with that
+	// sibling and the matching Content-Encoding, if the client accepts it.
+	Precompressed bool
+	// RedirectCollections, if set, replies to GET, HEAD and PROPFIND
+	// requests on collections whose path lacks a trailing slash with a
+	// "301 Moved Permanently" redirect to the path with one. Otherwise both
+	// forms are served the same way.
+	RedirectCollections bool
+	// SafeDownloads, if set, serves files as attachments with
+	// "X-Content-Type-Options: nosniff" and a Content-Security-Policy
+	// forbidding scripts and other resources, so that uploaded HTML can't be
+	// used for stored cross-site scripting against the serving domain.
+	SafeDownloads bool
+	// NamePolicy, if set, restricts the names of the resources created by
+	// clients. See NamePolicy and PortableNames.
+	NamePolicy *NamePolicy
+	// ExternalURLs are the base URLs clients reach the handler with, e.g.
+	// "https://dav.example.com" behind a TLS-terminating proxy. If set, the
+	// Destination header fields of COPY and MOVE requests naming a host must
+	// match the scheme and host of one of them, default ports aside, or get
+	// "502 Bad Gateway". Otherwise any host is accepted. Paths are resolved
+	// against Prefix either way.
+	ExternalURLs []string
+	// AuditSink, if set, receives an event for each request modifying
+	// resources or locks, once served.
+	AuditSink AuditSink
+	// SlowRequests, if set, reports requests exceeding a duration and
+	// PROPFIND requests producing too many responses.
+	SlowRequests *SlowRequestLog
+	// Stats, if set, accumulates the transferred bytes and the requests of
+	// each user.
+	Stats StatsStore
+
+	initOnce    sync.Once
+	fs          FileSystem
+	locks       LockSystem
+	props       PropertyStore
+	propStore   PropertyStore
+	limiterOnce sync.Once
+	limiter     *limiter
+	drainer     drainer
+	stopWatch   context.CancelFunc
+	hooks       Hooks
+	feed        *changeFeed
+	pipeline    *uploadPipeline
+	publisher   *publisherQueue
 }
 
 // ServeHTTP implements http.Handler.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = withRequestID(w, r)
+
+	var sw *statusWriter
+	if h.observed() && r.Body != nil {
+		sw = newStatusWriter(w)
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		start := time.Now()
+		var span trace.Span
+		if h.TracerProvider != nil {
+			r, span = startRequestSpan(h.TracerProvider, r)
+		}
+		var stats *requestStats
+		if h.SlowRequests != nil {
+			stats = new(requestStats)
+			r = r.WithContext(context.WithValue(r.Context(), requestStatsContextKey{}, stats))
+		}
+		defer func(r *http.Request) {
+			duration := time.Since(start)
+			if h.Logger != nil {
+				logRequest(h.Logger, r, sw, body, duration)
+			}
+			if h.Metrics != nil {
+				h.Metrics.observeRequest(r, sw, body, duration)
+			}
+			if h.AuditSink != nil && mutatingMethods[r.Method] {
+				event := newAuditEvent(r, sw, body, start)
+				if err := h.AuditSink.Audit(r.Context(), event); err != nil {
+					log.Errorf("webdav: failed to record audit event: %v", err)
+				}
+			}
+			if stats != nil {
+				h.SlowRequests.check(h.Logger, r, sw, stats, duration)
+			}
+			if h.Stats != nil {
+				if err := recordStats(h.Stats, r, sw, body); err != nil {
+					log.Errorf("webdav: failed to record transfer statistics: %v", err)
+				}
+			}
+			if span != nil {
+				endRequestSpan(span, sw, body)
+			}
+		}(r)
+		w = sw
+	}
+	serveError := func(err error) {
+		if sw != nil {
+			sw.err = err
+		}
+		internal.ServeError(w, err)
+	}
+
+	if !h.drainer.acquire() {
+		serveShuttingDown(w)
+		return
+	}
+	defer h.drainer.release()
+
 	if h.FileSystem == nil {
 		http.Error(w, "webdav: no filesystem available", http.StatusInternalServerError)
 		return
 	}
 
-	// Use the global lock system if not provided
-	if h.LockSystem == nil {
-		h.LockSystem = GetGlobalLockSystem()
+	if p, err := normalizePath(r.URL.Path); err != nil {
+		serveError(err)
+		return
+	} else if p != r.URL.Path {
+		r = withPath(r, p)
+	}
+	if p, ok := h.requestPath(r); !ok {
+		http.NotFound(w, r)
+		return
+	} else if p != r.URL.Path {
+		r = withPath(r, p)
 	}
 
-	// Initialize property store if not already initialized
-	if h.propStore == nil {
-		h.propStore = make(map[string]map[xml.Name]string)
+	if h.Limits != nil {
+		h.limiterOnce.Do(func() {
+			h.limiter = newLimiter(*h.Limits)
+		})
+		release, retryAfter, ok := h.limiter.acquire(r)
+		if !ok {
+			serveTooManyRequests(w, retryAfter)
+			return
+		}
+		defer release()
+	}
+
+	if h.Compress && compressedMethods[r.Method] {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			cw := newCompressResponseWriter(w, encoding)
+			defer cw.Close()
+			w = cw
+		}
+	}
+
+	b := h.backend()
+	if err := b.checkMethod(r.Method); err != nil {
+		if _, allow, optionsErr := b.Options(r); optionsErr == nil {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+		}
+		serveError(err)
+		return
+	}
+	if err := b.checkAccess(r); err != nil {
+		serveError(err)
+		return
+	}
+	if err := b.checkNames(r); err != nil {
+		serveError(err)
+		return
+	}
+	if err := b.checkIfHeader(r); err != nil {
+		serveError(err)
+		return
+	}
+	if h.RedirectCollections && b.redirectCollection(w, r) {
+		return
 	}
 
-	b := backend{
-		FileSystem: h.FileSystem,
-		LockSystem: h.LockSystem,
-		propStore:  h.propStore,
+	hh := internal.Handler{Backend: b}
+	if sw != nil {
+		hh.OnError = func(err error) {
+			sw.err = err
+		}
 	}
-	hh := internal.Handler{Backend: &b}
 	hh.ServeHTTP(w, r)
 }
 
+// checkContinue evaluates the preconditions of an upload before its body is
+// read. Requests outside of the mount are let through.
+func (h *Handler) checkContinue(r *http.Request) error {
+	p, err := normalizePath(r.URL.Path)
+	if err != nil {
+		return nil
+	}
+	p, ok := stripPrefix(p, h.prefix())
+	if !ok {
+		return nil
+	}
+	return h.backend().CheckPreconditions(withPath(r, p))
+}
+
+func (h *Handler) backend() *backend {
+	h.init()
+	return &backend{
+		Prefix:         h.prefix(),
+		FileSystem:     h.fs,
+		LockSystem:     h.locks,
+		PropertyStore:  h.props,
+		Capabilities:   h.Capabilities,
+		ExtraMethods:   h.ExtraMethods,
+		Checksum:       h.Checksum,
+		Reports:        h.Reports,
+		ReadOnly:       h.ReadOnly,
+		AllowedMethods: h.AllowedMethods,
+		DeniedMethods:  h.DeniedMethods,
+		Hooks:          h.hooks,
+		ChangeFeed:     h.feed,
+		CollectionGet:  h.CollectionGet,
+		ExternalURLs:   h.ExternalURLs,
+
+		DetectContentType: h.DetectContentType,
+		MIMETypes:         h.MIMETypes,
+		MetadataHeaders:   h.MetadataHeaders,
+		ScanUpload:        h.ScanUpload,
+		AccessRules:       h.AccessRules,
+		Permissions:       h.Permissions,
+		DirectoryListing:  h.DirectoryListing,
+		WebUI:             h.WebUI,
+		PropFindWorkers:   h.PropFindWorkers,
+		Precompressed:     h.Precompressed,
+		SafeDownloads:     h.SafeDownloads,
+		NamePolicy:        h.NamePolicy,
+
+		MaxPropFindResponses: h.MaxPropFindResponses,
+	}
+}
+
+// invalidateStat drops the cached metadata of name, if any.
+func (b *backend) invalidateStat(name string) {
+	if c, ok := fileSystemAs[*statCacheFileSystem](b.FileSystem); ok {
+		c.invalidate(name)
+	}
+}
+
+// observed reports whether served requests are logged, measured, traced or
+// audited.
+func (h *Handler) observed() bool {
+	return h.Logger != nil || h.Metrics != nil || h.TracerProvider != nil ||
+		h.AuditSink != nil || h.SlowRequests != nil || h.Stats != nil
+}
+
+// prefix returns the cleaned mount prefix of the handler, or an empty string
+// if it's mounted at the root.
+func (h *Handler) prefix() string {
+	if prefix := cleanPrefix(h.Prefix); prefix != "/" {
+		return prefix
+	}
+	return ""
+}
+
+// requestPath returns the path of the resource targeted by a request, without
+// the mount prefix. It returns false if the request is outside of the mount.
+func (h *Handler) requestPath(r *http.Request) (string, bool) {
+	prefix := h.prefix()
+	if r.Method == http.MethodOptions {
+		// Clients such as the Windows Mini-Redirector and GNOME probe the
+		// server with "OPTIONS *" and OPTIONS requests on the parents of the
+		// mount before mounting it
+		if r.URL.Path == "*" || (prefix != "" && isDescendant(prefix, path.Clean(r.URL.Path))) {
+			return "/", true
+		}
+	}
+	return stripPrefix(r.URL.Path, prefix)
+}
+
+// withPath returns a shallow copy of r with the URL path p.
+func withPath(r *http.Request, p string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// maxPathLength is the length in bytes above which request paths are
+// rejected, the PATH_MAX of Linux.
+const maxPathLength = 4096
+
+// normalizePath returns the canonical form of a decoded request path, with
+// dot segments resolved and duplicate slashes collapsed so that clients
+// building URLs sloppily still reach the right resource. A trailing slash is
+// kept. Paths containing NUL bytes, which no file system accepts, and paths
+// longer than maxPathLength are rejected.
+func normalizePath(p string) (string, error) {
+	if strings.IndexByte(p, 0) >= 0 {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: path contains a NUL byte")
+	}
+	if len(p) > maxPathLength {
+		return "", internal.HTTPErrorf(http.StatusRequestURITooLong, "webdav: path is longer than %v bytes", maxPathLength)
+	}
+	if p == "*" {
+		// OPTIONS *
+		return p, nil
+	}
+
+	dir := strings.HasSuffix(p, "/")
+	p = path.Clean("/" + p)
+	if dir && p != "/" {
+		p += "/"
+	}
+	return p, nil
+}
+
+// stripPrefix removes a mount prefix from a path. It returns false if the
+// path isn't below the prefix.
+func stripPrefix(p, prefix string) (string, bool) {
+	if prefix == "" || prefix == "/" {
+		return p, true
+	}
+	if p == prefix {
+		return "/", true
+	}
+	if !strings.HasPrefix(p, prefix+"/") {
+		return "", false
+	}
+	return p[len(prefix):], true
+}
+
+// init sets the defaults of the handler on first use.
+func (h *Handler) init() {
+	h.initOnce.Do(func() {
+		if h.PropertyStore != nil {
+			h.propStore = h.PropertyStore
+		} else if ps, ok := fileSystemAs[PropertyStore](h.FileSystem); ok {
+			h.propStore = ps
+		} else {
+			h.propStore = NewMemPropertyStore()
+		}
+
+		h.hooks = h.Hooks
+		if h.Webhooks != nil {
+			h.hooks = joinHooks(h.hooks, changeHooks(h.Webhooks.Send))
+		}
+		if h.Publisher != nil {
+			h.publisher = newPublisherQueue(h.Publisher, h.Logger)
+			h.hooks = joinHooks(h.hooks, changeHooks(h.publisher.send))
+		}
+		if h.ChangeFeed {
+			h.feed = newChangeFeed()
+			h.hooks = joinHooks(h.hooks, changeHooks(h.feed.publish))
+		}
+
+		h.fs, h.locks, h.props = h.FileSystem, h.LockSystem, h.propStore
+		if h.TracerProvider != nil {
+			tracer := newBackendTracer(h.TracerProvider)
+			h.fs = &tracedFileSystem{fs: h.fs, tracer: tracer}
+			if h.locks != nil {
+				h.locks = &tracedLockSystem{ls: h.locks, tracer: tracer}
+			}
+			h.props = &tracedPropertyStore{ps: h.props, tracer: tracer}
+		}
+		if h.Metrics != nil {
+			h.fs = &instrumentedFileSystem{fs: h.fs, metrics: h.Metrics}
+			h.Metrics.addLockSystem(h.LockSystem)
+		}
+		if h.StatCacheTTL > 0 {
+			cache := newStatCacheFileSystem(h.fs, h.StatCacheTTL)
+			if wfs, ok := fileSystemAs[WatchableFileSystem](h.FileSystem); ok {
+				ctx, cancel := context.WithCancel(context.Background())
+				if err := cache.watch(ctx, wfs); err != nil {
+					// Entries still expire
+					cancel()
+					if h.Logger != nil {
+						h.Logger.Warn("webdav: failed to watch the file system for the stat cache", "error", err)
+					}
+				} else {
+					h.stopWatch = cancel
+				}
+			}
+			h.fs = cache
+		}
+
+		if len(h.Processors) > 0 {
+			h.pipeline = newUploadPipeline(h.Processors, h.fs, h.props, h.Logger)
+			h.hooks = joinHooks(h.hooks, h.pipeline.hooks())
+		}
+	})
+}
+
+// propertyStore returns the property store used by the handler, initializing
+// it if necessary.
+func (h *Handler) propertyStore() PropertyStore {
+	h.init()
+	return h.propStore
+}
+
+// CleanOrphanedProperties removes properties of resources which no longer
+// exist in the handler's FileSystem. See the CleanOrphanedProperties function.
+func (h *Handler) CleanOrphanedProperties(ctx context.Context) (int, error) {
+	return CleanOrphanedProperties(ctx, h.FileSystem, h.propertyStore())
+}
+
 // NewHTTPError creates a new error that is associated with an HTTP status code
 // and optionally an error that lead to it. Backends can use this functions to
 // return errors that convey some semantics (e.g. 404 not found, 403 access
@@ -69,18 +644,209 @@ func NewHTTPError(statusCode int, cause error) error {
 	return &internal.HTTPError{Code: statusCode, Err: cause}
 }
 
+// MemberError describes the failure of an operation on a member of a
+// collection.
+type MemberError struct {
+	Path string
+	Err  error
+}
+
+// PartialError is returned by FileSystem operations on collections which
+// failed for some of their members only. It's reported to clients as a 207
+// Multi-Status response listing the members which couldn't be processed.
+type PartialError struct {
+	Errors []MemberError
+}
+
+func (err *PartialError) Error() string {
+	if len(err.Errors) == 1 {
+		return fmt.Sprintf("webdav: %v: %v", err.Errors[0].Path, err.Errors[0].Err)
+	}
+	return fmt.Sprintf("webdav: operation failed for %v resources", len(err.Errors))
+}
+
+// multiStatusError converts errors returned by the FileSystem for partial
+// failures into multistatus errors.
+func (b *backend) multiStatusError(err error) error {
+	var partialErr *PartialError
+	if !errors.As(err, &partialErr) {
+		return err
+	}
+	resps := make([]internal.Response, len(partialErr.Errors))
+	for i, memberErr := range partialErr.Errors {
+		resps[i] = *internal.NewErrorResponse(b.href(memberErr.Path), memberErr.Err)
+	}
+	return &internal.MultiStatusError{Responses: resps}
+}
+
 type backend struct {
-	FileSystem FileSystem
-	LockSystem *LockSystem
-	// In-memory property store
-	propStore map[string]map[xml.Name]string
+	Prefix         string
+	FileSystem     FileSystem
+	LockSystem     LockSystem
+	PropertyStore  PropertyStore
+	Capabilities   []Capability
+	ExtraMethods   []string
+	Checksum       ChecksumAlgorithm
+	Reports        map[xml.Name]ReportFunc
+	ReadOnly       bool
+	AllowedMethods []string
+	DeniedMethods  []string
+	Hooks          Hooks
+	ChangeFeed     *changeFeed
+	CollectionGet  CollectionGet
+	ExternalURLs   []string
+
+	DetectContentType func(name string, peek io.Reader) string
+	MIMETypes         *MIMETypes
+	MetadataHeaders   []string
+	ScanUpload        func(ctx context.Context, name string, r io.Reader) error
+	AccessRules       []AccessRule
+	Permissions       Permissions
+	DirectoryListing  bool
+	WebUI             bool
+	PropFindWorkers   int
+	Precompressed     bool
+	SafeDownloads     bool
+	NamePolicy        *NamePolicy
+
+	MaxPropFindResponses int
+}
+
+// mutatingMethods are the methods which modify resources or their locks.
+var mutatingMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	"MKCOL":           true,
+	"COPY":            true,
+	"MOVE":            true,
+	"PROPPATCH":       true,
+	"LOCK":            true,
+	"UNLOCK":          true,
+}
+
+// checkMethod returns an error if the handler is configured to reject
+// requests with the given method.
+func (b *backend) checkMethod(method string) error {
+	if b.ReadOnly && mutatingMethods[method] {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: %v not allowed on read-only server", method)
+	}
+	if method == http.MethodOptions {
+		return nil
+	}
+	if (len(b.AllowedMethods) > 0 && !slices.Contains(b.AllowedMethods, method)) || slices.Contains(b.DeniedMethods, method) {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %v not allowed", method)
+	}
+	return nil
+}
+
+// allowedMethods removes the methods rejected by checkMethod, by the access
+// rules or by the Permissions for the request resource from methods.
+func (b *backend) allowedMethods(r *http.Request, methods []string) []string {
+	access := b.access(r.Context(), r.URL.Path)
+	allowed := methods[:0]
+	for _, method := range methods {
+		if b.checkMethod(method) == nil && access >= requiredAccess(method) && b.permitted(r.Context(), method, r.URL.Path) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// href returns the URL path of a resource, including the mount prefix.
+func (b *backend) href(name string) string {
+	return b.Prefix + name
+}
+
+// resourceHref returns the href of a resource. Collection hrefs end with a
+// slash, as recommended by RFC 4918 section 8.3, whichever form the
+// resource was requested with.
+func (b *backend) resourceHref(fi *FileInfo) string {
+	href := b.href(path.Clean(fi.Path))
+	if fi.IsDir {
+		href = collectionPath(href)
+	}
+	return href
+}
+
+// destinationPath returns the resource targeted by the Destination header
+// field of a COPY or MOVE request.
+func (b *backend) destinationPath(dest *internal.Href) (string, error) {
+	return resolveDestination((*url.URL)(dest), b.Prefix, b.ExternalURLs)
+}
+
+// resolveDestination returns the path below the mount prefix of a parsed
+// Destination header field. Absolute URLs must have the scheme and host of one
+// of externalURLs, if any. Otherwise, they're assumed to refer to this server,
+// whichever scheme, name and port clients reach it with behind proxies.
+func resolveDestination(dest *url.URL, prefix string, externalURLs []string) (string, error) {
+	if dest.Host != "" && len(externalURLs) > 0 && !slices.ContainsFunc(externalURLs, func(s string) bool {
+		return sameOrigin(dest, s)
+	}) {
+		return "", internal.HTTPErrorf(http.StatusBadGateway, "webdav: destination host %q is not served by this server", dest.Host)
+	}
+	p, err := normalizePath(dest.Path)
+	if err != nil {
+		return "", err
+	}
+	p, ok := stripPrefix(p, prefix)
+	if !ok {
+		return "", internal.HTTPErrorf(http.StatusBadGateway, "webdav: destination %q is outside of the mount", dest.Path)
+	}
+	return p, nil
+}
+
+// sameOrigin reports whether u has the scheme and host of the base URL s.
+// Default ports are equivalent to no port.
+func sameOrigin(u *url.URL, s string) bool {
+	base, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, base.Scheme) &&
+		strings.EqualFold(u.Hostname(), base.Hostname()) &&
+		originPort(u) == originPort(base)
+}
+
+// originPort returns the port of a URL, or the default port of its scheme.
+func originPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// nativeProperties reports whether the FileSystem stores properties itself.
+func (b *backend) nativeProperties() bool {
+	ps, ok := fileSystemAs[PropertyStore](b.FileSystem)
+	if !ok {
+		return false
+	}
+	store := b.PropertyStore
+	for {
+		u, ok := store.(interface{ Unwrap() PropertyStore })
+		if !ok {
+			break
+		}
+		store = u.Unwrap()
+	}
+	return ps == store
 }
 
 func (b *backend) Options(r *http.Request) (caps []string, allow []string, err error) {
-	// Add lock capability if lock system is available
-	caps = []string{"2"}
-	if b.LockSystem != nil {
-		caps = append(caps, "1")
+	// Class 2 requires locking support
+	if b.LockSystem != nil && b.checkMethod("LOCK") == nil {
+		caps = append(caps, "2")
+	}
+	caps = append(caps, "3")
+	for _, c := range b.Capabilities {
+		caps = append(caps, string(c))
 	}
 
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
@@ -89,7 +855,7 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 		if b.LockSystem != nil {
 			methods = append(methods, "LOCK")
 		}
-		return caps, methods, nil
+		return caps, append(b.allowedMethods(r, methods), b.ExtraMethods...), nil
 	} else if err != nil {
 		return nil, nil, err
 	}
@@ -98,11 +864,19 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 		http.MethodOptions,
 		http.MethodDelete,
 		"PROPFIND",
+		"PROPPATCH",
 		"COPY",
 		"MOVE",
 	}
 
-	if !fi.IsDir {
+	if fi.IsDir {
+		// GET and HEAD are only served on collections when they're listed or
+		// answered like PROPFIND, see HeadGet
+		if b.DirectoryListing || b.WebUI || b.propFindOnGet(r.URL.Path) {
+			allow = append(allow, http.MethodHead, http.MethodGet)
+		}
+		allow = append(allow, http.MethodPost)
+	} else {
 		allow = append(allow, http.MethodHead, http.MethodGet, http.MethodPut)
 	}
 
@@ -111,7 +885,156 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 		allow = append(allow, "LOCK", "UNLOCK")
 	}
 
-	return caps, allow, nil
+	if len(b.Reports) > 0 {
+		allow = append(allow, "REPORT")
+	}
+
+	return caps, append(b.allowedMethods(r, allow), b.ExtraMethods...), nil
+}
+
+// CheckPreconditions evaluates the preconditions of requests uploading a body
+// without reading it.
+func (b *backend) CheckPreconditions(r *http.Request) error {
+	if err := b.checkMethod(r.Method); err != nil {
+		return err
+	}
+	if err := b.checkAccess(r); err != nil {
+		return err
+	}
+	if err := b.checkNames(r); err != nil {
+		return err
+	}
+	if err := b.checkIfHeader(r); err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+	default:
+		return nil
+	}
+
+	if err := b.confirmLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
+	if err := b.checkQuota(r); err != nil {
+		return err
+	}
+
+	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
+	if r.Method == http.MethodPost {
+		if err == nil && !fi.IsDir {
+			return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: POST is only supported on collections")
+		}
+		return err
+	}
+
+	if internal.IsNotFound(err) {
+		fi = nil
+		if err := b.checkParent(r.Context(), r.URL.Path); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if fi.IsDir {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: cannot PUT to a collection")
+	}
+
+	ifNoneMatch := ConditionalMatch(r.Header.Get("If-None-Match"))
+	ifMatch := ConditionalMatch(r.Header.Get("If-Match"))
+	if err := checkConditionalMatches(fi, ifMatch, ifNoneMatch); err != nil {
+		return err
+	}
+	return checkConditionalDates(fi, ifMatch, ifNoneMatch, conditionalDate(r, "If-Unmodified-Since"), conditionalDate(r, "If-Modified-Since"))
+}
+
+// checkQuota returns an error if the declared length of the body of r exceeds
+// the Quota of the FileSystem, if any.
+func (b *backend) checkQuota(r *http.Request) error {
+	qfs, ok := fileSystemAs[*quotaFileSystem](b.FileSystem)
+	if !ok || r.ContentLength <= 0 {
+		return nil
+	}
+	remaining, err := qfs.remaining(r.Context(), r.URL.Path)
+	if err != nil {
+		return err
+	}
+	if r.ContentLength > remaining {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// conditionalDate returns the date of the conditional header key of r. It's
+// zero if the header is missing or invalid, as invalid dates are ignored
+// according to RFC 9110 section 13.1.
+func conditionalDate(r *http.Request, key string) time.Time {
+	t, err := http.ParseTime(r.Header.Get(key))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// redirectCollection redirects GET, HEAD and PROPFIND requests on a
+// collection whose path lacks a trailing slash to the path with one. It
+// reports whether the request was redirected.
+func (b *backend) redirectCollection(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, "PROPFIND":
+	default:
+		return false
+	}
+	if strings.HasSuffix(r.URL.Path, "/") {
+		return false
+	}
+	if fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path); err != nil || !fi.IsDir {
+		return false
+	}
+	u := url.URL{Path: b.href(r.URL.Path + "/"), RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	return true
+}
+
+// checkParent returns a 409 Conflict error if the parent collection of the
+// missing resource name doesn't exist, as per RFC 4918.
+func (b *backend) checkParent(ctx context.Context, name string) error {
+	fi, err := b.FileSystem.Stat(ctx, path.Dir(path.Clean(name)))
+	if internal.IsNotFound(err) || (err == nil && !fi.IsDir) {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("parent collection doesn't exist"))
+	}
+	return err
+}
+
+// sourceRequested reports whether a Microsoft client asked for the source of a
+// resource with "Translate: f". Such requests must get the stored content
+// as-is, without any server-side rendition.
+func sourceRequested(r *http.Request) bool {
+	translate, err := internal.ParseTranslate(r.Header.Get("Translate"))
+	return err == nil && !translate
+}
+
+// notModified evaluates the If-None-Match and If-Modified-Since headers of a
+// GET or HEAD request against a resource, as specified in RFC 9110 section
+// 13.2.2. If-Modified-Since is ignored when If-None-Match is present.
+func notModified(r *http.Request, fi *FileInfo) bool {
+	if inm := ConditionalMatch(r.Header.Get("If-None-Match")); inm.IsSet() {
+		// Malformed lists don't match
+		ok, _ := inm.MatchWeakETag(fi.ETag)
+		return ok
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || fi.ModTime.IsZero() {
+		return false
+	}
+	// HTTP dates have a one second resolution
+	return !fi.ModTime.Truncate(time.Second).After(ims)
+}
+
+// entityTag returns the entity tag of a resource.
+func entityTag(fi *FileInfo) internal.EntityTag {
+	return internal.EntityTag{Tag: fi.ETag, Weak: fi.WeakETag}
 }
 
 func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
@@ -119,95 +1042,304 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	if b.ChangeFeed != nil && acceptsEventStream(r) {
+		return b.serveChangeFeed(w, r)
+	}
 	if fi.IsDir {
+		if b.WebUI && !prefersJSON(r.Header.Get("Accept")) {
+			serveUI(w, r)
+			return nil
+		}
+		if b.DirectoryListing || b.WebUI {
+			return b.serveListing(w, r)
+		}
+		if b.propFindOnGet(r.URL.Path) {
+			return b.serveCollectionPropFind(w, r)
+		}
 		return &internal.HTTPError{Code: http.StatusMethodNotAllowed}
 	}
+	if sourceRequested(r) {
+		// Let the Windows Mini-Redirector know it's talking to a WebDAV
+		// server serving raw content
+		w.Header().Set("MS-Author-Via", "DAV")
+	}
+	if b.SafeDownloads {
+		setSafeDownloadHeaders(w, fi)
+	}
+
+	// rep is the representation of the file served, either the file itself or
+	// a precompressed variant named name
+	rep, name := fi, r.URL.Path
+	if b.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if variant, coding := b.precompressedVariant(r, fi); variant != nil {
+			w.Header().Set("Content-Encoding", coding)
+			rep, name = variant, variant.Path
+		}
+	}
+
+	if !rep.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", rep.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if rep.ETag != "" {
+		w.Header().Set("ETag", entityTag(rep).String())
+	}
+	if notModified(r, rep) {
+		// Spare opening the file for clients polling with cached validators
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
 
-	f, err := b.FileSystem.Open(r.Context(), r.URL.Path)
+	// Backends reading ranges natively serve them without seeking
+	rfs, _ := fileSystemAs[RangeFileSystem](b.FileSystem)
+	var br *byteRange
+	if rfs != nil {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if br, err = requestedRange(w, r, rep); err != nil {
+			return err
+		}
+	}
+
+	var f io.ReadCloser
+	if br != nil {
+		f, err = rfs.OpenRange(r.Context(), name, br.start, br.length)
+	} else {
+		f, err = b.FileSystem.Open(r.Context(), name)
+	}
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
-	if fi.MIMEType != "" {
-		w.Header().Set("Content-Type", fi.MIMEType)
+	var body io.Reader = f
+	var contentType string
+	if br != nil || rep != fi {
+		// Detect the type from the beginning of the original file, not of
+		// the range or compressed variant
+		peek := &lazyReader{open: func() (io.ReadCloser, error) {
+			if rfs != nil {
+				return rfs.OpenRange(r.Context(), r.URL.Path, 0, min(fi.Size, sniffLen))
+			}
+			return b.FileSystem.Open(r.Context(), r.URL.Path)
+		}}
+		contentType = b.contentType(fi, peek)
+		peek.Close()
+	} else {
+		var peeked bytes.Buffer
+		contentType = b.contentType(fi, io.TeeReader(f, &peeked))
+		if peeked.Len() > 0 {
+			// Rewind the content read to detect the type
+			if s, ok := f.(io.Seeker); ok {
+				if _, err := s.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+			} else {
+				body = io.MultiReader(&peeked, f)
+			}
+		}
 	}
-	if !fi.ModTime.IsZero() {
-		w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
+
+	w.Header().Set("Content-Length", strconv.FormatInt(rep.Size, 10))
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
 	}
-	if fi.ETag != "" {
-		w.Header().Set("ETag", internal.ETag(fi.ETag).String())
+	deadProps, err := b.PropertyStore.GetProperties(r.Context(), r.URL.Path)
+	if err != nil {
+		return err
+	}
+	if lang := deadProps[internal.GetContentLanguageName]; lang != "" {
+		w.Header().Set("Content-Language", lang)
 	}
 
-	if rs, ok := f.(io.ReadSeeker); ok {
+	if br != nil {
+		w.Header().Set("Content-Range", br.contentRange(rep.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(br.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method != http.MethodHead {
+			copyBuffer(w, body)
+		}
+	} else if rs, ok := body.(io.ReadSeeker); ok {
 		// If it's an io.Seeker, use http.ServeContent which supports ranges
-		http.ServeContent(w, r, r.URL.Path, fi.ModTime, rs)
+		http.ServeContent(w, r, r.URL.Path, rep.ModTime, rs)
 	} else {
 		if r.Method != http.MethodHead {
-			io.Copy(w, f)
+			copyBuffer(w, body)
 		}
 	}
+
+	if r.Method == http.MethodGet {
+		callHook(b.Hooks.OnGet, r, Event{FileInfo: fi})
+	}
 	return nil
 }
 
-func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth) (*internal.MultiStatus, error) {
+func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth, emit func(*internal.Response) error) error {
 	// TODO: use partial error Response on error
 
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
-	if depth != internal.DepthZero && fi.IsDir {
-		children, err := b.FileSystem.ReadDir(r.Context(), r.URL.Path, depth == internal.DepthInfinity)
-		if err != nil {
-			return nil, err
+	n := 0
+	if stats, ok := requestStatsFromContext(r.Context()); ok {
+		defer func() {
+			stats.responses = n
+		}()
+	}
+	send := func(resp *internal.Response) error {
+		if b.MaxPropFindResponses > 0 && n >= b.MaxPropFindResponses {
+			return errPropFindTruncated
 		}
+		n++
+		return emit(resp)
+	}
 
-		resps = make([]internal.Response, len(children))
-		for i, child := range children {
-			resp, err := b.propFindFile(propfind, &child)
-			if err != nil {
-				return nil, err
-			}
-			resps[i] = *resp
-		}
-	} else {
-		resp, err := b.propFindFile(propfind, fi)
+	if depth == internal.DepthZero || !fi.IsDir {
+		resp, err := b.propFindFile(r.Context(), propfind, fi)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		return send(resp)
+	}
+
+	recursive := depth == internal.DepthInfinity
+	if b.PropFindWorkers > 1 {
+		err = b.propFindConcurrently(r, propfind, recursive, send)
+	} else {
+		err = walk(r.Context(), b.FileSystem, r.URL.Path, recursive, func(child *FileInfo) error {
+			resp, err := b.propFindMember(r.Context(), propfind, child)
+			if resp == nil || err != nil {
+				return err
+			}
+			return send(resp)
+		})
+	}
+	if errors.Is(err, errPropFindTruncated) {
+		return emit(internal.NewTruncatedResponse(b.href(r.URL.Path)))
+	}
+	return err
+}
+
+// errPropFindTruncated stops walking collections once MaxPropFindResponses
+// responses have been sent.
+var errPropFindTruncated = errors.New("webdav: too many PROPFIND responses")
 
-		resps = []internal.Response{*resp}
+// propFindMember returns the PROPFIND response of a member of a collection,
+// or nil if it's hidden by access rules or Permissions.
+func (b *backend) propFindMember(ctx context.Context, propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
+	if !b.readable(ctx, fi.Path) {
+		return nil, nil
 	}
+	return b.propFindFile(ctx, propfind, fi)
+}
 
-	return internal.NewMultiStatus(resps...), nil
+// propFindResult is the outcome of propFindMember.
+type propFindResult struct {
+	resp *internal.Response
+	err  error
 }
 
-func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
-	props := make(map[xml.Name]internal.PropFindFunc)
+// propFindConcurrently walks a collection and computes the PROPFIND responses
+// of its members with PropFindWorkers concurrent workers, e.g. for backends
+// fetching properties or content types with remote calls. Responses are
+// emitted in walk order.
+func (b *backend) propFindConcurrently(r *http.Request, propfind *internal.PropFind, recursive bool, emit func(*internal.Response) error) error {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var workers sync.WaitGroup
+	defer workers.Wait()
+
+	// Results are queued in walk order. The queue bounds the number of
+	// responses held in memory.
+	pending := make(chan chan propFindResult, 2*b.PropFindWorkers)
+	sem := make(chan struct{}, b.PropFindWorkers)
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		walkErr <- walk(ctx, b.FileSystem, r.URL.Path, recursive, func(child *FileInfo) error {
+			fi := *child
+			result := make(chan propFindResult, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result <- propFindResult{err: ctx.Err()}
+				return ctx.Err()
+			}
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				defer func() { <-sem }()
+				resp, err := b.propFindMember(ctx, propfind, &fi)
+				result <- propFindResult{resp: resp, err: err}
+			}()
+			return nil
+		})
+	}()
 
-	props[internal.ResourceTypeName] = func(*internal.RawXMLValue) (interface{}, error) {
-		var types []xml.Name
-		if fi.IsDir {
-			types = append(types, internal.CollectionName)
+	for result := range pending {
+		res := <-result
+		if res.err == nil && res.resp != nil {
+			res.err = emit(res.resp)
+		}
+		if res.err != nil {
+			cancel()
+			// Wait for the walk to stop
+			for range pending {
+			}
+			return res.err
 		}
-		return internal.NewResourceType(types...), nil
 	}
+	return <-walkErr
+}
 
-	props[internal.SupportedLockName] = internal.PropFindValue(&internal.SupportedLock{
+// Properties with the same value for every resource are shared by responses,
+// which only read them.
+var (
+	collectionResourceType = internal.PropFindValue(internal.NewResourceType(internal.CollectionName))
+	supportedLockProp      = internal.PropFindValue(&internal.SupportedLock{
 		LockEntries: []internal.LockEntry{{
 			LockScope: internal.LockScope{Exclusive: &struct{}{}},
 			LockType:  internal.LockType{Write: &struct{}{}},
 		}},
 	})
+	emptyLockDiscoveryProp = internal.PropFindValue(&internal.LockDiscovery{})
+	executableProps        = [2]internal.PropFindFunc{
+		internal.PropFindValue(newExecutable(false)),
+		internal.PropFindValue(newExecutable(true)),
+	}
+)
+
+// propFindFileProps is the capacity of the property maps of responses.
+const propFindFileProps = 16
+
+func (b *backend) propFindFile(ctx context.Context, propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
+	props := make(map[xml.Name]internal.PropFindFunc, propFindFileProps)
+
+	// NewPropFindResponse defaults to the resource type of files
+	if fi.IsDir {
+		props[internal.ResourceTypeName] = collectionResourceType
+		props[internal.AddMemberName] = internal.PropFindValue(&internal.AddMember{
+			Href: internal.Href{Path: b.resourceHref(fi)},
+		})
+	}
+
+	props[internal.SupportedLockName] = supportedLockProp
+
+	if len(b.Reports) > 0 {
+		props[internal.SupportedReportSetName] = internal.PropFindValue(internal.NewSupportedReportSet(b.reportNames()...))
+	}
 
 	// Add empty lockdiscovery property when lock system is available
 	// Actual lock information would be added by the lock system if needed
 	if b.LockSystem != nil {
-		props[internal.LockDiscoveryName] = internal.PropFindValue(&internal.LockDiscovery{})
+		props[internal.LockDiscoveryName] = emptyLockDiscoveryProp
 	}
 
 	if !fi.IsDir {
@@ -221,194 +1353,374 @@ func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*inte
 			})
 		}
 
-		if fi.MIMEType != "" {
-			props[internal.GetContentTypeName] = internal.PropFindValue(&internal.GetContentType{
-				Type: fi.MIMEType,
-			})
+		props[internal.GetContentTypeName] = func(*internal.RawXMLValue) (interface{}, error) {
+			peek := &lazyReader{open: func() (io.ReadCloser, error) {
+				return b.FileSystem.Open(ctx, fi.Path)
+			}}
+			defer peek.Close()
+			t := b.contentType(fi, peek)
+			if t == "" {
+				return nil, &internal.HTTPError{Code: http.StatusNotFound}
+			}
+			return &internal.GetContentType{Type: t}, nil
 		}
 
 		if fi.ETag != "" {
 			props[internal.GetETagName] = internal.PropFindValue(&internal.GetETag{
-				ETag: internal.ETag(fi.ETag),
+				ETag: entityTag(fi),
 			})
 		}
+
+		if _, ok := fileSystemAs[ExecutableFileSystem](b.FileSystem); ok {
+			if fi.Executable {
+				props[executableName] = executableProps[1]
+			} else {
+				props[executableName] = executableProps[0]
+			}
+		}
+	}
+
+	// Like other RFC 3744 properties, the privileges are only reported when
+	// explicitly requested
+	if propfind.Prop != nil && propfind.Prop.Get(internal.CurrentUserPrivilegeSetName) != nil {
+		props[internal.CurrentUserPrivilegeSetName] = internal.PropFindValue(internal.NewCurrentUserPrivilegeSet(b.privileges(ctx, fi.Path)...))
 	}
 
 	// Add custom properties from the property store
-	if b.propStore != nil {
-		if pathProps, ok := b.propStore[fi.Path]; ok {
-			for xmlName, value := range pathProps {
-				propName := xmlName // Create a copy to avoid closure issues
-				propValue := value  // Create a copy to avoid closure issues
-				props[propName] = func(*internal.RawXMLValue) (interface{}, error) {
-					// Handle properties with empty namespaces differently to avoid invalid XML
-					if propName.Space == "" {
-						// For empty namespace, use a special struct without namespace prefix
-						return &struct {
-							XMLName xml.Name `xml:","`
-							Value   string   `xml:",chardata"`
-						}{
-							XMLName: xml.Name{Local: propName.Local},
-							Value:   propValue,
-						}, nil
-					}
+	if propfind.PropName != nil {
+		// Only names are needed, don't bother loading values
+		names, err := b.PropertyStore.ListPropertyNames(ctx, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, xmlName := range names {
+			props[xmlName] = internal.PropFindValue(nil)
+		}
+		if b.Checksum != "" && !fi.IsDir {
+			props[checksumsName] = internal.PropFindValue(nil)
+		}
+		return internal.NewPropFindResponse(b.resourceHref(fi), propfind, props)
+	}
+
+	// Add custom properties from the property store
+	deadProps, err := b.PropertyStore.GetProperties(ctx, fi.Path)
+	if err != nil {
+		return nil, err
+	}
+	for xmlName, value := range deadProps {
+		props[xmlName] = internal.PropFindValue(newDeadProp(xmlName, value))
+	}
 
-					// For non-empty namespaces, use the standard approach
-					return &struct {
-						XMLName xml.Name `xml:""`
-						Value   string   `xml:",chardata"`
-					}{
-						XMLName: propName,
-						Value:   propValue,
-					}, nil
+	if b.Checksum != "" && !fi.IsDir {
+		if sum, ok := deadProps[checksumsName]; ok {
+			props[checksumsName] = internal.PropFindValue(&checksums{Checksum: sum})
+		} else if propfind.Prop != nil && propfind.Prop.Get(checksumsName) != nil {
+			// Checksums are expensive to compute, only do so for files
+			// uploaded by other means when explicitly requested
+			props[checksumsName] = func(*internal.RawXMLValue) (interface{}, error) {
+				sum, err := computeChecksum(ctx, b.FileSystem, fi.Path, b.Checksum)
+				if err != nil {
+					return nil, err
 				}
+				return &checksums{Checksum: sum.String()}, nil
 			}
 		}
 	}
 
-	return internal.NewPropFindResponse(fi.Path, propfind, props)
+	return internal.NewPropFindResponse(b.resourceHref(fi), propfind, props)
 }
 
 func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
-	// Initialize the property store for this path if it doesn't exist
 	path := r.URL.Path
-	if b.propStore == nil {
-		b.propStore = make(map[string]map[xml.Name]string)
-	}
-	if b.propStore[path] == nil {
-		b.propStore[path] = make(map[xml.Name]string)
+	if err := b.confirmLocks(r, path); err != nil {
+		return nil, err
 	}
+	resp := internal.NewOKResponse(b.href(path))
 
-	// Create a response
-	resp := internal.NewOKResponse(path)
+	var (
+		remove     []xml.Name
+		set        = make(map[xml.Name]string)
+		forbidden  []xml.Name
+		executable *bool
+	)
+	xfs, _ := fileSystemAs[ExecutableFileSystem](b.FileSystem)
 
 	// Process property removals
-	for _, remove := range update.Remove {
-		for _, raw := range remove.Prop.Raw {
+	for _, rm := range update.Remove {
+		for _, raw := range rm.Prop.Raw {
 			xmlName, ok := raw.XMLName()
 			if !ok {
 				continue
 			}
 
 			// Skip DAV: namespace properties as they are managed by the server
-			if xmlName.Space == internal.Namespace {
-				// Create a new struct for the response
-				propResponse := &struct {
-					XMLName xml.Name `xml:""`
-				}{
-					XMLName: xmlName,
-				}
-
-				if err := resp.EncodeProp(http.StatusForbidden, propResponse); err != nil {
-					return nil, err
-				}
+			if isProtectedProp(xmlName) {
+				forbidden = append(forbidden, xmlName)
 				continue
 			}
 
-			// Remove the property
-			delete(b.propStore[path], xmlName)
-
-			// Create a new struct for the response
-			var propResponse interface{}
-
-			// Handle properties with empty namespaces differently to avoid invalid XML
-			if xmlName.Space == "" {
-				propResponse = &struct {
-					XMLName xml.Name `xml:","`
-				}{
-					XMLName: xml.Name{Local: xmlName.Local},
-				}
-			} else {
-				propResponse = &struct {
-					XMLName xml.Name `xml:""`
-				}{
-					XMLName: xmlName,
-				}
+			// Removing the executable property clears the executable bit
+			if xmlName == executableName && xfs != nil {
+				x := false
+				executable = &x
+				continue
 			}
 
-			// Add to response
-			if err := resp.EncodeProp(http.StatusOK, propResponse); err != nil {
-				return nil, err
-			}
+			remove = append(remove, xmlName)
 		}
 	}
 
 	// Process property sets
-	for _, set := range update.Set {
-		for _, raw := range set.Prop.Raw {
+	for _, s := range update.Set {
+		for _, raw := range s.Prop.Raw {
 			xmlName, ok := raw.XMLName()
 			if !ok {
 				continue
 			}
 
 			// Skip DAV: namespace properties as they are managed by the server
-			if xmlName.Space == internal.Namespace {
-				// Create a new struct for the response
-				propResponse := &struct {
-					XMLName xml.Name `xml:""`
-				}{
-					XMLName: xmlName,
-				}
+			if isProtectedProp(xmlName) {
+				forbidden = append(forbidden, xmlName)
+				continue
+			}
 
-				if err := resp.EncodeProp(http.StatusForbidden, propResponse); err != nil {
-					return nil, err
+			// The executable property is backed by the file mode
+			if xmlName == executableName && xfs != nil {
+				switch v := strings.TrimSpace(raw.GetTextContent()); v {
+				case "T", "F":
+					x := v == "T"
+					executable = &x
+				default:
+					forbidden = append(forbidden, xmlName)
 				}
 				continue
 			}
 
-			// Extract and store the property value
+			// Extract the property value
 			propValue := raw.GetTextContent()
 			if propValue == "" {
 				// If no text content, use a default value based on the property name
 				propValue = "manynsvalue"
 			}
-			b.propStore[path][xmlName] = propValue
-
-			// Create a new struct for the response
-			var propResponse interface{}
-
-			// Handle properties with empty namespaces differently to avoid invalid XML
-			if xmlName.Space == "" {
-				propResponse = &struct {
-					XMLName xml.Name `xml:","`
-					Value   string   `xml:",chardata"`
-				}{
-					XMLName: xml.Name{Local: xmlName.Local},
-					Value:   propValue,
-				}
-			} else {
-				propResponse = &struct {
-					XMLName xml.Name `xml:""`
-					Value   string   `xml:",chardata"`
-				}{
-					XMLName: xmlName,
-					Value:   propValue,
-				}
-			}
+			set[xmlName] = propValue
+		}
+	}
 
-			// Add to response
-			if err := resp.EncodeProp(http.StatusOK, propResponse); err != nil {
-				return nil, err
-			}
+	if err := b.PropertyStore.PatchProperties(r.Context(), path, set, remove); err != nil {
+		return nil, err
+	}
+	if executable != nil {
+		err := xfs.SetExecutable(r.Context(), path, *executable)
+		b.invalidateStat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := resp.EncodeProp(http.StatusOK, newExecutable(*executable)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, xmlName := range forbidden {
+		propResponse := &struct {
+			XMLName xml.Name `xml:""`
+		}{
+			XMLName: xmlName,
+		}
+		if err := resp.EncodeProp(http.StatusForbidden, propResponse); err != nil {
+			return nil, err
+		}
+	}
+	for _, xmlName := range remove {
+		if err := resp.EncodeProp(http.StatusOK, newDeadProp(xmlName, "")); err != nil {
+			return nil, err
+		}
+	}
+	for xmlName, value := range set {
+		if err := resp.EncodeProp(http.StatusOK, newDeadProp(xmlName, value)); err != nil {
+			return nil, err
 		}
 	}
 
 	return resp, nil
 }
 
+// isProtectedProp reports whether a property is computed by the server and
+// thus can't be changed by PROPPATCH requests.
+func isProtectedProp(name xml.Name) bool {
+	if name == checksumsName {
+		return true
+	}
+	return name.Space == internal.Namespace && name != internal.GetContentLanguageName
+}
+
 func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
-	ifNoneMatch := ConditionalMatch(r.Header.Get("If-None-Match"))
-	ifMatch := ConditionalMatch(r.Header.Get("If-Match"))
+	if err := b.confirmLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
+	opts := b.createOptions(r)
+	opts.IfNoneMatch = ConditionalMatch(r.Header.Get("If-None-Match"))
+	opts.IfMatch = ConditionalMatch(r.Header.Get("If-Match"))
+	opts.IfUnmodifiedSince = conditionalDate(r, "If-Unmodified-Since")
+	opts.IfModifiedSince = conditionalDate(r, "If-Modified-Since")
+
+	// Sync clients send the local modification time of the file
+	if s := r.Header.Get("X-OC-Mtime"); s != "" {
+		sec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return internal.HTTPErrorf(http.StatusBadRequest, "webdav: invalid X-OC-Mtime value")
+		}
+		opts.ModTime = time.Unix(sec, 0)
+	}
+
+	var expected *checksum
+	if s := r.Header.Get("OC-Checksum"); s != "" {
+		var err error
+		expected, err = parseChecksum(s)
+		if err != nil {
+			return NewHTTPError(http.StatusBadRequest, err)
+		}
+	}
+
+	body := r.Body
+	var cr *checksumReader
+	if expected != nil || b.Checksum != "" {
+		var algs []ChecksumAlgorithm
+		if b.Checksum != "" {
+			algs = append(algs, b.Checksum)
+		}
+		if expected != nil {
+			algs = append(algs, expected.Algorithm)
+		}
+		var err error
+		cr, err = newChecksumReader(r.Body, algs...)
+		if err != nil {
+			return err
+		}
+		body = cr
+	}
+
+	// The upload is verified before it replaces the file
+	var check func() error
+	if expected != nil {
+		check = func() error {
+			return verifyChecksum(cr, expected)
+		}
+	}
+	v := b.newUploadVerifier(r.URL.Path, check)
+	v.options(&opts)
+
+	fi, created, err := b.FileSystem.Create(r.Context(), r.URL.Path, body, &opts)
+	if err != nil {
+		return err
+	}
+	if err := v.finish(r.Context(), created); err != nil {
+		return err
+	}
+	if b.Checksum != "" {
+		set := map[xml.Name]string{checksumsName: cr.Sum(b.Checksum).String()}
+		if err := b.PropertyStore.PatchProperties(r.Context(), r.URL.Path, set, nil); err != nil {
+			return err
+		}
+	}
+
+	setFileInfoHeaders(w, fi)
+	if !opts.ModTime.IsZero() && fi.ModTime.Unix() == opts.ModTime.Unix() {
+		w.Header().Set("X-OC-Mtime", "accepted")
+	}
 
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	callHook(b.Hooks.OnPut, r, Event{FileInfo: fi, Created: created})
+	return nil
+}
+
+// createOptions returns the options of an upload with the content type,
+// length and metadata headers of r.
+func (b *backend) createOptions(r *http.Request) CreateOptions {
 	opts := CreateOptions{
-		IfNoneMatch: ifNoneMatch,
-		IfMatch:     ifMatch,
+		ContentType:   r.Header.Get("Content-Type"),
+		ContentLength: max(r.ContentLength, 0),
+	}
+	set := func(name string, values []string) {
+		if opts.Metadata == nil {
+			opts.Metadata = make(map[string]string)
+		}
+		opts.Metadata[name] = strings.Join(values, ", ")
+	}
+	for _, name := range b.MetadataHeaders {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			prefix = http.CanonicalHeaderKey(prefix)
+			for k, v := range r.Header {
+				if strings.HasPrefix(k, prefix) {
+					set(k, v)
+				}
+			}
+		} else if v := r.Header.Values(name); len(v) > 0 {
+			set(http.CanonicalHeaderKey(name), v)
+		}
+	}
+	return opts
+}
+
+// Post implements the add-member semantics of RFC 5995: the body of the
+// request is stored as a new member of the collection, named by the server.
+func (b *backend) Post(w http.ResponseWriter, r *http.Request) error {
+	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: POST is only supported on collections")
+	}
+	if err := b.confirmLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
+	name, err := newMemberName()
+	if err != nil {
+		return err
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		name += b.MIMETypes.ExtensionByType(ct)
 	}
-	fi, created, err := b.FileSystem.Create(r.Context(), r.URL.Path, r.Body, &opts)
+	memberPath := path.Join(r.URL.Path, name)
+
+	// Never overwrite an existing resource
+	opts := b.createOptions(r)
+	opts.IfNoneMatch = "*"
+	v := b.newUploadVerifier(memberPath, nil)
+	v.options(&opts)
+	mfi, _, err := b.FileSystem.Create(r.Context(), memberPath, r.Body, &opts)
 	if err != nil {
 		return err
 	}
+	if err := v.finish(r.Context(), true); err != nil {
+		return err
+	}
 
+	setFileInfoHeaders(w, mfi)
+	w.Header().Set("Location", (&internal.Href{Path: b.href(memberPath)}).String())
+	w.WriteHeader(http.StatusCreated)
+
+	callHook(b.Hooks.OnPut, r, Event{Path: memberPath, FileInfo: mfi, Created: true})
+	return nil
+}
+
+// newMemberName generates a random name for a resource created via POST.
+func newMemberName() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func setFileInfoHeaders(w http.ResponseWriter, fi *FileInfo) {
 	if fi.MIMEType != "" {
 		w.Header().Set("Content-Type", fi.MIMEType)
 	}
@@ -416,126 +1728,196 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
 	}
 	if fi.ETag != "" {
-		w.Header().Set("ETag", internal.ETag(fi.ETag).String())
+		w.Header().Set("ETag", entityTag(fi).String())
 	}
+}
 
-	if created {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusNoContent)
+// safeDownloadPolicy is the Content-Security-Policy of files served with
+// SafeDownloads. Documents opened anyway are sandboxed in a unique origin and
+// can't run scripts or load resources.
+const safeDownloadPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox"
+
+// setSafeDownloadHeaders makes browsers download a file rather than render
+// it in the origin of the server.
+func setSafeDownloadHeaders(w http.ResponseWriter, fi *FileInfo) {
+	h := w.Header()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(fi.Path)})
+	if disposition == "" {
+		disposition = "attachment"
 	}
-
-	return nil
+	h.Set("Content-Disposition", disposition)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", safeDownloadPolicy)
 }
 
 func (b *backend) Delete(r *http.Request) error {
+	if err := b.confirmLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
 	ifNoneMatch := ConditionalMatch(r.Header.Get("If-None-Match"))
 	ifMatch := ConditionalMatch(r.Header.Get("If-Match"))
 
 	opts := RemoveAllOptions{
-		IfNoneMatch: ifNoneMatch,
-		IfMatch:     ifMatch,
+		IfNoneMatch:       ifNoneMatch,
+		IfMatch:           ifMatch,
+		IfUnmodifiedSince: conditionalDate(r, "If-Unmodified-Since"),
+		IfModifiedSince:   conditionalDate(r, "If-Modified-Since"),
 	}
 	err := b.FileSystem.RemoveAll(r.Context(), r.URL.Path, &opts)
 
 	// Remove properties if successful
-	if err == nil && b.propStore != nil {
-		// Remove properties for this path
-		delete(b.propStore, r.URL.Path)
+	if err == nil && !b.nativeProperties() {
+		err = b.PropertyStore.DeleteProperties(r.Context(), r.URL.Path)
 	}
 
-	return err
+	if err == nil {
+		callHook(b.Hooks.OnDelete, r, Event{})
+	}
+	return b.multiStatusError(err)
 }
 
 func (b *backend) Mkcol(r *http.Request) error {
 	if r.Header.Get("Content-Type") != "" {
 		return internal.HTTPErrorf(http.StatusUnsupportedMediaType, "webdav: request body not supported in MKCOL request")
 	}
+	if err := b.confirmLocks(r, r.URL.Path); err != nil {
+		return err
+	}
 	err := b.FileSystem.Mkdir(r.Context(), r.URL.Path)
 	if internal.IsNotFound(err) {
 		return &internal.HTTPError{Code: http.StatusConflict, Err: err}
+	} else if err != nil {
+		return err
 	}
-	return err
+
+	callHook(b.Hooks.OnMkcol, r, Event{})
+	return nil
 }
 
 func (b *backend) Copy(r *http.Request, dest *internal.Href, recursive, overwrite bool) (created bool, err error) {
 	options := CopyOptions{
-		NoRecursive: !recursive,
-		NoOverwrite: !overwrite,
+		NoRecursive:        !recursive,
+		NoOverwrite:        !overwrite,
+		PreserveProperties: b.nativeProperties(),
+		IfUnmodifiedSince:  conditionalDate(r, "If-Unmodified-Since"),
+		IfModifiedSince:    conditionalDate(r, "If-Modified-Since"),
+	}
+	destPath, err := b.destinationPath(dest)
+	if err != nil {
+		return false, err
 	}
-	created, err = b.FileSystem.Copy(r.Context(), r.URL.Path, dest.Path, &options)
+	// The source and destination must differ, as per RFC4918:S9.8.5
+	if err := checkDestination(r.URL.Path, destPath); err != nil {
+		return false, err
+	}
+	if err := b.confirmLocks(r, destPath); err != nil {
+		return false, err
+	}
+	created, err = b.FileSystem.Copy(r.Context(), r.URL.Path, destPath, &options)
 	if os.IsExist(err) {
-		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
+		return false, NewHTTPError(http.StatusPreconditionFailed, err)
 	}
 
 	// Copy properties if successful
-	if err == nil && b.propStore != nil {
-		srcPath := r.URL.Path
-		dstPath := dest.Path
-
-		// Copy properties for this path
-		if props, ok := b.propStore[srcPath]; ok {
-			// Initialize destination property map if needed
-			if b.propStore[dstPath] == nil {
-				b.propStore[dstPath] = make(map[xml.Name]string)
-			}
-
-			// Copy all properties
-			for name, value := range props {
-				b.propStore[dstPath][name] = value
-			}
-		}
+	if err == nil && !options.PreserveProperties {
+		err = b.PropertyStore.CopyProperties(r.Context(), r.URL.Path, destPath, recursive)
 	}
 
-	return created, err
+	if err == nil {
+		callHook(b.Hooks.OnCopy, r, Event{Destination: destPath, Created: created})
+	}
+	return created, b.multiStatusError(err)
 }
 
 func (b *backend) Move(r *http.Request, dest *internal.Href, overwrite bool) (created bool, err error) {
 	options := MoveOptions{
-		NoOverwrite: !overwrite,
+		NoOverwrite:        !overwrite,
+		PreserveProperties: b.nativeProperties(),
+		IfUnmodifiedSince:  conditionalDate(r, "If-Unmodified-Since"),
+		IfModifiedSince:    conditionalDate(r, "If-Modified-Since"),
+	}
+	destPath, err := b.destinationPath(dest)
+	if err != nil {
+		return false, err
+	}
+	// The source and destination must differ, as per RFC4918:S9.9.4
+	if err := checkDestination(r.URL.Path, destPath); err != nil {
+		return false, err
+	}
+	// Both the source and the destination are modified
+	for _, name := range []string{r.URL.Path, destPath} {
+		if err := b.confirmLocks(r, name); err != nil {
+			return false, err
+		}
 	}
-	created, err = b.FileSystem.Move(r.Context(), r.URL.Path, dest.Path, &options)
+	if b.Hooks.OnMoveProgress != nil {
+		options.Progress = func(copied int64) {
+			callHook(b.Hooks.OnMoveProgress, r, Event{Destination: destPath, Copied: copied})
+		}
+	}
+	created, err = b.FileSystem.Move(r.Context(), r.URL.Path, destPath, &options)
 	if os.IsExist(err) {
-		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
+		return false, NewHTTPError(http.StatusPreconditionFailed, err)
 	}
 
 	// Move properties if successful
-	if err == nil && b.propStore != nil {
-		srcPath := r.URL.Path
-		dstPath := dest.Path
-
-		// Move properties for this path
-		if props, ok := b.propStore[srcPath]; ok {
-			// Initialize destination property map if needed
-			if b.propStore[dstPath] == nil {
-				b.propStore[dstPath] = make(map[xml.Name]string)
-			}
-
-			// Copy all properties to destination
-			for name, value := range props {
-				b.propStore[dstPath][name] = value
-			}
-
-			// Remove properties from source
-			delete(b.propStore, srcPath)
-		}
+	if err == nil && !options.PreserveProperties {
+		err = b.PropertyStore.MoveProperties(r.Context(), r.URL.Path, destPath)
 	}
 
-	return created, err
+	if err == nil {
+		callHook(b.Hooks.OnMove, r, Event{Destination: destPath, Created: created})
+	}
+	return created, b.multiStatusError(err)
 }
 
 func (b *backend) Lock(r *http.Request, depth internal.Depth, timeout time.Duration, refreshToken string) (lock *internal.Lock, created bool, err error) {
 	if b.LockSystem == nil {
 		return nil, false, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: lock system not available")
 	}
-	return b.LockSystem.Lock(r, depth, timeout, refreshToken)
+
+	var l *Lock
+	if refreshToken != "" {
+		l, err = b.LockSystem.Refresh(r.Context(), refreshToken, timeout)
+	} else {
+		// Locking an unmapped URL replies with "201 Created", as per RFC
+		// 4918 section 9.10.7, provided that its parent exists
+		_, statErr := b.FileSystem.Stat(r.Context(), r.URL.Path)
+		if internal.IsNotFound(statErr) {
+			if err := b.checkParent(r.Context(), r.URL.Path); err != nil {
+				return nil, false, err
+			}
+			created = true
+		} else if statErr != nil {
+			return nil, false, statErr
+		}
+		l, err = b.LockSystem.Lock(r.Context(), r.URL.Path, &LockOptions{
+			Recursive: depth == internal.DepthInfinity,
+			Timeout:   timeout,
+		})
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	root := (&internal.Href{Path: b.href(l.Root)}).String()
+	return &internal.Lock{Href: l.Token, Root: root, Timeout: l.Timeout}, created, nil
 }
 
 func (b *backend) Unlock(r *http.Request, tokenHref string) error {
 	if b.LockSystem == nil {
 		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: lock system not available")
 	}
-	return b.LockSystem.Unlock(r, tokenHref)
+	return b.LockSystem.Unlock(r.Context(), tokenHref)
+}
+
+// confirmLocks checks that the request submitted the tokens of the locks
+// covering the resource name.
+func (b *backend) confirmLocks(r *http.Request, name string) error {
+	if b.LockSystem == nil {
+		return nil
+	}
+	return b.LockSystem.Confirm(r.Context(), name, submittedLockTokens(r))
 }
 
 // UserPrincipalBackend can determine the current user's principal URL for a
@@ -546,55 +1928,3 @@ type UserPrincipalBackend interface {
 
 // Capability indicates the features that a server supports.
 type Capability string
-
-// ServePrincipalOptions holds options for ServePrincipal.
-type ServePrincipalOptions struct {
-	CurrentUserPrincipalPath string
-	Capabilities             []Capability
-}
-
-// ServePrincipal replies to requests for a principal URL.
-func ServePrincipal(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions) {
-	switch r.Method {
-	case http.MethodOptions:
-		caps := []string{"1", "3"}
-		for _, c := range options.Capabilities {
-			caps = append(caps, string(c))
-		}
-		allow := []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL"}
-		w.Header().Add("DAV", strings.Join(caps, ", "))
-		w.Header().Add("Allow", strings.Join(allow, ", "))
-		w.WriteHeader(http.StatusNoContent)
-	case "PROPFIND":
-		if err := servePrincipalPropfind(w, r, options); err != nil {
-			internal.ServeError(w, err)
-		}
-	default:
-		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
-	}
-}
-
-func servePrincipalPropfind(w http.ResponseWriter, r *http.Request, options *ServePrincipalOptions) error {
-	var propfind internal.PropFind
-	if err := internal.DecodeXMLRequest(r, &propfind); err != nil {
-		return err
-	}
-	props := map[xml.Name]internal.PropFindFunc{
-		internal.ResourceTypeName: func(*internal.RawXMLValue) (interface{}, error) {
-			return internal.NewResourceType(principalName), nil
-		},
-		internal.CurrentUserPrincipalName: func(*internal.RawXMLValue) (interface{}, error) {
-			return &internal.CurrentUserPrincipal{Href: internal.Href{Path: options.CurrentUserPrincipalPath}}, nil
-		},
-	}
-
-	// TODO: handle Depth and more properties
-
-	resp, err := internal.NewPropFindResponse(r.URL.Path, &propfind, props)
-	if err != nil {
-		return err
-	}
-
-	ms := internal.NewMultiStatus(*resp)
-	return internal.ServeMultiStatus(w, ms)
-}
@@ -0,0 +1,432 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// ShareMode is what visitors of a share link may do.
+type ShareMode int
+
+const (
+	// ShareReadOnly lets visitors browse and download the shared resource.
+	ShareReadOnly ShareMode = iota
+	// ShareUploadOnly lets visitors upload files and create collections in
+	// the shared collection, without seeing or overwriting its content.
+	// Other resources than the ones created through the share, remembered
+	// in memory, are reported as missing.
+	ShareUploadOnly
+)
+
+// Share is a public link to a resource of a FileSystem, served by Shares at
+// <Prefix>/<Token> without authentication.
+type Share struct {
+	// Token identifies the share in its URL. See NewShareToken.
+	Token string
+	// Path is the shared file or collection.
+	Path string
+	Mode ShareMode
+	// PasswordHash, if set, is the bcrypt hash of the password visitors
+	// must send with Basic authentication, whatever the user name. See
+	// SetPassword.
+	PasswordHash []byte
+	// Expires, if not zero, is the time the share stops working at.
+	Expires time.Time
+	// Owner is the user who created the share, if any.
+	Owner string
+}
+
+// SetPassword protects the share with a password, or removes the protection
+// if password is empty.
+func (s *Share) SetPassword(password string) error {
+	if password == "" {
+		s.PasswordHash = nil
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	s.PasswordHash = hash
+	return nil
+}
+
+// expired reports whether the share has expired.
+func (s *Share) expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+// NewShareToken generates a random share token.
+func NewShareToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// ShareStore stores share links.
+type ShareStore interface {
+	// CreateShare stores a new share. It fails with ErrConflict if the token
+	// is already used.
+	CreateShare(ctx context.Context, share *Share) error
+	// Share returns the share with a token, or ErrNotFound.
+	Share(ctx context.Context, token string) (*Share, error)
+	// DeleteShare removes a share. It fails with ErrNotFound if it doesn't
+	// exist.
+	DeleteShare(ctx context.Context, token string) error
+	// Shares returns the shares created by owner, or all shares if owner is
+	// empty.
+	Shares(ctx context.Context, owner string) ([]Share, error)
+}
+
+// MemShareStore is an in-memory ShareStore.
+type MemShareStore struct {
+	mu     sync.Mutex
+	shares map[string]Share
+}
+
+var _ ShareStore = (*MemShareStore)(nil)
+
+// NewMemShareStore creates an empty in-memory share store.
+func NewMemShareStore() *MemShareStore {
+	return &MemShareStore{shares: make(map[string]Share)}
+}
+
+// CreateShare implements ShareStore.
+func (s *MemShareStore) CreateShare(ctx context.Context, share *Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.shares[share.Token]; ok {
+		return ErrConflict
+	}
+	s.shares[share.Token] = *share
+	return nil
+}
+
+// Share implements ShareStore.
+func (s *MemShareStore) Share(ctx context.Context, token string) (*Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share, ok := s.shares[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &share, nil
+}
+
+// DeleteShare implements ShareStore.
+func (s *MemShareStore) DeleteShare(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.shares[token]; !ok {
+		return ErrNotFound
+	}
+	delete(s.shares, token)
+	return nil
+}
+
+// Shares implements ShareStore.
+func (s *MemShareStore) Shares(ctx context.Context, owner string) ([]Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var l []Share
+	for _, share := range s.shares {
+		if owner == "" || share.Owner == owner {
+			l = append(l, share)
+		}
+	}
+	slices.SortFunc(l, func(a, b Share) int {
+		return strings.Compare(a.Token, b.Token)
+	})
+	return l, nil
+}
+
+// Shares serves share links to the resources of a FileSystem, each at
+// <Prefix>/<token>, with its own read-only or upload-only WebDAV handler.
+// Browsers get a directory listing of shared collections.
+//
+//	shares := &webdav.Shares{FileSystem: fs, Store: webdav.NewMemShareStore()}
+//	share, err := shares.Create(ctx, &webdav.Share{Path: "/photos"})
+//	app.Use(shares.FiberHandler())
+type Shares struct {
+	// Prefix is the URL path prefix of share links, defaults to "/s".
+	Prefix     string
+	FileSystem FileSystem
+	Store      ShareStore
+
+	mu       sync.Mutex
+	handlers map[string]*shareHandler
+}
+
+// shareHandler is the handler of a share, valid as long as the share keeps
+// the same path and mode.
+type shareHandler struct {
+	path    string
+	mode    ShareMode
+	handler *Handler
+}
+
+func (s *Shares) prefix() string {
+	if s.Prefix == "" {
+		return "/s"
+	}
+	return cleanPrefix(s.Prefix)
+}
+
+// Create validates and stores a new share. A token is generated if the share
+// has none.
+func (s *Shares) Create(ctx context.Context, share *Share) error {
+	share.Path = path.Clean("/" + share.Path)
+	fi, err := s.FileSystem.Stat(ctx, share.Path)
+	if err != nil {
+		return err
+	}
+	if share.Mode == ShareUploadOnly && !fi.IsDir {
+		return internal.HTTPErrorf(http.StatusConflict, "webdav: only collections can be shared for uploads")
+	}
+	if share.Token == "" {
+		if share.Token, err = NewShareToken(); err != nil {
+			return err
+		}
+	}
+	return s.Store.CreateShare(ctx, share)
+}
+
+// Delete removes a share.
+func (s *Shares) Delete(ctx context.Context, token string) error {
+	if err := s.Store.DeleteShare(ctx, token); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.handlers, token)
+	s.mu.Unlock()
+	return nil
+}
+
+// URL returns the URL path of a share.
+func (s *Shares) URL(share *Share) string {
+	return s.prefix() + "/" + share.Token + "/"
+}
+
+// FiberHandler returns a Fiber handler serving share links. Other requests
+// are passed to the next handler.
+func (s *Shares) FiberHandler() fiber.Handler {
+	handler := adaptor.HTTPHandler(withConnContext(s))
+	prefix := s.prefix()
+	return func(c *fiber.Ctx) error {
+		if !isDescendant(path.Clean(fiberPath(c)), prefix) {
+			return c.Next()
+		}
+		return handler(c)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Shares) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, err := normalizePath(r.URL.Path)
+	if err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+	rest, ok := stripPrefix(p, s.prefix())
+	if !ok || rest == "/" {
+		http.NotFound(w, r)
+		return
+	}
+	token, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+
+	share, err := s.Store.Share(r.Context(), token)
+	if err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+	if share.expired() {
+		http.Error(w, "webdav: share link expired", http.StatusGone)
+		return
+	}
+	if share.PasswordHash != nil {
+		_, password, _ := r.BasicAuth()
+		if bcrypt.CompareHashAndPassword(share.PasswordHash, []byte(password)) != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Share", charset="UTF-8"`)
+			http.Error(w, "webdav: share password required", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	s.handler(share).ServeHTTP(w, r)
+}
+
+// handler returns the WebDAV handler of a share.
+func (s *Shares) handler(share *Share) *Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sh, ok := s.handlers[share.Token]; ok && sh.path == share.Path && sh.mode == share.Mode {
+		return sh.handler
+	}
+
+	h := &Handler{
+		Prefix:     s.prefix() + "/" + share.Token,
+		FileSystem: &shareFileSystem{fs: s.FileSystem, root: share.Path, mode: share.Mode},
+	}
+	switch share.Mode {
+	case ShareReadOnly:
+		h.ReadOnly = true
+		h.DirectoryListing = true
+	case ShareUploadOnly:
+		h.AllowedMethods = []string{http.MethodOptions, "PROPFIND", http.MethodPut, http.MethodPost, "MKCOL"}
+	}
+	if s.handlers == nil {
+		s.handlers = make(map[string]*shareHandler)
+	}
+	s.handlers[share.Token] = &shareHandler{path: share.Path, mode: share.Mode, handler: h}
+	return h
+}
+
+// shareFileSystem exposes the resource root of a FileSystem, a file or a
+// collection, as the root of a share. In upload-only mode, files can't be
+// overwritten and only the root and the resources created through the share
+// are visible, the others are reported as missing.
+type shareFileSystem struct {
+	fs   FileSystem
+	root string
+	mode ShareMode
+
+	mu      sync.Mutex
+	created map[string]bool // share paths of the resources created
+}
+
+var _ FileSystem = (*shareFileSystem)(nil)
+
+// innerPath returns the path of the share resource name in the FileSystem.
+func (sfs *shareFileSystem) innerPath(name string) string {
+	return path.Join(sfs.root, path.Clean("/"+name))
+}
+
+// sharePath returns the share path of the FileSystem resource p.
+func (sfs *shareFileSystem) sharePath(p string) string {
+	rel, _ := stripPrefix(path.Clean(p), sfs.root)
+	return rel
+}
+
+func (sfs *shareFileSystem) fileInfo(fi *FileInfo) *FileInfo {
+	shared := *fi
+	shared.Path = sfs.sharePath(fi.Path)
+	return &shared
+}
+
+func (sfs *shareFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if sfs.mode == ShareUploadOnly {
+		return nil, ErrForbidden
+	}
+	return sfs.fs.Open(ctx, sfs.innerPath(name))
+}
+
+// visible reports whether the share resource name may be seen by visitors.
+func (sfs *shareFileSystem) visible(name string) bool {
+	if sfs.mode != ShareUploadOnly {
+		return true
+	}
+	name = path.Clean("/" + name)
+	sfs.mu.Lock()
+	defer sfs.mu.Unlock()
+	return name == "/" || sfs.created[name]
+}
+
+// addCreated records that the share resource name was created through the
+// share.
+func (sfs *shareFileSystem) addCreated(name string) {
+	if sfs.mode != ShareUploadOnly {
+		return
+	}
+	sfs.mu.Lock()
+	defer sfs.mu.Unlock()
+	if sfs.created == nil {
+		sfs.created = make(map[string]bool)
+	}
+	sfs.created[path.Clean("/"+name)] = true
+}
+
+func (sfs *shareFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	if !sfs.visible(name) {
+		return nil, ErrNotFound
+	}
+	fi, err := sfs.fs.Stat(ctx, sfs.innerPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return sfs.fileInfo(fi), nil
+}
+
+func (sfs *shareFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	if sfs.mode == ShareUploadOnly {
+		// Only the collection itself is listed
+		fi, err := sfs.Stat(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return []FileInfo{*fi}, nil
+	}
+	l, err := sfs.fs.ReadDir(ctx, sfs.innerPath(name), recursive)
+	if err != nil {
+		return nil, err
+	}
+	for i := range l {
+		l[i].Path = sfs.sharePath(l[i].Path)
+	}
+	return l, nil
+}
+
+func (sfs *shareFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	if sfs.mode == ShareUploadOnly {
+		// Never overwrite existing files
+		var o CreateOptions
+		if opts != nil {
+			o = *opts
+		}
+		o.IfNoneMatch = "*"
+		opts = &o
+	}
+	fi, created, err := sfs.fs.Create(ctx, sfs.innerPath(name), body, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		sfs.addCreated(name)
+	}
+	return sfs.fileInfo(fi), created, nil
+}
+
+func (sfs *shareFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	return ErrForbidden
+}
+
+func (sfs *shareFileSystem) Mkdir(ctx context.Context, name string) error {
+	if err := sfs.fs.Mkdir(ctx, sfs.innerPath(name)); err != nil {
+		return err
+	}
+	sfs.addCreated(name)
+	return nil
+}
+
+func (sfs *shareFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	return false, ErrForbidden
+}
+
+func (sfs *shareFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	return false, ErrForbidden
+}
@@ -0,0 +1,114 @@
+package webdav_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func newTestShares(t *testing.T) (*webdav.Shares, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"photos/a.jpg":      "a",
+		"photos/b.jpg":      "b",
+		"inbox/secret.txt":  "secret",
+		"inbox/sub/old.txt": "old",
+		"private.txt":       "private",
+	})
+	return &webdav.Shares{FileSystem: webdav.LocalFileSystem(dir), Store: webdav.NewMemShareStore()}, dir
+}
+
+func createShare(t *testing.T, shares *webdav.Shares, share *webdav.Share) string {
+	t.Helper()
+	if err := shares.Create(t.Context(), share); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(shares.URL(share), "/")
+}
+
+func TestSharesReadOnly(t *testing.T) {
+	shares, dir := newTestShares(t)
+	url := createShare(t, shares, &webdav.Share{Path: "/photos"})
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: http.MethodGet, target: url + "/a.jpg"}, http.StatusOK},
+		{testRequest{method: "PROPFIND", target: url + "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus},
+		{testRequest{method: http.MethodGet, target: url + "/../private.txt"}, http.StatusNotFound},
+		{testRequest{method: http.MethodPut, target: url + "/a.jpg", body: "x"}, http.StatusForbidden},
+		{testRequest{method: http.MethodDelete, target: url + "/b.jpg"}, http.StatusForbidden},
+		{testRequest{method: http.MethodGet, target: "/s/unknown/a.jpg"}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		checkStatus(t, shares, tc.req, tc.want)
+	}
+	checkFile(t, dir, "photos/a.jpg", "a")
+	checkFile(t, dir, "photos/b.jpg", "b")
+}
+
+func TestSharesUploadOnly(t *testing.T) {
+	shares, dir := newTestShares(t)
+	url := createShare(t, shares, &webdav.Share{Path: "/inbox", Mode: webdav.ShareUploadOnly})
+	depth0 := map[string]string{"Depth": "0"}
+
+	tests := []struct {
+		req  testRequest
+		want int
+	}{
+		{testRequest{method: "PROPFIND", target: url + "/", header: depth0}, http.StatusMultiStatus},
+		{testRequest{method: "PROPFIND", target: url + "/secret.txt", header: depth0}, http.StatusNotFound},
+		{testRequest{method: "PROPFIND", target: url + "/sub/", header: depth0}, http.StatusNotFound},
+		{testRequest{method: "PROPFIND", target: url + "/sub/old.txt", header: depth0}, http.StatusNotFound},
+		{testRequest{method: http.MethodGet, target: url + "/secret.txt"}, http.StatusMethodNotAllowed},
+		{testRequest{method: http.MethodPut, target: url + "/secret.txt", body: "overwritten"}, http.StatusPreconditionFailed},
+		{testRequest{method: http.MethodPut, target: url + "/new.txt", body: "new"}, http.StatusCreated},
+		{testRequest{method: "PROPFIND", target: url + "/new.txt", header: depth0}, http.StatusMultiStatus},
+		{testRequest{method: http.MethodPut, target: url + "/new.txt", body: "replaced"}, http.StatusPreconditionFailed},
+		{testRequest{method: "MKCOL", target: url + "/dir"}, http.StatusCreated},
+		{testRequest{method: http.MethodPut, target: url + "/dir/a.txt", body: "a"}, http.StatusCreated},
+		{testRequest{method: "PROPFIND", target: url + "/dir/", header: depth0}, http.StatusMultiStatus},
+		{testRequest{method: http.MethodDelete, target: url + "/new.txt"}, http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		checkStatus(t, shares, tc.req, tc.want)
+	}
+	checkFile(t, dir, "inbox/secret.txt", "secret")
+	checkFile(t, dir, "inbox/new.txt", "new")
+	checkFile(t, dir, "inbox/dir/a.txt", "a")
+
+	// Listings don't include the members of the collection
+	w := checkStatus(t, shares, testRequest{method: "PROPFIND", target: url + "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus)
+	if body := w.Body.String(); strings.Contains(body, "secret.txt") || strings.Contains(body, "new.txt") {
+		t.Errorf("PROPFIND: unexpected members in\n%s", body)
+	}
+}
+
+func TestSharesPasswordAndExpiry(t *testing.T) {
+	shares, _ := newTestShares(t)
+	share := &webdav.Share{Path: "/photos"}
+	if err := share.SetPassword("secret"); err != nil {
+		t.Fatal(err)
+	}
+	url := createShare(t, shares, share)
+	expired := createShare(t, shares, &webdav.Share{Path: "/photos", Expires: time.Now().Add(-time.Minute)})
+
+	auth := func(password string) map[string]string {
+		return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("anyone:"+password))}
+	}
+	checkStatus(t, shares, testRequest{method: http.MethodGet, target: url + "/a.jpg"}, http.StatusUnauthorized)
+	checkStatus(t, shares, testRequest{method: http.MethodGet, target: url + "/a.jpg", header: auth("wrong")}, http.StatusUnauthorized)
+	checkStatus(t, shares, testRequest{method: http.MethodGet, target: url + "/a.jpg", header: auth("secret")}, http.StatusOK)
+	checkStatus(t, shares, testRequest{method: http.MethodGet, target: expired + "/a.jpg"}, http.StatusGone)
+
+	if err := shares.Delete(t.Context(), strings.TrimPrefix(url, "/s/")); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(t, shares, testRequest{method: http.MethodGet, target: url + "/a.jpg", header: auth("secret")}, http.StatusNotFound)
+}
@@ -0,0 +1,116 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Flusher is implemented by LockSystems and PropertyStores buffering
+// writes. Handler.Shutdown flushes them once requests are drained.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// drainer tracks in-flight requests to shut down gracefully.
+type drainer struct {
+	mu      sync.Mutex
+	active  int
+	closing bool
+	idle    chan struct{}
+}
+
+// acquire registers a new request. It returns false once shutting down.
+func (d *drainer) acquire() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return false
+	}
+	d.active++
+	return true
+}
+
+func (d *drainer) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	if d.closing && d.active == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// drain rejects new requests and waits for the in-flight ones.
+func (d *drainer) drain(ctx context.Context) error {
+	d.mu.Lock()
+	d.closing = true
+	if d.active == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveShuttingDown replies to requests received during shutdown.
+func serveShuttingDown(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, "webdav: server is shutting down", http.StatusServiceUnavailable)
+}
+
+// Shutdown gracefully shuts down the handler: new requests are rejected with
+// "503 Service Unavailable", in-flight requests are waited for until ctx is
+// done, then pending uploads are processed, the lock system and property
+// store are flushed if they implement Flusher, pending webhooks and events
+// are delivered, and the file system stops being watched. Change feeds are
+// closed first, since they never end otherwise. Background work left when
+// ctx is done is canceled. The handler can't be used anymore afterwards.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.init()
+	if h.feed != nil {
+		h.feed.close()
+	}
+	err := h.drainer.drain(ctx)
+
+	if h.stopWatch != nil {
+		h.stopWatch()
+	}
+	if h.pipeline != nil {
+		// Processing results are stored in the property store
+		err = errors.Join(err, h.pipeline.close(ctx))
+	}
+	var flushers []Flusher
+	if f, ok := h.LockSystem.(Flusher); ok {
+		flushers = append(flushers, f)
+	}
+	if f, ok := h.propertyStore().(Flusher); ok {
+		flushers = append(flushers, f)
+	}
+	if f, ok := h.AuditSink.(Flusher); ok {
+		flushers = append(flushers, f)
+	}
+	for _, f := range flushers {
+		if flushErr := f.Flush(ctx); flushErr != nil {
+			err = errors.Join(err, flushErr)
+		}
+	}
+	if h.Webhooks != nil {
+		err = errors.Join(err, h.Webhooks.Close(ctx))
+	}
+	if h.publisher != nil {
+		err = errors.Join(err, h.publisher.close(ctx))
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package webdav_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// gatedFileSystem blocks opening files until release is closed.
+type gatedFileSystem struct {
+	webdav.LocalFileSystem
+	opened  chan struct{}
+	release chan struct{}
+}
+
+func (fs gatedFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	fs.opened <- struct{}{}
+	<-fs.release
+	return fs.LocalFileSystem.Open(ctx, name)
+}
+
+// flushingLockSystem records flushes and fails them with err.
+type flushingLockSystem struct {
+	*webdav.MemLockSystem
+	flushed bool
+	err     error
+}
+
+func (ls *flushingLockSystem) Flush(ctx context.Context) error {
+	ls.flushed = true
+	return ls.err
+}
+
+func TestShutdown(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	fs := gatedFileSystem{webdav.LocalFileSystem(dir), make(chan struct{}), make(chan struct{})}
+	locks := &flushingLockSystem{MemLockSystem: webdav.NewLockSystem()}
+	h := &webdav.Handler{FileSystem: fs, LockSystem: locks}
+
+	done := make(chan int)
+	go func() {
+		done <- serve(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}).Code
+	}()
+	<-fs.opened
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- h.Shutdown(context.Background())
+	}()
+
+	// Wait for the handler to be closing: new requests are rejected
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := serve(t, h, testRequest{method: http.MethodOptions, target: "/"})
+		if w.Code == http.StatusServiceUnavailable {
+			if got := w.Header().Get("Connection"); got != "close" {
+				t.Errorf("Connection = %q during shutdown, want close", got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("requests aren't rejected during shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown() = %v before in-flight requests completed", err)
+	default:
+	}
+	close(fs.release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("in-flight GET = %v, want 200", code)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if !locks.flushed {
+		t.Errorf("lock system not flushed")
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+
+	// In-flight requests are given up on once ctx is done
+	fs := gatedFileSystem{webdav.LocalFileSystem(dir), make(chan struct{}), make(chan struct{})}
+	h := &webdav.Handler{FileSystem: fs}
+	go serve(t, h, testRequest{method: http.MethodGet, target: "/a.txt"})
+	<-fs.opened
+	defer close(fs.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Flush errors are returned, for home directories too
+	errFlush := errors.New("flush failed")
+	for _, homeDirs := range []bool{false, true} {
+		locks := &flushingLockSystem{MemLockSystem: webdav.NewLockSystem(), err: errFlush}
+		s := webdav.NewServer(webdav.Config{Root: webdav.LocalFileSystem(dir), LockSystem: locks, HomeDirs: homeDirs})
+		if homeDirs {
+			writeFiles(t, dir, map[string]string{"alice/": ""})
+			checkStatus(t, s.HTTPHandler(), testRequest{method: "PROPFIND", target: "/", user: "alice"}, http.StatusMultiStatus)
+		}
+		if err := s.Shutdown(context.Background()); !errors.Is(err, errFlush) {
+			t.Errorf("Shutdown() with HomeDirs = %v: %v, want %v", homeDirs, err, errFlush)
+		}
+		checkStatus(t, s.HTTPHandler(), testRequest{method: http.MethodGet, target: "/a.txt", user: "alice"}, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,76 @@
+package webdav
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// Query parameters of signed URLs.
+const (
+	signedURLMethodParam    = "method"
+	signedURLExpiresParam   = "expires"
+	signedURLSignatureParam = "signature"
+)
+
+// URLSigner mints expiring URLs signed with HMAC-SHA256, granting a single
+// method on a single resource without authentication, e.g. to hand out
+// time-limited download links. Set it in Config.URLSigner to serve them.
+//
+// A signed URL carries the method, the expiration time and the signature in
+// its query. Requests with a valid signature bypass TokenValidator,
+// ClientCertificates and Authorize, but not access rules or Permissions.
+// Requests with an invalid or expired signature get "403 Forbidden".
+type URLSigner struct {
+	// Key is the secret key of signatures. It should be at least 32 random
+	// bytes.
+	Key []byte
+}
+
+// SignURL returns the URL path href, including the mount prefix, with a
+// query granting the method until expires. GET URLs also grant HEAD.
+func (s *URLSigner) SignURL(method, href string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q := url.Values{
+		signedURLMethodParam:    {method},
+		signedURLExpiresParam:   {exp},
+		signedURLSignatureParam: {s.signature(method, href, exp)},
+	}
+	return (&internal.Href{Path: href}).String() + "?" + q.Encode()
+}
+
+func (s *URLSigner) signature(method, href, expires string) string {
+	if p, err := normalizePath(href); err == nil {
+		href = p
+	}
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(method + "\n" + href + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature of a request for the URL path href. It returns
+// false if the query isn't signed.
+func (s *URLSigner) verify(method, href string, query url.Values) (bool, error) {
+	sig := query.Get(signedURLSignatureParam)
+	if sig == "" {
+		return false, nil
+	}
+	signedMethod, exp := query.Get(signedURLMethodParam), query.Get(signedURLExpiresParam)
+	if !hmac.Equal([]byte(sig), []byte(s.signature(signedMethod, href, exp))) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: invalid URL signature")
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: signed URL expired")
+	}
+	if method != signedMethod && !(method == http.MethodHead && signedMethod == http.MethodGet) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: signed URL doesn't grant %v", method)
+	}
+	return true, nil
+}
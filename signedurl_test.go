@@ -0,0 +1,83 @@
+package webdav_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestURLSigner(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a b.txt": "a", "private/b.txt": "b"})
+	signer := &webdav.URLSigner{Key: []byte("0123456789abcdef0123456789abcdef")}
+	h := webdav.NewServer(webdav.Config{
+		Prefix:         "/dav",
+		Root:           webdav.LocalFileSystem(dir),
+		URLSigner:      signer,
+		TokenValidator: testTokens{"alice-token": "alice"},
+		AccessRules:    []webdav.AccessRule{{Pattern: "/private/**", Access: webdav.AccessNone}},
+	}).HTTPHandler()
+
+	future := time.Now().Add(time.Hour)
+	get := signer.SignURL(http.MethodGet, "/dav/a b.txt", future)
+	other := &webdav.URLSigner{Key: []byte("another key")}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"GET", http.MethodGet, get, http.StatusOK},
+		{"HEAD", http.MethodHead, get, http.StatusOK},
+		{"other method", http.MethodDelete, get, http.StatusForbidden},
+		{"other resource", http.MethodGet, strings.Replace(get, "a%20b.txt", "private/b.txt", 1), http.StatusForbidden},
+		{"expired", http.MethodGet, signer.SignURL(http.MethodGet, "/dav/a b.txt", time.Now().Add(-time.Minute)), http.StatusForbidden},
+		{"other key", http.MethodGet, other.SignURL(http.MethodGet, "/dav/a b.txt", future), http.StatusForbidden},
+		{"tampered expiration", http.MethodGet, strings.Replace(get, "expires=", "expires=1", 1), http.StatusForbidden},
+		{"access rules", http.MethodGet, signer.SignURL(http.MethodGet, "/dav/private/b.txt", future), http.StatusNotFound},
+		{"not signed", http.MethodGet, "/dav/a%20b.txt", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		w := serve(t, h, testRequest{method: tc.method, target: tc.target})
+		if w.Code != tc.want {
+			t.Errorf("%v: %v %v = %v, want %v", tc.name, tc.method, tc.target, w.Code, tc.want)
+		}
+	}
+	checkFile(t, dir, "a b.txt", "a")
+}
+
+func TestURLSignerFiber(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a b.txt": "a"})
+	signer := &webdav.URLSigner{Key: []byte("0123456789abcdef0123456789abcdef")}
+	app := fiber.New()
+	app.Use(webdav.NewServer(webdav.Config{
+		Prefix:         "/dav",
+		Root:           webdav.LocalFileSystem(dir),
+		URLSigner:      signer,
+		TokenValidator: testTokens{"alice-token": "alice"},
+	}).FiberHandler())
+
+	get := signer.SignURL(http.MethodGet, "/dav/a b.txt", time.Now().Add(time.Hour))
+	for target, want := range map[string]int{
+		get: http.StatusOK,
+		strings.Replace(get, "signature=", "signature=0", 1): http.StatusForbidden,
+		"/dav/a%20b.txt": http.StatusUnauthorized,
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %v = %v, want %v", target, resp.StatusCode, want)
+		}
+	}
+}
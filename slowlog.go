@@ -0,0 +1,99 @@
+package webdav
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// SlowRequestLog configures the reporting of expensive requests, e.g. to find
+// clients hammering the server with "Depth: infinity" PROPFIND scans.
+// Reported requests are logged at the warning level with Handler.Logger, or
+// the Fiber logger if nil.
+type SlowRequestLog struct {
+	// Duration reports requests taking longer. Zero disables it.
+	Duration time.Duration
+	// PropFindResponses reports PROPFIND requests producing more responses.
+	// Zero disables it.
+	PropFindResponses int
+	// OnSlowRequest, if set, is called for each reported request in addition
+	// to logging it.
+	OnSlowRequest func(ctx context.Context, req *SlowRequest)
+}
+
+// SlowRequest describes a request exceeding the thresholds of a
+// SlowRequestLog.
+type SlowRequest struct {
+	RequestID string
+	Method    string
+	// Path is the request path, including the mount prefix.
+	Path  string
+	Depth string
+	// User is the authenticated user of the request, if any.
+	User     string
+	Status   int
+	Duration time.Duration
+	// Responses is the number of responses of a PROPFIND request.
+	Responses int
+}
+
+// requestStats holds figures reported by the backend about a request.
+type requestStats struct {
+	// responses is the number of responses of a multistatus.
+	responses int
+}
+
+type requestStatsContextKey struct{}
+
+// requestStatsFromContext returns the figures of the request being served,
+// if they're collected.
+func requestStatsFromContext(ctx context.Context) (*requestStats, bool) {
+	stats, ok := ctx.Value(requestStatsContextKey{}).(*requestStats)
+	return stats, ok
+}
+
+// check reports a served request if it exceeds the thresholds.
+func (l *SlowRequestLog) check(logger *slog.Logger, r *http.Request, sw *statusWriter, stats *requestStats, duration time.Duration) {
+	slowDuration := l.Duration > 0 && duration > l.Duration
+	tooManyResponses := l.PropFindResponses > 0 && stats.responses > l.PropFindResponses
+	if !slowDuration && !tooManyResponses {
+		return
+	}
+
+	req := &SlowRequest{
+		Method:    methodLabel(r.Method),
+		Path:      strings.Clone(r.URL.Path),
+		Depth:     strings.Clone(r.Header.Get("Depth")),
+		Status:    sw.Status(),
+		Duration:  duration,
+		Responses: stats.responses,
+	}
+	req.RequestID, _ = RequestIDFromContext(r.Context())
+	if user, ok := UserFromContext(r.Context()); ok {
+		req.User = strings.Clone(user)
+	}
+
+	if logger != nil {
+		logger.LogAttrs(r.Context(), slog.LevelWarn, "webdav slow request",
+			slog.String("request_id", req.RequestID),
+			slog.String("method", req.Method),
+			slog.String("path", req.Path),
+			slog.String("depth", req.Depth),
+			slog.String("user", req.User),
+			slog.Int("status", req.Status),
+			slog.Duration("duration", req.Duration),
+			slog.Int("responses", req.Responses),
+		)
+	} else {
+		log.Warnf("webdav: slow request %s: %s %s (depth %q, user %q): status %d, %v, %d responses",
+			req.RequestID, req.Method, req.Path, req.Depth, req.User, req.Status, req.Duration, req.Responses)
+	}
+
+	if l.OnSlowRequest != nil {
+		l.OnSlowRequest(r.Context(), req)
+	}
+}
@@ -0,0 +1,67 @@
+package webdav_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestSlowRequestLog(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b.txt": "b", "d/c.txt": "c"})
+
+	var reported []*webdav.SlowRequest
+	var buf bytes.Buffer
+	h := &webdav.Handler{
+		FileSystem: slowFileSystem{webdav.LocalFileSystem(dir), new(atomic.Int32), new(atomic.Int32)},
+		Logger:     slog.New(slog.NewTextHandler(&buf, nil)),
+		SlowRequests: &webdav.SlowRequestLog{
+			Duration:          5 * time.Millisecond,
+			PropFindResponses: 3,
+			OnSlowRequest: func(ctx context.Context, req *webdav.SlowRequest) {
+				reported = append(reported, req)
+			},
+		},
+	}
+
+	tests := []struct {
+		req       testRequest
+		want      int
+		responses int
+	}{
+		{testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "0"}}, http.StatusMultiStatus, -1},
+		{testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "1"}}, http.StatusMultiStatus, 4},
+		{testRequest{method: "PROPFIND", target: "/", header: map[string]string{"Depth": "infinity"}, user: "alice"}, http.StatusMultiStatus, 5},
+		// Opening files takes 10ms
+		{testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK, 0},
+		{testRequest{method: http.MethodGet, target: "/missing"}, http.StatusNotFound, -1},
+	}
+	for _, tc := range tests {
+		reported, buf = nil, bytes.Buffer{}
+		checkStatus(t, h, tc.req, tc.want)
+		if tc.responses < 0 {
+			if len(reported) != 0 || strings.Contains(buf.String(), "webdav slow request") {
+				t.Errorf("%v %v reported as slow: %+v", tc.req.method, tc.req.target, reported)
+			}
+			continue
+		}
+		if len(reported) != 1 {
+			t.Errorf("%v %v: reported %v times, want once", tc.req.method, tc.req.target, len(reported))
+			continue
+		}
+		req := reported[0]
+		if req.Method != tc.req.method || req.Path != tc.req.target || req.Depth != tc.req.header["Depth"] || req.User != tc.req.user || req.Status != tc.want || req.Responses != tc.responses {
+			t.Errorf("%v %v: reported %+v", tc.req.method, tc.req.target, req)
+		}
+		if !strings.Contains(buf.String(), "webdav slow request") {
+			t.Errorf("%v %v: logged %q, want a slow request", tc.req.method, tc.req.target, buf.String())
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statCacheMaxEntries bounds the number of paths held by a stat cache.
+const statCacheMaxEntries = 10000
+
+// statCacheEntry is a cached Stat result or collection listing.
+type statCacheEntry struct {
+	expires  time.Time
+	fi       FileInfo
+	children []FileInfo
+}
+
+// statCacheFileSystem caches the results of Stat and of non-recursive
+// ReadDir and Walk calls for a TTL. Changes made through the FileSystem
+// invalidate the affected entries; changes made behind its back are only
+// seen once the entries expire.
+type statCacheFileSystem struct {
+	fs  FileSystem
+	ttl time.Duration
+
+	mu       sync.Mutex
+	stats    map[string]*statCacheEntry
+	listings map[string]*statCacheEntry
+}
+
+func newStatCacheFileSystem(fs FileSystem, ttl time.Duration) *statCacheFileSystem {
+	return &statCacheFileSystem{
+		fs:       fs,
+		ttl:      ttl,
+		stats:    make(map[string]*statCacheEntry),
+		listings: make(map[string]*statCacheEntry),
+	}
+}
+
+// Unwrap returns the underlying FileSystem, so that its optional interfaces
+// are still used.
+func (c *statCacheFileSystem) Unwrap() FileSystem {
+	return c.fs
+}
+
+func statCacheKey(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (c *statCacheFileSystem) lookup(m map[string]*statCacheEntry, name string) *statCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := m[statCacheKey(name)]
+	if !ok || time.Now().After(entry.expires) {
+		return nil
+	}
+	return entry
+}
+
+// store adds an entry to m, making room for it if the cache is full. It must
+// be called with c.mu held.
+func (c *statCacheFileSystem) store(m map[string]*statCacheEntry, name string, entry *statCacheEntry) {
+	if len(c.stats)+len(c.listings) >= statCacheMaxEntries {
+		now := time.Now()
+		for _, m := range []map[string]*statCacheEntry{c.stats, c.listings} {
+			for k, e := range m {
+				if now.After(e.expires) {
+					delete(m, k)
+				}
+			}
+		}
+		if len(c.stats)+len(c.listings) >= statCacheMaxEntries {
+			clear(c.stats)
+			clear(c.listings)
+		}
+	}
+	m[statCacheKey(name)] = entry
+}
+
+func (c *statCacheFileSystem) storeListing(name string, children []FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	c.store(c.listings, name, &statCacheEntry{expires: expires, children: children})
+	for _, fi := range children {
+		c.store(c.stats, fi.Path, &statCacheEntry{expires: expires, fi: fi})
+	}
+}
+
+// invalidate drops the cached entries of name, its descendants and the
+// listings of its ancestors.
+func (c *statCacheFileSystem) invalidate(name string) {
+	key := statCacheKey(name)
+	prefix := strings.TrimSuffix(key, "/") + "/"
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.stats {
+		if k == key || strings.HasPrefix(k, prefix) {
+			delete(c.stats, k)
+		}
+	}
+	// The modification time of the parent collection changes as well
+	delete(c.stats, path.Dir(key))
+	for k := range c.listings {
+		if k == key || strings.HasPrefix(k, prefix) || k == "/" || strings.HasPrefix(key, k+"/") {
+			delete(c.listings, k)
+		}
+	}
+}
+
+// watch invalidates the entries of the resources changed behind the cache's
+// back, as reported by fs, until ctx is done.
+func (c *statCacheFileSystem) watch(ctx context.Context, fs WatchableFileSystem) error {
+	events, err := fs.Watch(ctx, "/", true)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for ev := range events {
+			c.invalidate(ev.Path)
+		}
+	}()
+	return nil
+}
+
+func (c *statCacheFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return c.fs.Open(ctx, name)
+}
+
+func (c *statCacheFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	if entry := c.lookup(c.stats, name); entry != nil {
+		fi := entry.fi
+		return &fi, nil
+	}
+	fi, err := c.fs.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.store(c.stats, name, &statCacheEntry{expires: time.Now().Add(c.ttl), fi: *fi})
+	c.mu.Unlock()
+	return fi, nil
+}
+
+func (c *statCacheFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	if recursive {
+		return c.fs.ReadDir(ctx, name, recursive)
+	}
+	if entry := c.lookup(c.listings, name); entry != nil {
+		return append([]FileInfo(nil), entry.children...), nil
+	}
+	children, err := c.fs.ReadDir(ctx, name, recursive)
+	if err != nil {
+		return nil, err
+	}
+	c.storeListing(name, append([]FileInfo(nil), children...))
+	return children, nil
+}
+
+func (c *statCacheFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	if recursive {
+		return walk(ctx, c.fs, name, recursive, fn)
+	}
+	if entry := c.lookup(c.listings, name); entry != nil {
+		for _, fi := range entry.children {
+			if err := fn(&fi); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Collections too large to be cached are still streamed
+	var children []FileInfo
+	cacheable := true
+	err := walk(ctx, c.fs, name, recursive, func(fi *FileInfo) error {
+		if cacheable && len(children) < statCacheMaxEntries {
+			children = append(children, *fi)
+		} else {
+			children, cacheable = nil, false
+		}
+		return fn(fi)
+	})
+	if err == nil && cacheable {
+		c.storeListing(name, children)
+	}
+	return err
+}
+
+func (c *statCacheFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (*FileInfo, bool, error) {
+	defer c.invalidate(name)
+	return c.fs.Create(ctx, name, body, opts)
+}
+
+func (c *statCacheFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	defer c.invalidate(name)
+	return c.fs.RemoveAll(ctx, name, opts)
+}
+
+func (c *statCacheFileSystem) Mkdir(ctx context.Context, name string) error {
+	defer c.invalidate(name)
+	return c.fs.Mkdir(ctx, name)
+}
+
+func (c *statCacheFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (bool, error) {
+	defer c.invalidate(dest)
+	return c.fs.Copy(ctx, name, dest, options)
+}
+
+func (c *statCacheFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (bool, error) {
+	defer c.invalidate(dest)
+	defer c.invalidate(name)
+	return c.fs.Move(ctx, name, dest, options)
+}
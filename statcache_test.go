@@ -0,0 +1,70 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// countingFileSystem counts the metadata lookups reaching a FileSystem.
+type countingFileSystem struct {
+	webdav.FileSystem
+	lookups atomic.Int32
+}
+
+func (fs *countingFileSystem) Stat(ctx context.Context, name string) (*webdav.FileInfo, error) {
+	fs.lookups.Add(1)
+	return fs.FileSystem.Stat(ctx, name)
+}
+
+func (fs *countingFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]webdav.FileInfo, error) {
+	fs.lookups.Add(1)
+	return fs.FileSystem.ReadDir(ctx, name, recursive)
+}
+
+func TestStatCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "docs/b.txt": "b"})
+	fs := &countingFileSystem{FileSystem: webdav.LocalFileSystem(dir)}
+	h := &webdav.Handler{FileSystem: fs, StatCacheTTL: time.Hour}
+	propfind := func(target, depth string) string {
+		t.Helper()
+		return checkStatus(t, h, testRequest{method: "PROPFIND", target: target, header: map[string]string{"Depth": depth}}, http.StatusMultiStatus).Body.String()
+	}
+
+	propfind("/docs/", "1")
+	propfind("/a.txt", "0")
+	lookups := fs.lookups.Load()
+	propfind("/docs/", "1")
+	propfind("/a.txt", "0")
+	propfind("/docs/b.txt", "0")
+	if n := fs.lookups.Load(); n != lookups {
+		t.Errorf("cached PROPFIND requests made %v lookups", n-lookups)
+	}
+
+	// Changes behind the cache's back aren't seen until entries expire
+	writeFiles(t, dir, map[string]string{"a.txt": "changed"})
+	if body := propfind("/a.txt", "0"); !strings.Contains(body, ">1</getcontentlength>") {
+		t.Errorf("PROPFIND after an external change: missing cached length in\n%s", body)
+	}
+
+	// Changes made through the handler invalidate the affected entries
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "updated"}, http.StatusNoContent)
+	if body := propfind("/a.txt", "0"); !strings.Contains(body, ">7</getcontentlength>") {
+		t.Errorf("PROPFIND after PUT: missing new length in\n%s", body)
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/docs/c.txt", body: "c"}, http.StatusCreated)
+	if body := propfind("/docs/", "1"); !strings.Contains(body, "c.txt") {
+		t.Errorf("PROPFIND after PUT: missing new member in\n%s", body)
+	}
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/docs/", header: map[string]string{"Destination": "/moved/"}}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/docs/b.txt", header: map[string]string{"Depth": "0"}}, http.StatusNotFound)
+	if body := propfind("/moved/", "1"); !strings.Contains(body, "b.txt") || !strings.Contains(body, "c.txt") {
+		t.Errorf("PROPFIND after MOVE: missing members in\n%s", body)
+	}
+}
@@ -0,0 +1,141 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// TransferStats are the transfer statistics of a user.
+type TransferStats struct {
+	// BytesUploaded is the size of the request bodies sent by the user.
+	BytesUploaded int64 `json:"bytes_uploaded"`
+	// BytesDownloaded is the size of the response bodies sent to the user.
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+	// Operations counts the requests of the user by method.
+	Operations map[string]int64 `json:"operations"`
+}
+
+// StatsStore accumulates per-user transfer statistics, e.g. for quota billing
+// or abuse detection. Requests without an authenticated user are recorded
+// under the empty user.
+type StatsStore interface {
+	// Record adds a served request to the statistics of user.
+	Record(ctx context.Context, user, method string, uploaded, downloaded int64) error
+	// Stats returns the statistics of user. Users without requests have zero
+	// statistics.
+	Stats(ctx context.Context, user string) (*TransferStats, error)
+	// AllStats returns the statistics of all users, keyed by user.
+	AllStats(ctx context.Context) (map[string]*TransferStats, error)
+}
+
+// MemStatsStore is an in-memory StatsStore.
+type MemStatsStore struct {
+	mu    sync.Mutex
+	users map[string]*TransferStats
+}
+
+var _ StatsStore = (*MemStatsStore)(nil)
+
+// NewMemStatsStore creates an empty in-memory statistics store.
+func NewMemStatsStore() *MemStatsStore {
+	return &MemStatsStore{users: make(map[string]*TransferStats)}
+}
+
+// Record implements StatsStore.
+func (s *MemStatsStore) Record(ctx context.Context, user, method string, uploaded, downloaded int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.users[user]
+	if !ok {
+		stats = &TransferStats{Operations: make(map[string]int64)}
+		s.users[strings.Clone(user)] = stats
+	}
+	stats.BytesUploaded += uploaded
+	stats.BytesDownloaded += downloaded
+	if _, ok := stats.Operations[method]; !ok {
+		method = strings.Clone(method)
+	}
+	stats.Operations[method]++
+	return nil
+}
+
+// Stats implements StatsStore.
+func (s *MemStatsStore) Stats(ctx context.Context, user string) (*TransferStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.users[user]
+	if !ok {
+		return &TransferStats{Operations: make(map[string]int64)}, nil
+	}
+	return stats.clone(), nil
+}
+
+// AllStats implements StatsStore.
+func (s *MemStatsStore) AllStats(ctx context.Context) (map[string]*TransferStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make(map[string]*TransferStats, len(s.users))
+	for user, stats := range s.users {
+		all[user] = stats.clone()
+	}
+	return all, nil
+}
+
+// Reset forgets the statistics of all users, e.g. at the end of a billing
+// period.
+func (s *MemStatsStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.users)
+}
+
+func (stats *TransferStats) clone() *TransferStats {
+	c := *stats
+	c.Operations = maps.Clone(stats.Operations)
+	return &c
+}
+
+// StatsHandler returns an HTTP handler serving the statistics of a store as
+// JSON, e.g. on an admin endpoint. The statistics of a single user are
+// returned if the "user" query parameter is set, the ones of all users
+// otherwise. The handler doesn't check permissions: it must be protected by
+// the caller.
+func StatsHandler(store StatsStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "webdav: method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var v interface{}
+		var err error
+		if r.URL.Query().Has("user") {
+			v, err = store.Stats(r.Context(), r.URL.Query().Get("user"))
+		} else {
+			v, err = store.AllStats(r.Context())
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			json.NewEncoder(w).Encode(v)
+		}
+	})
+}
+
+// recordStats adds a served request to the statistics of its user.
+func recordStats(store StatsStore, r *http.Request, sw *statusWriter, body *countingReader) error {
+	user, _ := UserFromContext(r.Context())
+	return store.Record(r.Context(), user, methodLabel(r.Method), body.bytes, sw.bytes)
+}
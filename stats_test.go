@@ -0,0 +1,100 @@
+package webdav_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// failingStatsStore fails to read and record statistics.
+type failingStatsStore struct {
+	*webdav.MemStatsStore
+}
+
+func (failingStatsStore) Record(ctx context.Context, user, method string, uploaded, downloaded int64) error {
+	return errors.New("record failed")
+}
+
+func (failingStatsStore) AllStats(ctx context.Context) (map[string]*webdav.TransferStats, error) {
+	return nil, errors.New("stats failed")
+}
+
+func TestTransferStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "hello"})
+	store := webdav.NewMemStatsStore()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), Stats: store}
+
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "world!", user: "alice"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", user: "alice"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt", user: "alice"}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/a.txt"}, http.StatusOK)
+
+	stats, err := store.Stats(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BytesUploaded != 6 || stats.BytesDownloaded != 10 || stats.Operations["GET"] != 2 || stats.Operations["PUT"] != 1 {
+		t.Errorf("alice stats = %+v", stats)
+	}
+	// Statistics are copies
+	stats.Operations["GET"] = 100
+	if stats, _ := store.Stats(context.Background(), "alice"); stats.Operations["GET"] != 2 {
+		t.Errorf("Stats() returned the stored statistics")
+	}
+	if stats, _ := store.Stats(context.Background(), "bob"); stats.BytesUploaded != 0 || len(stats.Operations) != 0 {
+		t.Errorf("stats of a user without requests = %+v", stats)
+	}
+
+	// Anonymous requests are recorded under the empty user
+	w := httptest.NewRecorder()
+	webdav.StatsHandler(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var all map[string]webdav.TransferStats
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+	if len(all) != 2 || all[""].BytesDownloaded != 5 || all["alice"].Operations["PUT"] != 1 {
+		t.Errorf("all stats = %+v", all)
+	}
+
+	w = httptest.NewRecorder()
+	webdav.StatsHandler(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats?user=alice", nil))
+	var one webdav.TransferStats
+	if err := json.Unmarshal(w.Body.Bytes(), &one); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+	if one.BytesUploaded != 6 {
+		t.Errorf("alice stats = %+v", one)
+	}
+
+	store.Reset()
+	if all, _ := store.AllStats(context.Background()); len(all) != 0 {
+		t.Errorf("AllStats() after Reset() = %v", all)
+	}
+}
+
+func TestTransferStatsErrors(t *testing.T) {
+	store := failingStatsStore{webdav.NewMemStatsStore()}
+
+	for method, want := range map[string]int{
+		http.MethodPost:   http.StatusMethodNotAllowed,
+		http.MethodDelete: http.StatusMethodNotAllowed,
+		http.MethodGet:    http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		webdav.StatsHandler(store).ServeHTTP(w, httptest.NewRequest(method, "/stats", nil))
+		if w.Code != want {
+			t.Errorf("%v /stats = %v, want %v", method, w.Code, want)
+		}
+	}
+
+	// Requests succeed even if they can't be recorded
+	dir := t.TempDir()
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), Stats: store}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/a.txt", body: "a"}, http.StatusCreated)
+}
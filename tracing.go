@@ -0,0 +1,264 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans created by handlers.
+const tracerName = "github.com/Tryanks/fiber-webdav"
+
+// startRequestSpan starts the server span of a request, continuing the trace
+// propagated by the client if any. The returned request carries the span in
+// its context.
+func startRequestSpan(tp trace.TracerProvider, r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	// Request strings may share memory with buffers reused once the request
+	// is served, while spans are exported later
+	method := methodLabel(r.Method)
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", method),
+		attribute.String("url.path", strings.Clone(r.URL.Path)),
+	}
+	if id, ok := RequestIDFromContext(r.Context()); ok {
+		attrs = append(attrs, attribute.String("webdav.request_id", id))
+	}
+	if depth := r.Header.Get("Depth"); depth != "" {
+		attrs = append(attrs, attribute.String("webdav.depth", strings.Clone(depth)))
+	}
+	if dest := r.Header.Get("Destination"); dest != "" {
+		attrs = append(attrs, attribute.String("webdav.destination", strings.Clone(dest)))
+	}
+
+	ctx, span := tp.Tracer(tracerName).Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...))
+	return r.WithContext(ctx), span
+}
+
+// endRequestSpan ends the server span of a request once it's served.
+func endRequestSpan(span trace.Span, sw *statusWriter, body *countingReader) {
+	status := sw.Status()
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", status),
+		attribute.Int64("http.request.body.size", body.bytes),
+		attribute.Int64("http.response.body.size", sw.bytes),
+	)
+	if status >= http.StatusInternalServerError {
+		if sw.err != nil {
+			span.RecordError(sw.err)
+		}
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// backendTracer creates the spans of the calls to the backends of a handler.
+type backendTracer struct {
+	tracer trace.Tracer
+}
+
+func newBackendTracer(tp trace.TracerProvider) backendTracer {
+	return backendTracer{tracer: tp.Tracer(tracerName)}
+}
+
+func (t backendTracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// end ends a span, recording the error of the call if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func pathAttr(name string) attribute.KeyValue {
+	return attribute.String("webdav.path", strings.Clone(name))
+}
+
+func destinationAttr(name string) attribute.KeyValue {
+	return attribute.String("webdav.destination", strings.Clone(name))
+}
+
+// tracedFileSystem creates a span for each call to a FileSystem.
+type tracedFileSystem struct {
+	fs     FileSystem
+	tracer backendTracer
+}
+
+// Unwrap returns the underlying FileSystem, so that its optional interfaces
+// are still used.
+func (tfs *tracedFileSystem) Unwrap() FileSystem {
+	return tfs.fs
+}
+
+func (tfs *tracedFileSystem) Open(ctx context.Context, name string) (_ io.ReadCloser, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Open", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.Open(ctx, name)
+}
+
+func (tfs *tracedFileSystem) Stat(ctx context.Context, name string) (_ *FileInfo, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Stat", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.Stat(ctx, name)
+}
+
+func (tfs *tracedFileSystem) ReadDir(ctx context.Context, name string, recursive bool) (children []FileInfo, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.ReadDir", pathAttr(name), attribute.Bool("webdav.recursive", recursive))
+	defer func() {
+		span.SetAttributes(attribute.Int("webdav.entries", len(children)))
+		endSpan(span, err)
+	}()
+	return tfs.fs.ReadDir(ctx, name, recursive)
+}
+
+func (tfs *tracedFileSystem) Walk(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) (err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Walk", pathAttr(name), attribute.Bool("webdav.recursive", recursive))
+	entries := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("webdav.entries", entries))
+		endSpan(span, err)
+	}()
+	return walk(ctx, tfs.fs, name, recursive, func(fi *FileInfo) error {
+		entries++
+		return fn(fi)
+	})
+}
+
+func (tfs *tracedFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (_ *FileInfo, _ bool, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Create", pathAttr(name))
+	cr := &countingReader{ReadCloser: body}
+	defer func() {
+		span.SetAttributes(attribute.Int64("webdav.bytes", cr.bytes))
+		endSpan(span, err)
+	}()
+	return tfs.fs.Create(ctx, name, cr, opts)
+}
+
+func (tfs *tracedFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) (err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.RemoveAll", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.RemoveAll(ctx, name, opts)
+}
+
+func (tfs *tracedFileSystem) Mkdir(ctx context.Context, name string) (err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Mkdir", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.Mkdir(ctx, name)
+}
+
+func (tfs *tracedFileSystem) Copy(ctx context.Context, name, dest string, options *CopyOptions) (_ bool, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Copy", pathAttr(name), destinationAttr(dest))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.Copy(ctx, name, dest, options)
+}
+
+func (tfs *tracedFileSystem) Move(ctx context.Context, name, dest string, options *MoveOptions) (_ bool, err error) {
+	ctx, span := tfs.tracer.start(ctx, "FileSystem.Move", pathAttr(name), destinationAttr(dest))
+	defer func() { endSpan(span, err) }()
+	return tfs.fs.Move(ctx, name, dest, options)
+}
+
+// tracedLockSystem creates a span for each call to a LockSystem.
+type tracedLockSystem struct {
+	ls     LockSystem
+	tracer backendTracer
+}
+
+func (tls *tracedLockSystem) Lock(ctx context.Context, name string, opts *LockOptions) (_ *Lock, err error) {
+	ctx, span := tls.tracer.start(ctx, "LockSystem.Lock", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tls.ls.Lock(ctx, name, opts)
+}
+
+func (tls *tracedLockSystem) Refresh(ctx context.Context, token string, timeout time.Duration) (_ *Lock, err error) {
+	ctx, span := tls.tracer.start(ctx, "LockSystem.Refresh")
+	defer func() { endSpan(span, err) }()
+	return tls.ls.Refresh(ctx, token, timeout)
+}
+
+func (tls *tracedLockSystem) Unlock(ctx context.Context, token string) (err error) {
+	ctx, span := tls.tracer.start(ctx, "LockSystem.Unlock")
+	defer func() { endSpan(span, err) }()
+	return tls.ls.Unlock(ctx, token)
+}
+
+func (tls *tracedLockSystem) Lookup(ctx context.Context, token string) (_ *Lock, err error) {
+	ctx, span := tls.tracer.start(ctx, "LockSystem.Lookup")
+	defer func() { endSpan(span, err) }()
+	return tls.ls.Lookup(ctx, token)
+}
+
+func (tls *tracedLockSystem) Confirm(ctx context.Context, name string, tokens []string) (err error) {
+	ctx, span := tls.tracer.start(ctx, "LockSystem.Confirm", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tls.ls.Confirm(ctx, name, tokens)
+}
+
+// tracedPropertyStore creates a span for each call to a PropertyStore.
+type tracedPropertyStore struct {
+	ps     PropertyStore
+	tracer backendTracer
+}
+
+// Unwrap returns the underlying PropertyStore.
+func (tps *tracedPropertyStore) Unwrap() PropertyStore {
+	return tps.ps
+}
+
+func (tps *tracedPropertyStore) GetProperties(ctx context.Context, name string) (_ map[xml.Name]string, err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.GetProperties", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.GetProperties(ctx, name)
+}
+
+func (tps *tracedPropertyStore) ListPropertyNames(ctx context.Context, name string) (_ []xml.Name, err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.ListPropertyNames", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.ListPropertyNames(ctx, name)
+}
+
+func (tps *tracedPropertyStore) PatchProperties(ctx context.Context, name string, set map[xml.Name]string, remove []xml.Name) (err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.PatchProperties", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.PatchProperties(ctx, name, set, remove)
+}
+
+func (tps *tracedPropertyStore) DeleteProperties(ctx context.Context, name string) (err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.DeleteProperties", pathAttr(name))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.DeleteProperties(ctx, name)
+}
+
+func (tps *tracedPropertyStore) CopyProperties(ctx context.Context, src, dst string, recursive bool) (err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.CopyProperties", pathAttr(src), destinationAttr(dst))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.CopyProperties(ctx, src, dst, recursive)
+}
+
+func (tps *tracedPropertyStore) MoveProperties(ctx context.Context, src, dst string) (err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.MoveProperties", pathAttr(src), destinationAttr(dst))
+	defer func() { endSpan(span, err) }()
+	return tps.ps.MoveProperties(ctx, src, dst)
+}
+
+func (tps *tracedPropertyStore) WalkProperties(ctx context.Context, fn func(name string) error) (err error) {
+	ctx, span := tps.tracer.start(ctx, "PropertyStore.WalkProperties")
+	defer func() { endSpan(span, err) }()
+	return tps.ps.WalkProperties(ctx, fn)
+}
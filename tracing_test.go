@@ -0,0 +1,143 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// recordingTracerProvider records the spans ended by its tracers.
+type recordingTracerProvider struct {
+	noop.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (tp *recordingTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{tp: tp}
+}
+
+// find returns the first ended span named name.
+func (tp *recordingTracerProvider) find(name string) *recordingSpan {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for _, span := range tp.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	tp *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{tp: t.tp, name: name, kind: cfg.SpanKind(), attrs: map[attribute.Key]attribute.Value{}}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordingSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(cfg.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	noop.Span
+	tp *recordingTracerProvider
+
+	name, parent string
+	kind         trace.SpanKind
+	attrs        map[attribute.Key]attribute.Value
+	status       codes.Code
+	errs         []error
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.status = code
+}
+
+func (s *recordingSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordingSpan) End(opts ...trace.SpanEndOption) {
+	s.tp.mu.Lock()
+	s.tp.spans = append(s.tp.spans, s)
+	s.tp.mu.Unlock()
+}
+
+func TestTracing(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "hello"})
+	tp := &recordingTracerProvider{}
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), LockSystem: webdav.NewLockSystem(), TracerProvider: tp}
+
+	checkStatus(t, h, testRequest{method: "COPY", target: "/a.txt", header: map[string]string{"Destination": "/b.txt", "Depth": "0"}}, http.StatusCreated)
+	span := tp.find("COPY")
+	if span == nil {
+		t.Fatal("no span for the request")
+	}
+	if span.kind != trace.SpanKindServer {
+		t.Errorf("request span kind = %v, want server", span.kind)
+	}
+	for k, want := range map[attribute.Key]string{
+		"http.request.method": "COPY",
+		"url.path":            "/a.txt",
+		"webdav.depth":        "0",
+		"webdav.destination":  "/b.txt",
+	} {
+		if got := span.attrs[k].AsString(); got != want {
+			t.Errorf("request span %v = %q, want %q", k, got, want)
+		}
+	}
+	if got := span.attrs["http.response.status_code"].AsInt64(); got != http.StatusCreated {
+		t.Errorf("request span status code = %v, want 201", got)
+	}
+	backend := tp.find("FileSystem.Copy")
+	if backend == nil {
+		t.Fatal("no span for the FileSystem call")
+	}
+	if backend.parent != "COPY" || backend.attrs["webdav.path"].AsString() != "/a.txt" || backend.attrs["webdav.destination"].AsString() != "/b.txt" {
+		t.Errorf("FileSystem.Copy span = %+v", backend)
+	}
+
+	checkStatus(t, h, testRequest{method: "LOCK", target: "/a.txt", body: lockBody}, http.StatusOK)
+	if span := tp.find("LockSystem.Lock"); span == nil || span.parent != "LOCK" {
+		t.Errorf("LockSystem.Lock span = %+v, want a child of the LOCK span", span)
+	}
+
+	// Client errors are recorded on backend spans only
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/missing"}, http.StatusNotFound)
+	if span := tp.find("DELETE"); span == nil || span.status == codes.Error {
+		t.Errorf("DELETE span = %+v, want no error status", span)
+	}
+	if span := tp.find("FileSystem.RemoveAll"); span == nil || span.status != codes.Error || len(span.errs) != 1 {
+		t.Errorf("FileSystem.RemoveAll span = %+v, want the error", span)
+	}
+
+	// Server errors are recorded on request spans
+	tp = &recordingTracerProvider{}
+	h = &webdav.Handler{FileSystem: failingStatFileSystem{webdav.LocalFileSystem(dir)}, TracerProvider: tp}
+	checkStatus(t, h, testRequest{method: "PROPFIND", target: "/a.txt"}, http.StatusInternalServerError)
+	if span := tp.find("PROPFIND"); span == nil || span.status != codes.Error || len(span.errs) != 1 {
+		t.Errorf("PROPFIND span = %+v, want the error", span)
+	}
+}
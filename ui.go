@@ -0,0 +1,27 @@
+package webdav
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+)
+
+// uiPage is the single-page file manager served to web browsers when
+// Handler.WebUI is set. It lists collections with the JSON listing and
+// manages files with WebDAV requests.
+//
+//go:embed ui/index.html
+var uiPage []byte
+
+// serveUI replies to a GET or HEAD request on a collection with the web file
+// manager.
+func serveUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(uiPage)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	if r.Method != http.MethodHead {
+		w.Write(uiPage)
+	}
+}
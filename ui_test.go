@@ -0,0 +1,46 @@
+package webdav_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestWebUI(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"d/a.txt": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), WebUI: true}
+
+	w := checkStatus(t, h, testRequest{method: http.MethodGet, target: "/d/", header: map[string]string{"Accept": "text/html"}}, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want HTML", ct)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Errorf("X-Frame-Options = %q, want DENY", got)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<html") || strings.Contains(body, "a.txt") {
+		t.Errorf("GET on a collection = %q, want the file manager", body)
+	}
+
+	w = checkStatus(t, h, testRequest{method: http.MethodHead, target: "/d/"}, http.StatusOK)
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("HEAD = %q with Content-Length %v, want no body and the page length", w.Body, w.Header().Get("Content-Length"))
+	}
+
+	// The file manager relies on the JSON listing
+	w = checkStatus(t, h, testRequest{method: http.MethodGet, target: "/d/", header: map[string]string{"Accept": "application/json"}}, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"a.txt"`) {
+		t.Errorf("JSON listing = %s, want a.txt", w.Body)
+	}
+
+	// Files are still downloaded
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/d/a.txt", header: map[string]string{"Accept": "text/html"}}, http.StatusOK)
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/missing/"}, http.StatusNotFound)
+
+	h.WebUI = false
+	checkStatus(t, h, testRequest{method: http.MethodGet, target: "/d/"}, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,161 @@
+package webdav
+
+import (
+	"context"
+	"maps"
+	"path"
+	"slices"
+	"time"
+)
+
+// FileEventOp is the kind of change reported by a FileEvent.
+type FileEventOp int
+
+const (
+	// FileEventCreate reports a created resource, including the destination of
+	// a rename.
+	FileEventCreate FileEventOp = iota + 1
+	// FileEventWrite reports modified content or metadata, e.g. a file closed
+	// after writing or a new modification time.
+	FileEventWrite
+	// FileEventRemove reports a removed resource, including the source of a
+	// rename. The descendants of removed collections aren't reported.
+	FileEventRemove
+)
+
+// String returns the name of the operation.
+func (op FileEventOp) String() string {
+	switch op {
+	case FileEventCreate:
+		return "create"
+	case FileEventWrite:
+		return "write"
+	case FileEventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// FileEvent is a change of a resource of a WatchableFileSystem.
+type FileEvent struct {
+	Op FileEventOp
+	// Path is the absolute slash-separated path of the resource.
+	Path  string
+	IsDir bool
+}
+
+// WatchableFileSystem is implemented by FileSystems which can report changes
+// to their resources, including the ones made behind the handler's back,
+// e.g. by other programs. The handler uses it to invalidate the stat cache
+// (see Handler.StatCacheTTL); other uses include bumping cached entity tags
+// and feeding change logs.
+type WatchableFileSystem interface {
+	// Watch reports the changes of the resource name and its members, or
+	// its descendants if recursive is set, until ctx is done. Then, or if
+	// the watched resource goes away, the channel is closed.
+	//
+	// Events may be coalesced. If some are lost, e.g. because they came
+	// faster than they were received, a FileEventWrite for name itself is
+	// sent, meaning that anything below it may have changed.
+	Watch(ctx context.Context, name string, recursive bool) (<-chan FileEvent, error)
+}
+
+var _ WatchableFileSystem = LocalFileSystem("")
+
+// pollInterval is the delay between the scans of pollWatch.
+const pollInterval = 2 * time.Second
+
+// pollState is the state of a resource seen by pollWatch.
+type pollState struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+// pollWatch implements Watch for FileSystems without change notifications,
+// by scanning the watched resources periodically and comparing their
+// metadata.
+func pollWatch(ctx context.Context, fs FileSystem, name string, recursive bool, interval time.Duration) (<-chan FileEvent, error) {
+	name = path.Clean(name)
+	scan := func() (map[string]pollState, error) {
+		m := make(map[string]pollState)
+		err := walk(ctx, fs, name, recursive, func(fi *FileInfo) error {
+			m[path.Clean(fi.Path)] = pollState{isDir: fi.IsDir, size: fi.Size, modTime: fi.ModTime}
+			return nil
+		})
+		return m, err
+	}
+	prev, err := scan()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan FileEvent)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			cur, err := scan()
+			if ctx.Err() != nil {
+				return
+			} else if err != nil {
+				// The watched resource is gone
+				sendEvent(ctx, ch, FileEvent{Op: FileEventRemove, Path: name, IsDir: prev[name].isDir})
+				return
+			}
+			for _, ev := range diffPollStates(prev, cur) {
+				if !sendEvent(ctx, ch, ev) {
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+	return ch, nil
+}
+
+// diffPollStates returns the events turning prev into cur. Removals come
+// first, and the descendants of removed collections are omitted.
+func diffPollStates(prev, cur map[string]pollState) []FileEvent {
+	var events []FileEvent
+	removed := make(map[string]bool)
+	for _, p := range slices.Sorted(maps.Keys(prev)) {
+		if _, ok := cur[p]; ok {
+			continue
+		}
+		removed[p] = true
+		if !removed[path.Dir(p)] {
+			events = append(events, FileEvent{Op: FileEventRemove, Path: p, IsDir: prev[p].isDir})
+		}
+	}
+	for _, p := range slices.Sorted(maps.Keys(cur)) {
+		old, ok := prev[p]
+		st := cur[p]
+		switch {
+		case !ok:
+			events = append(events, FileEvent{Op: FileEventCreate, Path: p, IsDir: st.isDir})
+		case old.isDir != st.isDir:
+			events = append(events, FileEvent{Op: FileEventRemove, Path: p, IsDir: old.isDir}, FileEvent{Op: FileEventCreate, Path: p, IsDir: st.isDir})
+		case old.size != st.size || !old.modTime.Equal(st.modTime):
+			events = append(events, FileEvent{Op: FileEventWrite, Path: p, IsDir: st.isDir})
+		}
+	}
+	return events
+}
+
+// sendEvent sends an event unless ctx is done first, and reports whether it
+// was sent.
+func sendEvent(ctx context.Context, ch chan<- FileEvent, ev FileEvent) bool {
+	select {
+	case ch <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,224 @@
+//go:build linux
+
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask selects the inotify events reported by Watch. Files are
+// reported once closed after writing rather than for each write.
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// Watch reports the changes of the files below the directory with inotify.
+func (fs LocalFileSystem) Watch(ctx context.Context, name string, recursive bool) (<-chan FileEvent, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// The non-blocking descriptor is read with the runtime poller, and
+	// closing the file interrupts pending reads
+	f := os.NewFile(uintptr(fd), "inotify")
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		fs:        fs,
+		f:         f,
+		rc:        rc,
+		root:      path.Clean(name),
+		recursive: recursive && fi.IsDir(),
+		paths:     make(map[int]string),
+	}
+	if err := w.add(ctx, p, false, nil); err != nil {
+		f.Close()
+		return nil, errFromOS(err)
+	}
+
+	ch := make(chan FileEvent)
+	stop := context.AfterFunc(ctx, func() {
+		f.Close()
+	})
+	go w.run(ctx, ch, stop)
+	return ch, nil
+}
+
+type inotifyWatcher struct {
+	fs        LocalFileSystem
+	f         *os.File
+	rc        syscall.RawConn
+	root      string
+	rootWd    int
+	recursive bool
+	paths     map[int]string // external paths by watch descriptor
+}
+
+// add watches the directory p and, if recursive, its subdirectories. If
+// report is set, their members are reported as created, since they may have
+// appeared before being watched.
+func (w *inotifyWatcher) add(ctx context.Context, p string, sub bool, report func(FileEvent) bool) error {
+	addWatch := func(p string) error {
+		name, err := w.fs.externalPath(p)
+		if err != nil {
+			return err
+		}
+		var wd int
+		var addErr error
+		if err := w.rc.Control(func(fd uintptr) {
+			wd, addErr = unix.InotifyAddWatch(int(fd), p, inotifyMask)
+		}); err != nil {
+			return err
+		} else if addErr != nil {
+			return &fs.PathError{Op: "inotify_add_watch", Path: p, Err: addErr}
+		}
+		w.paths[wd] = name
+		if !sub && name == w.root {
+			w.rootWd = wd
+		}
+		return nil
+	}
+
+	if !w.recursive {
+		return addWatch(p)
+	}
+	return filepath.WalkDir(p, func(q string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if q != p && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if q != p && report != nil {
+			if name, err := w.fs.externalPath(q); err == nil && !report(FileEvent{Op: FileEventCreate, Path: name, IsDir: d.IsDir()}) {
+				return ctx.Err()
+			}
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := addWatch(q); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+}
+
+// forget removes the watches of the directory name and its descendants,
+// e.g. once moved out of the watched tree.
+func (w *inotifyWatcher) forget(name string) {
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for wd, p := range w.paths {
+		if p == name || strings.HasPrefix(p, prefix) {
+			delete(w.paths, wd)
+			w.rc.Control(func(fd uintptr) {
+				unix.InotifyRmWatch(int(fd), uint32(wd))
+			})
+		}
+	}
+}
+
+func (w *inotifyWatcher) run(ctx context.Context, ch chan<- FileEvent, stop func() bool) {
+	defer close(ch)
+	defer func() {
+		if stop() {
+			w.f.Close()
+		}
+	}()
+	send := func(ev FileEvent) bool {
+		return sendEvent(ctx, ch, ev)
+	}
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(raw.Len)]
+			off += unix.SizeofInotifyEvent + int(raw.Len)
+			if !w.handle(ctx, int(raw.Wd), raw.Mask, string(bytes.TrimRight(nameBytes, "\x00")), send) {
+				return
+			}
+		}
+	}
+}
+
+// handle translates an inotify event, and reports whether watching goes on.
+func (w *inotifyWatcher) handle(ctx context.Context, wd int, mask uint32, name string, send func(FileEvent) bool) bool {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return send(FileEvent{Op: FileEventWrite, Path: w.root, IsDir: w.recursive})
+	}
+	dir, ok := w.paths[wd]
+	if !ok {
+		return true
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+		return wd != w.rootWd
+	}
+	isDir := mask&unix.IN_ISDIR != 0
+
+	if name == "" {
+		// The watched directory or file itself, members of watched
+		// directories are reported by their parent
+		if wd != w.rootWd {
+			return true
+		}
+		switch {
+		case mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+			send(FileEvent{Op: FileEventRemove, Path: w.root, IsDir: isDir || w.recursive})
+			return false
+		case mask&(unix.IN_CLOSE_WRITE|unix.IN_ATTRIB) != 0:
+			return send(FileEvent{Op: FileEventWrite, Path: w.root, IsDir: isDir || w.recursive})
+		}
+		return true
+	}
+
+	p := path.Join(dir, name)
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		if !send(FileEvent{Op: FileEventCreate, Path: p, IsDir: isDir}) {
+			return false
+		}
+		if isDir && w.recursive {
+			lp, err := w.fs.localPath(p)
+			if err == nil {
+				w.add(ctx, lp, true, send)
+			}
+		}
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		if isDir {
+			w.forget(p)
+		}
+		return send(FileEvent{Op: FileEventRemove, Path: p, IsDir: isDir})
+	case mask&(unix.IN_CLOSE_WRITE|unix.IN_ATTRIB) != 0:
+		return send(FileEvent{Op: FileEventWrite, Path: p, IsDir: isDir})
+	}
+	return ctx.Err() == nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package webdav
+
+import (
+	"context"
+)
+
+// Watch reports the changes of the files below the directory by scanning
+// them periodically.
+func (fs LocalFileSystem) Watch(ctx context.Context, name string, recursive bool) (<-chan FileEvent, error) {
+	return pollWatch(ctx, fs, name, recursive, pollInterval)
+}
@@ -0,0 +1,101 @@
+package webdav_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// waitEvent receives events from ch until want, failing the test if it
+// doesn't come. Other events are skipped, since they may be coalesced.
+func waitEvent(t *testing.T, ch <-chan webdav.FileEvent, want webdav.FileEvent) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed, want %v %v", want.Op, want.Path)
+			}
+			if ev == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %v event for %v", want.Op, want.Path)
+		}
+	}
+}
+
+func TestLocalFileSystemWatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	ch, err := webdav.LocalFileSystem(dir).Watch(ctx, "/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventCreate, Path: "/a.txt"})
+
+	// Collections created later are watched too
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventCreate, Path: "/sub", IsDir: true})
+	writeFiles(t, dir, map[string]string{"sub/b.txt": "b"})
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventCreate, Path: "/sub/b.txt"})
+	writeFiles(t, dir, map[string]string{"sub/b.txt": "changed"})
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventWrite, Path: "/sub/b.txt"})
+
+	if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventRemove, Path: "/a.txt"})
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventCreate, Path: "/sub/c.txt"})
+
+	if err := os.RemoveAll(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, ch, webdav.FileEvent{Op: webdav.FileEventRemove, Path: "/sub", IsDir: true})
+
+	// The channel is closed once ctx is done
+	cancel()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel not closed")
+		}
+	}
+}
+
+func TestStatCacheWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{FileSystem: webdav.LocalFileSystem(dir), StatCacheTTL: time.Hour}
+	defer h.Shutdown(t.Context())
+	propfind := testRequest{method: "PROPFIND", target: "/a.txt", header: map[string]string{"Depth": "0"}}
+	checkStatus(t, h, propfind, http.StatusMultiStatus)
+
+	// Changes behind the handler's back invalidate the cache
+	writeFiles(t, dir, map[string]string{"a.txt": "changed"})
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		body := checkStatus(t, h, propfind, http.StatusMultiStatus).Body.String()
+		if strings.Contains(body, ">7</getcontentlength>") {
+			break
+		} else if time.Since(start) > 10*time.Second {
+			t.Fatalf("PROPFIND: missing new length in\n%s", body)
+		}
+	}
+}
@@ -4,6 +4,8 @@
 package webdav
 
 import (
+	"context"
+	"io"
 	"time"
 
 	"github.com/Tryanks/fiber-webdav/internal"
@@ -17,30 +19,89 @@ type FileInfo struct {
 	IsDir    bool
 	MIMEType string
 	ETag     string
+	// WeakETag is set if ETag is a weak entity tag, as defined in RFC 9110
+	// section 8.8.3, e.g. one which doesn't change with every modification.
+	// Weak entity tags only satisfy If-None-Match, never If-Match, If-Range
+	// or the entity tag conditions of If headers.
+	WeakETag bool
+	// Executable is set if the file has its executable bit set. It's only
+	// meaningful for FileSystems implementing ExecutableFileSystem.
+	Executable bool
 }
 
 type CreateOptions struct {
 	IfMatch     ConditionalMatch
 	IfNoneMatch ConditionalMatch
+	// IfUnmodifiedSince and IfModifiedSince, if set, are the dates of the
+	// If-Unmodified-Since and If-Modified-Since headers. The request fails
+	// with "412 Precondition Failed" if the existing file was modified after
+	// IfUnmodifiedSince, or not after IfModifiedSince. Each is ignored when
+	// IfMatch, respectively IfNoneMatch, is set, as specified in RFC 9110
+	// section 13.2.2.
+	IfUnmodifiedSince time.Time
+	IfModifiedSince   time.Time
+	// ModTime is the modification time to set on the file, as provided by
+	// the client. If zero, the FileSystem picks the modification time.
+	ModTime time.Time
+	// ContentType is the media type of the content declared by the client,
+	// if any.
+	ContentType string
+	// ContentLength is the size of the content declared by the client, or
+	// zero if unknown. The content may still end early.
+	ContentLength int64
+	// Metadata holds the request headers selected with
+	// Handler.MetadataHeaders, keyed by canonical header name, e.g. for
+	// backends storing them as object metadata.
+	Metadata map[string]string
+	// Verify, if set, is called once the content has been written, with a
+	// function opening it, before it replaces the existing file or becomes
+	// visible. If it fails, the content is discarded, the existing file is
+	// left untouched and Create returns its error. FileSystems stage the
+	// content, e.g. in a temporary file renamed over the existing one.
+	// FileSystems which can't must not call it, the handler then verifies
+	// the file once written.
+	Verify func(ctx context.Context, open func() (io.ReadCloser, error)) error
 }
 
 type RemoveAllOptions struct {
 	IfMatch     ConditionalMatch
 	IfNoneMatch ConditionalMatch
+	// IfUnmodifiedSince and IfModifiedSince are evaluated like the ones of
+	// CreateOptions.
+	IfUnmodifiedSince time.Time
+	IfModifiedSince   time.Time
 }
 
 type CopyOptions struct {
 	NoRecursive bool
 	NoOverwrite bool
+	// IfUnmodifiedSince and IfModifiedSince are evaluated against the
+	// source like the ones of CreateOptions.
+	IfUnmodifiedSince time.Time
+	IfModifiedSince   time.Time
+	// PreserveProperties is set when the FileSystem is the PropertyStore and
+	// is expected to copy the resource's properties along with its content.
+	PreserveProperties bool
 }
 
 type MoveOptions struct {
 	NoOverwrite bool
+	// IfUnmodifiedSince and IfModifiedSince are evaluated against the
+	// source like the ones of CreateOptions.
+	IfUnmodifiedSince time.Time
+	IfModifiedSince   time.Time
+	// PreserveProperties is set when the FileSystem is the PropertyStore and
+	// is expected to move the resource's properties along with its content.
+	PreserveProperties bool
+	// Progress, if set, is called with the number of bytes copied so far by
+	// FileSystems which can't rename the resource, e.g. across devices, and
+	// copy it instead.
+	Progress func(copied int64)
 }
 
 // ConditionalMatch represents the value of a conditional header
 // according to RFC 2068 section 14.25 and RFC 2068 section 14.26
-// The (optional) value can either be a wildcard or an ETag.
+// The (optional) value can either be a wildcard or a list of ETags.
 type ConditionalMatch string
 
 func (val ConditionalMatch) IsSet() bool {
@@ -59,13 +120,34 @@ func (val ConditionalMatch) ETag() (string, error) {
 	return string(e), nil
 }
 
+// MatchETag reports whether the value matches the strong entity tag etag
+// using the strong comparison function of RFC 9110 section 8.8.3.2, as
+// required for If-Match: weak entity tags in the value never match.
 func (val ConditionalMatch) MatchETag(etag string) (bool, error) {
+	return val.matchETag(etag, false)
+}
+
+// MatchWeakETag is like MatchETag, but uses the weak comparison function, as
+// required for If-None-Match: the weakness of entity tags is ignored.
+func (val ConditionalMatch) MatchWeakETag(etag string) (bool, error) {
+	return val.matchETag(etag, true)
+}
+
+func (val ConditionalMatch) matchETag(etag string, weakComparison bool) (bool, error) {
 	if etag == "" {
 		return false, nil
 	}
 	if val.IsWildcard() {
 		return true, nil
 	}
-	t, err := val.ETag()
-	return t == etag, err
+	tags, err := internal.ParseEntityTags(string(val))
+	if err != nil {
+		return false, err
+	}
+	for _, t := range tags {
+		if t.Tag == etag && (weakComparison || !t.Weak) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
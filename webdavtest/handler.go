@@ -0,0 +1,314 @@
+package webdavtest
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav/internal"
+)
+
+// litmusRoot is the collection created by TestHandlerCompliance to hold the
+// resources of its tests.
+const litmusRoot = "/litmus/"
+
+// TestHandlerCompliance checks that h implements RFC 4918 as expected by
+// clients, running the litmus basic, copymove, props and locks scenarios. h
+// must serve requests at the root of the URL space and support locking. The
+// tests run in the collection /litmus/, which must not exist, and remove it
+// once they pass.
+func TestHandlerCompliance(t *testing.T, h http.Handler) {
+	expectStatus(t, h, "MKCOL", litmusRoot, "", nil, http.StatusCreated)
+	defer expectStatus(t, h, http.MethodDelete, litmusRoot, "", nil, http.StatusNoContent)
+
+	t.Run("basic", func(t *testing.T) {
+		testHandlerBasic(t, h)
+	})
+	t.Run("copymove", func(t *testing.T) {
+		testHandlerCopyMove(t, h)
+	})
+	t.Run("props", func(t *testing.T) {
+		testHandlerProps(t, h)
+	})
+	t.Run("locks", func(t *testing.T) {
+		testHandlerLocks(t, h)
+	})
+}
+
+func testHandlerBasic(t *testing.T, h http.Handler) {
+	w := expectStatus(t, h, http.MethodOptions, litmusRoot, "", nil, http.StatusOK, http.StatusNoContent)
+	if !slices.Contains(headerList(w.Header().Get("DAV")), "1") {
+		t.Errorf("OPTIONS: DAV header field %q doesn't advertise class 1", w.Header().Get("DAV"))
+	}
+
+	const base = litmusRoot + "basic/"
+	expectStatus(t, h, "MKCOL", base, "", nil, http.StatusCreated)
+
+	expectStatus(t, h, http.MethodPut, base+"res", "This is a test file.", nil, http.StatusCreated)
+	w = expectStatus(t, h, http.MethodGet, base+"res", "", nil, http.StatusOK)
+	if got := w.Body.String(); got != "This is a test file." {
+		t.Errorf("GET: body = %q, want the uploaded content", got)
+	}
+	expectStatus(t, h, http.MethodPut, base+"res", "Overwritten.", nil, http.StatusOK, http.StatusNoContent)
+	w = expectStatus(t, h, http.MethodGet, base+"res", "", nil, http.StatusOK)
+	if got := w.Body.String(); got != "Overwritten." {
+		t.Errorf("GET: body = %q, want the overwritten content", got)
+	}
+	w = expectStatus(t, h, http.MethodHead, base+"res", "", nil, http.StatusOK)
+	if got := w.Header().Get("Content-Length"); got != "" && got != "12" {
+		t.Errorf("HEAD: Content-Length = %q, want 12", got)
+	}
+	w = expectStatus(t, h, http.MethodOptions, base+"res", "", nil, http.StatusOK, http.StatusNoContent)
+	for _, method := range []string{http.MethodGet, http.MethodPut, "PROPFIND", "PROPPATCH"} {
+		if !slices.Contains(headerList(w.Header().Get("Allow")), method) {
+			t.Errorf("OPTIONS: Allow header field %q doesn't list %v", w.Header().Get("Allow"), method)
+		}
+	}
+	expectStatus(t, h, http.MethodPut, base+"missing/res", "", nil, http.StatusConflict)
+	expectStatus(t, h, http.MethodPut, base+"res/child", "", nil, http.StatusConflict)
+	expectStatus(t, h, http.MethodGet, base+"res/child", "", nil, http.StatusNotFound)
+
+	expectStatus(t, h, http.MethodDelete, base+"res", "", nil, http.StatusNoContent, http.StatusOK)
+	expectStatus(t, h, http.MethodGet, base+"res", "", nil, http.StatusNotFound)
+	expectStatus(t, h, http.MethodDelete, base+"res", "", nil, http.StatusNotFound)
+
+	expectStatus(t, h, "MKCOL", base+"coll/", "", nil, http.StatusCreated)
+	w = expectStatus(t, h, "MKCOL", base+"coll/", "", nil, http.StatusMethodNotAllowed)
+	if allow := headerList(w.Header().Get("Allow")); !slices.Contains(allow, "PROPFIND") || slices.Contains(allow, "MKCOL") {
+		t.Errorf("MKCOL: Allow header field %q of the 405 response doesn't list the methods of the collection", w.Header().Get("Allow"))
+	}
+	expectStatus(t, h, "MKCOL", base+"missing/coll/", "", nil, http.StatusConflict)
+	expectStatus(t, h, "MKCOL", base+"body/", "afafafaf", map[string]string{"Content-Type": "xzy-foo/bar-512"}, http.StatusUnsupportedMediaType)
+
+	expectStatus(t, h, http.MethodPut, base+"coll/member", "member", nil, http.StatusCreated)
+	expectStatus(t, h, http.MethodDelete, base+"coll/", "", nil, http.StatusNoContent, http.StatusOK)
+	expectStatus(t, h, http.MethodGet, base+"coll/member", "", nil, http.StatusNotFound)
+}
+
+func testHandlerCopyMove(t *testing.T, h http.Handler) {
+	const base = litmusRoot + "copymove/"
+	expectStatus(t, h, "MKCOL", base, "", nil, http.StatusCreated)
+	destination := func(name string, overwrite bool) map[string]string {
+		return map[string]string{
+			"Destination": "http://example.com" + base + name,
+			"Overwrite":   internal.FormatOverwrite(overwrite),
+		}
+	}
+
+	expectStatus(t, h, http.MethodPut, base+"src", "source", nil, http.StatusCreated)
+	expectStatus(t, h, "COPY", base+"src", "", destination("dest", true), http.StatusCreated)
+	expectStatus(t, h, "COPY", base+"src", "", destination("dest", false), http.StatusPreconditionFailed)
+	expectStatus(t, h, "COPY", base+"src", "", destination("dest", true), http.StatusNoContent)
+	expectStatus(t, h, "COPY", base+"src", "", destination("missing/dest", true), http.StatusConflict)
+	expectStatus(t, h, "COPY", base+"missing", "", destination("dest2", true), http.StatusNotFound)
+
+	expectStatus(t, h, "MKCOL", base+"coll/", "", nil, http.StatusCreated)
+	expectStatus(t, h, http.MethodPut, base+"coll/member", "member", nil, http.StatusCreated)
+	expectStatus(t, h, "COPY", base+"coll/", "", destination("coll2/", true), http.StatusCreated)
+	w := expectStatus(t, h, http.MethodGet, base+"coll2/member", "", nil, http.StatusOK)
+	if got := w.Body.String(); got != "member" {
+		t.Errorf("GET on a copied member: body = %q, want %q", got, "member")
+	}
+	hdr := destination("coll3/", true)
+	hdr["Depth"] = "0"
+	expectStatus(t, h, "COPY", base+"coll/", "", hdr, http.StatusCreated)
+	expectStatus(t, h, http.MethodGet, base+"coll3/member", "", nil, http.StatusNotFound)
+
+	expectStatus(t, h, "MOVE", base+"src", "", destination("moved", true), http.StatusCreated)
+	expectStatus(t, h, http.MethodGet, base+"src", "", nil, http.StatusNotFound)
+	expectStatus(t, h, "MOVE", base+"moved", "", destination("dest", false), http.StatusPreconditionFailed)
+	expectStatus(t, h, "MOVE", base+"moved", "", destination("dest", true), http.StatusNoContent)
+	expectStatus(t, h, "MOVE", base+"dest", "", destination("missing/dest", true), http.StatusConflict)
+
+	expectStatus(t, h, "MOVE", base+"coll/", "", destination("coll4/", true), http.StatusCreated)
+	expectStatus(t, h, http.MethodGet, base+"coll/member", "", nil, http.StatusNotFound)
+	expectStatus(t, h, http.MethodGet, base+"coll4/member", "", nil, http.StatusOK)
+}
+
+var litmusPropName = xml.Name{Space: "http://example.com/litmus", Local: "prop"}
+
+func testHandlerProps(t *testing.T, h http.Handler) {
+	const base = litmusRoot + "props/"
+	expectStatus(t, h, "MKCOL", base, "", nil, http.StatusCreated)
+	expectStatus(t, h, http.MethodPut, base+"res", "props", nil, http.StatusCreated)
+	// Hrefs must be escaped so that clients decode them to the same name
+	expectStatus(t, h, http.MethodPut, base+"sp%20ace%23%3F%25%C3%A9", "props", nil, http.StatusCreated)
+
+	ms := propFind(t, h, base, "1", `<D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`)
+	var hrefs []string
+	for _, resp := range ms.Responses {
+		p, err := resp.Path()
+		if err != nil {
+			t.Errorf("PROPFIND: %v", err)
+			continue
+		}
+		hrefs = append(hrefs, p)
+		// Collection hrefs end with a slash, as per RFC 4918 section 8.3
+		var rt internal.ResourceType
+		if err := resp.DecodeProp(&rt); err != nil {
+			t.Errorf("PROPFIND: resourcetype of %q: %v", p, err)
+		} else if isColl := rt.Is(internal.CollectionName); isColl != strings.HasSuffix(p, "/") {
+			t.Errorf("PROPFIND: resourcetype of %q: collection = %v", p, isColl)
+		}
+	}
+	slices.Sort(hrefs)
+	if want := []string{base, base + "res", base + "sp ace#?%é"}; !slices.Equal(hrefs, want) {
+		t.Errorf("PROPFIND with Depth 1: hrefs = %q, want %q", hrefs, want)
+	}
+
+	ms = propFind(t, h, base+"res", "0", `<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/></D:prop></D:propfind>`)
+	if len(ms.Responses) == 1 {
+		var length internal.GetContentLength
+		if err := ms.Responses[0].DecodeProp(&length); err != nil {
+			t.Errorf("PROPFIND: getcontentlength: %v", err)
+		} else if length.Length != 5 {
+			t.Errorf("PROPFIND: getcontentlength = %v, want 5", length.Length)
+		}
+	}
+
+	expectStatus(t, h, "PROPFIND", base, "<D:propfind", map[string]string{"Content-Type": "application/xml"}, http.StatusBadRequest)
+	expectStatus(t, h, "PROPFIND", base+"missing", "", map[string]string{"Depth": "0"}, http.StatusNotFound)
+
+	w := expectStatus(t, h, "PROPPATCH", base+"res", `<D:propertyupdate xmlns:D="DAV:" xmlns:L="http://example.com/litmus">
+<D:set><D:prop><L:prop>value</L:prop></D:prop></D:set>
+</D:propertyupdate>`, map[string]string{"Content-Type": "application/xml"}, http.StatusMultiStatus)
+	checkPropStatus(t, "PROPPATCH set", decodeMultiStatus(t, w), litmusPropName, http.StatusOK)
+
+	ms = propFind(t, h, base+"res", "0", `<D:propfind xmlns:D="DAV:"><D:prop><L:prop xmlns:L="http://example.com/litmus"/></D:prop></D:propfind>`)
+	checkPropStatus(t, "PROPFIND of a dead property", ms, litmusPropName, http.StatusOK)
+	if len(ms.Responses) == 1 && len(ms.Responses[0].PropStats) > 0 {
+		if raw := ms.Responses[0].PropStats[0].Prop.Get(litmusPropName); raw != nil && raw.GetTextContent() != "value" {
+			t.Errorf("PROPFIND: dead property value = %q, want %q", raw.GetTextContent(), "value")
+		}
+	}
+
+	w = expectStatus(t, h, "PROPPATCH", base+"res", `<D:propertyupdate xmlns:D="DAV:" xmlns:L="http://example.com/litmus">
+<D:remove><D:prop><L:prop/></D:prop></D:remove>
+</D:propertyupdate>`, map[string]string{"Content-Type": "application/xml"}, http.StatusMultiStatus)
+	checkPropStatus(t, "PROPPATCH remove", decodeMultiStatus(t, w), litmusPropName, http.StatusOK)
+
+	ms = propFind(t, h, base+"res", "0", `<D:propfind xmlns:D="DAV:"><D:prop><L:prop xmlns:L="http://example.com/litmus"/></D:prop></D:propfind>`)
+	checkPropStatus(t, "PROPFIND of a removed dead property", ms, litmusPropName, http.StatusNotFound)
+}
+
+const litmusLockInfo = `<D:lockinfo xmlns:D="DAV:">
+<D:lockscope><D:exclusive/></D:lockscope>
+<D:locktype><D:write/></D:locktype>
+</D:lockinfo>`
+
+func testHandlerLocks(t *testing.T, h http.Handler) {
+	const base = litmusRoot + "locks/"
+	expectStatus(t, h, "MKCOL", base, "", nil, http.StatusCreated)
+	expectStatus(t, h, http.MethodPut, base+"res", "locks", nil, http.StatusCreated)
+
+	xmlHeader := map[string]string{"Content-Type": "application/xml", "Depth": "0"}
+	w := expectStatus(t, h, "LOCK", base+"res", litmusLockInfo, xmlHeader, http.StatusOK)
+	token := w.Header().Get("Lock-Token")
+	if token == "" {
+		t.Fatalf("LOCK: no Lock-Token header field")
+	}
+	ifHeader := map[string]string{"If": "(" + token + ")"}
+
+	expectStatus(t, h, "LOCK", base+"res", litmusLockInfo, xmlHeader, http.StatusLocked)
+	expectStatus(t, h, http.MethodPut, base+"res", "unlocked", nil, http.StatusLocked)
+	expectStatus(t, h, http.MethodDelete, base+"res", "", nil, http.StatusLocked)
+	expectStatus(t, h, http.MethodPut, base+"res", "locked", ifHeader, http.StatusOK, http.StatusNoContent)
+
+	ms := propFind(t, h, base+"res", "0", `<D:propfind xmlns:D="DAV:"><D:prop><D:lockdiscovery/></D:prop></D:propfind>`)
+	checkPropStatus(t, "PROPFIND of lockdiscovery", ms, internal.LockDiscoveryName, http.StatusOK)
+
+	refresh := map[string]string{"If": "(" + token + ")", "Timeout": "Second-3600"}
+	expectStatus(t, h, "LOCK", base+"res", "", refresh, http.StatusOK)
+
+	expectStatus(t, h, "UNLOCK", base+"res", "", map[string]string{"Lock-Token": token}, http.StatusNoContent)
+	expectStatus(t, h, http.MethodPut, base+"res", "unlocked", nil, http.StatusOK, http.StatusNoContent)
+
+	expectStatus(t, h, "LOCK", base+"missing/res", litmusLockInfo, xmlHeader, http.StatusConflict)
+	w = expectStatus(t, h, "LOCK", base+"unmapped", litmusLockInfo, xmlHeader, http.StatusCreated)
+	expectStatus(t, h, "UNLOCK", base+"unmapped", "", map[string]string{"Lock-Token": w.Header().Get("Lock-Token")}, http.StatusNoContent)
+
+	expectStatus(t, h, "MKCOL", base+"coll/", "", nil, http.StatusCreated)
+	xmlHeader["Depth"] = "infinity"
+	w = expectStatus(t, h, "LOCK", base+"coll/", litmusLockInfo, xmlHeader, http.StatusOK)
+	token = w.Header().Get("Lock-Token")
+	expectStatus(t, h, http.MethodPut, base+"coll/member", "member", nil, http.StatusLocked)
+	expectStatus(t, h, http.MethodPut, base+"coll/member", "member", map[string]string{"If": "(" + token + ")"}, http.StatusCreated)
+	expectStatus(t, h, "UNLOCK", base+"coll/", "", map[string]string{"Lock-Token": token}, http.StatusNoContent)
+}
+
+// do sends a request to h and returns the recorded response.
+func do(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body != "" {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// expectStatus sends a request to h and checks that the response has one of
+// the status codes.
+func expectStatus(t *testing.T, h http.Handler, method, target, body string, header map[string]string, codes ...int) *httptest.ResponseRecorder {
+	t.Helper()
+	w := do(h, method, target, body, header)
+	if !slices.Contains(codes, w.Code) {
+		t.Errorf("%v %v: status %v, want %v\n%s", method, target, w.Code, codes, w.Body)
+	}
+	return w
+}
+
+func propFind(t *testing.T, h http.Handler, target, depth, body string) *internal.MultiStatus {
+	t.Helper()
+	header := map[string]string{"Content-Type": "application/xml", "Depth": depth}
+	w := expectStatus(t, h, "PROPFIND", target, body, header, http.StatusMultiStatus)
+	return decodeMultiStatus(t, w)
+}
+
+func decodeMultiStatus(t *testing.T, w *httptest.ResponseRecorder) *internal.MultiStatus {
+	t.Helper()
+	var ms internal.MultiStatus
+	if w.Code != http.StatusMultiStatus {
+		return &ms
+	}
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Errorf("malformed multistatus: %v", err)
+	}
+	return &ms
+}
+
+// checkPropStatus checks that the single response of ms reports the status
+// code for the property name.
+func checkPropStatus(t *testing.T, op string, ms *internal.MultiStatus, name xml.Name, code int) {
+	t.Helper()
+	if len(ms.Responses) != 1 {
+		t.Errorf("%v: got %v responses, want 1", op, len(ms.Responses))
+		return
+	}
+	for _, propstat := range ms.Responses[0].PropStats {
+		if propstat.Prop.Get(name) != nil {
+			if propstat.Status.Code != code {
+				t.Errorf("%v: property status %v, want %v", op, propstat.Status.Code, code)
+			}
+			return
+		}
+	}
+	t.Errorf("%v: property missing from the response", op)
+}
+
+// headerList splits a comma-separated header field value.
+func headerList(v string) []string {
+	var l []string
+	for _, s := range strings.Split(v, ",") {
+		l = append(l, strings.TrimSpace(s))
+	}
+	return l
+}
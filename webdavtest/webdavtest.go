@@ -0,0 +1,280 @@
+// Package webdavtest provides conformance tests for WebDAV FileSystems and
+// handlers, modelled after the litmus test suite. Authors of custom backends
+// can run them in their own tests:
+//
+//	func TestFileSystem(t *testing.T) {
+//		webdavtest.TestFileSystem(t, newMyFileSystem(t))
+//	}
+package webdavtest
+
+import (
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+// TestFileSystem checks that fs behaves as expected by the WebDAV handler,
+// following the FileSystem error contract. fs must be empty, it's left empty
+// once the tests pass.
+func TestFileSystem(t *testing.T, fs webdav.FileSystem) {
+	t.Run("basic", func(t *testing.T) {
+		testFileSystemBasic(t, fs)
+	})
+	t.Run("conditional", func(t *testing.T) {
+		testFileSystemConditional(t, fs)
+	})
+	t.Run("copymove", func(t *testing.T) {
+		testFileSystemCopyMove(t, fs)
+	})
+}
+
+func testFileSystemBasic(t *testing.T, fs webdav.FileSystem) {
+	ctx := t.Context()
+
+	fi, err := fs.Stat(ctx, "/")
+	if err != nil {
+		t.Fatalf("Stat(/) = %v", err)
+	} else if !fi.IsDir {
+		t.Fatalf("Stat(/): root isn't a collection")
+	}
+
+	mkdir(t, fs, "/basic")
+	defer removeAll(t, fs, "/basic")
+	checkStatus(t, "Mkdir on an existing collection", fs.Mkdir(ctx, "/basic"), 405)
+	checkStatus(t, "Mkdir with a missing parent", fs.Mkdir(ctx, "/basic/missing/coll"), 404, 409)
+
+	fi, created, err := fs.Create(ctx, "/basic/file", body("hello"), &webdav.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() = %v", err)
+	} else if !created {
+		t.Errorf("Create(): created = false for a new file")
+	}
+	if fi == nil {
+		t.Fatalf("Create(): no FileInfo returned")
+	} else if path.Clean(fi.Path) != "/basic/file" || fi.IsDir || fi.Size != 5 {
+		t.Errorf("Create() = %+v, want a 5 bytes file at /basic/file", fi)
+	}
+	checkContent(t, fs, "/basic/file", "hello")
+
+	_, created, err = fs.Create(ctx, "/basic/file", body("hello, world"), &webdav.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() to overwrite = %v", err)
+	} else if created {
+		t.Errorf("Create(): created = true for an existing file")
+	}
+	checkContent(t, fs, "/basic/file", "hello, world")
+	if fi, err := fs.Stat(ctx, "/basic/file"); err != nil {
+		t.Errorf("Stat() = %v", err)
+	} else if fi.IsDir || fi.Size != 12 {
+		t.Errorf("Stat() = %+v, want a 12 bytes file", fi)
+	}
+
+	_, _, err = fs.Create(ctx, "/basic/missing/file", body("hello"), &webdav.CreateOptions{})
+	checkStatus(t, "Create with a missing parent", err, 409)
+	_, _, err = fs.Create(ctx, "/basic/file/child", body("hello"), &webdav.CreateOptions{})
+	checkStatus(t, "Create below a file", err, 409)
+	_, err = fs.Stat(ctx, "/basic/file/child")
+	checkStatus(t, "Stat below a file", err, 404)
+	_, err = fs.Stat(ctx, "/basic/missing")
+	checkStatus(t, "Stat on a missing resource", err, 404)
+
+	mkdir(t, fs, "/basic/sub")
+	create(t, fs, "/basic/sub/nested", "")
+	checkReadDir(t, fs, "/basic", false, "/basic", "/basic/file", "/basic/sub")
+	checkReadDir(t, fs, "/basic", true, "/basic", "/basic/file", "/basic/sub", "/basic/sub/nested")
+
+	if err := fs.RemoveAll(ctx, "/basic/file", &webdav.RemoveAllOptions{}); err != nil {
+		t.Errorf("RemoveAll() = %v", err)
+	}
+	_, err = fs.Stat(ctx, "/basic/file")
+	checkStatus(t, "Stat on a removed file", err, 404)
+	checkStatus(t, "RemoveAll on a missing resource", fs.RemoveAll(ctx, "/basic/file", &webdav.RemoveAllOptions{}), 404)
+
+	if err := fs.RemoveAll(ctx, "/basic/sub", &webdav.RemoveAllOptions{}); err != nil {
+		t.Errorf("RemoveAll() on a collection = %v", err)
+	}
+	_, err = fs.Stat(ctx, "/basic/sub/nested")
+	checkStatus(t, "Stat on a member of a removed collection", err, 404)
+}
+
+func testFileSystemConditional(t *testing.T, fs webdav.FileSystem) {
+	ctx := t.Context()
+
+	mkdir(t, fs, "/conditional")
+	defer removeAll(t, fs, "/conditional")
+
+	fi := create(t, fs, "/conditional/file", "hello")
+	_, _, err := fs.Create(ctx, "/conditional/file", body("world"), &webdav.CreateOptions{IfNoneMatch: "*"})
+	checkStatus(t, "Create with If-None-Match: * on an existing file", err, 412)
+	_, _, err = fs.Create(ctx, "/conditional/new", body("world"), &webdav.CreateOptions{IfMatch: "*"})
+	checkStatus(t, "Create with If-Match: * on a missing file", err, 412)
+	_, _, err = fs.Create(ctx, "/conditional/file", body("world"), &webdav.CreateOptions{IfMatch: `"mismatch"`})
+	checkStatus(t, "Create with a mismatching If-Match", err, 412)
+	err = fs.RemoveAll(ctx, "/conditional/file", &webdav.RemoveAllOptions{IfMatch: `"mismatch"`})
+	checkStatus(t, "RemoveAll with a mismatching If-Match", err, 412)
+	checkContent(t, fs, "/conditional/file", "hello")
+
+	if fi.ETag == "" {
+		return
+	}
+	if fi.WeakETag {
+		_, _, err = fs.Create(ctx, "/conditional/file", body("world"), &webdav.CreateOptions{IfMatch: webdav.ConditionalMatch("W/" + strconv.Quote(fi.ETag))})
+		checkStatus(t, "Create with If-Match on a weak ETag", err, 412)
+		return
+	}
+	etag := webdav.ConditionalMatch(strconv.Quote(fi.ETag))
+	if _, _, err := fs.Create(ctx, "/conditional/file", body("world"), &webdav.CreateOptions{IfMatch: etag}); err != nil {
+		t.Errorf("Create() with a matching If-Match = %v", err)
+	}
+	err = fs.RemoveAll(ctx, "/conditional/file", &webdav.RemoveAllOptions{IfMatch: etag})
+	checkStatus(t, "RemoveAll with an outdated If-Match", err, 412)
+}
+
+func testFileSystemCopyMove(t *testing.T, fs webdav.FileSystem) {
+	ctx := t.Context()
+
+	mkdir(t, fs, "/copymove")
+	defer removeAll(t, fs, "/copymove")
+
+	create(t, fs, "/copymove/src", "source")
+	if created, err := fs.Copy(ctx, "/copymove/src", "/copymove/dst", &webdav.CopyOptions{}); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	} else if !created {
+		t.Errorf("Copy(): created = false for a new destination")
+	}
+	checkContent(t, fs, "/copymove/src", "source")
+	checkContent(t, fs, "/copymove/dst", "source")
+
+	create(t, fs, "/copymove/src", "changed")
+	_, err := fs.Copy(ctx, "/copymove/src", "/copymove/dst", &webdav.CopyOptions{NoOverwrite: true})
+	checkStatus(t, "Copy without overwrite onto an existing resource", err, 412)
+	checkContent(t, fs, "/copymove/dst", "source")
+	if created, err := fs.Copy(ctx, "/copymove/src", "/copymove/dst", &webdav.CopyOptions{}); err != nil {
+		t.Errorf("Copy() to overwrite = %v", err)
+	} else if created {
+		t.Errorf("Copy(): created = true for an existing destination")
+	}
+	checkContent(t, fs, "/copymove/dst", "changed")
+
+	_, err = fs.Copy(ctx, "/copymove/missing", "/copymove/dst2", &webdav.CopyOptions{})
+	checkStatus(t, "Copy of a missing resource", err, 404)
+	_, err = fs.Copy(ctx, "/copymove/src", "/copymove/missing/dst", &webdav.CopyOptions{})
+	checkStatus(t, "Copy with a missing destination parent", err, 409)
+
+	mkdir(t, fs, "/copymove/coll")
+	create(t, fs, "/copymove/coll/a", "a")
+	mkdir(t, fs, "/copymove/coll/sub")
+	create(t, fs, "/copymove/coll/sub/b", "b")
+	if _, err := fs.Copy(ctx, "/copymove/coll", "/copymove/coll2", &webdav.CopyOptions{}); err != nil {
+		t.Fatalf("Copy() of a collection = %v", err)
+	}
+	checkReadDir(t, fs, "/copymove/coll2", true, "/copymove/coll2", "/copymove/coll2/a", "/copymove/coll2/sub", "/copymove/coll2/sub/b")
+	checkContent(t, fs, "/copymove/coll2/sub/b", "b")
+	if _, err := fs.Copy(ctx, "/copymove/coll", "/copymove/coll3", &webdav.CopyOptions{NoRecursive: true}); err != nil {
+		t.Fatalf("Copy() of a collection without its members = %v", err)
+	}
+	checkReadDir(t, fs, "/copymove/coll3", true, "/copymove/coll3")
+
+	if created, err := fs.Move(ctx, "/copymove/src", "/copymove/moved", &webdav.MoveOptions{}); err != nil {
+		t.Fatalf("Move() = %v", err)
+	} else if !created {
+		t.Errorf("Move(): created = false for a new destination")
+	}
+	_, err = fs.Stat(ctx, "/copymove/src")
+	checkStatus(t, "Stat on a moved resource", err, 404)
+	checkContent(t, fs, "/copymove/moved", "changed")
+
+	_, err = fs.Move(ctx, "/copymove/moved", "/copymove/dst", &webdav.MoveOptions{NoOverwrite: true})
+	checkStatus(t, "Move without overwrite onto an existing resource", err, 412)
+	if created, err := fs.Move(ctx, "/copymove/moved", "/copymove/dst", &webdav.MoveOptions{}); err != nil {
+		t.Errorf("Move() to overwrite = %v", err)
+	} else if created {
+		t.Errorf("Move(): created = true for an existing destination")
+	}
+	checkContent(t, fs, "/copymove/dst", "changed")
+
+	_, err = fs.Move(ctx, "/copymove/dst", "/copymove/missing/dst", &webdav.MoveOptions{})
+	checkStatus(t, "Move with a missing destination parent", err, 409)
+
+	if _, err := fs.Move(ctx, "/copymove/coll", "/copymove/coll4", &webdav.MoveOptions{}); err != nil {
+		t.Fatalf("Move() of a collection = %v", err)
+	}
+	checkReadDir(t, fs, "/copymove/coll4", true, "/copymove/coll4", "/copymove/coll4/a", "/copymove/coll4/sub", "/copymove/coll4/sub/b")
+	_, err = fs.Stat(ctx, "/copymove/coll/sub/b")
+	checkStatus(t, "Stat on a member of a moved collection", err, 404)
+}
+
+func body(s string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(s))
+}
+
+func mkdir(t *testing.T, fs webdav.FileSystem, name string) {
+	t.Helper()
+	if err := fs.Mkdir(t.Context(), name); err != nil {
+		t.Fatalf("Mkdir(%q) = %v", name, err)
+	}
+}
+
+func create(t *testing.T, fs webdav.FileSystem, name, content string) *webdav.FileInfo {
+	t.Helper()
+	fi, _, err := fs.Create(t.Context(), name, body(content), &webdav.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create(%q) = %v", name, err)
+	}
+	return fi
+}
+
+func removeAll(t *testing.T, fs webdav.FileSystem, name string) {
+	t.Helper()
+	if err := fs.RemoveAll(t.Context(), name, &webdav.RemoveAllOptions{}); err != nil {
+		t.Errorf("RemoveAll(%q) = %v", name, err)
+	}
+}
+
+func checkContent(t *testing.T, fs webdav.FileSystem, name, want string) {
+	t.Helper()
+	rc, err := fs.Open(t.Context(), name)
+	if err != nil {
+		t.Errorf("Open(%q) = %v", name, err)
+		return
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Errorf("reading %q: %v", name, err)
+	} else if string(b) != want {
+		t.Errorf("content of %q = %q, want %q", name, b, want)
+	}
+}
+
+func checkReadDir(t *testing.T, fs webdav.FileSystem, name string, recursive bool, want ...string) {
+	t.Helper()
+	children, err := fs.ReadDir(t.Context(), name, recursive)
+	if err != nil {
+		t.Errorf("ReadDir(%q, %v) = %v", name, recursive, err)
+		return
+	}
+	var got []string
+	for _, fi := range children {
+		got = append(got, path.Clean(fi.Path))
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadDir(%q, %v) = %q, want %q", name, recursive, got, want)
+	}
+}
+
+// checkStatus checks that err carries one of the HTTP status codes.
+func checkStatus(t *testing.T, op string, err error, codes ...int) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("%v: succeeded, want status %v", op, codes)
+	} else if code := webdav.HTTPStatus(err); !slices.Contains(codes, code) {
+		t.Errorf("%v: status %v (%v), want %v", op, code, err, codes)
+	}
+}
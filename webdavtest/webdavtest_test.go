@@ -0,0 +1,27 @@
+package webdavtest
+
+import (
+	"testing"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestLocalFileSystem(t *testing.T) {
+	TestFileSystem(t, webdav.LocalFileSystem(t.TempDir()))
+}
+
+func TestRootFileSystem(t *testing.T) {
+	fs, err := webdav.NewRootFileSystem(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	TestFileSystem(t, fs)
+}
+
+func TestHandler(t *testing.T) {
+	TestHandlerCompliance(t, &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(t.TempDir()),
+		LockSystem: webdav.NewLockSystem(),
+	})
+}
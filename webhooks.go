@@ -0,0 +1,233 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookSignatureHeader is the header field carrying the HMAC-SHA256
+// signature of webhook payloads, as "sha256=" followed by the hex-encoded
+// MAC of the request body.
+const WebhookSignatureHeader = "X-Webdav-Signature-256"
+
+// SignWebhook returns the value of the WebhookSignatureHeader field for a
+// payload.
+func SignWebhook(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook reports whether signature, the value of the
+// WebhookSignatureHeader field of a webhook, is valid for payload. Receivers
+// use it to authenticate webhooks.
+func VerifyWebhook(secret, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, payload)), []byte(signature))
+}
+
+// Webhooks posts the changes made through a handler to URLs, as ChangeEvents
+// encoded in JSON, so that external systems can react to uploads, removals
+// and moves. Webhooks are queued and sent in the background, in order for
+// each URL; failed deliveries are retried with exponential backoff.
+//
+// Set it as Handler.Webhooks. Handler.Shutdown waits for pending deliveries,
+// then closes the Webhooks.
+type Webhooks struct {
+	// URLs receive the webhooks as POST requests.
+	URLs []string
+	// Secret, if set, signs payloads, see WebhookSignatureHeader.
+	Secret []byte
+	// Client sends the webhooks, defaults to a client with a 10 seconds
+	// timeout.
+	Client *http.Client
+	// MaxAttempts is the maximum number of attempts of a delivery, defaults
+	// to 5. Deliveries are retried on network errors, and on 408, 429 and
+	// 5xx statuses.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry, defaults to one
+	// second. It doubles with each retry.
+	RetryDelay time.Duration
+	// QueueSize is the number of events waiting for delivery to each URL
+	// beyond which new events are dropped, defaults to 1000.
+	QueueSize int
+	// Logger, if set, logs failed and dropped deliveries.
+	Logger *slog.Logger
+
+	once      sync.Once
+	endpoints []*webhookEndpoint
+	ctx       context.Context // canceled by Close
+	cancel    context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ Flusher = (*Webhooks)(nil)
+
+// webhookEndpoint delivers the events queued for a URL.
+type webhookEndpoint struct {
+	url     string
+	queue   chan *ChangeEvent
+	pending sync.WaitGroup
+}
+
+func (wh *Webhooks) init() {
+	wh.once.Do(func() {
+		size := wh.QueueSize
+		if size <= 0 {
+			size = 1000
+		}
+		wh.ctx, wh.cancel = context.WithCancel(context.Background())
+		for _, u := range wh.URLs {
+			e := &webhookEndpoint{url: u, queue: make(chan *ChangeEvent, size)}
+			wh.endpoints = append(wh.endpoints, e)
+			go wh.deliverAll(e)
+		}
+	})
+}
+
+// Send queues an event for delivery to each URL. If the ID or time of the
+// event are missing, they're filled in. Events sent after Close are dropped.
+func (wh *Webhooks) Send(event *ChangeEvent) {
+	wh.init()
+	if event.ID == "" {
+		event.ID = newRequestID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	if wh.closed {
+		return
+	}
+	for _, e := range wh.endpoints {
+		e.pending.Add(1)
+		select {
+		case e.queue <- event:
+		default:
+			e.pending.Done()
+			if wh.Logger != nil {
+				wh.Logger.Warn("webdav: webhook queue full, event dropped", "url", e.url, "id", event.ID, "type", event.Type, "path", event.Path)
+			}
+		}
+	}
+}
+
+// Flush implements Flusher by waiting for the queued events to be delivered,
+// or to fail, until ctx is done.
+func (wh *Webhooks) Flush(ctx context.Context) error {
+	wh.init()
+	done := make(chan struct{})
+	go func() {
+		for _, e := range wh.endpoints {
+			e.pending.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close waits for the queued events to be delivered, or to fail, until ctx
+// is done, then stops delivering webhooks. Deliveries still in progress are
+// canceled and the remaining events dropped.
+func (wh *Webhooks) Close(ctx context.Context) error {
+	err := wh.Flush(ctx)
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	if !wh.closed {
+		wh.closed = true
+		wh.cancel()
+		for _, e := range wh.endpoints {
+			close(e.queue)
+		}
+	}
+	return err
+}
+
+func (wh *Webhooks) deliverAll(e *webhookEndpoint) {
+	for event := range e.queue {
+		if wh.ctx.Err() != nil {
+			// Closed, drop the remaining events
+			e.pending.Done()
+			continue
+		}
+		if err := wh.deliver(e.url, event); err != nil && wh.Logger != nil {
+			wh.Logger.Warn("webdav: webhook delivery failed", "url", e.url, "id", event.ID, "type", event.Type, "path", event.Path, "error", err)
+		}
+		e.pending.Done()
+	}
+}
+
+// deliver posts an event to a URL, retrying transient failures.
+func (wh *Webhooks) deliver(url string, event *ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	client := wh.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	attempts := wh.MaxAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	delay := wh.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := wh.post(client, url, event, payload)
+		if err == nil || !retry || attempt >= attempts {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-wh.ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// post sends a webhook once, and reports whether a failure is transient.
+func (wh *Webhooks) post(client *http.Client, url string, event *ChangeEvent, payload []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(wh.ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webdav-Event", string(event.Type))
+	req.Header.Set("X-Webdav-Delivery", event.ID)
+	if len(wh.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(wh.Secret, payload))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webdav: webhook endpoint replied %v", resp.Status)
+}
@@ -0,0 +1,108 @@
+package webdav_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tryanks/fiber-webdav"
+)
+
+func TestWebhooks(t *testing.T) {
+	secret := []byte("secret")
+	var (
+		mu       sync.Mutex
+		events   []webdav.ChangeEvent
+		attempts atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails once, and is retried
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payload, _ := io.ReadAll(r.Body)
+		if !webdav.VerifyWebhook(secret, payload, r.Header.Get(webdav.WebhookSignatureHeader)) {
+			t.Errorf("invalid signature %q", r.Header.Get(webdav.WebhookSignatureHeader))
+		}
+		var event webdav.ChangeEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	h := &webdav.Handler{
+		FileSystem: webdav.LocalFileSystem(dir),
+		Webhooks:   &webdav.Webhooks{URLs: []string{srv.URL}, Secret: secret, RetryDelay: time.Millisecond},
+	}
+	checkStatus(t, h, testRequest{method: http.MethodPut, target: "/b.txt", body: "b"}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: "MOVE", target: "/b.txt", header: map[string]string{"Destination": "/c.txt"}}, http.StatusCreated)
+	checkStatus(t, h, testRequest{method: http.MethodDelete, target: "/a.txt"}, http.StatusNoContent)
+	if err := h.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []webdav.ChangeEvent{
+		{Type: webdav.ChangeCreate, Path: "/b.txt"},
+		{Type: webdav.ChangeMove, Path: "/b.txt", Destination: "/c.txt"},
+		{Type: webdav.ChangeDelete, Path: "/a.txt"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("received %v webhooks, want %v", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.Type != want[i].Type || event.Path != want[i].Path || event.Destination != want[i].Destination {
+			t.Errorf("webhook %v = %v %v %v, want %v %v %v", i, event.Type, event.Path, event.Destination, want[i].Type, want[i].Path, want[i].Destination)
+		}
+	}
+}
+
+func TestWebhooksClose(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	wh := &webdav.Webhooks{URLs: []string{srv.URL}, RetryDelay: time.Hour}
+	wh.Send(&webdav.ChangeEvent{Type: webdav.ChangeCreate, Path: "/a.txt"})
+	for received.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Close gives up on the retried delivery once ctx is done
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := wh.Close(ctx); err == nil {
+		t.Errorf("Close() = nil, want an error")
+	}
+	if err := wh.Flush(t.Context()); err != nil {
+		t.Errorf("Flush() after Close() = %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close() and Flush() took %v", d)
+	}
+
+	// Events sent afterwards are dropped
+	wh.Send(&webdav.ChangeEvent{Type: webdav.ChangeCreate, Path: "/b.txt"})
+	if err := wh.Flush(t.Context()); err != nil {
+		t.Errorf("Flush() = %v", err)
+	}
+	if n := received.Load(); n != 1 {
+		t.Errorf("received %v webhooks, want 1", n)
+	}
+}